@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/ryankurte/yawns/lib/batch"
+)
+
+// Options defines the command line options for batch runs
+type Options struct {
+	SweepFile  string `short:"s" long:"sweep" description:"Sweep specification file" required:"true"`
+	ConfigFile string `short:"c" long:"config" description:"Base simulation configuration file (overrides the sweep config)"`
+	OutputDir  string `short:"o" long:"output" description:"Output directory for runs and results" default:"batch"`
+	Simulator  string `long:"sim" description:"Simulator executable" default:"yawns-sim"`
+	Parallel   int    `short:"j" long:"parallel" description:"Number of simulations to run in parallel (defaults to the number of CPUs)"`
+	Port       int    `short:"p" long:"port" description:"Base port for simulation connectors, each run uses a unique port" default:"10200"`
+	Scheme     string `long:"scheme" description:"Simulation connector scheme, stream+tcp (native) or tcp (ZMQ, requires a simulator built with the zmq tag)" default:"stream+tcp"`
+	DryRun     bool   `long:"dry-run" description:"Print the run matrix without running simulations"`
+}
+
+func main() {
+	o := Options{}
+	_, err := flags.Parse(&o)
+	if err != nil {
+		os.Exit(-1)
+	}
+
+	sweep, err := batch.LoadSweepFile(o.SweepFile)
+	if err != nil {
+		log.Fatalf("Error loading sweep: %s", err)
+	}
+	if o.ConfigFile != "" {
+		sweep.Config = o.ConfigFile
+	}
+	if sweep.Config == "" {
+		log.Fatalf("No base configuration file in sweep or options")
+	}
+
+	if o.DryRun {
+		for _, r := range sweep.Matrix() {
+			fmt.Printf("%s seed: %d %+v\n", r.Name(), r.Seed, r.Values)
+		}
+		return
+	}
+
+	// Simulator paths are resolved before runs change directory
+	if strings.ContainsRune(o.Simulator, filepath.Separator) {
+		if o.Simulator, err = filepath.Abs(o.Simulator); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if o.Parallel == 0 {
+		o.Parallel = runtime.NumCPU()
+	}
+
+	b := batch.Batch{
+		Sweep:     sweep,
+		Simulator: o.Simulator,
+		OutputDir: o.OutputDir,
+		Parallel:  o.Parallel,
+		Port:      o.Port,
+		Scheme:    o.Scheme,
+	}
+
+	results, err := b.Run()
+	if err != nil {
+		log.Fatalf("Batch error: %s", err)
+	}
+
+	if err := b.WriteResults(results); err != nil {
+		log.Fatalf("Error writing results: %s", err)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	log.Printf("[INFO] Batch complete: %d runs, %d failed, results in %s", len(results), failed, o.OutputDir)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/ryankurte/yawns/lib/bridge"
+	"github.com/ryankurte/yawns/lib/client"
+	"github.com/ryankurte/yawns/lib/connector"
+)
+
+// Options defines the command line options for the bridge
+type Options struct {
+	Server  string `short:"s" long:"server" description:"Simulator address"`
+	Address string `short:"a" long:"address" description:"Node address to register with the simulator" required:"yes"`
+	Band    string `short:"b" long:"band" description:"Simulation band" required:"yes"`
+	Radio   uint32 `long:"radio" description:"Radio ID for nodes with multiple radios on the band" default:"0"`
+	Channel int32  `short:"c" long:"channel" description:"Radio channel" default:"0"`
+
+	Device  string `short:"d" long:"device" description:"Network interface name (assigned by the kernel if unset)"`
+	TAP     bool   `long:"tap" description:"Create a TAP (ethernet) interface rather than a TUN (IP) interface"`
+	Framing string `short:"f" long:"framing" description:"Radio framing (lowpan for 802.15.4/6LoWPAN, raw for unframed payloads)" choice:"lowpan" choice:"raw" default:"lowpan"`
+	PAN     uint16 `long:"pan" description:"802.15.4 PAN ID" default:"43981"`
+	MAC     string `long:"mac" description:"802.15.4 extended address (hex, derived from the node address if unset)"`
+}
+
+// macAddress derives an 802.15.4 extended address from a node address
+func macAddress(address string) uint64 {
+	if a, err := strconv.ParseUint(address, 0, 64); err == nil {
+		return a
+	}
+	h := fnv.New64a()
+	h.Write([]byte(address))
+	return h.Sum64()
+}
+
+func main() {
+	o := Options{Server: connector.DefaultClientAddress}
+
+	_, err := flags.Parse(&o)
+	if err != nil {
+		os.Exit(0)
+	}
+
+	if o.TAP && o.Framing == bridge.FramingLowpan {
+		log.Fatal("6LoWPAN framing requires a TUN interface")
+	}
+
+	mac := macAddress(o.Address)
+	if o.MAC != "" {
+		if mac, err = strconv.ParseUint(o.MAC, 16, 64); err != nil {
+			log.Fatalf("Invalid MAC address '%s' (%s)", o.MAC, err)
+		}
+	}
+
+	framer, err := bridge.NewFramer(o.Framing, o.PAN, mac)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	// Register with the simulator
+	c, err := client.NewClient(ctx, o.Server, o.Address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	registration, err := c.Registration(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, ok := registration.Band(o.Band); !ok {
+		log.Fatalf("Band %s not found in simulation", o.Band)
+	}
+
+	radio, err := c.Radio(o.Band, o.Radio)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create network interface
+	device, err := bridge.OpenDevice(o.Device, o.TAP)
+	if err != nil {
+		log.Fatalf("Error creating network interface (%s)", err)
+	}
+
+	log.Printf("Bridging interface %s to node %s (band: %s channel: %d framing: %s)",
+		device.Name, o.Address, o.Band, o.Channel, o.Framing)
+
+	b := bridge.NewBridge(radio, device, framer, o.Channel)
+	if err := b.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
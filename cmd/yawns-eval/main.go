@@ -19,14 +19,15 @@ import (
 )
 
 type Options struct {
-	ConfigFile   string   `short:"c" long:"config" description:"Simulation configuration file" default:"yawns.yml"`
-	Band         string   `short:"b" long:"band" description:"Medium band for evaluation"`
-	Nodes        []string `short:"n" long:"node" description:"Nodes to be filtered from configuration"`
-	OutputDir    string   `short:"o" long:"output" description:"Output directory" default:"outputs"`
-	LinkInfo     string   `long:"link-info" description:"Real link information file for analysis"`
-	RealOnly     bool     `long:"real-only" description:"Render real links only"`
-	SimOnly      bool     `long:"simulated-only" description:"Render simulated links only"`
-	TerrainFiles bool     `long:"terrain" description:"Render terrain slices"`
+	ConfigFile   string            `short:"c" long:"config" description:"Simulation configuration file" default:"yawns.yml"`
+	Variables    map[string]string `long:"var" description:"Set a configuration variable (name:value)"`
+	Band         string            `short:"b" long:"band" description:"Medium band for evaluation"`
+	Nodes        []string          `short:"n" long:"node" description:"Nodes to be filtered from configuration"`
+	OutputDir    string            `short:"o" long:"output" description:"Output directory" default:"outputs"`
+	LinkInfo     string            `long:"link-info" description:"Real link information file for analysis"`
+	RealOnly     bool              `long:"real-only" description:"Render real links only"`
+	SimOnly      bool              `long:"simulated-only" description:"Render simulated links only"`
+	TerrainFiles bool              `long:"terrain" description:"Render terrain slices"`
 }
 
 type LinkInfo struct {
@@ -79,7 +80,7 @@ func main() {
 
 	os.Mkdir(o.OutputDir, 0766)
 
-	c, err := config.LoadConfigFile(o.ConfigFile)
+	c, err := config.LoadConfigFileWithVariables(o.ConfigFile, o.Variables)
 	if err != nil {
 		fmt.Printf("Error parsing config file: %s", err)
 		os.Exit(-1)
@@ -148,6 +149,7 @@ func main() {
 	}
 
 	ml, _ := m.GetLayer("terrain")
+	lcl, _ := m.GetLayer("landcover")
 
 	simLinks := make(types.Links, 0)
 
@@ -193,7 +195,7 @@ func main() {
 		errors := make([]float64, 0)
 
 		fmt.Printf("\nReal world comparison:\n")
-		fmt.Printf("A,     B,     Real,    Free Space, Terrain, Foliage, Simulated, Error\n")
+		fmt.Printf("A,     B,     Real,    Free Space, Terrain, Foliage, Land Cover, Dominant Cover, Simulated, Error\n")
 		for _, l := range simLinks {
 			n1, n2 := nodes[l.A], nodes[l.B]
 
@@ -204,7 +206,13 @@ func main() {
 				simulated = append(simulated, l.Fading)
 				errors = append(errors, r.Fading-float64(l.Fading))
 				meta := l.Meta.(types.AttenuationMap)
-				fmt.Printf("%s, %s, %.2f, %.2f, %.2f, %.2f, %.2f, %.2f\n", n1.Address, n2.Address, r.Fading, meta["free-space"], meta["terrain"], meta["foliage"], l.Fading, r.Fading-float64(l.Fading))
+				dominant := ""
+				if lcl != nil {
+					landCover := lcl.(*layers.LandCoverLayer)
+					dominant = dominantLandCover(meta, landCover.ClassDistances(n1.Location, n2.Location))
+				}
+				fmt.Printf("%s, %s, %.2f, %.2f, %.2f, %.2f, %.2f, %s, %.2f, %.2f\n", n1.Address, n2.Address, r.Fading,
+					meta["free-space"], meta["terrain"], meta.Sum("foliage"), meta.Sum("landcover"), dominant, l.Fading, r.Fading-float64(l.Fading))
 			}
 		}
 
@@ -279,3 +287,18 @@ func RenderLinks(r *layers.RenderLayer, name string, nodes types.Nodes, links []
 	render = render.Nodes(nodes, color.RGBA{255, 0, 0, 128}, 16)
 	return render.Finish(name)
 }
+
+// dominantLandCover finds the land cover class contributing the most loss to a link (or the longest distance where no loss is applied)
+func dominantLandCover(meta types.AttenuationMap, distances map[string]float64) string {
+	dominant, loss, distance := "", types.Attenuation(-1), 0.0
+	for class, d := range distances {
+		l := meta["landcover/"+class]
+		if l > loss || (l == loss && d > distance) {
+			dominant, loss, distance = class, l, d
+		}
+	}
+	if dominant == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%s (%.0fm %.2fdB)", dominant, distance, loss)
+}
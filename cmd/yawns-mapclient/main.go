@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"image/color"
 	"os"
 	"strings"
 
@@ -11,25 +12,43 @@ import (
 	"github.com/ryankurte/go-mapbox/lib/maps"
 
 	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/helpers"
+	"github.com/ryankurte/yawns/lib/tiles"
 	"github.com/ryankurte/yawns/lib/types"
 )
 
 type Options struct {
-	Config    flags.Filename `short:"c" long:"config" description:"ONS Configuration file (used to automatically parse some options)" `
-	APIKey    string         `short:"a" long:"api-key" description:"Mapbox API key" env:"MAPBOX_TOKEN"`
-	Type      string         `short:"t" long:"map-type" description:"Map download type" default:"satellite"`
-	Level     int            `short:"l" long:"level" description:"Map level" default:"16" required:"yes"`
-	Output    string         `short:"o" long:"output-dir" description:"Output directory" default:"/tmp/yawns/"`
-	Cache     string         `short:"d" long:"cache-dir" description:"Cache directory" default:"/tmp/yawns/"`
-	Update    bool           `short:"u" long:"update" description:"Automatically updates the provided configuration file with new map references"`
-	NoHighDPI bool           `long:"no-high-dpi" description:"Uses standard (not high DPI) tiles"`
-	Flatten   bool           `long:"flatten-terrain" description:"Flattens terrain images to greyscale for human parsing"`
+	Config           flags.Filename `short:"c" long:"config" description:"ONS Configuration file (used to automatically parse some options)" `
+	APIKey           string         `short:"a" long:"api-key" description:"Mapbox API key (required where no tile source is specified)" env:"MAPBOX_TOKEN"`
+	Sources          []string       `short:"s" long:"source" description:"Tile source (MBTiles file, XYZ tile directory or URL template with {x} {y} {z} and {type}), prefixed with type= for a single map type (ie. terrain=dem.mbtiles), may be repeated, Mapbox API used if unset"`
+	TMS              bool           `long:"tms" description:"Tile source uses the TMS (flipped Y) tile scheme"`
+	Types            []string       `short:"t" long:"map-type" description:"Map download type (satellite, terrain, outdoors or foliage), may be repeated" default:"satellite"`
+	Level            int            `short:"l" long:"level" description:"Map level" default:"16" required:"yes"`
+	Margin           float64        `short:"m" long:"margin" description:"Margin in meters to add around node bounds" default:"0"`
+	Output           string         `short:"o" long:"output-dir" description:"Output directory" default:"/tmp/yawns/"`
+	Cache            string         `short:"d" long:"cache-dir" description:"Cache directory" default:"/tmp/yawns/"`
+	Update           bool           `short:"u" long:"update" description:"Automatically updates the provided configuration file with new satellite, terrain and foliage map references"`
+	NoHighDPI        bool           `long:"no-high-dpi" description:"Uses standard (not high DPI) tiles"`
+	Flatten          bool           `long:"flatten-terrain" description:"Flattens terrain images to greyscale for human parsing"`
+	FoliageColours   []string       `long:"foliage-colour" description:"Outdoors style colour (#rrggbb) to extract as foliage, may be repeated (defaults to mapbox outdoors wood and scrub)"`
+	FoliageTolerance float64        `long:"foliage-tolerance" description:"RGB distance within which pixels match a foliage colour"`
+}
+
+// mapboxTypes maps map types to mapbox map IDs and formats
+var mapboxTypes = map[string]struct {
+	ID     maps.MapID
+	Format maps.MapFormat
+}{
+	"satellite": {maps.MapIDSatellite, maps.MapFormatJpg90},
+	"terrain":   {maps.MapIDTerrainRGB, maps.MapFormatPngRaw},
+	"outdoors":  {maps.MapIDOutdoors, maps.MapFormatPng},
+	"foliage":   {maps.MapIDOutdoors, maps.MapFormatPng},
 }
 
 func main() {
 	fmt.Printf("YAWNS Map Fetching Utility\n")
 
-	options := Options{}
+	options := Options{FoliageTolerance: tiles.DefaultFoliageTolerance}
 
 	parser := flags.NewParser(&options, flags.Default)
 
@@ -38,8 +57,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	if options.APIKey == "" {
-		fmt.Printf("Mapbox API key must be specified\n")
+	if options.APIKey == "" && len(options.Sources) == 0 {
+		fmt.Printf("Mapbox API key or tile source must be specified\n")
 		os.Exit(-1)
 	}
 
@@ -48,10 +67,30 @@ func main() {
 		os.Exit(-1)
 	}
 
-	// Create mapbox connector
-	mbox := mapbox.NewMapbox(options.APIKey)
-	cache, _ := maps.NewFileCache(options.Cache)
-	mbox.Maps.SetCache(cache)
+	for _, t := range options.Types {
+		if _, ok := mapboxTypes[t]; !ok {
+			fmt.Printf("Unsupported map type '%s'\n", t)
+			os.Exit(-1)
+		}
+		// Outdoors maps are styled for display rather than classified, so are not usable as land cover maps
+		if t == "outdoors" && options.Update {
+			fmt.Printf("Outdoors maps are not referenced by the configuration and cannot be used with --update (use foliage or a classified land cover map)\n")
+			os.Exit(-1)
+		}
+	}
+
+	foliageColours := make([]color.RGBA, 0)
+	if len(options.FoliageColours) == 0 {
+		options.FoliageColours = tiles.DefaultFoliageColours
+	}
+	for _, c := range options.FoliageColours {
+		colour, err := helpers.ParseColour(c)
+		if err != nil {
+			fmt.Printf("Error parsing foliage colour: %s\n", err)
+			os.Exit(-1)
+		}
+		foliageColours = append(foliageColours, colour)
+	}
 
 	// Load configuration file
 	c, err := config.LoadConfigFile(string(options.Config))
@@ -61,94 +100,168 @@ func main() {
 	}
 
 	p1, p2 := types.GetNodeBounds(c.Nodes)
+	p1, p2 = tiles.ExpandBounds(p1, p2, options.Margin)
 
-	p1a := base.Location{Latitude: p1.Lat, Longitude: p1.Lng}
-	p2a := base.Location{Latitude: p2.Lat, Longitude: p2.Lng}
-
-	fmt.Printf("Fetching tiles...\n")
-
-	// Configure the map type
-	mapID := maps.MapIDSatellite
-	mapFormat := maps.MapFormatJpg90
-	extension := "jpg"
-
-	switch options.Type {
-	case "terrain":
-		mapID = maps.MapIDTerrainRGB
-		mapFormat = maps.MapFormatPngRaw
-		extension = "png"
-	case "outdoors":
-		mapID = maps.MapIDOutdoors
-		mapFormat = maps.MapFormatPng
-		extension = "png"
-	}
+	// Create tile fetcher
+	var fetch func(mapType string) (*tiles.Tile, string, error)
+	if len(options.Sources) != 0 {
+		sources := parseSources(options.Sources)
+		if s, ok := sources[""]; ok && len(options.Types) > 1 && !strings.Contains(s, "{type}") {
+			fmt.Printf("Warning: tile source %s has no {type} and is used for every map type\n", s)
+		}
 
-	// Fetch tiles enclosing the two extreme points
-	tiles, err := mbox.Maps.GetEnclosingTiles(mapID,
-		p1a, p2a, uint64(options.Level), mapFormat, !options.NoHighDPI)
-	if err != nil {
-		fmt.Printf("Error fetching map tiles: %s\n", err)
-		os.Exit(-1)
-	}
+		fetch = func(mapType string) (*tiles.Tile, string, error) {
+			uri, ok := sourceFor(sources, mapType)
+			if !ok {
+				return nil, "", fmt.Errorf("no tile source for map type %s", mapType)
+			}
 
-	// Stitch tiles into one super-tile
-	tile := maps.StitchTiles(tiles)
-	xCount, yCount := tile.Bounds().Dx()/int(tile.Size), tile.Bounds().Dy()/int(tile.Size)
-
-	// Print map information
-	fmt.Printf("\nTile Information:\n")
-	fmt.Printf("  - Mapbox ID: %s\n", string(mapID))
-	fmt.Printf("  - X: %d Y: %d Level: %d\n", tile.X, tile.Y, tile.Level)
-	fmt.Printf("  - Base tile size: %d\n", tile.Size)
-	fmt.Printf("  - Stitched tiles X: %d Y: %d\n", xCount, yCount)
-
-	// Flatten terrain map if requested
-	if options.Flatten && options.Type == "terrain" {
-		maxAlt := tile.GetHighestAltitude()
-		tile = tile.FlattenAltitudes(maxAlt + 1)
-		fmt.Printf("  - Flattened to maximum altitude of: %.2fm\n", maxAlt)
-	}
+			source, err := tiles.NewSource(uri, options.TMS)
+			if err != nil {
+				return nil, "", err
+			}
+			defer source.Close()
 
-	// Build the file name
-	fmt.Printf("\n")
-	flattened := ""
-	if options.Flatten {
-		flattened = "-flattened"
-	}
+			tile, err := tiles.GetEnclosingTiles(source, p1, p2, uint64(options.Level))
+			return tile, uri, err
+		}
+	} else {
+		mbox := mapbox.NewMapbox(options.APIKey)
+		cache, _ := maps.NewFileCache(options.Cache)
+		mbox.Maps.SetCache(cache)
 
-	fileName := fmt.Sprintf("%s%s-%d-%d-%d-%dx%d-%d%s.%s", options.Output, strings.Replace(string(mapID), ".", "-", -1), tile.Level, tile.X, tile.Y, xCount, yCount, tile.Size, flattened, extension)
-	switch mapFormat {
-	case maps.MapFormatJpg90:
-		err = maps.SaveImageJPG(tile, fileName)
-	case maps.MapFormatPng:
-		err = maps.SaveImagePNG(tile, fileName)
-	case maps.MapFormatPngRaw:
-		err = maps.SaveImagePNG(tile, fileName)
-	}
+		p1a := base.Location{Latitude: p1.Lat, Longitude: p1.Lng}
+		p2a := base.Location{Latitude: p2.Lat, Longitude: p2.Lng}
 
-	if err != nil {
-		fmt.Printf("Error saving map output: %s\n", err)
-		os.Exit(-1)
+		fetch = func(mapType string) (*tiles.Tile, string, error) {
+			mt := mapboxTypes[mapType]
+
+			// Fetch tiles enclosing the two extreme points
+			mapTiles, err := mbox.Maps.GetEnclosingTiles(mt.ID, p1a, p2a, uint64(options.Level), mt.Format, !options.NoHighDPI)
+			if err != nil {
+				return nil, "", err
+			}
+
+			// Stitch tiles into one super-tile
+			tile := maps.StitchTiles(mapTiles)
+
+			return &tiles.Tile{
+				Image:  tile,
+				X:      tile.X,
+				Y:      tile.Y,
+				Level:  tile.Level,
+				Size:   tile.Size,
+				XCount: uint64(tile.Bounds().Dx()) / tile.Size,
+				YCount: uint64(tile.Bounds().Dy()) / tile.Size,
+			}, "Mapbox " + string(mt.ID), nil
+		}
 	}
 
-	// Update the config file if required
-	if options.Update {
-		switch options.Type {
-		case "terrain":
-			c.Medium.Maps.Terrain = fileName
+	// Map keys to update in the configuration file
+	updates := make(map[string]interface{})
+
+	for _, mapType := range options.Types {
+		fmt.Printf("Fetching %s tiles...\n", mapType)
+
+		tile, sourceName, err := fetch(mapType)
+		if err != nil {
+			fmt.Printf("Error fetching map tiles: %s\n", err)
+			os.Exit(-1)
+		}
+
+		// Print map information
+		fmt.Printf("\nTile Information:\n")
+		fmt.Printf("  - Source: %s\n", sourceName)
+		fmt.Printf("  - X: %d Y: %d Level: %d\n", tile.X, tile.Y, tile.Level)
+		fmt.Printf("  - Base tile size: %d\n", tile.Size)
+		fmt.Printf("  - Stitched tiles X: %d Y: %d\n", tile.XCount, tile.YCount)
+
+		extension := "png"
+		suffix := ""
+
+		switch mapType {
 		case "satellite":
-			c.Medium.Maps.Satellite = fileName
-		default:
-			fmt.Printf("Unsupported map type '%s' for auto update\n", options.Type)
+			extension = "jpg"
+		case "terrain":
+			// Flatten terrain map if requested
+			if options.Flatten {
+				t := maps.NewTile(tile.X, tile.Y, tile.Level, tile.Size, tile.Image)
+				maxAlt := t.GetHighestAltitude()
+				tile.Image = t.FlattenAltitudes(maxAlt + 1)
+				suffix = "-flattened"
+				fmt.Printf("  - Flattened to maximum altitude of: %.2fm\n", maxAlt)
+			}
+		case "foliage":
+			// Extract foliage areas from the outdoors style
+			tile.Image = tiles.ExtractFoliage(tile.Image, foliageColours, options.FoliageTolerance)
+			fmt.Printf("  - Extracted foliage using %s\n", strings.Join(options.FoliageColours, ", "))
+		}
+
+		// Build the file name
+		fmt.Printf("\n")
+		fileName := fmt.Sprintf("%s%s-%d-%d-%d-%dx%d-%d%s.%s", options.Output, mapType, tile.Level, tile.X, tile.Y,
+			tile.XCount, tile.YCount, tile.Size, suffix, extension)
+
+		if extension == "jpg" {
+			err = maps.SaveImageJPG(tile, fileName)
+		} else {
+			err = maps.SaveImagePNG(tile, fileName)
+		}
+		if err != nil {
+			fmt.Printf("Error saving map output: %s\n", err)
 			os.Exit(-1)
 		}
 
-		c.Medium.Maps.Level = uint64(options.Level)
-		c.Medium.Maps.X = tile.X
-		c.Medium.Maps.Y = tile.Y
+		fmt.Printf("Output map written to: %s\n", fileName)
+
+		// Update the config if required
+		if options.Update {
+			updates[mapType] = fileName
+			updates["level"] = options.Level
+			updates["x"] = tile.X
+			updates["y"] = tile.Y
+			updates["tilesize"] = tile.Size
+		}
+	}
+
+	if options.Update {
+		if err := config.UpdateMapsFile(string(options.Config), updates); err != nil {
+			fmt.Printf("Error writing config file: %s\n", err)
+			os.Exit(-1)
+		}
+		fmt.Printf("Updated config file: %s\n", options.Config)
+	}
+}
 
-		config.WriteConfigFile(string(options.Config), c)
+// parseSources parses tile source options into sources by map type, with untyped sources under ""
+func parseSources(options []string) map[string]string {
+	sources := make(map[string]string)
+	for _, o := range options {
+		// Only known map type prefixes are split so URLs with query parameters are not
+		parts := strings.SplitN(o, "=", 2)
+		if _, ok := mapboxTypes[parts[0]]; ok && len(parts) == 2 {
+			sources[parts[0]] = parts[1]
+		} else {
+			sources[""] = o
+		}
 	}
+	return sources
+}
 
-	fmt.Printf("Output map written to: %s\n", fileName)
+// sourceFor fetches the tile source for a map type, foliage is extracted from the outdoors source if unset,
+// and {type} in untyped sources is replaced with the map type
+func sourceFor(sources map[string]string, mapType string) (string, bool) {
+	if s, ok := sources[mapType]; ok {
+		return s, true
+	}
+	if s, ok := sources["outdoors"]; ok && mapType == "foliage" {
+		return s, true
+	}
+	if s, ok := sources[""]; ok {
+		if mapType == "foliage" {
+			mapType = "outdoors"
+		}
+		return strings.Replace(s, "{type}", mapType, -1), true
+	}
+	return "", false
 }
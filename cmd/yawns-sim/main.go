@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 	"runtime"
 
 	"github.com/jessevdk/go-flags"
@@ -48,4 +49,9 @@ func main() {
 
 	// Exit simulation
 	sim.Close()
+
+	// Exit with an error if the simulation or any assertions failed
+	if err := sim.Result(); err != nil {
+		os.Exit(1)
+	}
 }
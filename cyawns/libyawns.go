@@ -22,6 +22,7 @@ import "C"
 
 import (
 	"fmt"
+	"time"
 	"unsafe"
 )
 
@@ -34,6 +35,25 @@ type ONSRadio struct {
 	radio C.struct_ons_radio_s
 }
 
+// RxInfo is the RF information for a received packet
+type RxInfo struct {
+	Channel   int32
+	RSSI      float32       // Received signal strength in dBm
+	SNR       float32       // Signal to noise ratio in dB
+	LQI       uint8         // Link quality indicator derived from SINR
+	StartTime time.Duration // Simulated transmission start time
+	EndTime   time.Duration // Simulated transmission end time
+}
+
+// BandInfo is the band information provided by the simulator on registration
+type BandInfo struct {
+	Name           string
+	Frequency      float64 // Centre frequency in Hz
+	Baud           float64 // Baud rate in bps
+	Channels       uint32  // Number of channels
+	ChannelSpacing float64 // Channel spacing in Hz
+}
+
 // NewONSConnector creates an ONS connector
 func NewONSConnector() *ONSConnector {
 	return &ONSConnector{C.struct_ons_s{}}
@@ -60,15 +80,21 @@ func (c *ONSConnector) Init(serverAddress string, localAddress string) error {
 
 // RadioInit Initialise a virtual radio using the connector
 func (c *ONSConnector) RadioInit(band string) (*ONSRadio, error) {
+	return c.RadioInitID(band, 0)
+}
+
+// RadioInitID Initialise a virtual radio with the provided ID using the connector
+// This is required for nodes with more than one radio on the same band
+func (c *ONSConnector) RadioInitID(band string, id uint32) (*ONSRadio, error) {
 	r := ONSRadio{
 		radio: C.struct_ons_radio_s{},
 	}
 	bandString := C.CString(band)
 
-	res := C.ONS_radio_init(&c.ons, &r.radio, bandString)
+	res := C.ONS_radio_init_id(&c.ons, &r.radio, bandString, C.uint32_t(id))
 	C.free(unsafe.Pointer(bandString))
 	if res != 0 {
-		return nil, fmt.Errorf("Error creating virtual radio for band: %s", band)
+		return nil, fmt.Errorf("Error creating virtual radio %d for band: %s", id, band)
 	}
 	return &r, nil
 }
@@ -78,6 +104,47 @@ func (c *ONSConnector) CloseRadio(r *ONSRadio) {
 	C.ONS_radio_close(&c.ons, &r.radio)
 }
 
+// Registered checks whether the simulator has accepted registration
+// This returns an error if the registration was rejected
+func (c *ONSConnector) Registered() (bool, error) {
+	switch C.ONS_get_registration(&c.ons) {
+	case C.ONS_REGISTRATION_ACCEPTED:
+		return true, nil
+	case C.ONS_REGISTRATION_REJECTED:
+		return false, fmt.Errorf("registration rejected: %s", C.GoString(&c.ons.reject_reason[0]))
+	default:
+		return false, nil
+	}
+}
+
+// GetBandInfo fetches band information provided by the simulator on registration
+func (c *ONSConnector) GetBandInfo(band string) (*BandInfo, error) {
+	b := C.CString(band)
+	info := C.struct_ons_band_info_s{}
+
+	res := C.ONS_get_band_info(&c.ons, b, &info)
+	if res < 0 {
+		return nil, fmt.Errorf("GetBandInfo error %d", res)
+	}
+
+	return &BandInfo{
+		Name:           C.GoString(&info.name[0]),
+		Frequency:      float64(info.frequency),
+		Baud:           float64(info.baud),
+		Channels:       uint32(info.channels),
+		ChannelSpacing: float64(info.channel_spacing),
+	}, nil
+}
+
+// Heartbeat sends a heartbeat to indicate the node is alive
+func (c *ONSConnector) Heartbeat() error {
+	res := C.ONS_heartbeat(&c.ons)
+	if res < 0 {
+		return fmt.Errorf("Heartbeat error %d", res)
+	}
+	return nil
+}
+
 // Close the ONS connector
 func (c *ONSConnector) Close() {
 	C.ONS_close(&c.ons)
@@ -159,6 +226,41 @@ func (r *ONSRadio) GetReceived() ([]byte, error) {
 	return safeData, nil
 }
 
+// GetReceivedInfo Fetch a received packet along with the received packet information
+func (r *ONSRadio) GetReceivedInfo() ([]byte, *RxInfo, error) {
+	// Create C objects for calling
+	data := make([]C.uint8_t, C.ONS_BUFFER_LENGTH)
+	dataPtr := (*C.uint8_t)(unsafe.Pointer(&data[0]))
+	maxLen := C.uint16_t(len(data))
+	len := C.uint16_t(0)
+	info := C.struct_ons_rx_info_s{}
+
+	// Call C method
+	res := C.ONS_radio_get_received_info(&r.radio, maxLen, dataPtr, &len, &info)
+
+	// Check response
+	if res <= 0 {
+		return []byte{}, nil, fmt.Errorf("ONS_get_received_info error %d", res)
+	}
+
+	// Convert to go data
+	safeData := make([]byte, len)
+	for i := range safeData {
+		safeData[i] = byte(data[i])
+	}
+
+	rxInfo := RxInfo{
+		Channel:   int32(info.channel),
+		RSSI:      float32(info.rssi),
+		SNR:       float32(info.snr),
+		LQI:       uint8(info.lqi),
+		StartTime: time.Duration(info.start_time) * time.Microsecond,
+		EndTime:   time.Duration(info.end_time) * time.Microsecond,
+	}
+
+	return safeData, &rxInfo, nil
+}
+
 // GetRSSI Check fetches RSSI for the device
 func (r *ONSRadio) GetRSSI(channel int) (float32, error) {
 	rssi := C.float(0.0)
@@ -214,3 +316,16 @@ func (c *ONSConnector) SetFieldf(name string, format string, data uint32) error
 	}
 	return nil
 }
+
+// ReadSensor reads a virtual sensor from the simulation
+func (c *ONSConnector) ReadSensor(name string) (float64, error) {
+	n := C.CString(name)
+	value := C.double(0)
+
+	res := C.ONS_read_sensor(&c.ons, n, &value)
+	C.free(unsafe.Pointer(n))
+	if res < 0 {
+		return 0, fmt.Errorf("ReadSensor error %d", res)
+	}
+	return float64(value), nil
+}
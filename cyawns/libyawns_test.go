@@ -56,6 +56,7 @@ func TestLibONS(t *testing.T) {
 			reg, ok := msg.(messages.Register)
 			assert.True(t, ok)
 			assert.EqualValues(t, clientAddress, reg.Address)
+			assert.EqualValues(t, connector.ProtocolVersion, reg.Version)
 
 		case <-time.After(timeout):
 			t.Errorf("Timeout")
@@ -63,6 +64,24 @@ func TestLibONS(t *testing.T) {
 		}
 	})
 
+	t.Run("Client receives registration acknowledgement", func(t *testing.T) {
+		server.InputChan <- messages.RegisterAck{
+			BaseMessage: messages.BaseMessage{Address: clientAddress},
+			Accepted:    true,
+			Bands:       []messages.BandInfo{{Name: band, Frequency: 433e6, Baud: 10e3, Channels: 8}},
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		registered, err := client.Registered()
+		assert.Nil(t, err)
+		assert.True(t, registered)
+
+		info, err := client.GetBandInfo(band)
+		assert.Nil(t, err)
+		assert.EqualValues(t, &BandInfo{Name: band, Frequency: 433e6, Baud: 10e3, Channels: 8}, info)
+	})
+
 	t.Run("Init radio", func(t *testing.T) {
 		r, err := client.RadioInit(band)
 		if err != nil {
@@ -126,6 +145,28 @@ func TestLibONS(t *testing.T) {
 
 	})
 
+	t.Run("Client receives packet metadata", func(t *testing.T) {
+		data := "Test Server Data String"
+
+		rfInfo := messages.NewRFInfo(band, 2)
+		rfInfo.RSSI, rfInfo.SNR, rfInfo.LQI = -62.5, 17.5, 148
+		rfInfo.StartTime, rfInfo.EndTime = 10*time.Millisecond, 12*time.Millisecond
+
+		server.InputChan <- messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: clientAddress},
+			RFInfo:      rfInfo,
+			Data:        []byte(data),
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		message, info, err := radio.GetReceivedInfo()
+		assert.Nil(t, err)
+		assert.EqualValues(t, data, message)
+		assert.EqualValues(t, RxInfo{Channel: 2, RSSI: -62.5, SNR: 17.5, LQI: 148,
+			StartTime: 10 * time.Millisecond, EndTime: 12 * time.Millisecond}, *info)
+	})
+
 	t.Run("Client can request rssi", func(t *testing.T) {
 
 		respond := func(t *testing.T, value float32) {
@@ -275,6 +316,31 @@ func TestLibONS(t *testing.T) {
 		}
 	})
 
+	t.Run("Client can read sensors", func(t *testing.T) {
+		go func() {
+			select {
+			case msg, ok := <-server.OutputChan:
+				assert.True(t, ok)
+				req, ok := msg.(messages.SensorRequest)
+				assert.True(t, ok)
+				assert.EqualValues(t, "temperature", req.Name)
+
+				server.InputChan <- messages.SensorResponse{
+					BaseMessage: messages.BaseMessage{Address: req.Address},
+					Name:        req.Name,
+					Value:       21.5,
+				}
+
+			case <-time.After(timeout):
+				t.Errorf("Timeout")
+			}
+		}()
+
+		value, err := client.ReadSensor("temperature")
+		assert.Nil(t, err)
+		assert.InDelta(t, 21.5, value, 0.01)
+	})
+
 	t.Run("Client can set fields with formatting", func(t *testing.T) {
 
 		name := "test-name"
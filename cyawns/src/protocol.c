@@ -43,11 +43,12 @@ int ons_send_pb(struct ons_s *ons, Base* message)
     return res;
 }
 
-RFInfo ons_build_rfinfo(char* band, int channel)
+RFInfo ons_build_rfinfo(char* band, uint32_t radio, int channel)
 {
     RFInfo info = RFINFO__INIT;
 
     info.band = band;
+    info.radio = radio;
     info.channel = channel;
 
     return info;
@@ -59,6 +60,7 @@ int ons_send_register(struct ons_s *ons, char* address)
     Register reg = REGISTER__INIT;
 
     reg.address = address;
+    reg.version = ONS_PROTOCOL_VERSION;
 
     base.message_case = BASE__MESSAGE_REGISTER;
     base.register_ = &reg;
@@ -91,6 +93,18 @@ int ons_send_field_req(struct ons_s *ons, char* name) {
     return ons_send_pb(ons, &base);
 }
 
+int ons_send_sensor_req(struct ons_s *ons, char* name) {
+    Base base = BASE__INIT;
+    SensorReq req = SENSOR_REQ__INIT;
+
+    req.name = name;
+
+    base.message_case = BASE__MESSAGE_SENSOR_REQ;
+    base.sensorreq = &req;
+
+    return ons_send_pb(ons, &base);
+}
+
 int ons_send_deregister(struct ons_s *ons, char* address)
 {
     Base base = BASE__INIT;
@@ -98,20 +112,32 @@ int ons_send_deregister(struct ons_s *ons, char* address)
 
     dereg.address = address;
 
+    base.message_case = BASE__MESSAGE_DEREGISTER;
+    base.deregister = &dereg;
+
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_packet(struct ons_s *ons, char* band, int32_t channel, uint8_t *data, uint16_t length)
+int ons_send_heartbeat(struct ons_s *ons)
+{
+    Base base = BASE__INIT;
+    Heartbeat heartbeat = HEARTBEAT__INIT;
+
+    base.message_case = BASE__MESSAGE_HEARTBEAT;
+    base.heartbeat = &heartbeat;
+
+    return ons_send_pb(ons, &base);
+}
+
+int ons_send_packet(struct ons_s *ons, char* band, uint32_t radio, int32_t channel, uint8_t *data, uint16_t length)
 {
     Base base = BASE__INIT;
     Packet packet = PACKET__INIT;
-    RFInfo info = RFINFO__INIT;
 
     packet.data.len = length;
     packet.data.data = data;
 
-    info.band = band;
-    info.channel = channel;
+    RFInfo info = ons_build_rfinfo(band, radio, channel);
     packet.info = &info;
 
     base.message_case = BASE__MESSAGE_PACKET;
@@ -120,12 +146,12 @@ int ons_send_packet(struct ons_s *ons, char* band, int32_t channel, uint8_t *dat
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_rssi_req(struct ons_s *ons, char* band, int channel)
+int ons_send_rssi_req(struct ons_s *ons, char* band, uint32_t radio, int channel)
 {
     Base base = BASE__INIT;
     RSSIReq req = RSSIREQ__INIT;
 
-    RFInfo info = ons_build_rfinfo(band, channel);
+    RFInfo info = ons_build_rfinfo(band, radio, channel);
     req.info = &info;
 
     base.message_case = BASE__MESSAGE_RSSI_REQ;
@@ -134,13 +160,14 @@ int ons_send_rssi_req(struct ons_s *ons, char* band, int channel)
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_state_req(struct ons_s *ons, char* band)
+int ons_send_state_req(struct ons_s *ons, char* band, uint32_t radio)
 {
     Base base = BASE__INIT;
     StateReq req = STATE_REQ__INIT;
 
     RFInfo info = RFINFO__INIT;
     info.band = band;
+    info.radio = radio;
     req.info = &info;
 
     base.message_case = BASE__MESSAGE_STATE_REQ;
@@ -149,12 +176,12 @@ int ons_send_state_req(struct ons_s *ons, char* band)
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_start_receive(struct ons_s *ons, char* band, int channel)
+int ons_send_start_receive(struct ons_s *ons, char* band, uint32_t radio, int channel)
 {
     Base base = BASE__INIT;
     StateSet stateset = STATE_SET__INIT;
 
-    RFInfo info = ons_build_rfinfo(band, channel);
+    RFInfo info = ons_build_rfinfo(band, radio, channel);
     stateset.info = &info;
 
     stateset.state = RFSTATE__RECEIVE;
@@ -165,13 +192,14 @@ int ons_send_start_receive(struct ons_s *ons, char* band, int channel)
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_idle(struct ons_s *ons, char* band)
+int ons_send_idle(struct ons_s *ons, char* band, uint32_t radio)
 {
     Base base = BASE__INIT;
     StateSet stateset = STATE_SET__INIT;
 
     RFInfo info = RFINFO__INIT;
     info.band = band;
+    info.radio = radio;
 
     stateset.info = &info;
     stateset.state = RFSTATE__IDLE;
@@ -182,13 +210,14 @@ int ons_send_idle(struct ons_s *ons, char* band)
     return ons_send_pb(ons, &base);
 }
 
-int ons_send_sleep(struct ons_s *ons, char* band)
+int ons_send_sleep(struct ons_s *ons, char* band, uint32_t radio)
 {
     Base base = BASE__INIT;
     StateSet stateset = STATE_SET__INIT;
 
     RFInfo info = RFINFO__INIT;
     info.band = band;
+    info.radio = radio;
     stateset.info = &info;
     stateset.state = RFSTATE__SLEEP;
 
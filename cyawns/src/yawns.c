@@ -43,12 +43,20 @@ int ONS_init(struct ons_s *ons, char *ons_address, char *local_address, struct o
     strncpy((char *)ons->local_address, local_address, ONS_STRING_LENGTH);
     ons->radio_count = 0;
     ons->config = config;
+    ons->registration = ONS_REGISTRATION_PENDING;
+    ons->band_count = 0;
+    ons->message_cb = NULL;
+    ons->message_cb_ctx = NULL;
+    ons->sensor_received = false;
 
     ONS_CORE_PRINT("[ONSC] Connecting to '%s' as '%s'\n", ons_address, local_address);
 
     // Create ZMQ socket
     ons->sock = zsock_new_dealer(ons_address);
 
+    // Initialise sensor request mutex
+    pthread_mutex_init(&ons->sensor_mutex, NULL);
+
     // Initialise radio list
     pthread_mutex_init(&ons->radios_mutex, NULL);
     for (int i = 0; i < ONS_MAX_RADIOS; i++) {
@@ -77,11 +85,44 @@ int ONS_status(struct ons_s *ons)
     return 0;
 }
 
+int ONS_get_registration(struct ons_s *ons)
+{
+    return ons->registration;
+}
+
+int ONS_get_band_info(struct ons_s *ons, char *band, struct ons_band_info_s *info)
+{
+    int res = -1;
+
+    pthread_mutex_lock(&ons->radios_mutex);
+    for (uint32_t i = 0; i < ons->band_count; i++) {
+        if (strncmp(ons->bands[i].name, band, ONS_STRING_LENGTH) == 0) {
+            *info = ons->bands[i];
+            res = 0;
+            break;
+        }
+    }
+    pthread_mutex_unlock(&ons->radios_mutex);
+
+    return res;
+}
+
+int ONS_heartbeat(struct ons_s *ons)
+{
+    return ons_send_heartbeat(ons);
+}
+
 int ONS_radio_init(struct ons_s *ons, struct ons_radio_s *radio, char *band)
+{
+    return ONS_radio_init_id(ons, radio, band, 0);
+}
+
+int ONS_radio_init_id(struct ons_s *ons, struct ons_radio_s *radio, char *band, uint32_t id)
 {
     radio->connector = NULL;
     radio->cb = NULL;
     strncpy(radio->band, band, sizeof(radio->band) - 1);
+    radio->id = id;
 
     // Init mutexes
     pthread_mutex_init(&radio->rssi_mutex, NULL);
@@ -134,6 +175,9 @@ int ONS_close(struct ons_s *ons)
 {
     ONS_CORE_PRINT("[ONSC] Closing connector\n");
 
+    // Deregister from the simulator
+    ons_send_deregister(ons, (char *)ons->local_address);
+
     ons->running = false;
 
     pthread_kill(ons->thread, SIGINT);
@@ -167,7 +211,7 @@ int ONS_radio_send(struct ons_radio_s *radio, int32_t channel, uint8_t *data, ui
     ONS_RADIO_PRINT("[ONCS] send %d bytes on channel %d\n", length, channel);
 
     radio->tx_complete = false;
-    return ons_send_packet(radio->connector, radio->band, channel, data, length);
+    return ons_send_packet(radio->connector, radio->band, radio->id, channel, data, length);
 }
 
 int ONS_radio_check_send(struct ons_radio_s *radio)
@@ -193,7 +237,7 @@ int ONS_radio_start_receive(struct ons_radio_s *radio, int32_t channel)
 
     ONS_RADIO_PRINT("[ONCS] start receive\n");
 
-    return ons_send_start_receive(radio->connector, radio->band, channel);
+    return ons_send_start_receive(radio->connector, radio->band, radio->id, channel);
 }
 
 int ONS_radio_stop_receive(struct ons_radio_s *radio)
@@ -204,7 +248,7 @@ int ONS_radio_stop_receive(struct ons_radio_s *radio)
 
     ONS_RADIO_PRINT("[ONCS] stop receive\n");
 
-    return ons_send_idle(radio->connector, radio->band);
+    return ons_send_idle(radio->connector, radio->band, radio->id);
 }
 
 int ONS_radio_sleep(struct ons_radio_s *radio)
@@ -215,7 +259,7 @@ int ONS_radio_sleep(struct ons_radio_s *radio)
 
     ONS_RADIO_PRINT("[ONCS] radio sleep\n");
 
-    return ons_send_sleep(radio->connector, radio->band);
+    return ons_send_sleep(radio->connector, radio->band, radio->id);
 }
 
 
@@ -234,6 +278,12 @@ int ONS_radio_check_receive(struct ons_radio_s *radio)
 }
 
 int ONS_radio_get_received(struct ons_radio_s *radio, uint16_t max_len, uint8_t *data, uint16_t *len)
+{
+    return ONS_radio_get_received_info(radio, max_len, data, len, NULL);
+}
+
+int ONS_radio_get_received_info(struct ons_radio_s *radio, uint16_t max_len, uint8_t *data, uint16_t *len,
+                                struct ons_rx_info_s *info)
 {
     if (radio == NULL) {
         return -1;
@@ -250,6 +300,9 @@ int ONS_radio_get_received(struct ons_radio_s *radio, uint16_t max_len, uint8_t
 
     *len = (radio->receive_length > max_len) ? max_len : radio->receive_length;
     memcpy(data, (const void *)radio->receive_data, *len);
+    if (info != NULL) {
+        *info = radio->receive_info;
+    }
 
     radio->receive_length = 0;
 
@@ -274,7 +327,7 @@ int ONS_radio_get_state(struct ons_radio_s *radio, uint32_t *state)
     pthread_mutex_trylock(&radio->state_mutex);
 
     // Send get CCA message
-    ons_send_state_req(radio->connector, radio->band);
+    ons_send_state_req(radio->connector, radio->band, radio->id);
 
     // Await cca mutex unlock from onsc thread
     res = pthread_mutex_lock(&radio->state_mutex);
@@ -332,7 +385,7 @@ int ONS_radio_get_rssi(struct ons_radio_s *radio, int32_t channel, float *rssi)
     pthread_mutex_trylock(&radio->rssi_mutex);
 
     // Send get CCA message
-    ons_send_rssi_req(radio->connector, radio->band, channel);
+    ons_send_rssi_req(radio->connector, radio->band, radio->id, channel);
 
     // Await cca mutex unlock from onsc thread
     res = pthread_mutex_lock(&radio->rssi_mutex);
@@ -364,6 +417,62 @@ int ONS_set_field(struct ons_s *ons, char* name, char* data_str)
     return ons_send_field_set(ons, name, data_str);
 }
 
+int ONS_get_field(struct ons_s *ons, char* name)
+{
+    return ons_send_field_req(ons, name);
+}
+
+int ONS_read_sensor(struct ons_s *ons, char* name, double *value)
+{
+    int res;
+
+    ONS_CORE_PRINT("[ONCS] read sensor %s\n", name);
+
+    ons->sensor_received = false;
+
+    // TryLock in case mutex already locked
+    pthread_mutex_trylock(&ons->sensor_mutex);
+
+    ons_send_sensor_req(ons, name);
+
+    // Await sensor mutex unlock from onsc thread
+    res = pthread_mutex_lock(&ons->sensor_mutex);
+    if (res < 0) {
+        perror("[ONSC] sensor mutex lock error");
+        return -1;
+    }
+
+    *value = ons->sensor_value;
+    bool sensor_received = ons->sensor_received;
+    bool sensor_ok = ons->sensor_ok;
+
+    // Return mutex to unlocked state
+    pthread_mutex_unlock(&ons->sensor_mutex);
+
+    if (sensor_received != true) {
+        ONS_CORE_PRINT("[ONCS] no sensor response received\n");
+        return -2;
+    }
+    if (sensor_ok != true) {
+        ONS_CORE_PRINT("[ONCS] sensor read failed\n");
+        return -3;
+    }
+
+    ONS_CORE_PRINT("[ONCS] got sensor value OK (%.2f)\n", *value);
+
+    return 0;
+}
+
+int ONS_set_message_cb(struct ons_s *ons, ons_message_cb_f cb, void* ctx)
+{
+    pthread_mutex_lock(&ons->radios_mutex);
+    ons->message_cb = cb;
+    ons->message_cb_ctx = ctx;
+    pthread_mutex_unlock(&ons->radios_mutex);
+
+    return 0;
+}
+
 int ONS_set_fieldf(struct ons_s *ons, char* name, char* format, ...)
 {
     va_list args;
@@ -394,11 +503,14 @@ void ONS_print_arr(char *name, uint8_t *data, uint16_t length)
 // Stub exit handler for signal binding
 void exit_handler(int x) {}
 
-// Find a radio instance by band
-struct ons_radio_s *ons_get_radio(struct ons_s *ons, char *band)
+// Find a radio instance by band and radio ID
+struct ons_radio_s *ons_get_radio(struct ons_s *ons, char *band, uint32_t id)
 {
     for (int i = 0; i < ONS_MAX_RADIOS; i++) {
-        if (strcmp(band, ons->radios[i]->band) == 0) {
+        if (ons->radios[i] == NULL) {
+            continue;
+        }
+        if ((strcmp(band, ons->radios[i]->band) == 0) && (ons->radios[i]->id == id)) {
             return ons->radios[i];
         }
     }
@@ -445,7 +557,7 @@ void *ons_handle_receive(void *ctx)
                 }
 
                 // Find matching radio instance
-                radio = ons_get_radio(ons, base->packet->info->band);
+                radio = ons_get_radio(ons, base->packet->info->band, base->packet->info->radio);
                 if (radio == NULL) {
                     ONS_CORE_PRINT("[ONCS THREAD] no radio found matching packet\n");
                     break;
@@ -456,6 +568,12 @@ void *ons_handle_receive(void *ctx)
                 pthread_mutex_lock(&radio->rx_mutex);
                 memcpy((void *)radio->receive_data, base->packet->data.data, max_size);
                 radio->receive_length = max_size;
+                radio->receive_info.channel = base->packet->info->channel;
+                radio->receive_info.rssi = base->packet->info->rssi;
+                radio->receive_info.snr = base->packet->info->snr;
+                radio->receive_info.lqi = base->packet->info->lqi;
+                radio->receive_info.start_time = base->packet->info->start_time;
+                radio->receive_info.end_time = base->packet->info->end_time;
                 if (ons->config->debug_prints)
                     ONS_print_arr("[ONSC THREAD] Received packet", (uint8_t *)radio->receive_data, radio->receive_length);
                 pthread_mutex_unlock(&radio->rx_mutex);
@@ -477,7 +595,7 @@ void *ons_handle_receive(void *ctx)
                 }
 
                 // Find matching radio instance
-                radio = ons_get_radio(ons, base->rssiresp->info->band);
+                radio = ons_get_radio(ons, base->rssiresp->info->band, base->rssiresp->info->radio);
                 if (radio == NULL) {
                     ONS_CORE_PRINT("[ONCS THREAD] no radio found matching rssi response\n");
                     break;
@@ -499,7 +617,7 @@ void *ons_handle_receive(void *ctx)
                 }
 
                 // Find matching radio instance
-                radio = ons_get_radio(ons, base->stateresp->info->band);
+                radio = ons_get_radio(ons, base->stateresp->info->band, base->stateresp->info->radio);
                 if (radio == NULL) {
                     ONS_CORE_PRINT("[ONCS THREAD] no radio found matching state response\n");
                     break;
@@ -519,7 +637,7 @@ void *ons_handle_receive(void *ctx)
                 }
 
                 // Find matching radio instance
-                radio = ons_get_radio(ons, base->sendcomplete->info->band);
+                radio = ons_get_radio(ons, base->sendcomplete->info->band, base->sendcomplete->info->radio);
                 if (radio == NULL) {
                     ONS_CORE_PRINT("[ONCS THREAD] no radio found matching rssi response\n");
                     break;
@@ -535,6 +653,86 @@ void *ons_handle_receive(void *ctx)
                 ONS_CORE_PRINT("[ONCS THREAD] got tx complete\n");
                 break;
 
+            case BASE__MESSAGE_FIELD_SET:
+                if (base->fieldset == NULL || base->fieldset->name == NULL) {
+                    ONS_CORE_PRINT("[ONCS THREAD] invalid field set\n");
+                    break;
+                }
+                ONS_CORE_PRINT("[ONCS THREAD] got field %s\n", base->fieldset->name);
+                if (ons->message_cb != NULL) {
+                    ons->message_cb(ons->message_cb_ctx, ONS_MESSAGE_FIELD, base->fieldset->name, base->fieldset->data);
+                }
+                break;
+
+            case BASE__MESSAGE_FIELD_RESP:
+                if (base->fieldresp == NULL || base->fieldresp->name == NULL) {
+                    ONS_CORE_PRINT("[ONCS THREAD] invalid field response\n");
+                    break;
+                }
+                ONS_CORE_PRINT("[ONCS THREAD] got field response %s\n", base->fieldresp->name);
+                if (ons->message_cb != NULL) {
+                    ons->message_cb(ons->message_cb_ctx, ONS_MESSAGE_FIELD_RESP, base->fieldresp->name, base->fieldresp->data);
+                }
+                break;
+
+            case BASE__MESSAGE_COMMAND:
+                if (base->command == NULL || base->command->name == NULL) {
+                    ONS_CORE_PRINT("[ONCS THREAD] invalid command\n");
+                    break;
+                }
+                ONS_CORE_PRINT("[ONCS THREAD] got command %s\n", base->command->name);
+                if (ons->message_cb != NULL) {
+                    ons->message_cb(ons->message_cb_ctx, ONS_MESSAGE_COMMAND, base->command->name, base->command->data);
+                }
+                break;
+
+            case BASE__MESSAGE_SENSOR_RESP:
+                if (base->sensorresp == NULL || base->sensorresp->name == NULL) {
+                    ONS_CORE_PRINT("[ONCS THREAD] invalid sensor response\n");
+                    break;
+                }
+                ons->sensor_value = base->sensorresp->value;
+                ons->sensor_ok = (base->sensorresp->error == NULL) || (strlen(base->sensorresp->error) == 0);
+                ons->sensor_received = true;
+                if (!ons->sensor_ok) {
+                    ONS_PRINTF("[ONCS THREAD] sensor %s error: %s\n", base->sensorresp->name, base->sensorresp->error);
+                }
+                ONS_CORE_PRINT("[ONCS THREAD] got sensor response %s %.2f\n", base->sensorresp->name, ons->sensor_value);
+                pthread_mutex_unlock(&ons->sensor_mutex);
+                break;
+
+            case BASE__MESSAGE_REGISTER_ACK:
+                if (base->registerack == NULL) {
+                    ONS_CORE_PRINT("[ONCS THREAD] invalid register ack\n");
+                    break;
+                }
+
+                ons->server_version = base->registerack->version;
+                ons->heartbeat_timeout = base->registerack->heartbeat_timeout;
+
+                if (!base->registerack->accepted) {
+                    strncpy(ons->reject_reason, base->registerack->reason, ONS_BUFFER_LENGTH - 1);
+                    ons->registration = ONS_REGISTRATION_REJECTED;
+                    ONS_PRINTF("[ONCS THREAD] registration rejected: %s\n", ons->reject_reason);
+                    break;
+                }
+
+                // Copy band information
+                ons->band_count = 0;
+                for (size_t i = 0; i < base->registerack->n_bands && i < ONS_MAX_BANDS; i++) {
+                    BandInfo *b = base->registerack->bands[i];
+                    struct ons_band_info_s *info = &ons->bands[ons->band_count++];
+                    strncpy(info->name, b->name, ONS_STRING_LENGTH - 1);
+                    info->frequency = b->frequency;
+                    info->baud = b->baud;
+                    info->channels = b->channels;
+                    info->channel_spacing = b->channel_spacing;
+                }
+
+                ons->registration = ONS_REGISTRATION_ACCEPTED;
+                ONS_CORE_PRINT("[ONCS THREAD] registration accepted (simulator version %d)\n", ons->server_version);
+                break;
+
             default:
                 ONS_CORE_PRINT("[ONCS THREAD] unrecognised type %d\n", base->message_case);
                 if (ons->config->debug_prints)
@@ -19,15 +19,17 @@ extern "C" {
 
 int ons_send_register(struct ons_s *ons, char* address);
 int ons_send_deregister(struct ons_s *ons, char* address);
-int ons_send_packet(struct ons_s *ons, char* band, int32_t channel, uint8_t *data, uint16_t length);
-int ons_send_rssi_req(struct ons_s *ons, char* band, int channel);
-int ons_send_state_req(struct ons_s *ons, char* band);
-int ons_send_start_receive(struct ons_s *ons, char* band, int channel);
-int ons_send_idle(struct ons_s *ons, char* band);
-int ons_send_sleep(struct ons_s *ons, char* band);
+int ons_send_heartbeat(struct ons_s *ons);
+int ons_send_packet(struct ons_s *ons, char* band, uint32_t radio, int32_t channel, uint8_t *data, uint16_t length);
+int ons_send_rssi_req(struct ons_s *ons, char* band, uint32_t radio, int channel);
+int ons_send_state_req(struct ons_s *ons, char* band, uint32_t radio);
+int ons_send_start_receive(struct ons_s *ons, char* band, uint32_t radio, int channel);
+int ons_send_idle(struct ons_s *ons, char* band, uint32_t radio);
+int ons_send_sleep(struct ons_s *ons, char* band, uint32_t radio);
 int ons_send_event(struct ons_s *ons, char* data);
 int ons_send_field_set(struct ons_s *ons, char* name, char* data_str);
-int ons_send_field_req(struct ons_s *ons, char* name);    
+int ons_send_field_req(struct ons_s *ons, char* name);
+int ons_send_sensor_req(struct ons_s *ons, char* name);
 
 #ifdef __cplusplus
 }
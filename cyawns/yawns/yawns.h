@@ -22,6 +22,10 @@ extern "C" {
 #define ONS_MAX_RADIOS 16     //!< Maximum Virtual Radio Interfaces per ONS connector
 #define ONS_STRING_LENGTH 64  //!< Maximum string length
 #define ONS_BUFFER_LENGTH 256 //!< Maximum buffer length
+#define ONS_MAX_BANDS 16      //!< Maximum bands reported by the simulator
+
+// Protocol version, this must match ProtocolVersion in the simulator connector
+#define ONS_PROTOCOL_VERSION 1
 
 // ONS radio state enumerations
 enum ons_radio_state_e {
@@ -39,6 +43,32 @@ enum ons_radio_event_e {
     ONS_RADIO_EVENT_SEND_DONE = 2,
 };
 
+// Received packet information
+struct ons_rx_info_s {
+    int32_t channel;        //!< Channel the packet was received on
+    float rssi;             //!< Received signal strength in dBm
+    float snr;              //!< Signal to noise ratio in dB
+    uint32_t lqi;           //!< Link quality indicator (0-255) derived from SINR
+    uint64_t start_time;    //!< Simulated transmission start time in microseconds
+    uint64_t end_time;      //!< Simulated transmission end time in microseconds
+};
+
+// ONS registration states
+enum ons_registration_e {
+    ONS_REGISTRATION_REJECTED = -1,
+    ONS_REGISTRATION_PENDING = 0,
+    ONS_REGISTRATION_ACCEPTED = 1,
+};
+
+// Band information provided by the simulator on registration
+struct ons_band_info_s {
+    char name[ONS_STRING_LENGTH];   //!< Band name
+    double frequency;               //!< Centre frequency in Hz
+    double baud;                    //!< Baud rate in bps
+    uint32_t channels;              //!< Number of channels
+    double channel_spacing;         //!< Channel spacing in Hz
+};
+
 // ONS connector configuration
 struct ons_config_s {
     bool intercept_signals;
@@ -51,6 +81,16 @@ struct ons_config_s {
         false              \
     }
 
+// ONS message enumerations for messages sent to nodes by the simulator
+enum ons_message_e {
+    ONS_MESSAGE_FIELD = 1,      //!< Field value pushed by the simulator
+    ONS_MESSAGE_FIELD_RESP = 2, //!< Field value in response to ONS_get_field
+    ONS_MESSAGE_COMMAND = 3,    //!< Command (ie. simulated button press or configuration change)
+};
+
+// ONS message callback, called from the receive thread with the message name and data
+typedef void (*ons_message_cb_f) (void* ctx, uint32_t type, char* name, char* data);
+
 // ONS connector instance
 struct ons_s {
     uint8_t local_address[ONS_STRING_LENGTH];
@@ -65,6 +105,21 @@ struct ons_s {
     struct ons_radio_s *radios[ONS_MAX_RADIOS];
     uint32_t radio_count;
 
+    volatile int registration;
+    char reject_reason[ONS_BUFFER_LENGTH];
+    uint32_t server_version;
+    uint32_t heartbeat_timeout;
+    struct ons_band_info_s bands[ONS_MAX_BANDS];
+    uint32_t band_count;
+
+    ons_message_cb_f message_cb;
+    void* message_cb_ctx;
+
+    pthread_mutex_t sensor_mutex;
+    volatile double sensor_value;
+    volatile bool sensor_received;
+    volatile bool sensor_ok;
+
     struct ons_config_s *config;
 };
 
@@ -74,10 +129,12 @@ typedef void (*ons_radio_cb_f) (void* ctx, uint32_t event);
 struct ons_radio_s {
     struct ons_s *connector;
     char band[128];
+    uint32_t id;
 
     pthread_mutex_t rx_mutex;
     volatile uint16_t receive_length;
     volatile uint8_t receive_data[ONS_BUFFER_LENGTH];
+    struct ons_rx_info_s receive_info;
 
     pthread_mutex_t tx_mutex;
     volatile bool tx_complete;
@@ -100,12 +157,25 @@ int ONS_init(struct ons_s *ons, char *ons_address, char *local_address, struct o
 // Print the ONS connector status
 int ONS_status(struct ons_s *ons);
 
+// Fetch the registration state (see ons_registration_e)
+int ONS_get_registration(struct ons_s *ons);
+
+// Fetch band information provided by the simulator on registration
+int ONS_get_band_info(struct ons_s *ons, char *band, struct ons_band_info_s *info);
+
+// Send a heartbeat to indicate the node is alive
+// This should be called periodically by idle nodes when the simulator heartbeat timeout is enabled
+int ONS_heartbeat(struct ons_s *ons);
+
 // Send an ONS event
 int YAWNS_event(struct ons_s *ons, uint8_t* name, uint8_t* data, size_t len);
 
 // Create a for a specific band using the ons connector
 int ONS_radio_init(struct ons_s *ons, struct ons_radio_s *radio, char *band);
 
+// Create a radio with the specified ID for a band, for nodes with multiple radios on the same band
+int ONS_radio_init_id(struct ons_s *ons, struct ons_radio_s *radio, char *band, uint32_t id);
+
 // Attach an event callback to a radio
 int ONS_radio_set_cb(struct ons_radio_s *radio, ons_radio_cb_f cb, void* ctx);
 
@@ -130,6 +200,10 @@ int ONS_radio_check_receive(struct ons_radio_s *radio);
 // Fetch a received packet
 int ONS_radio_get_received(struct ons_radio_s *radio, uint16_t max_len, uint8_t *data, uint16_t *len);
 
+// Fetch a received packet along with the received packet information
+int ONS_radio_get_received_info(struct ons_radio_s *radio, uint16_t max_len, uint8_t *data, uint16_t *len,
+                                struct ons_rx_info_s *info);
+
 // Put a radio into sleep mode
 int ONS_radio_sleep(struct ons_radio_s *radio);
 
@@ -145,6 +219,15 @@ int ONS_set_field(struct ons_s *ons, char* name, char* data_str);
 // Set a field in the simulation with formatted print
 int ONS_set_fieldf(struct ons_s *ons, char* name, char* format, ...);
 
+// Request a field from the simulation, the response is delivered to the message callback
+int ONS_get_field(struct ons_s *ons, char* name);
+
+// Read a virtual sensor from the simulation, blocking until a response is received
+int ONS_read_sensor(struct ons_s *ons, char* name, double *value);
+
+// Attach a callback for fields and commands sent by the simulator
+int ONS_set_message_cb(struct ons_s *ons, ons_message_cb_f cb, void* ctx);
+
 // Close the ONS connector
 int ONS_close(struct ons_s *ons);
 
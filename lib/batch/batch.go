@@ -0,0 +1,173 @@
+package batch
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/ryankurte/yawns/lib/helpers"
+	"github.com/ryankurte/yawns/lib/medium"
+)
+
+// Connector address schemes for simulation runs
+const (
+	// SchemeStream uses the native stream connector
+	SchemeStream = "stream+tcp"
+	// SchemeZMQ uses the ZMQ connector, which requires a simulator built with the zmq tag
+	SchemeZMQ = "tcp"
+)
+
+// Batch runs the simulations of a sweep
+type Batch struct {
+	Sweep *Sweep
+	// Simulator executable (yawns-sim)
+	Simulator string
+	// Output directory, each run writes to a run directory within this
+	OutputDir string
+	// Number of runs executed in parallel
+	Parallel int
+	// Base port, each run binds to a unique port from the base
+	Port int
+	// Connector address scheme (SchemeStream or SchemeZMQ), defaults to SchemeStream
+	Scheme string
+}
+
+// Run executes the batch, returning the results of each run
+func (b *Batch) Run() ([]Result, error) {
+	if _, _, err := b.addresses(b.Port); err != nil {
+		return nil, err
+	}
+
+	base, err := loadTree(b.Sweep.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	outputDir, err := filepath.Abs(b.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate run configurations before starting so configuration errors are reported early
+	runs := b.Sweep.Matrix()
+	for i := range runs {
+		dir := filepath.Join(outputDir, runs[i].Name())
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		t, err := runConfig(base, &runs[i], dir)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating %s configuration (%s)", runs[i].Name(), err)
+		}
+		if err := writeTree(filepath.Join(dir, "config.yml"), t); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("[INFO] Batch: running %d simulations (%d in parallel)", len(runs), b.Parallel)
+
+	parallel := b.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]Result, len(runs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = b.run(&runs[i], filepath.Join(outputDir, runs[i].Name()))
+			}
+		}()
+	}
+	for i := range runs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// run executes a single simulation and collects metrics from the run statistics
+func (b *Batch) run(r *Run, dir string) Result {
+	result := Result{Run: *r, Metrics: make(map[string]float64)}
+
+	logFile, err := os.Create(filepath.Join(dir, "yawns.log"))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer logFile.Close()
+
+	bind, client, err := b.addresses(b.Port + r.Index)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	cmd := exec.Command(b.Simulator,
+		"--config", filepath.Join(dir, "config.yml"),
+		"--address", bind,
+		"--client-address", client,
+		"--report", filepath.Join(dir, "report"),
+	)
+	// Runs execute from the base configuration directory so relative paths (ie. maps and scripts) resolve
+	cmd.Dir = filepath.Dir(b.Sweep.Config)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+
+	log.Printf("[INFO] Batch: starting %s (seed %d, %+v)", r.Name(), r.Seed, r.Values)
+
+	// Simulations exit with an error when assertions fail, in which case statistics are still available
+	err = cmd.Run()
+	if _, ok := err.(*exec.ExitError); ok || err == nil {
+		result.Passed = err == nil
+	} else {
+		result.Error = err.Error()
+		return result
+	}
+
+	stats := medium.NewStats()
+	if err := helpers.ReadYAMLFile(filepath.Join(dir, "stats.yml"), &stats); err != nil {
+		result.Passed, result.Error = false, err.Error()
+		return result
+	}
+	for _, name := range b.Sweep.Metrics {
+		v, err := Metric(name, &stats, b.Sweep.Power)
+		if err != nil {
+			log.Printf("[WARNING] Batch: %s metric %s unavailable (%s)", r.Name(), name, err)
+			continue
+		}
+		result.Metrics[name] = v
+	}
+
+	log.Printf("[INFO] Batch: completed %s (passed: %t)", r.Name(), result.Passed)
+
+	return result
+}
+
+// addresses builds the simulator bind and client addresses for a run port
+func (b *Batch) addresses(port int) (bind, client string, err error) {
+	switch b.Scheme {
+	case "", SchemeStream:
+		return fmt.Sprintf("%s://:%d", SchemeStream, port), fmt.Sprintf("%s://localhost:%d", SchemeStream, port), nil
+	case SchemeZMQ:
+		return fmt.Sprintf("%s://*:%d", SchemeZMQ, port), fmt.Sprintf("%s://localhost:%d", SchemeZMQ, port), nil
+	default:
+		return "", "", fmt.Errorf("Unsupported connector scheme '%s' (expected %s or %s)", b.Scheme, SchemeStream, SchemeZMQ)
+	}
+}
+
+// WriteResults writes the run results (runs.csv) and aggregated summaries (summary.csv) to the output directory
+func (b *Batch) WriteResults(results []Result) error {
+	if err := WriteRunsCSV(filepath.Join(b.OutputDir, "runs.csv"), results, b.Sweep.Parameters, b.Sweep.Metrics); err != nil {
+		return err
+	}
+	summaries := Aggregate(results, b.Sweep.Metrics, b.Sweep.Confidence)
+	return WriteSummaryCSV(filepath.Join(b.OutputDir, "summary.csv"), summaries, b.Sweep.Parameters, b.Sweep.Metrics)
+}
@@ -0,0 +1,242 @@
+package batch
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/medium"
+)
+
+const testConfig = `
+medium:
+  bands:
+    Sub1GHz:
+      baud: 10000
+plugins:
+  pcap:
+    file: out.pcap
+nodes:
+  - address: 0x0001
+    location: {lat: -36.8, lng: 174.7}
+`
+
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yawns-batch")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yml")
+	assert.Nil(t, ioutil.WriteFile(configFile, []byte(testConfig), 0644))
+
+	sweep := Sweep{
+		Config: configFile,
+		Parameters: []Parameter{
+			{Path: "medium.bands.Sub1GHz.baud", Values: []interface{}{10000, 50000}},
+			{Path: "medium.bands.Sub1GHz.linkbudget", Range: &Range{Start: 80, End: 100, Step: 10}},
+		},
+		Repeats: 2,
+		Metrics: []string{MetricPDR, MetricLatency},
+	}
+
+	t.Run("Validates sweeps", func(t *testing.T) {
+		assert.Nil(t, sweep.Validate())
+		assert.EqualValues(t, 0.95, sweep.Confidence)
+
+		invalid := []Sweep{
+			{Parameters: []Parameter{{Path: "seed"}}},
+			{Parameters: []Parameter{{Path: "seed", Range: &Range{Start: 1, End: 0, Step: 1}}}},
+			{Metrics: []string{"throughput"}},
+			{Metrics: []string{MetricEnergy}},
+			{Confidence: 0.5},
+		}
+		for _, s := range invalid {
+			assert.NotNil(t, s.Validate(), "%+v", s)
+		}
+	})
+
+	t.Run("Generates the run matrix", func(t *testing.T) {
+		runs := sweep.Matrix()
+		assert.Len(t, runs, 12)
+
+		assert.EqualValues(t, []Value{{"medium.bands.Sub1GHz.baud", 10000}, {"medium.bands.Sub1GHz.linkbudget", int64(80)}}, runs[0].Values)
+		assert.EqualValues(t, 1, runs[0].Seed)
+		assert.EqualValues(t, 2, runs[1].Seed, "Seeds vary fastest")
+		assert.EqualValues(t, runs[0].Set, runs[1].Set)
+		assert.EqualValues(t, int64(90), runs[2].Values[1].Value)
+		assert.EqualValues(t, 50000, runs[11].Values[0].Value)
+		assert.EqualValues(t, 5, runs[11].Set)
+		assert.EqualValues(t, "run-0011", runs[11].Name())
+
+		p := Parameter{Path: "nodes.0.location.lat", Range: &Range{Start: -36.85, End: -36.80, Step: 0.025}}
+		assert.EqualValues(t, []interface{}{-36.85, -36.825, -36.8}, p.values())
+
+		s := Sweep{Seeds: []int64{7, 9}}
+		runs = s.Matrix()
+		assert.Len(t, runs, 2)
+		assert.EqualValues(t, 9, runs[1].Seed)
+	})
+
+	t.Run("Generates run configurations", func(t *testing.T) {
+		base, err := loadTree(configFile)
+		assert.Nil(t, err)
+
+		r := Run{Values: []Value{{"Medium.Bands.Sub1GHz.Baud", 50000}, {"nodes.0.location.lat", -37.0}}, Seed: 3}
+		c, err := runConfig(base, &r, "/tmp/run")
+		assert.Nil(t, err)
+
+		data, err := yaml.Marshal(c)
+		assert.Nil(t, err)
+		doc := string(data)
+		assert.Contains(t, doc, "baud: 50000")
+		assert.Contains(t, doc, "lat: -37")
+		assert.Contains(t, doc, `address: "0x0001"`, "Preserves hex addresses")
+		assert.Contains(t, doc, "seed: 3")
+		assert.Contains(t, doc, "statsfile: /tmp/run/stats.yml")
+		assert.Contains(t, doc, "file: /tmp/run/capture.pcap")
+		assert.NotContains(t, doc, "consoles", "Only redirects configured outputs")
+
+		original, _ := yaml.Marshal(base)
+		assert.Contains(t, string(original), "baud: 10000", "Does not modify the base configuration")
+
+		_, err = runConfig(base, &Run{Values: []Value{{"nodes.3.address", "0x0004"}}}, "/tmp/run")
+		assert.NotNil(t, err)
+		_, err = runConfig(base, &Run{Values: []Value{{"medium.bands.Sub1GHz.baud.value", 1}}}, "/tmp/run")
+		assert.NotNil(t, err)
+	})
+
+	stats := medium.NewStats()
+	stats.Nodes["0x0001"] = medium.NodeStats{Sent: 10, Received: 4, Transceivers: map[string]medium.TransceiverStats{
+		"Sub1GHz:0": {TransmittingTime: 2 * time.Second, ReceiveTime: 10 * time.Second},
+	}}
+	stats.Nodes["0x0002"] = medium.NodeStats{Sent: 0, Received: 6}
+	stats.Nodes["0x0003"] = medium.NodeStats{Sent: 0, Received: 5}
+	stats.AddLatency(10 * time.Millisecond)
+	stats.AddLatency(20 * time.Millisecond)
+
+	t.Run("Calculates metrics", func(t *testing.T) {
+		v, err := Metric(MetricPDR, &stats, nil)
+		assert.Nil(t, err)
+		assert.InDelta(t, 15.0/20.0, v, 1e-9)
+
+		v, err = Metric(MetricLatency, &stats, nil)
+		assert.Nil(t, err)
+		assert.InDelta(t, 0.015, v, 1e-9)
+
+		v, err = Metric(MetricEnergy, &stats, map[string]float64{"transmitting": 0.1, "receive": 0.01})
+		assert.Nil(t, err)
+		assert.InDelta(t, 0.3, v, 1e-9)
+
+		v, err = Metric(MetricReceived, &stats, nil)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 15, v)
+
+		empty := medium.NewStats()
+		_, err = Metric(MetricLatency, &empty, nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Calculates confidence intervals", func(t *testing.T) {
+		i := NewInterval([]float64{1, 2, 3, 4}, 0.95)
+		assert.InDelta(t, 2.5, i.Mean, 1e-9)
+		assert.InDelta(t, 1.29099, i.StdDev, 1e-5)
+		assert.InDelta(t, 2.5-3.182*1.29099/2, i.Low, 1e-4)
+		assert.InDelta(t, 2.5+3.182*1.29099/2, i.High, 1e-4)
+
+		i = NewInterval([]float64{5}, 0.95)
+		assert.EqualValues(t, 5, i.Low)
+		assert.EqualValues(t, 5, i.High)
+		assert.True(t, math.IsNaN(NewInterval(nil, 0.95).Mean))
+	})
+
+	results := []Result{
+		{Run: Run{Index: 0, Set: 0, Values: []Value{{"seed", 1}}, Seed: 1}, Passed: true, Metrics: map[string]float64{"pdr": 0.8}},
+		{Run: Run{Index: 1, Set: 0, Values: []Value{{"seed", 1}}, Seed: 2}, Passed: false, Metrics: map[string]float64{"pdr": 0.6}},
+		{Run: Run{Index: 2, Set: 1, Values: []Value{{"seed", 2}}, Seed: 1}, Passed: false, Error: "failed to start", Metrics: map[string]float64{}},
+	}
+
+	t.Run("Aggregates results", func(t *testing.T) {
+		summaries := Aggregate(results, []string{"pdr"}, 0.95)
+		assert.Len(t, summaries, 2)
+		assert.EqualValues(t, 2, summaries[0].Runs)
+		assert.EqualValues(t, 1, summaries[0].Failed)
+		assert.InDelta(t, 0.7, summaries[0].Metrics["pdr"].Mean, 1e-9)
+		assert.EqualValues(t, 0, summaries[1].Metrics["pdr"].N)
+	})
+
+	t.Run("Writes CSV results", func(t *testing.T) {
+		parameters := []Parameter{{Path: "seed"}}
+		summaryFile, runsFile := filepath.Join(dir, "summary.csv"), filepath.Join(dir, "runs.csv")
+		assert.Nil(t, WriteSummaryCSV(summaryFile, Aggregate(results, []string{"pdr"}, 0.95), parameters, []string{"pdr"}))
+		assert.Nil(t, WriteRunsCSV(runsFile, results, parameters, []string{"pdr"}))
+
+		data, err := ioutil.ReadFile(summaryFile)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		assert.EqualValues(t, "seed,runs,failed,pdr_mean,pdr_stddev,pdr_ci_low,pdr_ci_high", lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "1,2,1,0.7,"))
+		assert.EqualValues(t, "2,1,1,,0,,", lines[2])
+
+		data, err = ioutil.ReadFile(runsFile)
+		assert.Nil(t, err)
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		assert.EqualValues(t, "run,seed,seed,passed,error,pdr", lines[0])
+		assert.EqualValues(t, "run-0002,2,1,false,failed to start,", lines[3])
+	})
+
+	t.Run("Runs simulations in parallel", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Requires a shell")
+		}
+
+		// Fake simulator writing statistics to the run directory and failing runs with port 10201
+		sim := filepath.Join(dir, "sim.sh")
+		script := `#!/bin/sh
+out=$(dirname "$2")
+printf 'nodes:\n  a: {sent: 2, received: 1}\n  b: {sent: 0, received: 1}\n' > "$out/stats.yml"
+case "$4" in *10201) exit 1;; esac
+`
+		assert.Nil(t, ioutil.WriteFile(sim, []byte(script), 0755))
+
+		s := Sweep{Config: configFile, Repeats: 3, Metrics: []string{MetricPDR}, Confidence: 0.95}
+		b := Batch{Sweep: &s, Simulator: sim, OutputDir: filepath.Join(dir, "batch"), Parallel: 2, Port: 10200}
+
+		results, err := b.Run()
+		assert.Nil(t, err)
+		assert.Len(t, results, 3)
+		assert.True(t, results[0].Passed)
+		assert.False(t, results[1].Passed, "Records failed simulations")
+		assert.InDelta(t, 1.0, results[1].Metrics[MetricPDR], 1e-9, "Collects metrics from failed simulations")
+
+		assert.Nil(t, b.WriteResults(results))
+		_, err = os.Stat(filepath.Join(dir, "batch", "summary.csv"))
+		assert.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dir, "batch", "run-0002", "config.yml"))
+		assert.Nil(t, err)
+	})
+	t.Run("Builds run addresses for connector schemes", func(t *testing.T) {
+		b := Batch{}
+		bind, client, err := b.addresses(10200)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "stream+tcp://:10200", bind, "Defaults to the native stream connector")
+		assert.EqualValues(t, "stream+tcp://localhost:10200", client)
+
+		b.Scheme = SchemeZMQ
+		bind, client, err = b.addresses(10200)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "tcp://*:10200", bind)
+		assert.EqualValues(t, "tcp://localhost:10200", client)
+
+		b = Batch{Sweep: &Sweep{Config: configFile}, Scheme: "udp"}
+		_, err = b.Run()
+		assert.NotNil(t, err, "Rejects unsupported schemes before running")
+	})
+}
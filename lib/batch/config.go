@@ -0,0 +1,148 @@
+package batch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ryankurte/yawns/lib/config"
+)
+
+// loadTree loads a configuration file as a generic YAML document, merging included files
+// Variables are substituted when runs load their configuration so they can be swept
+func loadTree(fileName string) (map[interface{}]interface{}, error) {
+	return config.LoadConfigTree(fileName)
+}
+
+// writeTree writes a generic YAML document to a file
+func writeTree(fileName string, t map[interface{}]interface{}) error {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// copyValue deep copies a YAML value
+func copyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		c := make(map[interface{}]interface{}, len(value))
+		for k, v := range value {
+			c[k] = copyValue(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(value))
+		for i, v := range value {
+			c[i] = copyValue(v)
+		}
+		return c
+	}
+	return v
+}
+
+// findKey finds a map key matching the path segment, ignoring case as configuration keys are lower cased
+func findKey(m map[interface{}]interface{}, segment string) (interface{}, bool) {
+	for k := range m {
+		if strings.EqualFold(fmt.Sprint(k), segment) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// setPath sets the value at a '.' separated path in a document, creating missing maps
+func setPath(t map[interface{}]interface{}, path string, value interface{}) error {
+	segments := strings.Split(path, ".")
+	var current interface{} = t
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch node := current.(type) {
+		case map[interface{}]interface{}:
+			key, ok := findKey(node, segment)
+			if !ok {
+				key = segment
+			}
+			if last {
+				node[key] = value
+				return nil
+			}
+			if node[key] == nil {
+				node[key] = make(map[interface{}]interface{})
+			}
+			current = node[key]
+
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return fmt.Errorf("Invalid index '%s' in path %s", segment, path)
+			}
+			if last {
+				node[index] = value
+				return nil
+			}
+			current = node[index]
+
+		default:
+			return fmt.Errorf("Path %s can not be set (%s is not a map or list)", path, strings.Join(segments[:i], "."))
+		}
+	}
+
+	return nil
+}
+
+// hasPath checks whether a '.' separated path of map keys exists in a document
+func hasPath(t map[interface{}]interface{}, path string) bool {
+	var current interface{} = t
+	for _, segment := range strings.Split(path, ".") {
+		node, ok := current.(map[interface{}]interface{})
+		if !ok {
+			return false
+		}
+		key, ok := findKey(node, segment)
+		if !ok {
+			return false
+		}
+		current = node[key]
+	}
+	return true
+}
+
+// runConfig generates the configuration for a run from the base configuration
+// Output files are redirected to the run directory so runs do not overwrite each other
+func runConfig(base map[interface{}]interface{}, r *Run, dir string) (map[interface{}]interface{}, error) {
+	t := copyValue(base).(map[interface{}]interface{})
+
+	for _, v := range r.Values {
+		if err := setPath(t, v.Path, v.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := setPath(t, "seed", r.Seed); err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]string{
+		"medium.statsfile":   "stats.yml",
+		"plugins.pcap.file":  "capture.pcap",
+		"plugins.state.file": "state.yml",
+		"consoles.dir":       "consoles",
+		"consoles.logfile":   "consoles.csv",
+	}
+	for path, name := range outputs {
+		if path == "medium.statsfile" || hasPath(t, path) {
+			if err := setPath(t, path, filepath.Join(dir, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,288 @@
+package batch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/ryankurte/yawns/lib/medium"
+)
+
+// Metric names
+const (
+	// MetricPDR is the ratio of packets received to packets that could have been received by other nodes
+	MetricPDR = "pdr"
+	// MetricLatency is the mean latency from the start of transmission to delivery (s)
+	MetricLatency = "latency"
+	// MetricEnergy is the total transceiver energy of all nodes (J), using the sweep transceiver power
+	MetricEnergy = "energy"
+	// MetricSent is the total number of packets sent
+	MetricSent = "sent"
+	// MetricReceived is the total number of packets received
+	MetricReceived = "received"
+	// MetricCollisions is the total number of packets lost to collisions
+	MetricCollisions = "collisions"
+	// MetricInterfered is the total number of packets lost to interferers
+	MetricInterfered = "interfered"
+)
+
+type metricFunc func(stats *medium.Stats, power map[string]float64) (float64, error)
+
+var metrics = map[string]metricFunc{
+	MetricPDR:        pdr,
+	MetricLatency:    latency,
+	MetricEnergy:     energy,
+	MetricSent:       total(func(n medium.NodeStats) uint64 { return n.Sent }),
+	MetricReceived:   total(func(n medium.NodeStats) uint64 { return n.Received }),
+	MetricCollisions: total(func(n medium.NodeStats) uint64 { return n.Collisions }),
+	MetricInterfered: total(func(n medium.NodeStats) uint64 { return n.Interfered }),
+}
+
+// Metric calculates a named metric from run statistics
+func Metric(name string, stats *medium.Stats, power map[string]float64) (float64, error) {
+	m, ok := metrics[name]
+	if !ok {
+		return 0, fmt.Errorf("Unrecognised metric '%s'", name)
+	}
+	return m(stats, power)
+}
+
+// total creates a metric summing a node statistic
+func total(field func(n medium.NodeStats) uint64) metricFunc {
+	return func(stats *medium.Stats, power map[string]float64) (float64, error) {
+		sum := uint64(0)
+		for _, n := range stats.Nodes {
+			sum += field(n)
+		}
+		return float64(sum), nil
+	}
+}
+
+// pdr calculates the broadcast packet delivery ratio, as every packet could be received by every other node
+func pdr(stats *medium.Stats, power map[string]float64) (float64, error) {
+	sent, received := uint64(0), uint64(0)
+	for _, n := range stats.Nodes {
+		sent += n.Sent
+		received += n.Received
+	}
+	if sent == 0 || len(stats.Nodes) < 2 {
+		return 0, fmt.Errorf("No packets sent")
+	}
+	return float64(received) / float64(sent*uint64(len(stats.Nodes)-1)), nil
+}
+
+// latency fetches the mean delivery latency
+func latency(stats *medium.Stats, power map[string]float64) (float64, error) {
+	if stats.Latency.Count == 0 {
+		return 0, fmt.Errorf("No packets delivered")
+	}
+	return stats.Latency.Mean.Seconds(), nil
+}
+
+// energy calculates the total energy used by transceivers from the time spent in each state
+func energy(stats *medium.Stats, power map[string]float64) (float64, error) {
+	sum := 0.0
+	for _, n := range stats.Nodes {
+		for _, t := range n.Transceivers {
+			sum += t.OffTime.Seconds() * power["off"]
+			sum += t.IdleTime.Seconds() * power["idle"]
+			sum += t.SleepTime.Seconds() * power["sleep"]
+			sum += t.ReceiveTime.Seconds() * power["receive"]
+			sum += t.ReceivingTime.Seconds() * power["receiving"]
+			sum += t.TransmittingTime.Seconds() * power["transmitting"]
+		}
+	}
+	return sum, nil
+}
+
+// tCritical contains two sided Student's t critical values for 1 to 30 degrees of freedom,
+// with the normal critical value used for larger samples
+var tCritical = map[float64][]float64{
+	0.9: {
+		6.314, 2.920, 2.353, 2.132, 2.015, 1.943, 1.895, 1.860, 1.833, 1.812,
+		1.796, 1.782, 1.771, 1.761, 1.753, 1.746, 1.740, 1.734, 1.729, 1.725,
+		1.721, 1.717, 1.714, 1.711, 1.708, 1.706, 1.703, 1.701, 1.699, 1.697,
+		1.645,
+	},
+	0.95: {
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+		1.960,
+	},
+	0.99: {
+		63.657, 9.925, 5.841, 4.604, 4.032, 3.707, 3.499, 3.355, 3.250, 3.169,
+		3.106, 3.055, 3.012, 2.977, 2.947, 2.921, 2.898, 2.878, 2.861, 2.845,
+		2.831, 2.819, 2.807, 2.797, 2.787, 2.779, 2.771, 2.763, 2.756, 2.750,
+		2.576,
+	},
+}
+
+// Interval is the mean and confidence interval of a metric over runs
+type Interval struct {
+	N      int
+	Mean   float64
+	StdDev float64
+	Low    float64
+	High   float64
+}
+
+// NewInterval calculates the mean and confidence interval of samples
+func NewInterval(samples []float64, confidence float64) Interval {
+	i := Interval{N: len(samples)}
+	if i.N == 0 {
+		i.Mean, i.Low, i.High = math.NaN(), math.NaN(), math.NaN()
+		return i
+	}
+
+	for _, s := range samples {
+		i.Mean += s
+	}
+	i.Mean /= float64(i.N)
+
+	if i.N > 1 {
+		for _, s := range samples {
+			i.StdDev += (s - i.Mean) * (s - i.Mean)
+		}
+		i.StdDev = math.Sqrt(i.StdDev / float64(i.N-1))
+	}
+
+	critical := tCritical[confidence]
+	t := critical[len(critical)-1]
+	if i.N-1 < len(critical) && i.N > 1 {
+		t = critical[i.N-2]
+	}
+	margin := t * i.StdDev / math.Sqrt(float64(i.N))
+	i.Low, i.High = i.Mean-margin, i.Mean+margin
+
+	return i
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Run
+	Passed  bool
+	Error   string
+	Metrics map[string]float64
+}
+
+// Summary aggregates the results of runs with the same parameter values
+type Summary struct {
+	Set     int
+	Values  []Value
+	Runs    int
+	Failed  int
+	Metrics map[string]Interval
+}
+
+// Aggregate summarises results for each parameter combination
+// Metrics missing from a run (ie. if the run did not complete) are excluded from the intervals
+func Aggregate(results []Result, names []string, confidence float64) []Summary {
+	summaries := make([]Summary, 0)
+	samples := make([]map[string][]float64, 0)
+
+	for _, r := range results {
+		for r.Set >= len(summaries) {
+			summaries = append(summaries, Summary{Set: len(summaries)})
+			samples = append(samples, make(map[string][]float64))
+		}
+		s := &summaries[r.Set]
+		s.Values = r.Values
+		s.Runs++
+		if !r.Passed {
+			s.Failed++
+		}
+		for name, v := range r.Metrics {
+			samples[r.Set][name] = append(samples[r.Set][name], v)
+		}
+	}
+
+	for i := range summaries {
+		summaries[i].Metrics = make(map[string]Interval)
+		for _, name := range names {
+			summaries[i].Metrics[name] = NewInterval(samples[i][name], confidence)
+		}
+	}
+
+	return summaries
+}
+
+// WriteSummaryCSV writes summaries to a CSV file with the mean, standard deviation and confidence interval of each metric
+func WriteSummaryCSV(fileName string, summaries []Summary, parameters []Parameter, names []string) error {
+	header := make([]string, 0)
+	for _, p := range parameters {
+		header = append(header, p.Path)
+	}
+	header = append(header, "runs", "failed")
+	for _, n := range names {
+		header = append(header, n+"_mean", n+"_stddev", n+"_ci_low", n+"_ci_high")
+	}
+
+	rows := [][]string{header}
+	for _, s := range summaries {
+		row := make([]string, 0, len(header))
+		for _, v := range s.Values {
+			row = append(row, fmt.Sprint(v.Value))
+		}
+		row = append(row, strconv.Itoa(s.Runs), strconv.Itoa(s.Failed))
+		for _, n := range names {
+			i := s.Metrics[n]
+			row = append(row, formatFloat(i.Mean), formatFloat(i.StdDev), formatFloat(i.Low), formatFloat(i.High))
+		}
+		rows = append(rows, row)
+	}
+
+	return writeCSV(fileName, rows)
+}
+
+// WriteRunsCSV writes the result and metrics of each run to a CSV file
+func WriteRunsCSV(fileName string, results []Result, parameters []Parameter, names []string) error {
+	header := []string{"run"}
+	for _, p := range parameters {
+		header = append(header, p.Path)
+	}
+	header = append(header, "seed", "passed", "error")
+	header = append(header, names...)
+
+	rows := [][]string{header}
+	for _, r := range results {
+		row := []string{r.Name()}
+		for _, v := range r.Values {
+			row = append(row, fmt.Sprint(v.Value))
+		}
+		row = append(row, strconv.FormatInt(r.Seed, 10), strconv.FormatBool(r.Passed), r.Error)
+		for _, n := range names {
+			if v, ok := r.Metrics[n]; ok {
+				row = append(row, formatFloat(v))
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return writeCSV(fileName, rows)
+}
+
+func formatFloat(f float64) string {
+	if math.IsNaN(f) {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+func writeCSV(fileName string, rows [][]string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
@@ -0,0 +1,174 @@
+/**
+ * OpenNetworkSim Batch Package
+ * Parameter sweeps and Monte Carlo batch runs of simulations with aggregated results
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package batch
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ryankurte/yawns/lib/helpers"
+)
+
+// Range is an inclusive range of numeric parameter values
+type Range struct {
+	Start, End, Step float64
+}
+
+// Parameter is a configuration value swept over a list or range of values
+type Parameter struct {
+	// Path to the value in the configuration, with map keys and list indices separated by '.'
+	// (ie. medium.bands.Sub1GHz.baud or nodes.0.location.lat)
+	Path   string
+	Values []interface{}
+	Range  *Range
+}
+
+// Sweep defines a batch of simulations
+type Sweep struct {
+	// Base configuration file, relative to the sweep file
+	Config string
+	// Parameters are swept over every combination of values
+	Parameters []Parameter
+	// Seeds each parameter combination is run with (sets the configuration seed)
+	Seeds []int64
+	// Repeats runs each parameter combination with seeds 1 to N if no seeds are specified
+	Repeats int
+	// Metrics aggregated from run statistics (see Metrics)
+	Metrics []string
+	// Power drawn in each transceiver state (W) for energy metrics (ie. transmitting: 0.066)
+	Power map[string]float64
+	// Confidence level of aggregated intervals (0.9, 0.95 or 0.99, defaults to 0.95)
+	Confidence float64
+}
+
+// Value is a parameter value for a run
+type Value struct {
+	Path  string
+	Value interface{}
+}
+
+// Run is a single simulation in a batch
+type Run struct {
+	Index  int     // Run index in the batch
+	Set    int     // Parameter combination index
+	Values []Value // Parameter values
+	Seed   int64
+}
+
+// LoadSweepFile loads a sweep specification, resolving the base configuration relative to the sweep file
+func LoadSweepFile(fileName string) (*Sweep, error) {
+	s := Sweep{}
+	if err := helpers.ReadYAMLFile(fileName, &s); err != nil {
+		return nil, err
+	}
+	if s.Config != "" && !filepath.IsAbs(s.Config) {
+		s.Config = filepath.Join(filepath.Dir(fileName), s.Config)
+	}
+	return &s, s.Validate()
+}
+
+// Validate checks a sweep specification and applies defaults
+func (s *Sweep) Validate() error {
+	for i, p := range s.Parameters {
+		if p.Path == "" {
+			return fmt.Errorf("Sweep parameter %d has no path", i)
+		}
+		if len(p.Values) == 0 && p.Range == nil {
+			return fmt.Errorf("Sweep parameter %s has no values or range", p.Path)
+		}
+		if p.Range != nil && (p.Range.Step <= 0 || p.Range.End < p.Range.Start) {
+			return fmt.Errorf("Sweep parameter %s has an invalid range (%+v)", p.Path, *p.Range)
+		}
+	}
+
+	for _, m := range s.Metrics {
+		if _, ok := metrics[m]; !ok {
+			return fmt.Errorf("Unrecognised sweep metric '%s'", m)
+		}
+		if m == MetricEnergy && len(s.Power) == 0 {
+			return fmt.Errorf("Energy metrics require transceiver power")
+		}
+	}
+
+	if s.Confidence == 0 {
+		s.Confidence = 0.95
+	}
+	if _, ok := tCritical[s.Confidence]; !ok {
+		return fmt.Errorf("Unsupported confidence level %g (use 0.9, 0.95 or 0.99)", s.Confidence)
+	}
+
+	return nil
+}
+
+// values fetches the values of a parameter
+func (p *Parameter) values() []interface{} {
+	if p.Range == nil {
+		return p.Values
+	}
+
+	values := make([]interface{}, 0)
+	steps := int(math.Floor((p.Range.End-p.Range.Start)/p.Range.Step + 1e-9))
+	for i := 0; i <= steps; i++ {
+		// Values are rounded to remove floating point error from steps (ie. 0.1 + 0.2)
+		v, _ := strconv.ParseFloat(strconv.FormatFloat(p.Range.Start+float64(i)*p.Range.Step, 'g', 12, 64), 64)
+		if v == math.Trunc(v) {
+			values = append(values, int64(v))
+		} else {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// seeds fetches the seeds each parameter combination is run with
+func (s *Sweep) seeds() []int64 {
+	if len(s.Seeds) > 0 {
+		return s.Seeds
+	}
+	repeats := s.Repeats
+	if repeats == 0 {
+		repeats = 1
+	}
+	seeds := make([]int64, repeats)
+	for i := range seeds {
+		seeds[i] = int64(i + 1)
+	}
+	return seeds
+}
+
+// Matrix generates the runs for every combination of parameter values and seeds
+// The first parameter varies slowest, and seeds vary fastest
+func (s *Sweep) Matrix() []Run {
+	sets := [][]Value{{}}
+	for _, p := range s.Parameters {
+		next := make([][]Value, 0)
+		for _, set := range sets {
+			for _, v := range p.values() {
+				values := append(append([]Value{}, set...), Value{Path: p.Path, Value: v})
+				next = append(next, values)
+			}
+		}
+		sets = next
+	}
+
+	runs := make([]Run, 0)
+	for i, set := range sets {
+		for _, seed := range s.seeds() {
+			runs = append(runs, Run{Index: len(runs), Set: i, Values: set, Seed: seed})
+		}
+	}
+	return runs
+}
+
+// Name generates a name for the run
+func (r *Run) Name() string {
+	return fmt.Sprintf("run-%04d", r.Index)
+}
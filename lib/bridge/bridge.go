@@ -0,0 +1,113 @@
+/**
+ * OpenNetworkSim Bridge Package
+ * Bridges a simulated node to a host TUN or TAP network interface, allowing standard host software to communicate
+ * over the simulated network with the node acting as a border router
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package bridge
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/ryankurte/yawns/lib/client"
+)
+
+// MaxPacketLength is the maximum packet length read from the network interface
+const MaxPacketLength = 65535
+
+// Radio defines the virtual radio interface used by the bridge
+type Radio interface {
+	Send(ctx context.Context, channel int32, data []byte) error
+	StartReceive(channel int32) error
+	OnReceive(cb func(client.Packet))
+}
+
+// Bridge passes packets between a network interface and a virtual radio
+type Bridge struct {
+	radio   Radio
+	device  io.ReadWriteCloser
+	framer  Framer
+	channel int32
+}
+
+// NewBridge creates a bridge between the provided network device and radio
+func NewBridge(radio Radio, device io.ReadWriteCloser, framer Framer, channel int32) *Bridge {
+	return &Bridge{
+		radio:   radio,
+		device:  device,
+		framer:  framer,
+		channel: channel,
+	}
+}
+
+// Run runs the bridge until the context is cancelled or an error occurs
+// The device is closed when the bridge exits
+func (b *Bridge) Run(ctx context.Context) error {
+	// Close the device to unblock reads on exit
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		b.device.Close()
+	}()
+
+	b.radio.OnReceive(b.handleFrame)
+	if err := b.radio.StartReceive(b.channel); err != nil {
+		return err
+	}
+
+	buff := make([]byte, MaxPacketLength)
+	for {
+		n, err := b.device.Read(buff)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		frames, err := b.framer.Encode(buff[:n])
+		if err != nil {
+			log.Printf("Bridge: dropping outgoing packet (%s)", err)
+			continue
+		}
+
+		for _, f := range frames {
+			if err := b.radio.Send(ctx, b.channel, f); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+
+		// Return to receive mode in case the band does not automatically transition after sending
+		if err := b.radio.StartReceive(b.channel); err != nil {
+			return err
+		}
+	}
+}
+
+// handleFrame delivers received frames to the network interface
+func (b *Bridge) handleFrame(p client.Packet) {
+	packet, err := b.framer.Decode(p.Data)
+	if err != nil {
+		log.Printf("Bridge: dropping incoming frame (%s)", err)
+		return
+	}
+	if packet == nil {
+		return
+	}
+
+	if _, err := b.device.Write(packet); err != nil {
+		log.Printf("Bridge: error writing to device (%s)", err)
+	}
+}
@@ -0,0 +1,181 @@
+package bridge
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/client"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPacket creates an IPv6 packet of the provided length
+func testPacket(length int) []byte {
+	p := make([]byte, length)
+	p[0] = 0x60
+	for i := 1; i < length; i++ {
+		p[i] = byte(i)
+	}
+	return p
+}
+
+type testRadio struct {
+	sent      chan []byte
+	onReceive func(client.Packet)
+}
+
+func (r *testRadio) Send(ctx context.Context, channel int32, data []byte) error {
+	r.sent <- data
+	return nil
+}
+
+func (r *testRadio) StartReceive(channel int32) error { return nil }
+
+func (r *testRadio) OnReceive(cb func(client.Packet)) { r.onReceive = cb }
+
+type testDevice struct {
+	in     chan []byte
+	out    chan []byte
+	closed chan struct{}
+}
+
+func (d *testDevice) Read(b []byte) (int, error) {
+	select {
+	case p := <-d.in:
+		return copy(b, p), nil
+	case <-d.closed:
+		return 0, io.EOF
+	}
+}
+
+func (d *testDevice) Write(b []byte) (int, error) {
+	d.out <- append([]byte{}, b...)
+	return len(b), nil
+}
+
+func (d *testDevice) Close() error {
+	select {
+	case <-d.closed:
+	default:
+		close(d.closed)
+	}
+	return nil
+}
+
+func TestFraming(t *testing.T) {
+	t.Run("Rejects unknown framing", func(t *testing.T) {
+		_, err := NewFramer("invalid", 0, 0)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Passes raw payloads", func(t *testing.T) {
+		f, err := NewFramer(FramingRaw, 0, 0)
+		assert.Nil(t, err)
+
+		frames, err := f.Encode([]byte{1, 2, 3})
+		assert.Nil(t, err)
+		assert.EqualValues(t, [][]byte{{1, 2, 3}}, frames)
+	})
+
+	a, b := NewLowpanFramer(0xabcd, 0x0001), NewLowpanFramer(0xabcd, 0x0002)
+
+	t.Run("Rejects non IPv6 packets", func(t *testing.T) {
+		_, err := a.Encode([]byte{0x45, 0x00})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Encapsulates small packets", func(t *testing.T) {
+		packet := testPacket(60)
+		frames, err := a.Encode(packet)
+		assert.Nil(t, err)
+		assert.Len(t, frames, 1)
+		assert.EqualValues(t, []byte{0x41, 0xc8}, frames[0][:2], "Data frame control field")
+		assert.EqualValues(t, []byte{0xcd, 0xab, 0xff, 0xff}, frames[0][3:7], "PAN and broadcast destination")
+		assert.EqualValues(t, dispatchIPv6, frames[0][headerLength])
+
+		decoded, err := b.Decode(frames[0])
+		assert.Nil(t, err)
+		assert.EqualValues(t, packet, decoded)
+	})
+
+	t.Run("Fragments large packets", func(t *testing.T) {
+		packet := testPacket(1280)
+		frames, err := a.Encode(packet)
+		assert.Nil(t, err)
+		assert.True(t, len(frames) > 1)
+
+		for i, f := range frames {
+			assert.True(t, len(f) <= MaxFrameLength-fcsLength)
+
+			decoded, err := b.Decode(f)
+			assert.Nil(t, err)
+			if i < len(frames)-1 {
+				assert.Nil(t, decoded)
+			} else {
+				assert.EqualValues(t, packet, decoded)
+			}
+		}
+	})
+
+	t.Run("Reassembles out of order fragments", func(t *testing.T) {
+		packet := testPacket(300)
+		frames, err := a.Encode(packet)
+		assert.Nil(t, err)
+
+		var decoded []byte
+		for i := len(frames) - 1; i >= 0; i-- {
+			decoded, err = b.Decode(frames[i])
+			assert.Nil(t, err)
+		}
+		assert.EqualValues(t, packet, decoded)
+	})
+
+	t.Run("Rejects unsupported frames", func(t *testing.T) {
+		_, err := b.Decode([]byte{0x00})
+		assert.NotNil(t, err)
+
+		frames, _ := a.Encode(testPacket(40))
+		frames[0][headerLength] = 0x60
+		_, err = b.Decode(frames[0])
+		assert.NotNil(t, err, "IPHC compression is not supported")
+	})
+}
+
+func TestBridge(t *testing.T) {
+	radio := testRadio{sent: make(chan []byte, 16)}
+	device := testDevice{in: make(chan []byte, 1), out: make(chan []byte, 1), closed: make(chan struct{})}
+
+	b := NewBridge(&radio, &device, NewLowpanFramer(0xabcd, 0x0001), 11)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.Run(ctx) }()
+
+	t.Run("Sends interface packets", func(t *testing.T) {
+		device.in <- testPacket(60)
+		select {
+		case f := <-radio.sent:
+			assert.EqualValues(t, testPacket(60), f[headerLength+1:])
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout awaiting frame")
+		}
+	})
+
+	t.Run("Delivers received frames", func(t *testing.T) {
+		frames, _ := NewLowpanFramer(0xabcd, 0x0002).Encode(testPacket(80))
+		radio.onReceive(client.Packet{Data: frames[0]})
+		assert.EqualValues(t, testPacket(80), <-device.out)
+	})
+
+	t.Run("Exits on cancellation", func(t *testing.T) {
+		cancel()
+		select {
+		case err := <-done:
+			assert.Nil(t, err)
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout awaiting exit")
+		}
+	})
+}
@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const tunDevice = "/dev/net/tun"
+
+// Device is a TUN or TAP network interface
+type Device struct {
+	*os.File
+	Name string
+}
+
+// OpenDevice creates a TUN (IP packet) or TAP (ethernet frame) network interface
+// The interface name is assigned by the kernel if unset, interfaces must be configured (ie. with ip) once created
+func OpenDevice(name string, tap bool) (*Device, error) {
+	fd, err := unix.Open(tunDevice, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	flags := uint16(unix.IFF_TUN | unix.IFF_NO_PI)
+	if tap {
+		flags = unix.IFF_TAP | unix.IFF_NO_PI
+	}
+	ifr.SetUint16(flags)
+
+	if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// Non-blocking descriptors are managed by the runtime poller so Close interrupts pending reads
+	return &Device{File: os.NewFile(uintptr(fd), tunDevice), Name: ifr.Name()}, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package bridge
+
+import (
+	"fmt"
+	"os"
+)
+
+// Device is a TUN or TAP network interface
+type Device struct {
+	*os.File
+	Name string
+}
+
+// OpenDevice creates a TUN or TAP network interface, this is only supported on linux
+func OpenDevice(name string, tap bool) (*Device, error) {
+	return nil, fmt.Errorf("TUN/TAP devices are not supported on this platform")
+}
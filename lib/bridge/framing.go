@@ -0,0 +1,284 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// FramingRaw sends interface packets as raw radio payloads
+	FramingRaw = "raw"
+	// FramingLowpan sends IPv6 packets as 802.15.4 frames using 6LoWPAN encapsulation and fragmentation
+	FramingLowpan = "lowpan"
+)
+
+// Framer converts between interface packets and radio frames
+type Framer interface {
+	// Encode converts a packet to one or more radio frames
+	Encode(packet []byte) ([][]byte, error)
+	// Decode converts a radio frame to a packet, returning nil until fragmented packets are complete
+	Decode(frame []byte) ([]byte, error)
+}
+
+// NewFramer creates a framer by name
+func NewFramer(framing string, pan uint16, address uint64) (Framer, error) {
+	switch framing {
+	case FramingRaw:
+		return &RawFramer{}, nil
+	case FramingLowpan:
+		return NewLowpanFramer(pan, address), nil
+	default:
+		return nil, fmt.Errorf("unrecognised framing '%s'", framing)
+	}
+}
+
+// RawFramer passes packets directly as radio payloads
+type RawFramer struct{}
+
+// Encode returns the packet as a single frame
+func (f *RawFramer) Encode(packet []byte) ([][]byte, error) {
+	return [][]byte{packet}, nil
+}
+
+// Decode returns the frame as a packet
+func (f *RawFramer) Decode(frame []byte) ([]byte, error) {
+	return frame, nil
+}
+
+const (
+	// MaxFrameLength is the maximum 802.15.4 frame length (including the FCS)
+	MaxFrameLength = 127
+
+	fcsLength    = 2
+	headerLength = 15
+
+	// Frame control fields (data frame, PAN ID compression, short destination and extended source addresses)
+	fcfTypeData       = 0x0001
+	fcfPANCompression = 0x0040
+	fcfAddrModeShort  = 0x02
+	fcfAddrModeExt    = 0x03
+
+	// BroadcastAddress is the 802.15.4 short broadcast address
+	BroadcastAddress = 0xFFFF
+
+	// 6LoWPAN dispatch values (RFC 4944)
+	dispatchIPv6      = 0x41
+	dispatchFrag1     = 0xC0
+	dispatchFragN     = 0xE0
+	dispatchFragMask  = 0xF8
+	frag1HeaderLength = 4
+	fragNHeaderLength = 5
+
+	// ReassemblyTimeout is the time after which incomplete fragmented packets are discarded
+	ReassemblyTimeout = 60 * time.Second
+)
+
+// reassembly is a fragmented packet being reassembled
+type reassembly struct {
+	data     []byte
+	received int
+	started  time.Time
+}
+
+// LowpanFramer encapsulates IPv6 packets in 802.15.4 frames using the 6LoWPAN uncompressed IPv6 dispatch,
+// fragmenting packets that exceed the frame length (RFC 4944)
+// Frames are broadcast, with packets filtered by address by the receiving network stack
+type LowpanFramer struct {
+	pan     uint16
+	address uint64
+
+	mu      sync.Mutex
+	seq     uint8
+	tag     uint16
+	pending map[string]*reassembly
+}
+
+// NewLowpanFramer creates a 6LoWPAN framer with the provided PAN ID and extended source address
+func NewLowpanFramer(pan uint16, address uint64) *LowpanFramer {
+	return &LowpanFramer{
+		pan:     pan,
+		address: address,
+		pending: make(map[string]*reassembly),
+	}
+}
+
+// header builds an 802.15.4 MAC header
+func (f *LowpanFramer) header() []byte {
+	fcf := uint16(fcfTypeData | fcfPANCompression | fcfAddrModeShort<<10 | fcfAddrModeExt<<14)
+
+	h := make([]byte, headerLength)
+	binary.LittleEndian.PutUint16(h[0:], fcf)
+	h[2] = f.seq
+	binary.LittleEndian.PutUint16(h[3:], f.pan)
+	binary.LittleEndian.PutUint16(h[5:], BroadcastAddress)
+	binary.LittleEndian.PutUint64(h[7:], f.address)
+
+	f.seq++
+
+	return h
+}
+
+// Encode encapsulates an IPv6 packet in one or more 802.15.4 frames
+func (f *LowpanFramer) Encode(packet []byte) ([][]byte, error) {
+	if len(packet) == 0 || packet[0]>>4 != 6 {
+		return nil, fmt.Errorf("6LoWPAN framing supports IPv6 packets only")
+	}
+	if len(packet) > 0x7FF {
+		return nil, fmt.Errorf("packet length %d exceeds maximum 6LoWPAN datagram size", len(packet))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payloadLength := MaxFrameLength - fcsLength - headerLength
+
+	// Send unfragmented packets where possible
+	if len(packet)+1 <= payloadLength {
+		frame := append(f.header(), dispatchIPv6)
+		return [][]byte{append(frame, packet...)}, nil
+	}
+
+	// Fragment payloads must be multiples of 8 octets (excluding the final fragment)
+	size := uint16(len(packet))
+	tag := f.tag
+	f.tag++
+
+	frames := make([][]byte, 0)
+	for offset := 0; offset < len(packet); {
+		frame := f.header()
+
+		var available int
+		if offset == 0 {
+			frame = append(frame, dispatchFrag1|byte(size>>8), byte(size), byte(tag>>8), byte(tag), dispatchIPv6)
+			available = payloadLength - frag1HeaderLength - 1
+		} else {
+			frame = append(frame, dispatchFragN|byte(size>>8), byte(size), byte(tag>>8), byte(tag), byte(offset/8))
+			available = payloadLength - fragNHeaderLength
+		}
+
+		length := len(packet) - offset
+		if length > available {
+			length = available &^ 0x07
+		}
+
+		frames = append(frames, append(frame, packet[offset:offset+length]...))
+		offset += length
+	}
+
+	return frames, nil
+}
+
+// Decode parses an 802.15.4 frame and returns the encapsulated IPv6 packet once complete
+func (f *LowpanFramer) Decode(frame []byte) ([]byte, error) {
+	source, payload, err := parseHeader(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty 6LoWPAN payload")
+	}
+
+	switch {
+	case payload[0] == dispatchIPv6:
+		return payload[1:], nil
+
+	case payload[0]&dispatchFragMask == dispatchFrag1:
+		if len(payload) < frag1HeaderLength+1 || payload[frag1HeaderLength] != dispatchIPv6 {
+			return nil, fmt.Errorf("unsupported 6LoWPAN fragment encoding")
+		}
+		return f.reassemble(source, payload, 0, payload[frag1HeaderLength+1:])
+
+	case payload[0]&dispatchFragMask == dispatchFragN:
+		if len(payload) < fragNHeaderLength {
+			return nil, fmt.Errorf("invalid 6LoWPAN fragment")
+		}
+		return f.reassemble(source, payload, int(payload[4])*8, payload[fragNHeaderLength:])
+
+	default:
+		return nil, fmt.Errorf("unsupported 6LoWPAN dispatch (0x%.2x)", payload[0])
+	}
+}
+
+// reassemble adds a fragment to a pending packet, returning the packet once all fragments are received
+func (f *LowpanFramer) reassemble(source []byte, header []byte, offset int, data []byte) ([]byte, error) {
+	size := int(header[0]&0x07)<<8 | int(header[1])
+	key := fmt.Sprintf("%x-%d-%d", source, size, binary.BigEndian.Uint16(header[2:]))
+
+	if offset+len(data) > size {
+		return nil, fmt.Errorf("6LoWPAN fragment exceeds datagram size")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Discard stale packets
+	now := time.Now()
+	for k, r := range f.pending {
+		if now.Sub(r.started) > ReassemblyTimeout {
+			delete(f.pending, k)
+		}
+	}
+
+	r, ok := f.pending[key]
+	if !ok {
+		r = &reassembly{data: make([]byte, size), started: now}
+		f.pending[key] = r
+	}
+
+	copy(r.data[offset:], data)
+	r.received += len(data)
+
+	if r.received < size {
+		return nil, nil
+	}
+
+	delete(f.pending, key)
+	return r.data, nil
+}
+
+// parseHeader parses an 802.15.4 data frame header, returning the source address and the frame payload
+func parseHeader(frame []byte) ([]byte, []byte, error) {
+	if len(frame) < 3 {
+		return nil, nil, fmt.Errorf("frame too short")
+	}
+
+	fcf := binary.LittleEndian.Uint16(frame)
+	if fcf&0x07 != fcfTypeData {
+		return nil, nil, fmt.Errorf("unsupported frame type (%d)", fcf&0x07)
+	}
+	if fcf&0x08 != 0 {
+		return nil, nil, fmt.Errorf("secured frames are not supported")
+	}
+
+	addrLength := func(mode uint16) int {
+		switch mode {
+		case fcfAddrModeShort:
+			return 2
+		case fcfAddrModeExt:
+			return 8
+		default:
+			return 0
+		}
+	}
+	dstMode, srcMode := (fcf>>10)&0x03, (fcf>>14)&0x03
+
+	offset := 3
+	if dstMode != 0 {
+		offset += 2 + addrLength(dstMode)
+	}
+	if srcMode != 0 {
+		if fcf&fcfPANCompression == 0 {
+			offset += 2
+		}
+		offset += addrLength(srcMode)
+	}
+
+	if len(frame) < offset {
+		return nil, nil, fmt.Errorf("frame too short")
+	}
+
+	source := frame[offset-addrLength(srcMode) : offset]
+	return source, frame[offset:], nil
+}
@@ -0,0 +1,548 @@
+/**
+ * YAWNS Go Client Library
+ * Implements the node side of the simulator protocol in go, allowing go applications to connect to
+ * the simulator as nodes without requiring libyawns.
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/ryankurte/yawns/lib/connector"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/protocol"
+)
+
+// HeartbeatInterval is the default interval at which heartbeats are sent to the simulator
+// Shorter intervals are used where required by the simulator heartbeat timeout
+const HeartbeatInterval = time.Second
+
+// transport is a message transport between a client and the simulator
+type transport interface {
+	Send(data []byte) error
+	Recv() <-chan []byte
+	Close()
+}
+
+// newTransport creates a transport to the simulator, with the backend selected by the address URI scheme
+// This matches the connector backends (see lib/connector)
+func newTransport(address string) (transport, error) {
+	scheme := ""
+	if i := strings.Index(address, "://"); i > 0 {
+		scheme = address[:i]
+	}
+
+	switch scheme {
+	case "tcp", "ipc":
+		return newZMQTransport(address)
+	case "stream+tcp":
+		return newStreamTransport("tcp", strings.TrimPrefix(address, "stream+tcp://"))
+	case "unix":
+		return newStreamTransport("unix", strings.TrimPrefix(address, "unix://"))
+	case "loopback":
+		conn, err := connector.DialLoopback(strings.TrimPrefix(address, "loopback://"))
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	default:
+		return nil, fmt.Errorf("unsupported simulator address '%s'", address)
+	}
+}
+
+// streamTransport is a length-prefixed protobuf transport over stream sockets
+type streamTransport struct {
+	conn net.Conn
+	mu   sync.Mutex
+	recv chan []byte
+}
+
+func newStreamTransport(network, address string) (transport, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	t := streamTransport{
+		conn: conn,
+		recv: make(chan []byte, 1024),
+	}
+
+	go func() {
+		for {
+			data, err := connector.ReadFrame(conn)
+			if err != nil {
+				close(t.recv)
+				return
+			}
+			t.recv <- data
+		}
+	}()
+
+	return &t, nil
+}
+
+func (t *streamTransport) Send(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return connector.WriteFrame(t.conn, data)
+}
+
+func (t *streamTransport) Recv() <-chan []byte {
+	return t.recv
+}
+
+func (t *streamTransport) Close() {
+	t.conn.Close()
+}
+
+// Client is a simulator node client
+type Client struct {
+	address   string
+	transport transport
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	radios  map[radioKey]*Radio
+	fields  map[string][]chan string
+	sensors map[string][]chan sensorReading
+	err     error
+
+	onField   func(name, data string)
+	onCommand func(name, data string)
+
+	registered   chan struct{}
+	registration *Registration
+
+	// heartbeat is only accessed by the run loop
+	heartbeat *time.Ticker
+}
+
+// Registration is the simulator response to client registration
+type Registration struct {
+	Version          uint32              // Simulator protocol version
+	Features         []string            // Protocol features supported by the simulator
+	HeartbeatTimeout time.Duration       // Simulator heartbeat timeout, zero if disabled
+	Bands            []messages.BandInfo // Configured simulation bands
+}
+
+// Band fetches information for a simulation band by name
+func (r *Registration) Band(name string) (messages.BandInfo, bool) {
+	for _, b := range r.Bands {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return messages.BandInfo{}, false
+}
+
+// NewClient connects to the simulator at the provided server address and registers as a node with the
+// provided address. The client is closed when the provided context is cancelled, or if the simulator rejects
+// the registration (see Registration).
+func NewClient(ctx context.Context, serverAddress, address string) (*Client, error) {
+	t, err := newTransport(serverAddress)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, t, address)
+}
+
+func newClient(ctx context.Context, t transport, address string) (*Client, error) {
+	c := Client{
+		address:   address,
+		transport: t,
+		radios:    make(map[radioKey]*Radio),
+		fields:    make(map[string][]chan string),
+		sensors:   make(map[string][]chan sensorReading),
+
+		registered: make(chan struct{}),
+		heartbeat:  time.NewTicker(HeartbeatInterval),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.run()
+
+	err := c.send(&protocol.Base{Message: &protocol.Base_Register{
+		Register: &protocol.Register{Address: address, Version: connector.ProtocolVersion},
+	}})
+	if err != nil {
+		c.cancel()
+		t.Close()
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Address fetches the node address of the client
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Registration awaits and returns the simulator registration acknowledgement
+// This returns an error if the simulator rejected the registration
+func (c *Client) Registration(ctx context.Context) (*Registration, error) {
+	select {
+	case <-c.registered:
+		return c.registration, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, c.closedError()
+	}
+}
+
+// closedError fetches the error that caused the client to close
+func (c *Client) closedError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	return fmt.Errorf("client closed")
+}
+
+// Close deregisters the client from the simulator and closes the connection
+func (c *Client) Close() error {
+	err := c.send(&protocol.Base{Message: &protocol.Base_Deregister{
+		Deregister: &protocol.Deregister{Address: c.address},
+	}})
+	c.cancel()
+	c.transport.Close()
+	return err
+}
+
+// Radio creates a radio on the provided band, IDs distinguish radios on the same band
+func (c *Client) Radio(band string, id uint32) (*Radio, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := radioKey{band, id}
+	if _, ok := c.radios[key]; ok {
+		return nil, fmt.Errorf("radio %d already exists for band %s", id, band)
+	}
+
+	r := newRadio(c, band, id)
+	c.radios[key] = r
+
+	return r, nil
+}
+
+// CloseRadio removes a radio from the client
+func (c *Client) CloseRadio(r *Radio) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.radios, radioKey{r.Band, r.ID})
+}
+
+// OnField sets a callback for field values pushed to the node by the simulator
+func (c *Client) OnField(cb func(name, data string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onField = cb
+}
+
+// OnCommand sets a callback for commands sent to the node by the simulator
+func (c *Client) OnCommand(cb func(name, data string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onCommand = cb
+}
+
+// Event sends an event to be logged by the simulator
+func (c *Client) Event(data string) error {
+	return c.send(&protocol.Base{Message: &protocol.Base_Event{
+		Event: &protocol.Event{Data: data},
+	}})
+}
+
+// SetField sets a field value in the simulator
+func (c *Client) SetField(name, data string) error {
+	return c.send(&protocol.Base{Message: &protocol.Base_FieldSet{
+		FieldSet: &protocol.FieldSet{Name: name, Data: data},
+	}})
+}
+
+// GetField requests a field value from the simulator
+func (c *Client) GetField(ctx context.Context, name string) (string, error) {
+	ch := make(chan string, 1)
+
+	c.mu.Lock()
+	c.fields[name] = append(c.fields[name], ch)
+	c.mu.Unlock()
+
+	err := c.send(&protocol.Base{Message: &protocol.Base_FieldReq{
+		FieldReq: &protocol.FieldReq{Name: name},
+	}})
+	if err != nil {
+		c.removeFieldWaiter(name, ch)
+		return "", err
+	}
+
+	select {
+	case data := <-ch:
+		return data, nil
+	case <-ctx.Done():
+		c.removeFieldWaiter(name, ch)
+		return "", ctx.Err()
+	case <-c.ctx.Done():
+		return "", c.closedError()
+	}
+}
+
+func (c *Client) removeFieldWaiter(name string, ch chan string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiters := c.fields[name]
+	for i, w := range waiters {
+		if w == ch {
+			c.fields[name] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+}
+
+// sensorReading is a virtual sensor response
+type sensorReading struct {
+	value float64
+	err   error
+}
+
+// ReadSensor requests a reading from a virtual sensor in the simulator
+func (c *Client) ReadSensor(ctx context.Context, name string) (float64, error) {
+	ch := make(chan sensorReading, 1)
+
+	c.mu.Lock()
+	c.sensors[name] = append(c.sensors[name], ch)
+	c.mu.Unlock()
+
+	err := c.send(&protocol.Base{Message: &protocol.Base_SensorReq{
+		SensorReq: &protocol.SensorReq{Name: name},
+	}})
+	if err != nil {
+		c.removeSensorWaiter(name, ch)
+		return 0, err
+	}
+
+	select {
+	case r := <-ch:
+		return r.value, r.err
+	case <-ctx.Done():
+		c.removeSensorWaiter(name, ch)
+		return 0, ctx.Err()
+	case <-c.ctx.Done():
+		return 0, c.closedError()
+	}
+}
+
+func (c *Client) removeSensorWaiter(name string, ch chan sensorReading) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiters := c.sensors[name]
+	for i, w := range waiters {
+		if w == ch {
+			c.sensors[name] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+}
+
+// send encodes and sends a protocol message
+func (c *Client) send(base *protocol.Base) error {
+	if c.ctx.Err() != nil {
+		return c.closedError()
+	}
+
+	data, err := proto.Marshal(base)
+	if err != nil {
+		return err
+	}
+
+	return c.transport.Send(data)
+}
+
+// run receives and handles messages from the simulator and sends heartbeats until the client is closed
+func (c *Client) run() {
+	defer func() { c.heartbeat.Stop() }()
+
+	for {
+		select {
+		case data, ok := <-c.transport.Recv():
+			if !ok {
+				c.cancel()
+				return
+			}
+			if err := c.handleIncoming(data); err != nil {
+				log.Printf("[WARNING] Client %s: %s", c.address, err)
+			}
+		case <-c.heartbeat.C:
+			err := c.send(&protocol.Base{Message: &protocol.Base_Heartbeat{
+				Heartbeat: &protocol.Heartbeat{},
+			}})
+			if err != nil {
+				log.Printf("[WARNING] Client %s: heartbeat error: %s", c.address, err)
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// getRadio fetches a radio by band and ID
+func (c *Client) getRadio(info *protocol.RFInfo) (*Radio, error) {
+	if info == nil {
+		return nil, fmt.Errorf("missing RF information")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.radios[radioKey{info.Band, info.Radio}]
+	if !ok {
+		return nil, fmt.Errorf("no radio %d found for band %s", info.Radio, info.Band)
+	}
+	return r, nil
+}
+
+// handleIncoming handles incoming messages from the simulator
+func (c *Client) handleIncoming(data []byte) error {
+	message := protocol.Base{}
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return fmt.Errorf("error parsing protobuf message (%s)", err)
+	}
+
+	switch m := message.GetMessage().(type) {
+	case *protocol.Base_Packet:
+		r, err := c.getRadio(m.Packet.Info)
+		if err != nil {
+			return err
+		}
+		r.handlePacket(newPacket(m.Packet))
+
+	case *protocol.Base_SendComplete:
+		r, err := c.getRadio(m.SendComplete.Info)
+		if err != nil {
+			return err
+		}
+		r.handleSendComplete()
+
+	case *protocol.Base_RssiResp:
+		r, err := c.getRadio(m.RssiResp.Info)
+		if err != nil {
+			return err
+		}
+		r.handleRSSI(m.RssiResp.Rssi)
+
+	case *protocol.Base_StateResp:
+		r, err := c.getRadio(m.StateResp.Info)
+		if err != nil {
+			return err
+		}
+		r.handleState(stateFromProtocol(m.StateResp.State))
+
+	case *protocol.Base_RegisterAck:
+		ack := m.RegisterAck
+		if !ack.Accepted {
+			c.mu.Lock()
+			c.err = fmt.Errorf("registration rejected: %s", ack.Reason)
+			c.mu.Unlock()
+			c.cancel()
+			return c.err
+		}
+
+		r := Registration{
+			Version:          ack.Version,
+			Features:         ack.Features,
+			HeartbeatTimeout: time.Duration(ack.HeartbeatTimeout) * time.Millisecond,
+		}
+		for _, b := range ack.Bands {
+			r.Bands = append(r.Bands, messages.BandInfo{
+				Name:           b.Name,
+				Frequency:      b.Frequency,
+				Baud:           b.Baud,
+				Channels:       b.Channels,
+				ChannelSpacing: b.ChannelSpacing,
+			})
+		}
+
+		// Send heartbeats within the simulator timeout
+		if r.HeartbeatTimeout > 0 && r.HeartbeatTimeout/3 < HeartbeatInterval {
+			c.heartbeat.Stop()
+			c.heartbeat = time.NewTicker(r.HeartbeatTimeout / 3)
+		}
+
+		select {
+		case <-c.registered:
+		default:
+			c.registration = &r
+			close(c.registered)
+		}
+
+	case *protocol.Base_FieldResp:
+		c.mu.Lock()
+		waiters := c.fields[m.FieldResp.Name]
+		delete(c.fields, m.FieldResp.Name)
+		c.mu.Unlock()
+
+		for _, w := range waiters {
+			w <- m.FieldResp.Data
+		}
+
+	case *protocol.Base_SensorResp:
+		c.mu.Lock()
+		waiters := c.sensors[m.SensorResp.Name]
+		delete(c.sensors, m.SensorResp.Name)
+		c.mu.Unlock()
+
+		r := sensorReading{value: m.SensorResp.Value}
+		if m.SensorResp.Error != "" {
+			r.err = fmt.Errorf("sensor %s: %s", m.SensorResp.Name, m.SensorResp.Error)
+		}
+		for _, w := range waiters {
+			w <- r
+		}
+
+	case *protocol.Base_FieldSet:
+		c.mu.Lock()
+		cb := c.onField
+		c.mu.Unlock()
+
+		if cb == nil {
+			return fmt.Errorf("no field callback set for field %s", m.FieldSet.Name)
+		}
+		cb(m.FieldSet.Name, m.FieldSet.Data)
+
+	case *protocol.Base_Command:
+		c.mu.Lock()
+		cb := c.onCommand
+		c.mu.Unlock()
+
+		if cb == nil {
+			return fmt.Errorf("no command callback set for command %s", m.Command.Name)
+		}
+		cb(m.Command.Name, m.Command.Data)
+
+	default:
+		return fmt.Errorf("unhandled message type (%T)", m)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/connector"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/protocol"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// testTransport loops client messages back to the test in place of the simulator
+type testTransport struct {
+	sent chan []byte
+	recv chan []byte
+}
+
+func newTestTransport() *testTransport {
+	return &testTransport{make(chan []byte, 16), make(chan []byte, 16)}
+}
+
+func (t *testTransport) Send(data []byte) error {
+	t.sent <- data
+	return nil
+}
+
+func (t *testTransport) Recv() <-chan []byte { return t.recv }
+
+func (t *testTransport) Close() {}
+
+func (t *testTransport) get(tt *testing.T) *protocol.Base {
+	select {
+	case data := <-t.sent:
+		base := protocol.Base{}
+		assert.Nil(tt, proto.Unmarshal(data, &base))
+		return &base
+	case <-time.After(time.Second):
+		tt.Fatalf("Timeout awaiting client message")
+	}
+	return nil
+}
+
+func (t *testTransport) put(base *protocol.Base) {
+	data, _ := proto.Marshal(base)
+	t.recv <- data
+}
+
+func TestClient(t *testing.T) {
+	tr := newTestTransport()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := newClient(ctx, tr, "0x0001")
+	assert.Nil(t, err)
+
+	t.Run("Registers with the simulator", func(t *testing.T) {
+		m := tr.get(t)
+		assert.EqualValues(t, "0x0001", m.GetRegister().Address)
+		assert.EqualValues(t, connector.ProtocolVersion, m.GetRegister().Version)
+	})
+
+	t.Run("Receives registration acknowledgement", func(t *testing.T) {
+		tr.put(&protocol.Base{Message: &protocol.Base_RegisterAck{RegisterAck: &protocol.RegisterAck{
+			Accepted:         true,
+			Version:          connector.ProtocolVersion,
+			HeartbeatTimeout: 60000,
+			Bands:            []*protocol.BandInfo{{Name: "Sub1GHz", Frequency: 433e6, Baud: 10e3, Channels: 8}},
+		}}})
+
+		reg, err := c.Registration(ctx)
+		assert.Nil(t, err)
+		assert.EqualValues(t, time.Minute, reg.HeartbeatTimeout)
+
+		band, ok := reg.Band("Sub1GHz")
+		assert.True(t, ok)
+		assert.EqualValues(t, messages.BandInfo{Name: "Sub1GHz", Frequency: 433e6, Baud: 10e3, Channels: 8}, band)
+	})
+
+	r, err := c.Radio("Sub1GHz", 1)
+	assert.Nil(t, err)
+
+	t.Run("Rejects duplicate radios", func(t *testing.T) {
+		_, err := c.Radio("Sub1GHz", 1)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Sends packets and awaits completion", func(t *testing.T) {
+		done := make(chan error)
+		go func() { done <- r.Send(ctx, 2, []byte("test data")) }()
+
+		m := tr.get(t)
+		assert.EqualValues(t, []byte("test data"), m.GetPacket().Data)
+		assert.EqualValues(t, &protocol.RFInfo{Band: "Sub1GHz", Radio: 1, Channel: 2}, m.GetPacket().Info)
+
+		tr.put(&protocol.Base{Message: &protocol.Base_SendComplete{
+			SendComplete: &protocol.SendComplete{Info: &protocol.RFInfo{Band: "Sub1GHz", Radio: 1}},
+		}})
+		assert.Nil(t, <-done)
+	})
+
+	t.Run("Receives packets with metadata", func(t *testing.T) {
+		assert.Nil(t, r.StartReceive(3))
+		m := tr.get(t)
+		assert.EqualValues(t, protocol.RFState_RECEIVE, m.GetStateSet().State)
+		assert.EqualValues(t, 3, m.GetStateSet().Info.Channel)
+
+		tr.put(&protocol.Base{Message: &protocol.Base_Packet{Packet: &protocol.Packet{
+			Info: &protocol.RFInfo{Band: "Sub1GHz", Radio: 1, Channel: 3, Rssi: -60, Snr: 20, Lqi: 170, StartTime: 1000, EndTime: 3000},
+			Data: []byte("test data"),
+		}}})
+
+		select {
+		case p := <-r.Packets:
+			assert.EqualValues(t, Packet{Channel: 3, Data: []byte("test data"), RSSI: -60, SNR: 20, LQI: 170,
+				StartTime: time.Millisecond, EndTime: 3 * time.Millisecond}, p)
+		case <-time.After(time.Second):
+			t.Errorf("Timeout awaiting packet")
+		}
+
+		received := make(chan Packet, 1)
+		r.OnReceive(func(p Packet) { received <- p })
+		tr.put(&protocol.Base{Message: &protocol.Base_Packet{Packet: &protocol.Packet{
+			Info: &protocol.RFInfo{Band: "Sub1GHz", Radio: 1},
+			Data: []byte("callback data"),
+		}}})
+
+		select {
+		case p := <-received:
+			assert.EqualValues(t, []byte("callback data"), p.Data)
+		case <-time.After(time.Second):
+			t.Errorf("Timeout awaiting packet callback")
+		}
+	})
+
+	t.Run("Requests RSSI and state", func(t *testing.T) {
+		go func() {
+			m := tr.get(t)
+			tr.put(&protocol.Base{Message: &protocol.Base_RssiResp{
+				RssiResp: &protocol.RSSIResp{Info: m.GetRssiReq().Info, Rssi: -75},
+			}})
+		}()
+		rssi, err := r.RSSI(ctx, 3)
+		assert.Nil(t, err)
+		assert.EqualValues(t, -75, rssi)
+
+		go func() {
+			m := tr.get(t)
+			tr.put(&protocol.Base{Message: &protocol.Base_StateResp{
+				StateResp: &protocol.StateResp{Info: m.GetStateReq().Info, State: protocol.RFState_RECEIVING},
+			}})
+		}()
+		state, err := r.State(ctx)
+		assert.Nil(t, err)
+		assert.EqualValues(t, types.TransceiverStateReceiving, state)
+
+		timeout, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancelTimeout()
+		_, err = r.RSSI(timeout, 3)
+		assert.NotNil(t, err)
+		tr.get(t)
+	})
+
+	t.Run("Sets and fetches fields", func(t *testing.T) {
+		assert.Nil(t, c.SetField("temperature", "21.5"))
+		m := tr.get(t)
+		assert.EqualValues(t, &protocol.FieldSet{Name: "temperature", Data: "21.5"}, m.GetFieldSet())
+
+		go func() {
+			m := tr.get(t)
+			tr.put(&protocol.Base{Message: &protocol.Base_FieldResp{
+				FieldResp: &protocol.FieldResp{Name: m.GetFieldReq().Name, Data: "21.5"},
+			}})
+		}()
+		data, err := c.GetField(ctx, "temperature")
+		assert.Nil(t, err)
+		assert.EqualValues(t, "21.5", data)
+	})
+
+	t.Run("Receives fields and commands", func(t *testing.T) {
+		received := make(chan [2]string, 2)
+		c.OnField(func(name, data string) { received <- [2]string{name, data} })
+		c.OnCommand(func(name, data string) { received <- [2]string{name, data} })
+
+		tr.put(&protocol.Base{Message: &protocol.Base_FieldSet{FieldSet: &protocol.FieldSet{Name: "setpoint", Data: "20"}}})
+		tr.put(&protocol.Base{Message: &protocol.Base_Command{Command: &protocol.Command{Name: "button", Data: "pressed"}}})
+
+		for _, expected := range [][2]string{{"setpoint", "20"}, {"button", "pressed"}} {
+			select {
+			case r := <-received:
+				assert.EqualValues(t, expected, r)
+			case <-time.After(time.Second):
+				t.Fatalf("Timeout awaiting callback")
+			}
+		}
+	})
+
+	t.Run("Reads sensors", func(t *testing.T) {
+		go func() {
+			m := tr.get(t)
+			tr.put(&protocol.Base{Message: &protocol.Base_SensorResp{
+				SensorResp: &protocol.SensorResp{Name: m.GetSensorReq().Name, Value: 21.5},
+			}})
+			m = tr.get(t)
+			tr.put(&protocol.Base{Message: &protocol.Base_SensorResp{
+				SensorResp: &protocol.SensorResp{Name: m.GetSensorReq().Name, Error: "no sensor found"},
+			}})
+		}()
+
+		value, err := c.ReadSensor(ctx, "temperature")
+		assert.Nil(t, err)
+		assert.EqualValues(t, 21.5, value)
+
+		_, err = c.ReadSensor(ctx, "humidity")
+		assert.EqualError(t, err, "sensor humidity: no sensor found")
+	})
+
+	t.Run("Sends events", func(t *testing.T) {
+		assert.Nil(t, c.Event("joined"))
+		m := tr.get(t)
+		assert.EqualValues(t, "joined", m.GetEvent().Data)
+	})
+
+	t.Run("Deregisters on close", func(t *testing.T) {
+		assert.Nil(t, c.Close())
+		m := tr.get(t)
+		assert.EqualValues(t, "0x0001", m.GetDeregister().Address)
+
+		assert.NotNil(t, c.Event("closed"))
+	})
+}
+
+func TestClientRejected(t *testing.T) {
+	tr := newTestTransport()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := newClient(ctx, tr, "0x0001")
+	assert.Nil(t, err)
+	tr.get(t)
+
+	tr.put(&protocol.Base{Message: &protocol.Base_RegisterAck{RegisterAck: &protocol.RegisterAck{
+		Accepted: false,
+		Reason:   "protocol version mismatch",
+	}}})
+
+	_, err = c.Registration(ctx)
+	assert.EqualError(t, err, "registration rejected: protocol version mismatch")
+	assert.EqualError(t, c.Event("rejected"), "registration rejected: protocol version mismatch")
+}
+
+func TestClientConnector(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	getOutput := func(t *testing.T, c connector.Connector) interface{} {
+		select {
+		case m := <-c.Output():
+			return m
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout awaiting connector output")
+		}
+		return nil
+	}
+
+	for _, address := range []string{"loopback://client-test", "stream+tcp://127.0.0.1:10119"} {
+		t.Run("Connects to "+address, func(t *testing.T) {
+			server, err := connector.NewConnector(address)
+			assert.Nil(t, err)
+			defer server.Exit()
+
+			c, err := NewClient(ctx, address, "0x0001")
+			assert.Nil(t, err)
+			defer c.Close()
+
+			assert.EqualValues(t, messages.Register{BaseMessage: messages.BaseMessage{Address: "0x0001"}, Version: connector.ProtocolVersion}, getOutput(t, server))
+
+			r, err := c.Radio("Sub1GHz", 0)
+			assert.Nil(t, err)
+
+			server.Input() <- messages.NewPacket("0x0001", []byte("test data"), messages.NewRFInfo("Sub1GHz", 1))
+			select {
+			case p := <-r.Packets:
+				assert.EqualValues(t, []byte("test data"), p.Data)
+			case <-time.After(time.Second):
+				t.Errorf("Timeout awaiting packet")
+			}
+		})
+	}
+
+	t.Run("Rejects unsupported addresses", func(t *testing.T) {
+		_, err := NewClient(ctx, "carrier-pigeon://coop", "0x0001")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,278 @@
+/**
+ * YAWNS Go Client Library
+ * Virtual radio implementation
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/protocol"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// ReceiveBufferLength is the number of received packets buffered by each radio
+const ReceiveBufferLength = 64
+
+// radioKey identifies a radio on a client
+type radioKey struct {
+	Band string
+	ID   uint32
+}
+
+// Packet is a packet received by a radio
+type Packet struct {
+	Channel   int32
+	Data      []byte
+	RSSI      float32       // Received signal strength in dBm
+	SNR       float32       // Signal to noise ratio in dB
+	LQI       uint8         // Link quality indicator derived from SINR
+	StartTime time.Duration // Simulated transmission start time
+	EndTime   time.Duration // Simulated transmission end time
+}
+
+func newPacket(p *protocol.Packet) Packet {
+	packet := Packet{Data: p.Data}
+	if p.Info != nil {
+		packet.Channel = p.Info.Channel
+		packet.RSSI = p.Info.Rssi
+		packet.SNR = p.Info.Snr
+		packet.LQI = uint8(p.Info.Lqi)
+		packet.StartTime = time.Duration(p.Info.StartTime) * time.Microsecond
+		packet.EndTime = time.Duration(p.Info.EndTime) * time.Microsecond
+	}
+	return packet
+}
+
+// Radio is a virtual radio attached to a client
+type Radio struct {
+	Band string
+	ID   uint32
+
+	// Packets receives packets not handled by a receive callback
+	Packets chan Packet
+
+	client *Client
+
+	cbMu           sync.Mutex
+	onReceive      func(Packet)
+	onSendComplete func()
+
+	// Request mutexes serialise requests awaiting responses
+	sendMu   sync.Mutex
+	sendDone chan struct{}
+	rssiMu   sync.Mutex
+	rssiCh   chan float32
+	stateMu  sync.Mutex
+	stateCh  chan types.TransceiverState
+}
+
+func newRadio(c *Client, band string, id uint32) *Radio {
+	return &Radio{
+		Band:     band,
+		ID:       id,
+		Packets:  make(chan Packet, ReceiveBufferLength),
+		client:   c,
+		sendDone: make(chan struct{}, 1),
+		rssiCh:   make(chan float32, 1),
+		stateCh:  make(chan types.TransceiverState, 1),
+	}
+}
+
+// OnReceive sets a callback for received packets, replacing delivery via the Packets channel
+func (r *Radio) OnReceive(cb func(Packet)) {
+	r.cbMu.Lock()
+	defer r.cbMu.Unlock()
+	r.onReceive = cb
+}
+
+// OnSendComplete sets a callback for send completion
+func (r *Radio) OnSendComplete(cb func()) {
+	r.cbMu.Lock()
+	defer r.cbMu.Unlock()
+	r.onSendComplete = cb
+}
+
+func (r *Radio) info(channel int32) *protocol.RFInfo {
+	return &protocol.RFInfo{Band: r.Band, Radio: r.ID, Channel: channel}
+}
+
+// Send sends a packet on the provided channel, blocking until the send is completed by the simulator
+func (r *Radio) Send(ctx context.Context, channel int32, data []byte) error {
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+
+	if err := r.SendAsync(channel, data); err != nil {
+		return err
+	}
+
+	return r.await(ctx, r.sendDone)
+}
+
+// SendAsync sends a packet on the provided channel without waiting for completion
+func (r *Radio) SendAsync(channel int32, data []byte) error {
+	select {
+	case <-r.sendDone:
+	default:
+	}
+
+	return r.client.send(&protocol.Base{Message: &protocol.Base_Packet{
+		Packet: &protocol.Packet{Info: r.info(channel), Data: data},
+	}})
+}
+
+// StartReceive puts the radio into receive mode on the provided channel
+func (r *Radio) StartReceive(channel int32) error {
+	return r.setState(channel, protocol.RFState_RECEIVE)
+}
+
+// Idle puts the radio into idle mode
+func (r *Radio) Idle() error {
+	return r.setState(0, protocol.RFState_IDLE)
+}
+
+// Sleep puts the radio into sleep mode
+func (r *Radio) Sleep() error {
+	return r.setState(0, protocol.RFState_SLEEP)
+}
+
+func (r *Radio) setState(channel int32, state protocol.RFState) error {
+	return r.client.send(&protocol.Base{Message: &protocol.Base_StateSet{
+		StateSet: &protocol.StateSet{Info: r.info(channel), State: state},
+	}})
+}
+
+// RSSI requests the current RSSI on the provided channel
+func (r *Radio) RSSI(ctx context.Context, channel int32) (float32, error) {
+	r.rssiMu.Lock()
+	defer r.rssiMu.Unlock()
+
+	select {
+	case <-r.rssiCh:
+	default:
+	}
+
+	err := r.client.send(&protocol.Base{Message: &protocol.Base_RssiReq{
+		RssiReq: &protocol.RSSIReq{Info: r.info(channel)},
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case rssi := <-r.rssiCh:
+		return rssi, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.client.ctx.Done():
+		return 0, r.client.closedError()
+	}
+}
+
+// State requests the current radio state
+func (r *Radio) State(ctx context.Context) (types.TransceiverState, error) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+
+	select {
+	case <-r.stateCh:
+	default:
+	}
+
+	err := r.client.send(&protocol.Base{Message: &protocol.Base_StateReq{
+		StateReq: &protocol.StateReq{Info: r.info(0)},
+	}})
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case state := <-r.stateCh:
+		return state, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-r.client.ctx.Done():
+		return "", r.client.closedError()
+	}
+}
+
+func (r *Radio) await(ctx context.Context, ch chan struct{}) error {
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.client.ctx.Done():
+		return r.client.closedError()
+	}
+}
+
+func (r *Radio) handlePacket(p Packet) {
+	r.cbMu.Lock()
+	cb := r.onReceive
+	r.cbMu.Unlock()
+
+	if cb != nil {
+		cb(p)
+		return
+	}
+
+	select {
+	case r.Packets <- p:
+	default:
+		// Drop packets when the receive buffer is full, as a real radio would
+	}
+}
+
+func (r *Radio) handleSendComplete() {
+	select {
+	case r.sendDone <- struct{}{}:
+	default:
+	}
+
+	r.cbMu.Lock()
+	cb := r.onSendComplete
+	r.cbMu.Unlock()
+
+	if cb != nil {
+		cb()
+	}
+}
+
+func (r *Radio) handleRSSI(rssi float32) {
+	select {
+	case r.rssiCh <- rssi:
+	default:
+	}
+}
+
+func (r *Radio) handleState(state types.TransceiverState) {
+	select {
+	case r.stateCh <- state:
+	default:
+	}
+}
+
+// stateFromProtocol maps protocol radio states to transceiver states
+func stateFromProtocol(s protocol.RFState) types.TransceiverState {
+	switch s {
+	case protocol.RFState_OFF:
+		return types.TransceiverStateOff
+	case protocol.RFState_RECEIVE:
+		return types.TransceiverStateReceive
+	case protocol.RFState_RECEIVING:
+		return types.TransceiverStateReceiving
+	case protocol.RFState_TRANSMITTING:
+		return types.TransceiverStateTransmitting
+	case protocol.RFState_SLEEP:
+		return types.TransceiverStateSleep
+	default:
+		return types.TransceiverStateIdle
+	}
+}
@@ -0,0 +1,45 @@
+//go:build zmq
+// +build zmq
+
+package client
+
+import (
+	"github.com/zeromq/goczmq"
+)
+
+// zmqTransport is a ZMQ dealer transport, matching the ZMQ router used by the simulator connector
+type zmqTransport struct {
+	ch   *goczmq.Channeler
+	recv chan []byte
+}
+
+func newZMQTransport(serverAddress string) (transport, error) {
+	t := zmqTransport{
+		ch:   goczmq.NewDealerChanneler(serverAddress),
+		recv: make(chan []byte, 1024),
+	}
+
+	go func() {
+		for m := range t.ch.RecvChan {
+			if len(m) > 0 {
+				t.recv <- m[len(m)-1]
+			}
+		}
+		close(t.recv)
+	}()
+
+	return &t, nil
+}
+
+func (t *zmqTransport) Send(data []byte) error {
+	t.ch.SendChan <- [][]byte{data}
+	return nil
+}
+
+func (t *zmqTransport) Recv() <-chan []byte {
+	return t.recv
+}
+
+func (t *zmqTransport) Close() {
+	t.ch.Destroy()
+}
@@ -0,0 +1,13 @@
+//go:build !zmq
+// +build !zmq
+
+package client
+
+import (
+	"fmt"
+)
+
+// newZMQTransport is unavailable in builds without ZMQ support
+func newZMQTransport(serverAddress string) (transport, error) {
+	return nil, fmt.Errorf("ZMQ transport unavailable (build with -tags zmq), use a stream+tcp://, unix:// or loopback:// address")
+}
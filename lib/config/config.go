@@ -1,12 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"time"
 
 	"github.com/go-yaml/yaml"
 
+	"github.com/ryankurte/yawns/lib/helpers"
 	"github.com/ryankurte/yawns/lib/types"
 )
 
@@ -23,17 +25,35 @@ type Config struct {
 	// Medium configuration
 	Medium Medium
 
+	// Virtual sensor configuration
+	Sensors Sensors
+
+	// Node console configuration
+	Consoles Consoles
+
 	// Plugin configuration
 	Plugins map[string]PluginConfig
 
 	// Defaults defines default settings for each node
 	Defaults types.Node
 
+	// Profiles defines named node settings, inherited by nodes (and other profiles) with the profile name
+	Profiles map[string]types.Node
+
 	// Nodes definitions for the engine
 	Nodes types.Nodes
 
+	// Generators append generated or imported nodes to the node definitions
+	Generators []Generator
+
 	// Event actions to execute when running
 	Updates []Update
+
+	// Named groups of updates, executed by group updates
+	Groups map[string][]Update
+
+	// Random seed for update timing
+	Seed int64
 }
 
 // PluginConfig implemented as a generic map[string]interface{} to support future extensions
@@ -46,7 +66,7 @@ const (
 )
 
 // LoadConfig parses a configuration object and initialises defaults
-func loadConfig(c *Config) *Config {
+func loadConfig(c *Config) (*Config, error) {
 
 	if c.EndTime == 0 {
 		c.EndTime = defaultEndTime
@@ -56,33 +76,66 @@ func loadConfig(c *Config) *Config {
 		c.TickRate = defaultTickRate
 	}
 
-	// Setup node defaults
-	for i, n := range c.Nodes {
-		if n.Command == "" {
-			n.Command = c.Defaults.Command
-		}
-		if n.Executable == "" {
-			n.Executable = c.Defaults.Executable
+	// Setup node profiles and defaults
+	for i := range c.Nodes {
+		if err := c.inheritNode(&c.Nodes[i]); err != nil {
+			return nil, err
 		}
-		for i, a := range c.Defaults.Arguments {
-			n.Arguments[i] = a
+	}
+
+	return c, nil
+}
+
+// inheritNode sets unset node fields from the node profile (and any profiles it inherits from), then the defaults
+func (c *Config) inheritNode(n *types.Node) error {
+	inherited := make(map[string]bool)
+	for name := n.Profile; name != ""; {
+		if inherited[name] {
+			return fmt.Errorf("node %s: profile '%s' inherits itself", n.Address, name)
 		}
+		inherited[name] = true
 
-		c.Nodes[i] = n
+		p, ok := c.Profiles[name]
+		if !ok {
+			return fmt.Errorf("node %s: profile '%s' not found", n.Address, name)
+		}
+		n.Inherit(&p)
+		name = p.Profile
 	}
 
-	return c
+	n.Inherit(&c.Defaults)
+
+	return nil
 }
 
 // LoadConfigFile loads an engine configuration from a config file
 func LoadConfigFile(file string) (*Config, error) {
+	return LoadConfigFileWithVariables(file, nil)
+}
 
-	data, err := ioutil.ReadFile(file)
+// LoadConfigFileWithVariables loads an engine configuration from a config file, merging included files and
+// substituting variables, with the provided variables overriding those defined in the configuration
+func LoadConfigFileWithVariables(file string, variables map[string]string) (*Config, error) {
+
+	t, err := LoadConfigTree(file)
 	if err != nil {
 		log.Printf("LoadConfig error loading file (%s)", err)
 		return nil, err
 	}
 
+	err = substituteVariables(t, variables)
+	if err != nil {
+		log.Printf("LoadConfig error substituting variables (%s)", err)
+		return nil, err
+	}
+
+	// The merged document is re-encoded to decode it into the configuration
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		log.Printf("LoadConfig error encoding file (%s)", err)
+		return nil, err
+	}
+
 	c := &Config{}
 
 	err = yaml.Unmarshal(data, c)
@@ -91,11 +144,35 @@ func LoadConfigFile(file string) (*Config, error) {
 		return nil, err
 	}
 
-	c = loadConfig(c)
+	err = c.generateNodes()
+	if err != nil {
+		log.Printf("LoadConfig error generating nodes (%s)", err)
+		return nil, err
+	}
+
+	c, err = loadConfig(c)
+	if err != nil {
+		log.Printf("LoadConfig error loading nodes (%s)", err)
+		return nil, err
+	}
+
+	err = c.Validate()
+	if err != nil {
+		log.Printf("LoadConfig error validating file (%s)", err)
+		return nil, err
+	}
 
 	return c, nil
 }
 
+// Validate checks the configuration for errors
+func (c *Config) Validate() error {
+	if err := validateGroups(c.Groups); err != nil {
+		return err
+	}
+	return validateUpdates(c.Updates, c.Groups, false)
+}
+
 // WriteConfigFile writes an engine configuration to a config file
 func WriteConfigFile(file string, c *Config) error {
 
@@ -114,6 +191,44 @@ func WriteConfigFile(file string, c *Config) error {
 	return nil
 }
 
+// UpdateMapsFile sets medium map keys (ie. satellite, level, x) in a configuration file
+// Only the medium.maps section of the original document is patched, so includes, variables, profiles
+// and generators are written back as they were rather than expanded
+func UpdateMapsFile(file string, values map[string]interface{}) error {
+	t, err := helpers.ReadYAMLTree(file)
+	if err != nil {
+		return err
+	}
+
+	medium, err := subTree(t, "medium")
+	if err != nil {
+		return fmt.Errorf("UpdateMapsFile error updating %s (%s)", file, err)
+	}
+	maps, err := subTree(medium, "maps")
+	if err != nil {
+		return fmt.Errorf("UpdateMapsFile error updating %s (%s)", file, err)
+	}
+	for k, v := range values {
+		maps[k] = v
+	}
+
+	return helpers.WriteYAMLFile(file, t)
+}
+
+// subTree fetches a map from a YAML document, creating it if unset
+func subTree(t map[interface{}]interface{}, key string) (map[interface{}]interface{}, error) {
+	switch v := t[key].(type) {
+	case nil:
+		s := make(map[interface{}]interface{})
+		t[key] = s
+		return s, nil
+	case map[interface{}]interface{}:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%s must be a map", key)
+	}
+}
+
 // Info prints information about the config to stdout
 func (c *Config) Info() {
 	log.Printf("Config Name: %s", c.Name)
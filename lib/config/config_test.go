@@ -2,10 +2,15 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ryankurte/yawns/lib/helpers"
 	"github.com/ryankurte/yawns/lib/types"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigLoading(t *testing.T) {
@@ -47,4 +52,376 @@ func TestConfigLoading(t *testing.T) {
 		}
 	})
 
+	t.Run("Validates update schedules", func(t *testing.T) {
+		group := map[string]string{"group": "a"}
+
+		c := Config{
+			Groups:  map[string][]Update{"a": {{Action: UpdateSetField}}},
+			Updates: []Update{{Action: UpdateGroup, Data: group, Trigger: &Trigger{Type: TriggerConnected}}},
+		}
+		assert.Nil(t, c.Validate())
+
+		c.Updates[0].Trigger.Type = "invalid"
+		assert.NotNil(t, c.Validate(), "Rejects unknown triggers")
+
+		c.Updates[0].Trigger = &Trigger{Type: TriggerField, Match: "("}
+		assert.NotNil(t, c.Validate(), "Rejects invalid trigger patterns")
+
+		c.Updates[0].Trigger = nil
+		c.Updates[0].Data = map[string]string{"group": "b"}
+		assert.NotNil(t, c.Validate(), "Rejects missing groups")
+
+		c.Updates[0].Data = group
+		c.Groups["a"] = append(c.Groups["a"], Update{Action: UpdateGroup, Data: group})
+		assert.NotNil(t, c.Validate(), "Rejects recursive groups")
+	})
+}
+
+func TestGenerators(t *testing.T) {
+	origin := types.Location{Lat: -36.85, Lng: 174.75}
+	bounds := []types.Location{origin, {Lat: -36.84, Lng: 174.76}}
+
+	t.Run("Generates grids", func(t *testing.T) {
+		g := Generator{Type: GeneratorGrid, Origin: origin, Rows: 2, Columns: 3, Spacing: 100, Alt: 5}
+		nodes, err := g.Generate(2, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 6)
+		assert.EqualValues(t, "0x0003", nodes[0].Address)
+		assert.EqualValues(t, "0x0008", nodes[5].Address)
+		assert.InDelta(t, 100, nodes[0].Location.Distance(nodes[1].Location), 0.1)
+		assert.InDelta(t, 100, nodes[0].Location.Distance(nodes[3].Location), 0.1)
+		assert.EqualValues(t, 5, nodes[5].Location.Alt)
+
+		g = Generator{Type: GeneratorGrid, Bounds: bounds, Spacing: 200}
+		nodes, err = g.Generate(0, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 30, "Fills the area (1.1km x 0.9km)")
+
+		g.Count = 10
+		nodes, err = g.Generate(0, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 10)
+
+		_, err = (&Generator{Type: GeneratorGrid, Rows: 2, Columns: 2}).Generate(0, 0)
+		assert.NotNil(t, err, "Requires a spacing")
+	})
+
+	t.Run("Places random nodes within polygons", func(t *testing.T) {
+		triangle := []types.Location{origin, {Lat: -36.84, Lng: 174.75}, {Lat: -36.85, Lng: 174.76}}
+		g := Generator{Type: GeneratorRandom, Count: 100, Polygon: triangle, Address: "n{{.index}}"}
+		a, _ := g.area()
+
+		nodes, err := g.Generate(0, 1)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 100)
+		assert.EqualValues(t, "n99", nodes[99].Address)
+		for _, n := range nodes {
+			assert.True(t, a.contains(n.Location))
+			assert.True(t, n.Location.Lat-origin.Lat+n.Location.Lng-origin.Lng < 0.01, "Within the triangle")
+		}
+
+		again, _ := g.Generate(0, 1)
+		assert.EqualValues(t, nodes, again, "Placement is seeded")
+		other, _ := g.Generate(0, 2)
+		assert.NotEqual(t, nodes[0].Location, other[0].Location)
+
+		_, err = (&Generator{Type: GeneratorRandom, Count: 10}).Generate(0, 0)
+		assert.NotNil(t, err, "Requires an area")
+	})
+
+	t.Run("Places poisson disk nodes", func(t *testing.T) {
+		g := Generator{Type: GeneratorPoisson, Count: 50, Bounds: bounds, MinDistance: 50}
+		nodes, err := g.Generate(0, 1)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 50)
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				assert.True(t, nodes[i].Location.Distance(nodes[j].Location) >= 50)
+			}
+		}
+
+		g.MinDistance = 1000
+		_, err = g.Generate(0, 1)
+		assert.NotNil(t, err, "Fails when nodes can not be placed")
+	})
+
+	t.Run("Places clustered nodes", func(t *testing.T) {
+		g := Generator{Type: GeneratorCluster, Count: 40, Clusters: 4, Radius: 20, Bounds: bounds}
+		a, _ := g.area()
+		nodes, err := g.Generate(0, 1)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 40)
+		for i, n := range nodes {
+			assert.True(t, a.contains(n.Location))
+			if i >= 4 {
+				assert.True(t, n.Location.Distance(nodes[i%4].Location) < 200, "Nodes are near their cluster")
+			}
+		}
+	})
+
+	dir, err := ioutil.TempDir("", "yawns-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("Imports CSV files", func(t *testing.T) {
+		file := filepath.Join(dir, "nodes.csv")
+		data := "name,Latitude,Longitude,role\n# comment\nlamp,-36.8,174.7,router\npost,-36.9,174.8,leaf\n"
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+
+		g := Generator{Type: GeneratorCSV, File: file, Address: "{{.role}}-{{.name}}", Alt: 3}
+		nodes, err := g.Generate(0, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 2)
+		assert.EqualValues(t, "router-lamp", nodes[0].Address)
+		assert.EqualValues(t, types.Location{Lat: -36.9, Lng: 174.8, Alt: 3}, nodes[1].Location)
+		assert.EqualValues(t, "leaf", nodes[1].Arguments["role"], "Properties are node arguments")
+
+		assert.Nil(t, ioutil.WriteFile(file, []byte("name,x\na,1\n"), 0644))
+		_, err = g.Generate(0, 0)
+		assert.NotNil(t, err, "Requires coordinate columns")
+
+		assert.Nil(t, ioutil.WriteFile(file, []byte("name,lat,lng\n# comment\na,-36.8,174.7\nb,north,174.7\n"), 0644))
+		_, err = g.Generate(0, 0)
+		assert.EqualError(t, err, file+" line 4: invalid coordinate 'north'", "Reports lines including comments")
+	})
+
+	t.Run("Imports GeoJSON files", func(t *testing.T) {
+		file := filepath.Join(dir, "nodes.geojson")
+		data := `{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [174.7, -36.8, 12]}, "properties": {"address": "0x0100", "id": 7}},
+			{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[174.7, -36.8], [174.8, -36.9]]}},
+			{"type": "Feature", "geometry": {"type": "MultiPoint", "coordinates": [[174.71, -36.81], [174.72, -36.82]]}, "properties": {"id": 8}}
+		]}`
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+
+		nodes, err := (&Generator{Type: GeneratorGeoJSON, File: file}).Generate(1, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 3)
+		assert.EqualValues(t, "0x0100", nodes[0].Address, "Uses imported addresses")
+		assert.EqualValues(t, types.Location{Lat: -36.8, Lng: 174.7, Alt: 12}, nodes[0].Location)
+		assert.EqualValues(t, "0x0004", nodes[2].Address)
+		assert.EqualValues(t, "8", nodes[2].Arguments["id"])
+	})
+
+	t.Run("Imports KML files", func(t *testing.T) {
+		file := filepath.Join(dir, "nodes.kml")
+		data := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Folder>
+  <Placemark><name>gateway</name><ExtendedData><Data name="height"><value>10</value></Data></ExtendedData>
+    <Point><coordinates>174.7,-36.8,4</coordinates></Point></Placemark>
+  <Placemark><name>road</name><LineString><coordinates>174.7,-36.8 174.8,-36.9</coordinates></LineString></Placemark>
+  <Placemark><name>sensor</name><Point><coordinates> 174.75,-36.85 </coordinates></Point></Placemark>
+</Folder></Document></kml>`
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+
+		nodes, err := (&Generator{Type: GeneratorKML, File: file, Address: "{{.name}}"}).Generate(0, 0)
+		assert.Nil(t, err)
+		assert.Len(t, nodes, 2)
+		assert.EqualValues(t, "gateway", nodes[0].Address)
+		assert.EqualValues(t, "10", nodes[0].Arguments["height"])
+		assert.EqualValues(t, types.Location{Lat: -36.85, Lng: 174.75}, nodes[1].Location)
+	})
+
+	t.Run("Loads generated nodes with defaults", func(t *testing.T) {
+		file := filepath.Join(dir, "config.yml")
+		data := `
+seed: 3
+defaults:
+  executable: ./node
+  arguments: {channel: "1", power: "0"}
+nodes:
+  - address: 0x0001
+    arguments: {power: "10"}
+generators:
+  - type: random
+    count: 3
+    bounds: [{lat: -36.85, lng: 174.75}, {lat: -36.84, lng: 174.76}]
+  - type: grid
+    origin: {lat: -36.85, lng: 174.75}
+    rows: 1
+    columns: 2
+    spacing: 10m
+    address: "0x0001"
+`
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+		_, err := LoadConfigFile(file)
+		assert.NotNil(t, err, "Rejects duplicate addresses")
+
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data[:len(data)-len("    address: \"0x0001\"\n")]), 0644))
+		c, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+		assert.Len(t, c.Nodes, 6)
+		assert.EqualValues(t, "0x0004", c.Nodes[3].Address)
+		assert.EqualValues(t, "0x0006", c.Nodes[5].Address)
+		for _, n := range c.Nodes {
+			assert.EqualValues(t, "./node", n.Executable)
+			assert.EqualValues(t, "1", n.Arguments["channel"])
+		}
+		assert.EqualValues(t, "10", c.Nodes[0].Arguments["power"], "Node arguments override defaults")
+		assert.EqualValues(t, "0", c.Nodes[1].Arguments["power"])
+	})
+}
+
+func TestTemplating(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yawns-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		file := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+		return file
+	}
+
+	write("common/bands.yml", `
+medium:
+  statsfile: bands.yml
+  bands:
+    Sub1GHz:
+      frequency: 433MHz
+      baud: ${baud}
+      linkbudget: 94dB
+variables:
+  baud: 10kbps
+`)
+	write("common/defaults.yml", `
+include: bands.yml
+defaults:
+  executable: ./node
+  command: "{{.server}} {{.address}} ${band}"
+  arguments: {channel: "1", power: "0"}
+profiles:
+  gateway:
+    executable: ./gateway
+    gain: 6
+    arguments: {power: "20"}
+    radios: [{band: Sub1GHz, id: 0}, {band: Sub1GHz, id: 1}]
+  rooftop:
+    profile: gateway
+    location: {lat: -36.8, lng: 174.7, alt: 40}
+variables:
+  band: Sub1GHz
+`)
+	file := write("config.yml", `
+include: [common/defaults.yml]
+name: ${name}
+endtime: ${endtime}
+medium:
+  bands:
+    Sub1GHz:
+      linkbudget: 100dB
+nodes:
+  - address: 0x0001
+    profile: rooftop
+    gain: 0
+    arguments: {channel: "2"}
+  - address: 0x0002
+    location: {lat: -36.9, lng: 174.8}
+    command: "echo $$ $${literal} $5"
+variables:
+  name: Test ${band}
+  endtime: 10s
+  count: 2
+generators:
+  - type: grid
+    profile: gateway
+    origin: {lat: -36.85, lng: 174.75}
+    rows: 1
+    columns: ${count}
+    spacing: 10m
+`)
+
+	t.Run("Merges included files", func(t *testing.T) {
+		tree, err := LoadConfigTree(file)
+		assert.Nil(t, err)
+		assert.Nil(t, tree["include"])
+
+		c, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+		band := c.Medium.Bands["Sub1GHz"]
+		assert.EqualValues(t, 433e6, band.Frequency, "Keeps included values")
+		assert.EqualValues(t, 100, band.LinkBudget, "Overrides included values")
+		assert.EqualValues(t, "bands.yml", c.Medium.StatsFile)
+		assert.EqualValues(t, 10*time.Second, c.EndTime)
+
+		write("loop-a.yml", "include: loop-b.yml\n")
+		_, err = LoadConfigTree(write("loop-b.yml", "include: loop-a.yml\n"))
+		assert.NotNil(t, err, "Rejects include loops")
+	})
+
+	t.Run("Substitutes variables", func(t *testing.T) {
+		c, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "Test ${band}", c.Name, "Variable values are not substituted")
+		assert.EqualValues(t, 10e3, c.Medium.Bands["Sub1GHz"].Baud)
+		assert.EqualValues(t, "{{.server}} {{.address}} Sub1GHz", c.Defaults.Command)
+		assert.EqualValues(t, "echo $$ ${literal} $5", c.Nodes[1].Command, "Only escapes $${")
+		assert.Len(t, c.Nodes, 4, "Variables keep value types")
+
+		c, err = LoadConfigFileWithVariables(file, map[string]string{"baud": "50kbps", "count": "3", "name": "Override"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, 50e3, c.Medium.Bands["Sub1GHz"].Baud)
+		assert.EqualValues(t, "Override", c.Name)
+		assert.Len(t, c.Nodes, 5)
+
+		write("missing.yml", "name: ${missing}\n")
+		_, err = LoadConfigFile(filepath.Join(dir, "missing.yml"))
+		assert.NotNil(t, err, "Rejects undefined variables")
+	})
+
+	t.Run("Inherits node profiles", func(t *testing.T) {
+		c, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+
+		rooftop := c.Nodes[0]
+		assert.EqualValues(t, "0x0001", rooftop.Address)
+		assert.EqualValues(t, "./gateway", rooftop.Executable, "Inherits profile parents")
+		assert.EqualValues(t, 40, rooftop.Location.Alt)
+		assert.Len(t, rooftop.Radios, 2)
+		assert.EqualValues(t, map[string]string{"channel": "2", "power": "20"}, rooftop.Arguments)
+		assert.EqualValues(t, "{{.server}} {{.address}} Sub1GHz", rooftop.Command, "Inherits defaults")
+
+		plain := c.Nodes[1]
+		assert.EqualValues(t, "./node", plain.Executable)
+		assert.EqualValues(t, map[string]string{"channel": "1", "power": "0"}, plain.Arguments)
+		assert.Len(t, plain.Radios, 0)
+
+		assert.EqualValues(t, "./gateway", c.Nodes[3].Executable, "Generated nodes inherit profiles")
+		assert.EqualValues(t, 6, c.Nodes[3].Gain)
+		assert.EqualValues(t, 0, rooftop.Gain, "Keeps explicitly zero values")
+		assert.EqualValues(t, -36.85, c.Nodes[3].Location.Lat, "Keeps generated locations")
+
+		c = &Config{Nodes: types.Nodes{{Address: "a", Profile: "x"}}, Profiles: map[string]types.Node{"x": {Profile: "y"}, "y": {Profile: "x"}}}
+		_, err = loadConfig(c)
+		assert.NotNil(t, err, "Rejects profile loops")
+
+		c.Nodes[0].Profile = "z"
+		_, err = loadConfig(c)
+		assert.NotNil(t, err, "Rejects missing profiles")
+	})
+
+	t.Run("Updates map references in place", func(t *testing.T) {
+		before, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+
+		err = UpdateMapsFile(file, map[string]interface{}{"satellite": "satellite.jpg", "level": 16, "x": uint64(64587), "y": uint64(40746)})
+		assert.Nil(t, err)
+
+		after, err := LoadConfigFile(file)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "satellite.jpg", after.Medium.Maps.Satellite)
+		assert.EqualValues(t, 16, after.Medium.Maps.Level)
+		assert.EqualValues(t, 64587, after.Medium.Maps.X)
+		assert.EqualValues(t, 40746, after.Medium.Maps.Y)
+
+		after.Medium.Maps = before.Medium.Maps
+		assert.EqualValues(t, before, after, "Preserves the rest of the configuration")
+
+		tree, err := helpers.ReadYAMLTree(file)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []interface{}{"common/defaults.yml"}, tree["include"], "Keeps includes")
+		assert.NotNil(t, tree["generators"], "Keeps generators")
+		assert.EqualValues(t, "${name}", tree["name"], "Keeps variables")
+	})
 }
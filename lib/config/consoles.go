@@ -0,0 +1,11 @@
+package config
+
+// Consoles defines pseudo-terminal consoles bridged to each node
+// Nodes are passed the console device path via the {{.console}} command argument
+type Consoles struct {
+	// Directory in which console links are created, consoles are disabled if unset
+	// Nodes are linked at <dir>/<address>.node and interactive consoles at <dir>/<address>
+	Dir string
+	// File to which console output is logged (CSV)
+	LogFile string
+}
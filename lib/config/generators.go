@@ -0,0 +1,376 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"text/template"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+const (
+	// GeneratorGrid places nodes in rows (north) and columns (east) from an Origin at a Spacing,
+	// or fills the Bounds or Polygon if rows and columns are unset
+	GeneratorGrid = "grid"
+	// GeneratorRandom places Count nodes uniformly within the Bounds or Polygon
+	GeneratorRandom = "random"
+	// GeneratorPoisson places Count nodes within the Bounds or Polygon at least MinDistance apart
+	GeneratorPoisson = "poisson"
+	// GeneratorCluster places Count nodes around random cluster centres within the Bounds or Polygon,
+	// with a gaussian spread Radius
+	GeneratorCluster = "cluster"
+	// GeneratorCSV imports nodes from a CSV file with a header row (lat, lng and optional alt and address columns)
+	GeneratorCSV = "csv"
+	// GeneratorGeoJSON imports nodes from GeoJSON point features
+	GeneratorGeoJSON = "geojson"
+	// GeneratorKML imports nodes from KML point placemarks
+	GeneratorKML = "kml"
+)
+
+// defaultAddress is the default generated address template, numbering nodes from the end of the node list
+const defaultAddress = `{{if .address}}{{.address}}{{else}}{{printf "0x%04x" .node}}{{end}}`
+
+// maxAttempts limits the number of samples per node when placing nodes within areas
+const maxAttempts = 1000
+
+// Generator generates node definitions, generated nodes inherit the node defaults
+type Generator struct {
+	// Generator type (grid, random, poisson, cluster, csv, geojson or kml)
+	Type string
+	// Address template, executed with the generator .index, the configuration .node number, and for imports
+	// the feature .name and properties (defaults to the imported address or 0x%04x of the node number)
+	Address string
+	// Number of nodes placed by random, poisson and cluster generators, or the maximum number of grid nodes
+	Count int
+	// Origin (south west corner) of grids
+	Origin types.Location
+	// Grid rows, columns and node spacing
+	Rows, Columns int
+	Spacing       types.Distance
+	// Bounding box (opposite corners) or polygon nodes are placed within
+	Bounds  []types.Location
+	Polygon []types.Location
+	// Minimum distance between poisson placed nodes
+	MinDistance types.Distance
+	// Number of clusters and the standard deviation of node distances from cluster centres
+	Clusters int
+	Radius   types.Distance
+	// Altitude of placed nodes, and of imported nodes without altitudes
+	Alt float64
+	// File nodes are imported from
+	File string
+	// Profile generated nodes inherit from
+	Profile string
+	// Random seed, defaults to the configuration seed
+	Seed int64
+}
+
+// point is a generated node location with template properties
+type point struct {
+	location   types.Location
+	name       string
+	properties map[string]string
+}
+
+// generateNodes appends the nodes from each generator to the node list
+func (c *Config) generateNodes() error {
+	for i := range c.Generators {
+		g := &c.Generators[i]
+
+		seed := g.Seed
+		if seed == 0 {
+			seed = c.Seed
+		}
+
+		nodes, err := g.Generate(len(c.Nodes), seed)
+		if err != nil {
+			return fmt.Errorf("generator %d (%s): %s", i, g.Type, err)
+		}
+		for _, n := range nodes {
+			if _, ok := c.Nodes.Find(n.Address); ok {
+				return fmt.Errorf("generator %d (%s): duplicate node address %s", i, g.Type, n.Address)
+			}
+			c.Nodes = append(c.Nodes, n)
+		}
+	}
+	return nil
+}
+
+// Generate creates the generator nodes, numbering nodes from after the existing node count
+func (g *Generator) Generate(existing int, seed int64) (types.Nodes, error) {
+	var points []point
+	var err error
+
+	r := rand.New(rand.NewSource(seed))
+
+	switch g.Type {
+	case GeneratorGrid:
+		points, err = g.grid()
+	case GeneratorRandom:
+		points, err = g.random(r)
+	case GeneratorPoisson:
+		points, err = g.poisson(r)
+	case GeneratorCluster:
+		points, err = g.cluster(r)
+	case GeneratorCSV:
+		points, err = loadCSVPoints(g.File)
+	case GeneratorGeoJSON:
+		points, err = loadGeoJSONPoints(g.File)
+	case GeneratorKML:
+		points, err = loadKMLPoints(g.File)
+	default:
+		return nil, fmt.Errorf("unrecognised generator type '%s'", g.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range points {
+		if points[i].location.Alt == 0 {
+			points[i].location.Alt = g.Alt
+		}
+	}
+
+	address := g.Address
+	if address == "" {
+		address = defaultAddress
+	}
+	tmpl, err := template.New("address").Option("missingkey=zero").Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address template (%s)", err)
+	}
+
+	nodes := make(types.Nodes, len(points))
+	for i, p := range points {
+		args := make(map[string]interface{})
+		for k, v := range p.properties {
+			args[k] = v
+		}
+		args["index"], args["node"], args["name"] = i, existing+i+1, p.name
+
+		buff := bytes.Buffer{}
+		if err := tmpl.Execute(&buff, args); err != nil {
+			return nil, fmt.Errorf("error generating address (%s)", err)
+		}
+		if buff.Len() == 0 {
+			return nil, fmt.Errorf("empty address for node %d", i)
+		}
+
+		nodes[i] = types.Node{Address: buff.String(), Profile: g.Profile, Location: p.location}
+		if len(p.properties) > 0 {
+			nodes[i].Arguments = p.properties
+		}
+	}
+
+	return nodes, nil
+}
+
+// grid places nodes on a grid from the origin, or covering the generator area
+func (g *Generator) grid() ([]point, error) {
+	if g.Spacing <= 0 {
+		return nil, fmt.Errorf("grid requires a spacing")
+	}
+
+	// Grids are limited to the area if provided, and fill the area if rows and columns are unset
+	var a *area
+	if len(g.Bounds) > 0 || len(g.Polygon) > 0 {
+		var err error
+		if a, err = g.area(); err != nil {
+			return nil, err
+		}
+	} else if g.Rows <= 0 || g.Columns <= 0 {
+		return nil, fmt.Errorf("grid requires rows and columns or an area")
+	}
+
+	origin, rows, columns := g.Origin, g.Rows, g.Columns
+	if rows <= 0 || columns <= 0 {
+		origin = a.min
+		north, east := a.min.Offset(a.max)
+		rows = int(math.Floor(north/float64(g.Spacing))) + 1
+		columns = int(math.Floor(east/float64(g.Spacing))) + 1
+	}
+
+	points := make([]point, 0)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			l := origin.Move(float64(row)*float64(g.Spacing), float64(column)*float64(g.Spacing))
+			if a != nil && !a.contains(l) {
+				continue
+			}
+			if g.Count > 0 && len(points) >= g.Count {
+				return points, nil
+			}
+			points = append(points, point{location: l})
+		}
+	}
+
+	return points, nil
+}
+
+// random places nodes uniformly within the generator area
+func (g *Generator) random(r *rand.Rand) ([]point, error) {
+	a, err := g.placement()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]point, g.Count)
+	for i := range points {
+		l, err := a.sample(r)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = point{location: l}
+	}
+	return points, nil
+}
+
+// poisson places nodes within the generator area with a minimum distance between nodes (dart throwing)
+// Placed nodes are indexed in a grid with cells small enough to hold one node (as in Bridson's algorithm),
+// so each sample is only compared with nodes in nearby cells
+func (g *Generator) poisson(r *rand.Rand) ([]point, error) {
+	a, err := g.placement()
+	if err != nil {
+		return nil, err
+	}
+	if g.MinDistance <= 0 {
+		return nil, fmt.Errorf("poisson placement requires a min distance")
+	}
+
+	size := float64(g.MinDistance) / math.Sqrt2
+	cells := make(map[[2]int]types.Location)
+	cell := func(l types.Location) [2]int {
+		north, east := a.min.Offset(l)
+		return [2]int{int(math.Floor(north / size)), int(math.Floor(east / size))}
+	}
+
+	points := make([]point, 0, g.Count)
+	for attempts := 0; len(points) < g.Count; attempts++ {
+		if attempts >= maxAttempts*g.Count {
+			return nil, fmt.Errorf("placed %d of %d nodes %.1fm apart, reduce the count or min distance",
+				len(points), g.Count, g.MinDistance)
+		}
+
+		l, err := a.sample(r)
+		if err != nil {
+			return nil, err
+		}
+
+		c, ok := cell(l), true
+		for i := c[0] - 2; i <= c[0]+2 && ok; i++ {
+			for j := c[1] - 2; j <= c[1]+2; j++ {
+				if p, found := cells[[2]int{i, j}]; found && p.Distance(l) < float64(g.MinDistance) {
+					ok = false
+					break
+				}
+			}
+		}
+		if ok {
+			cells[c] = l
+			points = append(points, point{location: l})
+		}
+	}
+
+	return points, nil
+}
+
+// cluster places nodes evenly between random cluster centres, with a gaussian spread around each centre
+func (g *Generator) cluster(r *rand.Rand) ([]point, error) {
+	a, err := g.placement()
+	if err != nil {
+		return nil, err
+	}
+	if g.Clusters <= 0 || g.Radius <= 0 {
+		return nil, fmt.Errorf("cluster placement requires clusters and a radius")
+	}
+
+	centres := make([]types.Location, g.Clusters)
+	for i := range centres {
+		if centres[i], err = a.sample(r); err != nil {
+			return nil, err
+		}
+	}
+
+	points := make([]point, g.Count)
+	for i := range points {
+		centre := centres[i%len(centres)]
+		for attempts := 0; ; attempts++ {
+			if attempts >= maxAttempts {
+				return nil, fmt.Errorf("unable to place node within area, reduce the cluster radius")
+			}
+			l := centre.Move(r.NormFloat64()*float64(g.Radius), r.NormFloat64()*float64(g.Radius))
+			if a.contains(l) {
+				points[i] = point{location: l}
+				break
+			}
+		}
+	}
+
+	return points, nil
+}
+
+// placement fetches the area for count based generators
+func (g *Generator) placement() (*area, error) {
+	if g.Count <= 0 {
+		return nil, fmt.Errorf("%s placement requires a count", g.Type)
+	}
+	return g.area()
+}
+
+// area is a polygon nodes are placed within, with the bounding box of the polygon
+type area struct {
+	polygon  []types.Location
+	min, max types.Location
+}
+
+// area fetches the generator polygon, or the polygon of the generator bounds
+func (g *Generator) area() (*area, error) {
+	polygon := g.Polygon
+	if len(polygon) == 0 {
+		if len(g.Bounds) != 2 {
+			return nil, fmt.Errorf("bounds must have two corners or a polygon must be provided")
+		}
+		a, b := g.Bounds[0], g.Bounds[1]
+		polygon = []types.Location{{Lat: a.Lat, Lng: a.Lng}, {Lat: a.Lat, Lng: b.Lng}, {Lat: b.Lat, Lng: b.Lng}, {Lat: b.Lat, Lng: a.Lng}}
+	}
+	if len(polygon) < 3 {
+		return nil, fmt.Errorf("polygons must have at least three points")
+	}
+
+	a := area{polygon: polygon, min: polygon[0], max: polygon[0]}
+	for _, l := range polygon {
+		a.min.Lat, a.min.Lng = math.Min(a.min.Lat, l.Lat), math.Min(a.min.Lng, l.Lng)
+		a.max.Lat, a.max.Lng = math.Max(a.max.Lat, l.Lat), math.Max(a.max.Lng, l.Lng)
+	}
+	a.min.Alt, a.max.Alt = 0, 0
+
+	return &a, nil
+}
+
+// contains checks whether a location is within the area polygon (ray casting)
+func (a *area) contains(l types.Location) bool {
+	inside := false
+	for i, j := 0, len(a.polygon)-1; i < len(a.polygon); j, i = i, i+1 {
+		p1, p2 := a.polygon[i], a.polygon[j]
+		if (p1.Lat > l.Lat) != (p2.Lat > l.Lat) &&
+			l.Lng < (p2.Lng-p1.Lng)*(l.Lat-p1.Lat)/(p2.Lat-p1.Lat)+p1.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// sample picks a uniformly distributed location within the area
+func (a *area) sample(r *rand.Rand) (types.Location, error) {
+	for i := 0; i < maxAttempts; i++ {
+		l := types.Location{
+			Lat: a.min.Lat + r.Float64()*(a.max.Lat-a.min.Lat),
+			Lng: a.min.Lng + r.Float64()*(a.max.Lng-a.min.Lng),
+		}
+		if a.contains(l) {
+			return l, nil
+		}
+	}
+	return types.Location{}, fmt.Errorf("unable to sample a location within the polygon")
+}
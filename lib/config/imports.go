@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// Column names recognised for CSV node imports, other columns are imported as properties
+var (
+	latColumns = []string{"lat", "latitude", "y"}
+	lngColumns = []string{"lng", "lon", "long", "longitude", "x"}
+	altColumns = []string{"alt", "altitude", "elevation", "z"}
+)
+
+// loadCSVPoints imports node locations from a CSV file with a header row
+func loadCSVPoints(file string) ([]point, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s header (%s)", file, err)
+	}
+	lat, lng, alt := findColumn(header, latColumns), findColumn(header, lngColumns), findColumn(header, altColumns)
+	if lat < 0 || lng < 0 {
+		return nil, fmt.Errorf("%s requires lat and lng columns", file)
+	}
+
+	points := make([]point, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		p := point{properties: make(map[string]string)}
+		coordinates := []*float64{&p.location.Lat, &p.location.Lng, &p.location.Alt}
+		for i, column := range []int{lat, lng, alt} {
+			if column < 0 || strings.TrimSpace(record[column]) == "" {
+				continue
+			}
+			if *coordinates[i], err = strconv.ParseFloat(strings.TrimSpace(record[column]), 64); err != nil {
+				line, _ := r.FieldPos(column)
+				return nil, fmt.Errorf("%s line %d: invalid coordinate '%s'", file, line, record[column])
+			}
+		}
+		for i, v := range record {
+			if i != lat && i != lng && i != alt {
+				p.properties[strings.TrimSpace(header[i])] = strings.TrimSpace(v)
+			}
+		}
+		p.name = p.properties["name"]
+
+		points = append(points, p)
+	}
+
+	return points, nil
+}
+
+// findColumn finds the index of the first header column matching one of the names, ignoring case
+func findColumn(header []string, names []string) int {
+	for i, h := range header {
+		for _, n := range names {
+			if strings.EqualFold(strings.TrimSpace(h), n) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// geoJSON is the subset of GeoJSON feature collections and features used for node imports
+type geoJSON struct {
+	Type       string
+	Features   []geoJSON
+	Geometry   *geoJSONGeometry
+	Properties map[string]interface{}
+}
+
+type geoJSONGeometry struct {
+	Type        string
+	Coordinates json.RawMessage
+}
+
+// loadGeoJSONPoints imports node locations from GeoJSON Point and MultiPoint features,
+// other geometries are ignored
+func loadGeoJSONPoints(file string) ([]point, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := geoJSON{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing %s (%s)", file, err)
+	}
+
+	features := doc.Features
+	if doc.Type == "Feature" {
+		features = []geoJSON{doc}
+	}
+
+	points := make([]point, 0)
+	for i, f := range features {
+		if f.Geometry == nil {
+			continue
+		}
+
+		coordinates := make([][]float64, 0)
+		switch f.Geometry.Type {
+		case "Point":
+			c := make([]float64, 0)
+			err = json.Unmarshal(f.Geometry.Coordinates, &c)
+			coordinates = append(coordinates, c)
+		case "MultiPoint":
+			err = json.Unmarshal(f.Geometry.Coordinates, &coordinates)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s feature %d: invalid coordinates (%s)", file, i, err)
+		}
+
+		properties := make(map[string]string)
+		for k, v := range f.Properties {
+			if v != nil {
+				properties[k] = fmt.Sprint(v)
+			}
+		}
+
+		for _, c := range coordinates {
+			// GeoJSON positions are longitude, latitude and optional altitude
+			if len(c) < 2 {
+				return nil, fmt.Errorf("%s feature %d: positions require a longitude and latitude", file, i)
+			}
+			l := types.Location{Lng: c[0], Lat: c[1]}
+			if len(c) > 2 {
+				l.Alt = c[2]
+			}
+			points = append(points, point{location: l, name: properties["name"], properties: properties})
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%s contains no point features", file)
+	}
+
+	return points, nil
+}
+
+// kmlPlacemark is the subset of KML placemarks used for node imports
+type kmlPlacemark struct {
+	Name        string   `xml:"name"`
+	Coordinates []string `xml:"Point>coordinates"`
+	Points      []string `xml:"MultiGeometry>Point>coordinates"`
+	Data        []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value"`
+	} `xml:"ExtendedData>Data"`
+	SimpleData []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"ExtendedData>SchemaData>SimpleData"`
+}
+
+// loadKMLPoints imports node locations from KML placemark points, including placemarks within folders
+func loadKMLPoints(file string) ([]point, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	points := make([]point, 0)
+	d := xml.NewDecoder(f)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing %s (%s)", file, err)
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+
+		p := kmlPlacemark{}
+		if err := d.DecodeElement(&p, &start); err != nil {
+			return nil, fmt.Errorf("error parsing %s placemark (%s)", file, err)
+		}
+
+		properties := make(map[string]string)
+		for _, v := range p.Data {
+			properties[v.Name] = strings.TrimSpace(v.Value)
+		}
+		for _, v := range p.SimpleData {
+			properties[v.Name] = strings.TrimSpace(v.Value)
+		}
+		name := strings.TrimSpace(p.Name)
+		if name != "" {
+			properties["name"] = name
+		}
+
+		for _, c := range append(p.Coordinates, p.Points...) {
+			// KML coordinates are whitespace separated longitude,latitude[,altitude] tuples
+			for _, tuple := range strings.Fields(c) {
+				l, err := parseKMLCoordinate(tuple)
+				if err != nil {
+					return nil, fmt.Errorf("%s placemark %s: %s", file, name, err)
+				}
+				points = append(points, point{location: l, name: name, properties: properties})
+			}
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%s contains no point placemarks", file)
+	}
+
+	return points, nil
+}
+
+// parseKMLCoordinate parses a longitude,latitude[,altitude] tuple
+func parseKMLCoordinate(tuple string) (types.Location, error) {
+	parts := strings.Split(tuple, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return types.Location{}, fmt.Errorf("invalid coordinates '%s'", tuple)
+	}
+
+	values := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return types.Location{}, fmt.Errorf("invalid coordinates '%s'", tuple)
+		}
+		values[i] = v
+	}
+
+	l := types.Location{Lng: values[0], Lat: values[1]}
+	if len(values) > 2 {
+		l.Alt = values[2]
+	}
+	return l, nil
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ryankurte/yawns/lib/helpers"
+)
+
+const (
+	// includeKey lists files (relative to the including file) merged beneath a configuration
+	includeKey = "include"
+	// variablesKey defines default values for ${name} variables
+	variablesKey = "variables"
+)
+
+// variableRegex matches ${name} variable references and $${ escapes, other $ characters are kept
+// (ie. $$ in shell commands)
+var variableRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z0-9_.-]+)\}`)
+
+// LoadConfigTree loads a configuration file as a generic YAML document with included files merged,
+// prior to variable substitution
+func LoadConfigTree(file string) (map[interface{}]interface{}, error) {
+	return loadTree(file, nil)
+}
+
+// loadTree loads a configuration file and its includes, tracking parent files to detect include loops
+func loadTree(file string, parents []string) (map[interface{}]interface{}, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range parents {
+		if p == path {
+			return nil, fmt.Errorf("%s includes itself", file)
+		}
+	}
+
+	t, err := helpers.ReadYAMLTree(file)
+	if err != nil {
+		return nil, err
+	}
+
+	includes := make([]string, 0)
+	switch v := t[includeKey].(type) {
+	case nil:
+	case string:
+		includes = append(includes, v)
+	case []interface{}:
+		for _, i := range v {
+			includes = append(includes, fmt.Sprint(i))
+		}
+	default:
+		return nil, fmt.Errorf("%s: include must be a file or a list of files", file)
+	}
+	delete(t, includeKey)
+
+	// Included files are merged in order, with the including file merged last
+	merged := make(map[interface{}]interface{})
+	for _, i := range includes {
+		if !filepath.IsAbs(i) {
+			i = filepath.Join(filepath.Dir(file), i)
+		}
+		included, err := loadTree(i, append(parents, path))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeTrees(merged, included)
+	}
+
+	return mergeTrees(merged, t), nil
+}
+
+// mergeTrees deep merges an override document into a base document
+// Maps are merged recursively, while lists and values are replaced
+func mergeTrees(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	for k, v := range override {
+		b, baseMap := base[k].(map[interface{}]interface{})
+		o, overrideMap := v.(map[interface{}]interface{})
+		if baseMap && overrideMap {
+			base[k] = mergeTrees(b, o)
+		} else {
+			base[k] = v
+		}
+	}
+	return base
+}
+
+// substituteVariables replaces ${name} references in document values with the document variables,
+// overridden by the provided variables. $${ is replaced with a literal ${.
+func substituteVariables(t map[interface{}]interface{}, overrides map[string]string) error {
+	variables := make(map[string]string)
+
+	switch v := t[variablesKey].(type) {
+	case nil:
+	case map[interface{}]interface{}:
+		for k, value := range v {
+			if value == nil {
+				value = ""
+			}
+			variables[fmt.Sprint(k)] = fmt.Sprint(value)
+		}
+	default:
+		return fmt.Errorf("variables must be a map of names to values")
+	}
+	delete(t, variablesKey)
+
+	for k, v := range overrides {
+		variables[k] = v
+	}
+
+	_, err := substitute(t, variables)
+	return err
+}
+
+// substitute replaces variable references in a document value
+func substitute(v interface{}, variables map[string]string) (interface{}, error) {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		for k, c := range value {
+			s, err := substitute(c, variables)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = s
+		}
+
+	case []interface{}:
+		for i, c := range value {
+			s, err := substitute(c, variables)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = s
+		}
+
+	case string:
+		// Values consisting of a single variable take the type of the variable value (ie. numbers)
+		if m := variableRegex.FindStringSubmatch(value); m != nil && m[0] == value && m[1] != "" {
+			s, ok := variables[m[1]]
+			if !ok {
+				return nil, fmt.Errorf("variable '%s' is not defined", m[1])
+			}
+			return typedValue(s), nil
+		}
+
+		var err error
+		s := variableRegex.ReplaceAllStringFunc(value, func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+			name := match[2 : len(match)-1]
+			s, ok := variables[name]
+			if !ok {
+				err = fmt.Errorf("variable '%s' is not defined", name)
+			}
+			return s
+		})
+		return s, err
+	}
+
+	return v, nil
+}
+
+// typedValue parses a variable value as a YAML number or boolean, numbers in other bases
+// (ie. addresses), yes/no style booleans and other values are kept as strings
+func typedValue(s string) interface{} {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch value := v.(type) {
+	case int, int64, uint64, bool:
+		if fmt.Sprint(value) == s {
+			return value
+		}
+	case float64:
+		return value
+	}
+	return s
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/ryankurte/yawns/lib/types"
 )
 
@@ -38,25 +40,97 @@ type Band struct {
 	FreeSpaceThreshold float64
 }
 
+// LandCoverClass defines a land cover class and the attenuation model applied to paths through it
+type LandCoverClass struct {
+	// Colour of the class in the land cover map (#rrggbb)
+	Colour string
+	// Attenuation model (weissberger, linear, fixed or none)
+	Model string
+	// Specific attenuation in dB per meter for the linear model
+	Rate types.Attenuation
+	// Loss for the fixed model, or maximum loss for the linear model
+	Loss types.Attenuation
+}
+
 // Maps configuration for the Medium Map layer
 type Maps struct {
 	// X and Y tile locations
 	X, Y uint64
 	// Map level
 	Level uint64
+	// Base tile size in pixels (defaults to 512 for high DPI mapbox tiles)
+	TileSize uint64
 	// Satellite map file
 	Satellite string
 	// Terrain map file
 	Terrain string
-	// Foliage map file
+	// Elevation model files (SRTM .hgt, GeoTIFF or ESRI ASCII grid), used in place of the terrain map where set
+	Elevation []string
+	// Foliage map file (foliage areas blacked out)
 	Foliage string
+	// Land cover map file, classified by pixel colour using LandCoverClasses
+	LandCover string
+	// Land cover classes by name
+	LandCoverClasses map[string]LandCoverClass
+	// Floorplan file (YAML or GeoJSON) for indoor wall and floor attenuation
+	Floorplan string
 	// Default terrain offset (for unset altitudes)
 	DefaultOffset types.Distance
 }
 
+// DefaultTileSize is the default base tile size for map files
+const DefaultTileSize = 512
+
+// GetTileSize fetches the base tile size for map files
+func (m *Maps) GetTileSize() uint64 {
+	if m.TileSize == 0 {
+		return DefaultTileSize
+	}
+	return m.TileSize
+}
+
+// Interferer activity patterns
+const (
+	// InterfererContinuous interferers are always active
+	InterfererContinuous = "continuous"
+	// InterfererPeriodic interferers are active for Duration every Period
+	InterfererPeriodic = "periodic"
+	// InterfererRandom interferers switch on and off with exponentially distributed MeanOn and MeanOff times
+	InterfererRandom = "random"
+	// InterfererTrace interferers replay power levels from a trace file
+	InterfererTrace = "trace"
+)
+
+// Interferer defines an external noise source or jammer
+type Interferer struct {
+	// Interferer location
+	Location types.Location
+	// Band in which the interferer is active
+	Band string
+	// Channels on which the interferer is active (all channels if unset)
+	Channels []int32
+	// Power in dB relative to the node transmit power
+	Power types.Attenuation
+	// Activity pattern (continuous, periodic, random or trace)
+	Pattern string
+	// Period and active Duration for periodic interferers, with an Offset to the first burst
+	Period, Duration, Offset time.Duration
+	// Mean on and off times for random interferers
+	MeanOn, MeanOff time.Duration
+	// Random seed for random interferers
+	Seed int64
+	// Trace file for trace interferers, CSV of time offset and power in dB (or "off")
+	Trace string
+	// Loop the trace file once complete
+	Loop bool
+	// Disabled interferers do not start until enabled by an update
+	Disabled bool
+}
+
 // Medium defines the simulator configuration for the medium module
 type Medium struct {
-	Maps      Maps
-	Bands     map[string]Band // Frequency bands in simulation
-	StatsFile string
+	Maps        Maps
+	Bands       map[string]Band       // Frequency bands in simulation
+	Interferers map[string]Interferer // External noise sources and jammers
+	StatsFile   string
 }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+const (
+	// SensorConstant sensors have a uniform value (changing at Rate per second)
+	SensorConstant = "constant"
+	// SensorGradient sensors change by Gradient per meter from the Origin in the direction of Bearing
+	SensorGradient = "gradient"
+	// SensorHotspot sensors add a Peak value at a hotspot with gaussian spread Radius, moving from the Origin
+	// at Speed in the direction of Bearing
+	SensorHotspot = "hotspot"
+	// SensorTrace sensors replay per node time series files
+	SensorTrace = "trace"
+)
+
+const (
+	// NoiseGaussian noise is normally distributed with standard deviation Amplitude
+	NoiseGaussian = "gaussian"
+	// NoiseUniform noise is uniformly distributed between -Amplitude and +Amplitude
+	NoiseUniform = "uniform"
+)
+
+// SensorNoise defines the noise model applied to sensor readings
+type SensorNoise struct {
+	// Noise model (gaussian or uniform), no noise is applied if unset
+	Model string
+	// Noise amplitude (standard deviation for gaussian noise)
+	Amplitude float64
+	// Reading resolution, readings are quantised to this if set
+	Resolution float64
+	// Random seed
+	Seed int64
+}
+
+// Sensor defines a virtual sensor readable by nodes
+type Sensor struct {
+	// Sensor type (constant, gradient, hotspot or trace)
+	Type string
+	// Base value
+	Value float64
+	// Rate of change of the base value per second
+	Rate float64
+	// Origin of gradient fields, or the starting location of hotspots
+	Origin types.Location
+	// Bearing in degrees from north for gradients and moving hotspots
+	Bearing float64
+	// Gradient in units per meter for gradient fields
+	Gradient float64
+	// Peak value, spread and speed (in m/s) for hotspots
+	Peak   float64
+	Radius types.Distance
+	Speed  float64
+	// Trace files by node address for trace sensors, CSV of time offset and value
+	Traces map[string]string
+	// Noise model applied to readings
+	Noise SensorNoise
+}
+
+// Sensors defines virtual sensors available to nodes
+type Sensors struct {
+	// Sensor definitions by name
+	Fields map[string]Sensor
+	// File to which sensor readings are logged (CSV)
+	LogFile string
+}
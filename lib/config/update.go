@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 )
 
@@ -12,8 +14,68 @@ const (
 	UpdateSetLocation UpdateAction = "set-location"
 	// UpdateSetState sets a state field for a given node and key
 	UpdateSetState UpdateAction = "set-state"
-	// UpdateCheckState checks a state field for a given node and key
+	// UpdateCheckState checks a state field for a given node and key ("key" with a comparison "op" and "value", or an
+	// "expr" expression), optionally "within" a window (eventually) or "during" a window (always)
 	UpdateCheckState UpdateAction = "check-state"
+	// UpdateCheckTree checks that node fields ("key", ie. parent) form a tree rooted at a node ("root")
+	UpdateCheckTree UpdateAction = "check-tree"
+	// UpdateCheckStats checks a medium statistic ("metric") for nodes, links ("to") or bands ("band" without nodes)
+	UpdateCheckStats UpdateAction = "check-stats"
+	// UpdateSetField pushes a field value to a node ("key" and "value" in the update data)
+	UpdateSetField UpdateAction = "set-field"
+	// UpdateCommand sends a command to a node ("command" and optional "data" in the update data)
+	UpdateCommand UpdateAction = "command"
+	// UpdateConsoleSend sends a line to a node console ("line" in the update data, with optional "expect" pattern
+	// and "timeout" to await a response)
+	UpdateConsoleSend UpdateAction = "console-send"
+	// UpdateConsoleExpect awaits console output from a node matching a pattern ("pattern" and optional "timeout")
+	UpdateConsoleExpect UpdateAction = "console-expect"
+	// UpdateGroup runs a named group of updates, with member timestamps relative to the group execution ("group" in
+	// the update data). Group members without nodes inherit the nodes of the group update
+	UpdateGroup UpdateAction = "group"
+	// UpdateStartInterferer starts the interferers named in the update data ("interferers", comma separated)
+	UpdateStartInterferer UpdateAction = "start-interferer"
+	// UpdateStopInterferer stops the interferers named in the update data ("interferers", comma separated)
+	UpdateStopInterferer UpdateAction = "stop-interferer"
+
+	// Fault injection updates, those with a "duration" in the update data are reversed after the duration
+
+	// UpdateKillNode kills node processes (restarted after the duration)
+	UpdateKillNode UpdateAction = "kill-node"
+	// UpdateRestartNode restarts (or starts killed) node processes
+	UpdateRestartNode UpdateAction = "restart-node"
+	// UpdatePauseNode freezes node processes with SIGSTOP (resumed after the duration)
+	UpdatePauseNode UpdateAction = "pause-node"
+	// UpdateResumeNode resumes frozen node processes with SIGCONT
+	UpdateResumeNode UpdateAction = "resume-node"
+	// UpdateRadioOff powers off node radios in the medium, all radios unless a "band" is specified, and all radios on
+	// the band unless a "radio" is specified
+	UpdateRadioOff UpdateAction = "radio-off"
+	// UpdateRadioOn powers on node radios in the medium
+	UpdateRadioOn UpdateAction = "radio-on"
+	// UpdateBlockLink blocks links between each of the update nodes, or between "groups" of nodes
+	UpdateBlockLink UpdateAction = "block-link"
+	// UpdateDegradeLink adds "attenuation" (ie. 20dB) to links between each of the update nodes, or between "groups"
+	UpdateDegradeLink UpdateAction = "degrade-link"
+	// UpdateRestoreLink clears faults on links between each of the update nodes or "groups", or all links otherwise
+	UpdateRestoreLink UpdateAction = "restore-link"
+	// UpdatePartition partitions the network into "groups" of nodes (ie. "0x0001,0x0002|0x0003"), blocking links
+	// between nodes in different groups
+	UpdatePartition UpdateAction = "partition"
+)
+
+// TriggerType type for valid update triggers
+type TriggerType string
+
+const (
+	// TriggerConnected activates when a node connects
+	TriggerConnected TriggerType = "connected"
+	// TriggerDisconnected activates when a node disconnects, matching the disconnection reason
+	TriggerDisconnected TriggerType = "disconnected"
+	// TriggerField activates when a node reports a field, matching the field value
+	TriggerField TriggerType = "field"
+	// TriggerEvent activates when a node reports an event, matching the event data
+	TriggerEvent TriggerType = "event"
 )
 
 // Update struct defines changes to the system
@@ -23,4 +85,84 @@ type Update struct {
 	Nodes     []string          `yaml:"nodes"`     // Node address for Update to be applied
 	Data      map[string]string `yaml:"data"`      // Update data, parsed based on action
 	Comment   string            `yaml:"comment"`   // Comment for log purposes
+
+	Period  time.Duration `yaml:"period,omitempty"`  // Period at which the update is repeated after the first execution
+	Count   int           `yaml:"count,omitempty"`   // Maximum number of executions of periodic updates (unlimited if zero)
+	Until   time.Duration `yaml:"until,omitempty"`   // Simulation time after which the update is no longer executed
+	Jitter  time.Duration `yaml:"jitter,omitempty"`  // Maximum random delay added to each execution
+	Trigger *Trigger      `yaml:"trigger,omitempty"` // Trigger for the update, the timestamp is a delay after activation
+}
+
+// Trigger defines the conditions under which a triggered update is executed
+// Triggered updates without nodes are applied to the node that activated the trigger
+type Trigger struct {
+	Type  TriggerType `yaml:"type"`            // Trigger type
+	Nodes []string    `yaml:"nodes,omitempty"` // Nodes that can activate the trigger, any node if unset
+	Key   string      `yaml:"key,omitempty"`   // Field name for field triggers
+	Match string      `yaml:"match,omitempty"` // Regular expression matched against the field value, event data or reason
+	Once  bool        `yaml:"once,omitempty"`  // Only activate the trigger on the first match
+}
+
+// validateUpdates checks update schedules, triggers and group references
+func validateUpdates(updates []Update, groups map[string][]Update, inGroup bool) error {
+	for i, u := range updates {
+		if u.Period < 0 || u.Jitter < 0 || u.Count < 0 {
+			return fmt.Errorf("update %d (%s): period, jitter and count must not be negative", i, u.Action)
+		}
+
+		if u.Trigger != nil {
+			if inGroup {
+				return fmt.Errorf("update %d (%s): group updates can not be triggered", i, u.Action)
+			}
+			switch u.Trigger.Type {
+			case TriggerConnected, TriggerDisconnected, TriggerField, TriggerEvent:
+			default:
+				return fmt.Errorf("update %d (%s): unrecognised trigger type '%s'", i, u.Action, u.Trigger.Type)
+			}
+			if _, err := regexp.Compile(u.Trigger.Match); err != nil {
+				return fmt.Errorf("update %d (%s): invalid trigger match (%s)", i, u.Action, err)
+			}
+		}
+
+		if u.Action == UpdateGroup {
+			if _, ok := groups[u.Data["group"]]; !ok {
+				return fmt.Errorf("update %d: group '%s' not found", i, u.Data["group"])
+			}
+		}
+	}
+	return nil
+}
+
+// validateGroups checks group updates and ensures groups do not run themselves
+func validateGroups(groups map[string][]Update) error {
+	for name, updates := range groups {
+		if err := validateUpdates(updates, groups, true); err != nil {
+			return fmt.Errorf("group %s: %s", name, err)
+		}
+	}
+
+	var visit func(name string, path map[string]bool) error
+	visit = func(name string, path map[string]bool) error {
+		if path[name] {
+			return fmt.Errorf("group %s runs itself", name)
+		}
+		path[name] = true
+		defer delete(path, name)
+
+		for _, u := range groups[name] {
+			if u.Action == UpdateGroup {
+				if err := visit(u.Data["group"], path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for name := range groups {
+		if err := visit(name, make(map[string]bool)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
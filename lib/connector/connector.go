@@ -1,10 +1,29 @@
+/**
+ * OpenNetworkSim Connector Package
+ * Connectors link nodes to the simulator, mapping protocol messages to and from simulator messages.
+ * Nodes are disconnected on deregistration, on connection close (for stream and websocket backends), or when no
+ * messages (including heartbeats) are received within the heartbeat timeout if one is set.
+ * Backends are selected by the address URI scheme:
+ *  - tcp:// and ipc:// use ZMQ (as required by libyawns), available in builds with the zmq tag
+ *  - stream+tcp://host:port uses length-prefixed protobuf over plain TCP
+ *  - unix:///path uses length-prefixed protobuf over a unix domain socket
+ *  - loopback://name uses an in-process loopback for go tests and embedded nodes
+ *  - ws://host:port/path uses websockets with binary protobuf or JSON messages, for browser and WASM nodes
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
 package connector
 
 import (
+	"fmt"
 	"log"
-	"reflect"
+	"net/url"
+	"strings"
+	"time"
 
-	"github.com/zeromq/goczmq"
+	"github.com/ryankurte/yawns/lib/messages"
 )
 
 const (
@@ -12,54 +31,139 @@ const (
 	DefaultIPCAddress = "ipc:///ons"
 )
 
-// ZMQConnector is a connector instance using ZMQ messaging
-type ZMQConnector struct {
-	ch         *goczmq.Channeler
-	clients    map[string][]byte
+// Connector is a connector instance linking nodes to the simulator
+type Connector interface {
+	// Input fetches the channel of messages to be sent to nodes
+	Input() chan interface{}
+	// Output fetches the channel of messages received from nodes
+	Output() chan interface{}
+	// SetHeartbeatTimeout sets the timeout after which silent nodes are disconnected, zero disables timeouts
+	SetHeartbeatTimeout(timeout time.Duration)
+	// Exit closes the connector
+	Exit()
+}
+
+// NewConnector creates a connector bound to the provided address, with the backend selected by the URI scheme
+func NewConnector(address string) (Connector, error) {
+	scheme := ""
+	if i := strings.Index(address, "://"); i > 0 {
+		scheme = address[:i]
+	}
+
+	var c Connector
+	var err error
+
+	switch scheme {
+	case "tcp", "ipc":
+		return newZMQConnector(address)
+	case "stream+tcp":
+		c, err = NewStreamConnector("tcp", strings.TrimPrefix(address, "stream+tcp://"))
+	case "unix":
+		c, err = NewStreamConnector("unix", strings.TrimPrefix(address, "unix://"))
+	case "loopback":
+		c, err = NewLoopbackConnector(strings.TrimPrefix(address, "loopback://"))
+	case "ws":
+		u, e := url.Parse(address)
+		if e != nil {
+			return nil, e
+		}
+		c, err = NewWebSocketConnector(u.Host, u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported connector address '%s'", address)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// frame is a protocol message received from a client, identified by a backend specific client ID
+// Frames with closed set indicate that the backend connection to the client has been closed
+type frame struct {
+	id     string
+	data   []byte
+	closed bool
+}
+
+// base implements the protocol mapping and client tracking shared by connector backends
+// Backends push received frames to the incoming channel and provide a send function for outgoing frames
+type base struct {
+	clients    map[string]string
+	lastSeen   map[string]time.Time
+	timeout    time.Duration
+	incoming   chan frame
+	timeoutCh  chan time.Duration
+	done       chan struct{}
+	send       func(id string, data []byte) error
 	InputChan  chan interface{}
 	OutputChan chan interface{}
 }
 
-// NewZMQConnector creates a new ZMQ based connector instance and binds a connector instance and handler to the provided address
-func NewZMQConnector(bindAddress string) *ZMQConnector {
-	c := ZMQConnector{}
-
-	c.clients = make(map[string][]byte)
-	c.InputChan = make(chan interface{}, 1024)
-	c.OutputChan = make(chan interface{}, 1024)
+func newBase(send func(id string, data []byte) error) base {
+	return base{
+		clients:    make(map[string]string),
+		lastSeen:   make(map[string]time.Time),
+		incoming:   make(chan frame, 1024),
+		timeoutCh:  make(chan time.Duration),
+		done:       make(chan struct{}),
+		send:       send,
+		InputChan:  make(chan interface{}, 1024),
+		OutputChan: make(chan interface{}, 1024),
+	}
+}
 
-	c.ch = goczmq.NewRouterChanneler(bindAddress)
+// Input fetches the channel of messages to be sent to nodes
+func (c *base) Input() chan interface{} {
+	return c.InputChan
+}
 
-	go c.Run()
+// Output fetches the channel of messages received from nodes
+func (c *base) Output() chan interface{} {
+	return c.OutputChan
+}
 
-	return &c
+// SetHeartbeatTimeout sets the timeout after which silent nodes are disconnected, zero disables timeouts
+func (c *base) SetHeartbeatTimeout(timeout time.Duration) {
+	select {
+	case c.timeoutCh <- timeout:
+	case <-c.done:
+	}
 }
 
 // sendMsg sends an ONS message to the provided client by address
 // Note that address lookup is not available until the server has received a message from each client
-func (c *ZMQConnector) sendMsg(address string, data []byte) {
-	// Lookup ZMQ ID by address
+func (c *base) sendMsg(address string, data []byte) {
+	// Lookup client ID by address
 	id, ok := c.clients[address]
 	if !ok {
 		return
 	}
 
-	// Send message via channel
-	c.ch.SendChan <- [][]byte{id, data}
+	if err := c.send(id, data); err != nil {
+		log.Printf("Send error: %s", err)
+	}
 }
 
-// Run the ZMQ connector
-func (c *ZMQConnector) Run() {
+// run handles incoming and outgoing messages until the connector is closed
+func (c *base) run() {
+	var ticker *time.Ticker
+	var check <-chan time.Time
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
 	for {
 		select {
 		// Handle protocol messages from clients
-		case p, ok := <-c.ch.RecvChan:
-			if !ok {
-				log.Printf("channel error")
-				break
+		case f := <-c.incoming:
+			if f.closed {
+				c.handleClosed(f.id)
+				continue
 			}
-			//log.Printf("RX from client: %+v", p)
-			err := c.handleIncoming(p)
+			err := c.handleIncoming(f.id, f.data)
 			if err != nil {
 				log.Printf("Parsing error: %s", err)
 			}
@@ -68,33 +172,112 @@ func (c *ZMQConnector) Run() {
 		case p, ok := <-c.InputChan:
 			if !ok {
 				log.Printf("channel error")
-				break
+				return
 			}
-			//log.Printf("TX from server: %+v", p)
 			err := c.handleOutgoing(p)
 			if err != nil {
 				log.Printf("Parsing error: %s", err)
 			}
+
+		// Update heartbeat timeout checking
+		case timeout := <-c.timeoutCh:
+			c.timeout = timeout
+			if ticker != nil {
+				ticker.Stop()
+				ticker, check = nil, nil
+			}
+			if timeout > 0 {
+				ticker = time.NewTicker(timeout / 4)
+				check = ticker.C
+			}
+
+		case now := <-check:
+			c.checkTimeouts(now)
+
+		case <-c.done:
+			return
 		}
 	}
 }
 
-// Exit a ZMQConnector instance
-func (c *ZMQConnector) Exit() {
-	c.ch.Destroy()
+// forward passes a frame received from a client to the connector, returning false if the connector has been closed
+func (c *base) forward(id string, data []byte) bool {
+	if c.closed() {
+		return false
+	}
+	select {
+	case c.incoming <- frame{id: id, data: data}:
+		return true
+	case <-c.done:
+		return false
+	}
 }
 
-func (c *ZMQConnector) findClientIDByAddress(address string) []byte {
-	id, ok := c.clients[address]
-	if !ok {
-		return []byte{}
+// disconnect notifies the connector that a backend connection to a client has been closed
+func (c *base) disconnect(id string) {
+	select {
+	case c.incoming <- frame{id: id, closed: true}:
+	case <-c.done:
+	}
+}
+
+// handleClosed disconnects the node registered with a closed client connection
+func (c *base) handleClosed(id string) {
+	address := c.findClientAddressByID(id)
+	if address == "" {
+		return
+	}
+	c.removeClient(address, messages.DeregisterClosed)
+}
+
+// checkTimeouts disconnects nodes that have not sent a message within the heartbeat timeout
+func (c *base) checkTimeouts(now time.Time) {
+	for address, seen := range c.lastSeen {
+		if now.Sub(seen) > c.timeout {
+			c.removeClient(address, messages.DeregisterTimeout)
+		}
 	}
-	return id
 }
 
-func (c *ZMQConnector) findClientAddressByID(id []byte) string {
+// removeClient removes a node registration and signals that the node has disconnected
+func (c *base) removeClient(address, reason string) {
+	delete(c.clients, address)
+	delete(c.lastSeen, address)
+
+	log.Printf("Node %s disconnected (%s)", address, reason)
+
+	c.OutputChan <- messages.Deregister{
+		BaseMessage: messages.BaseMessage{Address: address},
+		Reason:      reason,
+	}
+}
+
+// close stops the connector run loop
+func (c *base) close() {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+// closed checks whether the connector has been closed
+func (c *base) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *base) findClientIDByAddress(address string) string {
+	return c.clients[address]
+}
+
+func (c *base) findClientAddressByID(id string) string {
 	for key, client := range c.clients {
-		if reflect.DeepEqual(client, id) {
+		if client == id {
 			return key
 		}
 	}
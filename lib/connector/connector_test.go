@@ -0,0 +1,380 @@
+package connector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/protocol"
+)
+
+// testConn is a client connection to a connector under test
+type testConn interface {
+	Send(data []byte) error
+	Recv() ([]byte, error)
+}
+
+type streamTestConn struct {
+	net.Conn
+}
+
+func (s *streamTestConn) Send(data []byte) error { return WriteFrame(s.Conn, data) }
+func (s *streamTestConn) Recv() ([]byte, error) {
+	s.Conn.SetReadDeadline(time.Now().Add(time.Second))
+	return ReadFrame(s.Conn)
+}
+
+type loopbackTestConn struct {
+	*LoopbackConn
+}
+
+func (l *loopbackTestConn) Recv() ([]byte, error) {
+	select {
+	case data := <-l.LoopbackConn.Recv():
+		return data, nil
+	case <-time.After(time.Second):
+		return nil, fmt.Errorf("timeout awaiting loopback message")
+	}
+}
+
+type wsTestConn struct {
+	*websocket.Conn
+}
+
+func (w *wsTestConn) Send(data []byte) error { return w.WriteMessage(websocket.BinaryMessage, data) }
+func (w *wsTestConn) Recv() ([]byte, error) {
+	w.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := w.ReadMessage()
+	return data, err
+}
+
+func getOutput(t *testing.T, c Connector) interface{} {
+	select {
+	case m := <-c.Output():
+		return m
+	case <-time.After(time.Second):
+		t.Fatalf("Timeout awaiting connector output")
+	}
+	return nil
+}
+
+// testConnector registers a client and exchanges packets using the provided connection
+func testConnector(t *testing.T, c Connector, conn testConn) {
+	send := func(base *protocol.Base) {
+		data, err := proto.Marshal(base)
+		assert.Nil(t, err)
+		assert.Nil(t, conn.Send(data))
+	}
+
+	send(&protocol.Base{Message: &protocol.Base_Register{Register: &protocol.Register{Address: "0x0001", Version: ProtocolVersion}}})
+	assert.EqualValues(t, messages.Register{BaseMessage: messages.BaseMessage{Address: "0x0001"}, Version: ProtocolVersion}, getOutput(t, c))
+
+	send(&protocol.Base{Message: &protocol.Base_Packet{Packet: &protocol.Packet{
+		Info: &protocol.RFInfo{Band: "Sub1GHz", Channel: 1},
+		Data: []byte("test data"),
+	}}})
+	assert.EqualValues(t, messages.Packet{
+		BaseMessage: messages.BaseMessage{Address: "0x0001"},
+		RFInfo:      messages.NewRFInfo("Sub1GHz", 1),
+		Data:        []byte("test data"),
+	}, getOutput(t, c))
+
+	c.Input() <- messages.NewPacket("0x0001", []byte("response data"), messages.NewRFInfo("Sub1GHz", 2))
+	data, err := conn.Recv()
+	assert.Nil(t, err)
+
+	base := protocol.Base{}
+	assert.Nil(t, proto.Unmarshal(data, &base))
+	assert.EqualValues(t, []byte("response data"), base.GetPacket().Data)
+	assert.EqualValues(t, 2, base.GetPacket().Info.Channel)
+}
+
+func TestConnector(t *testing.T) {
+
+	t.Run("Frames messages", func(t *testing.T) {
+		r, w := net.Pipe()
+		go WriteFrame(w, []byte("test data"))
+		data, err := ReadFrame(r)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []byte("test data"), data)
+
+		assert.NotNil(t, WriteFrame(w, make([]byte, MaxFrameLength+1)))
+	})
+
+	t.Run("Selects backends by address", func(t *testing.T) {
+		_, err := NewConnector("carrier-pigeon://coop")
+		assert.NotNil(t, err)
+
+		_, err = NewConnector("stream+tcp://256.0.0.1:0")
+		assert.NotNil(t, err)
+
+		c, err := NewConnector("loopback://address-test")
+		assert.Nil(t, err)
+		assert.IsType(t, &LoopbackConnector{}, c)
+		c.Exit()
+	})
+
+	t.Run("Connects over TCP", func(t *testing.T) {
+		c, err := NewStreamConnector("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		conn, err := net.Dial("tcp", c.Addr().String())
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		testConnector(t, c, &streamTestConn{conn})
+	})
+
+	t.Run("Connects over unix sockets", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "yawns-connector")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		c, err := NewConnector("unix://" + filepath.Join(dir, "yawns.sock"))
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		conn, err := net.Dial("unix", filepath.Join(dir, "yawns.sock"))
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		testConnector(t, c, &streamTestConn{conn})
+	})
+
+	t.Run("Connects in process", func(t *testing.T) {
+		c, err := NewLoopbackConnector("connector-test")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		_, err = NewLoopbackConnector("connector-test")
+		assert.NotNil(t, err)
+
+		conn, err := DialLoopback("connector-test")
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		testConnector(t, c, &loopbackTestConn{conn})
+	})
+
+	t.Run("Does not block sending to closed connectors", func(t *testing.T) {
+		c, err := NewLoopbackConnector("closed-test")
+		assert.Nil(t, err)
+
+		conn, err := DialLoopback("closed-test")
+		assert.Nil(t, err)
+		c.Exit()
+
+		for i := 0; i < 2048; i++ {
+			assert.NotNil(t, conn.Send([]byte("test data")))
+		}
+	})
+
+	t.Run("Connects over websockets", func(t *testing.T) {
+		c, err := NewConnector("ws://127.0.0.1:0/yawns")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		ws, ok := c.(*WebSocketConnector)
+		assert.True(t, ok)
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+ws.Addr().String()+"/yawns", nil)
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		testConnector(t, c, &wsTestConn{conn})
+	})
+
+	t.Run("Connects over websockets using JSON", func(t *testing.T) {
+		c, err := NewWebSocketConnector("127.0.0.1:0", "/")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+c.Addr().String()+"/", nil)
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		err = conn.WriteMessage(websocket.TextMessage, []byte(`{"register": {"address": "0x0002", "version": 1}}`))
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.Register{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Version: ProtocolVersion}, getOutput(t, c))
+
+		c.Input() <- messages.NewPacket("0x0002", []byte("response data"), messages.NewRFInfo("Sub1GHz", 2))
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		messageType, data, err := conn.ReadMessage()
+		assert.Nil(t, err)
+		assert.EqualValues(t, websocket.TextMessage, messageType)
+
+		base := protocol.Base{}
+		assert.Nil(t, jsonpb.UnmarshalString(string(data), &base))
+		assert.EqualValues(t, []byte("response data"), base.GetPacket().Data)
+	})
+
+	t.Run("Handles disconnection", func(t *testing.T) {
+		c, err := NewLoopbackConnector("disconnect-test")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		send := func(conn *LoopbackConn, base *protocol.Base) {
+			data, err := proto.Marshal(base)
+			assert.Nil(t, err)
+			assert.Nil(t, conn.Send(data))
+		}
+		register := &protocol.Base{Message: &protocol.Base_Register{Register: &protocol.Register{Address: "0x0001", Version: ProtocolVersion}}}
+		registered := messages.Register{BaseMessage: messages.BaseMessage{Address: "0x0001"}, Version: ProtocolVersion}
+		deregistered := func(reason string) messages.Deregister {
+			return messages.Deregister{BaseMessage: messages.BaseMessage{Address: "0x0001"}, Reason: reason}
+		}
+
+		conn := c.Connect()
+		send(conn, register)
+		assert.EqualValues(t, registered, getOutput(t, c))
+
+		t.Run("Ignores duplicate registration", func(t *testing.T) {
+			send(conn, register)
+			send(conn, &protocol.Base{Message: &protocol.Base_Event{Event: &protocol.Event{Data: "test"}}})
+			assert.IsType(t, messages.Event{}, getOutput(t, c))
+		})
+
+		t.Run("Remaps re-registered nodes", func(t *testing.T) {
+			next := c.Connect()
+			send(next, register)
+			assert.EqualValues(t, deregistered(messages.DeregisterReplaced), getOutput(t, c))
+			assert.EqualValues(t, registered, getOutput(t, c))
+
+			c.Input() <- messages.NewPacket("0x0001", []byte("data"), messages.NewRFInfo("Sub1GHz", 1))
+			select {
+			case <-next.Recv():
+			case <-time.After(time.Second):
+				t.Errorf("Timeout awaiting packet on re-registered connection")
+			}
+
+			conn.Close()
+			conn = next
+		})
+
+		t.Run("Handles deregistration", func(t *testing.T) {
+			send(conn, &protocol.Base{Message: &protocol.Base_Deregister{Deregister: &protocol.Deregister{Address: "0x0001"}}})
+			assert.EqualValues(t, deregistered(messages.DeregisterRequested), getOutput(t, c))
+
+			send(conn, register)
+			assert.EqualValues(t, registered, getOutput(t, c))
+		})
+
+		t.Run("Handles closed connections", func(t *testing.T) {
+			conn.Close()
+			assert.EqualValues(t, deregistered(messages.DeregisterClosed), getOutput(t, c))
+		})
+
+		t.Run("Handles heartbeat timeouts", func(t *testing.T) {
+			c.SetHeartbeatTimeout(200 * time.Millisecond)
+			defer c.SetHeartbeatTimeout(0)
+
+			conn = c.Connect()
+			defer conn.Close()
+			send(conn, register)
+			assert.EqualValues(t, registered, getOutput(t, c))
+
+			// Heartbeats keep the node connected
+			for i := 0; i < 6; i++ {
+				time.Sleep(50 * time.Millisecond)
+				send(conn, &protocol.Base{Message: &protocol.Base_Heartbeat{Heartbeat: &protocol.Heartbeat{}}})
+			}
+			select {
+			case m := <-c.Output():
+				t.Errorf("Unexpected connector output: %+v", m)
+			default:
+			}
+
+			assert.EqualValues(t, deregistered(messages.DeregisterTimeout), getOutput(t, c))
+		})
+	})
+
+	t.Run("Negotiates registration", func(t *testing.T) {
+		c, err := NewLoopbackConnector("registration-test")
+		assert.Nil(t, err)
+		defer c.Exit()
+
+		register := func(r *protocol.Register) *protocol.RegisterAck {
+			conn := &loopbackTestConn{c.Connect()}
+			defer conn.Close()
+
+			data, err := proto.Marshal(&protocol.Base{Message: &protocol.Base_Register{Register: r}})
+			assert.Nil(t, err)
+			assert.Nil(t, conn.Send(data))
+
+			if r.Version == ProtocolVersion && len(r.Features) == 0 {
+				getOutput(t, c)
+				c.Input() <- messages.RegisterAck{
+					BaseMessage: messages.BaseMessage{Address: r.Address},
+					Accepted:    true,
+					Bands:       []messages.BandInfo{{Name: "Sub1GHz", Frequency: 433e6, Baud: 10e3, Channels: 8}},
+				}
+			}
+
+			data, err = conn.Recv()
+			assert.Nil(t, err)
+			base := protocol.Base{}
+			assert.Nil(t, proto.Unmarshal(data, &base))
+			return base.GetRegisterAck()
+		}
+
+		t.Run("Rejects mismatched versions", func(t *testing.T) {
+			ack := register(&protocol.Register{Address: "0x0001"})
+			assert.False(t, ack.Accepted)
+			assert.Contains(t, ack.Reason, "version")
+			assert.EqualValues(t, ProtocolVersion, ack.Version)
+		})
+
+		t.Run("Rejects unsupported features", func(t *testing.T) {
+			ack := register(&protocol.Register{Address: "0x0001", Version: ProtocolVersion, Features: []string{"teleportation"}})
+			assert.False(t, ack.Accepted)
+			assert.Contains(t, ack.Reason, "teleportation")
+		})
+
+		t.Run("Acknowledges registration", func(t *testing.T) {
+			c.SetHeartbeatTimeout(time.Minute)
+			defer c.SetHeartbeatTimeout(0)
+
+			ack := register(&protocol.Register{Address: "0x0001", Version: ProtocolVersion})
+			assert.True(t, ack.Accepted)
+			assert.EqualValues(t, Features, ack.Features)
+			assert.EqualValues(t, 60000, ack.HeartbeatTimeout)
+			assert.EqualValues(t, []*protocol.BandInfo{{Name: "Sub1GHz", Frequency: 433e6, Baud: 10e3, Channels: 8}}, ack.Bands)
+
+			// Connection is closed on return
+			assert.IsType(t, messages.Deregister{}, getOutput(t, c))
+		})
+
+		t.Run("Removes rejected nodes", func(t *testing.T) {
+			conn := c.Connect()
+			defer conn.Close()
+
+			data, _ := proto.Marshal(&protocol.Base{Message: &protocol.Base_Register{
+				Register: &protocol.Register{Address: "0x0002", Version: ProtocolVersion}}})
+			assert.Nil(t, conn.Send(data))
+			getOutput(t, c)
+
+			c.Input() <- messages.RegisterAck{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Reason: "unknown node"}
+			<-conn.Recv()
+
+			data, _ = proto.Marshal(&protocol.Base{Message: &protocol.Base_Event{Event: &protocol.Event{Data: "test"}}})
+			assert.Nil(t, conn.Send(data))
+			select {
+			case m := <-c.Output():
+				t.Errorf("Unexpected connector output: %+v", m)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package connector
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Loopback connectors are registered by name for in-process clients to dial
+var (
+	loopbackMu         sync.Mutex
+	loopbackConnectors = make(map[string]*LoopbackConnector)
+)
+
+// LoopbackConnector is an in-process connector for go tests and embedded nodes
+// Messages are carried as encoded protobufs to exercise the same protocol handling as other backends
+type LoopbackConnector struct {
+	base
+	name string
+
+	mu    sync.Mutex
+	conns map[string]*LoopbackConn
+	count int
+}
+
+// LoopbackConn is a client connection to a loopback connector
+type LoopbackConn struct {
+	id        string
+	connector *LoopbackConnector
+	recv      chan []byte
+	once      sync.Once
+}
+
+// NewLoopbackConnector creates a loopback connector registered with the provided name
+func NewLoopbackConnector(name string) (*LoopbackConnector, error) {
+	loopbackMu.Lock()
+	defer loopbackMu.Unlock()
+
+	if _, ok := loopbackConnectors[name]; ok {
+		return nil, fmt.Errorf("loopback connector %s already exists", name)
+	}
+
+	c := LoopbackConnector{
+		name:  name,
+		conns: make(map[string]*LoopbackConn),
+	}
+	c.base = newBase(c.sendFrame)
+	loopbackConnectors[name] = &c
+
+	go c.run()
+
+	return &c, nil
+}
+
+// DialLoopback connects to the loopback connector registered with the provided name
+func DialLoopback(name string) (*LoopbackConn, error) {
+	loopbackMu.Lock()
+	c, ok := loopbackConnectors[name]
+	loopbackMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no loopback connector found with name %s", name)
+	}
+
+	return c.Connect(), nil
+}
+
+// Connect creates a new client connection to the connector
+func (c *LoopbackConnector) Connect() *LoopbackConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count++
+	conn := LoopbackConn{
+		id:        fmt.Sprintf("loopback-%d", c.count),
+		connector: c,
+		recv:      make(chan []byte, 1024),
+	}
+	c.conns[conn.id] = &conn
+
+	return &conn
+}
+
+func (c *LoopbackConnector) sendFrame(id string, data []byte) error {
+	c.mu.Lock()
+	conn, ok := c.conns[id]
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no connection found for client %s", id)
+	}
+
+	select {
+	case conn.recv <- data:
+		return nil
+	default:
+		return fmt.Errorf("receive buffer full for client %s", id)
+	}
+}
+
+// Exit closes the connector and any open connections
+func (c *LoopbackConnector) Exit() {
+	loopbackMu.Lock()
+	if loopbackConnectors[c.name] == c {
+		delete(loopbackConnectors, c.name)
+	}
+	loopbackMu.Unlock()
+
+	c.close()
+
+	c.mu.Lock()
+	conns := c.conns
+	c.conns = make(map[string]*LoopbackConn)
+	c.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.closeRecv()
+	}
+}
+
+// Send sends an encoded protocol message to the connector
+func (l *LoopbackConn) Send(data []byte) error {
+	if !l.connector.forward(l.id, data) {
+		return fmt.Errorf("loopback connector closed")
+	}
+	return nil
+}
+
+// Recv fetches the channel of encoded protocol messages from the connector
+func (l *LoopbackConn) Recv() <-chan []byte {
+	return l.recv
+}
+
+// Close closes the connection
+func (l *LoopbackConn) Close() {
+	l.connector.mu.Lock()
+	delete(l.connector.conns, l.id)
+	l.connector.mu.Unlock()
+
+	l.closeRecv()
+	l.connector.disconnect(l.id)
+}
+
+func (l *LoopbackConn) closeRecv() {
+	l.once.Do(func() { close(l.recv) })
+}
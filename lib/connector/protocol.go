@@ -11,6 +11,8 @@ package connector
 
 import (
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -19,20 +21,63 @@ import (
 	"github.com/ryankurte/yawns/lib/types"
 )
 
-// handleIncoming handles incoming messages from external sources (ie. from nodes to ONS)
-// This maps from Protobuf to ONS messages
-func (c *ZMQConnector) handleIncoming(data [][]byte) error {
+// ProtocolVersion is the protocol version implemented by the simulator
+// This must match ONS_PROTOCOL_VERSION in libyawns, and is incremented on incompatible protocol changes
+const ProtocolVersion uint32 = 1
+
+// Protocol features supported by the simulator
+const (
+	FeatureRadios     = "radios"      // Multiple radios per band
+	FeatureRFMetadata = "rf-metadata" // Received packet RSSI, SNR, LQI and timing information
+	FeatureHeartbeat  = "heartbeat"   // Heartbeat messages and timeouts
+)
 
-	if len(data) != 2 {
-		return fmt.Errorf("Error parsing message, required 2 parts")
+// Features lists the protocol features supported by the simulator
+var Features = []string{FeatureRadios, FeatureRFMetadata, FeatureHeartbeat}
+
+// checkRegistration checks a registration is compatible with the simulator
+func checkRegistration(r *protocol.Register) error {
+	if r.Version != ProtocolVersion {
+		return fmt.Errorf("protocol version mismatch (node: %d simulator: %d)", r.Version, ProtocolVersion)
 	}
+	for _, f := range r.Features {
+		supported := false
+		for _, s := range Features {
+			if f == s {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("unsupported feature '%s'", f)
+		}
+	}
+	return nil
+}
 
-	// Fetch ZMQ client ID
-	clientID := data[0]
+// rejectRegistration sends a registration rejection to a client
+func (c *base) rejectRegistration(clientID string, reason string) error {
+	data, err := proto.Marshal(&protocol.Base{Message: &protocol.Base_RegisterAck{
+		RegisterAck: &protocol.RegisterAck{
+			Accepted: false,
+			Reason:   reason,
+			Version:  ProtocolVersion,
+			Features: Features,
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	return c.send(clientID, data)
+}
+
+// handleIncoming handles incoming messages from external sources (ie. from nodes to ONS)
+// This maps from Protobuf to ONS messages
+func (c *base) handleIncoming(clientID string, data []byte) error {
 
 	// Decode message
 	message := protocol.Base{}
-	if err := proto.Unmarshal(data[1], &message); err != nil {
+	if err := proto.Unmarshal(data, &message); err != nil {
 		return fmt.Errorf("Error parsing protobuf message (%s)", err)
 	}
 
@@ -41,29 +86,55 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 		// Bind address to ID lookup for sending
 		address := m.Register.Address
 
-		if _, ok := c.clients[address]; !ok {
-			// Save to list
-			c.clients[address] = clientID
-			// Send connected event
-			c.OutputChan <- messages.Register{
-				BaseMessage: messages.BaseMessage{Address: address},
+		// Reject incompatible nodes
+		if err := checkRegistration(m.Register); err != nil {
+			if sendErr := c.rejectRegistration(clientID, err.Error()); sendErr != nil {
+				log.Printf("Send error: %s", sendErr)
+			}
+			return fmt.Errorf("Rejected registration for node %s (%s)", address, err)
+		}
+
+		if id, ok := c.clients[address]; ok {
+			// Ignore duplicate registrations
+			if id == clientID {
+				c.lastSeen[address] = time.Now()
+				return nil
 			}
+			// Client ID has changed (ie. the node has restarted), drop the previous registration
+			c.removeClient(address, messages.DeregisterReplaced)
+		}
+
+		// Save to list
+		c.clients[address] = clientID
+		c.lastSeen[address] = time.Now()
+		// Send connected event
+		c.OutputChan <- messages.Register{
+			BaseMessage: messages.BaseMessage{Address: address},
+			Version:     m.Register.Version,
+			Features:    m.Register.Features,
 		}
 
 		return nil
 	}
 
-	// Perform ZMQ Client ID to address lookup
+	// Perform client ID to address lookup
 	address := c.findClientAddressByID(clientID)
 	if address == "" {
 		return fmt.Errorf("Received message for unknown clientID (%s)", clientID)
 	}
 
+	// Any message from a node indicates liveness
+	c.lastSeen[address] = time.Now()
+
 	//log.Printf("Incoming From: %s Message: %+v", address, &message)
 
 	// Handle messages
 	switch m := message.GetMessage().(type) {
 	case *protocol.Base_Deregister:
+		c.removeClient(address, messages.DeregisterRequested)
+
+	// Heartbeats only update liveness
+	case *protocol.Base_Heartbeat:
 
 	// Receive a packet from a device
 	case *protocol.Base_Packet:
@@ -71,6 +142,7 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 			BaseMessage: messages.BaseMessage{Address: address},
 			RFInfo: messages.RFInfo{
 				Band:    m.Packet.Info.Band,
+				Radio:   m.Packet.Info.Radio,
 				Channel: m.Packet.Info.Channel},
 			Data: m.Packet.Data,
 		}
@@ -95,6 +167,7 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 			BaseMessage: messages.BaseMessage{Address: address},
 			RFInfo: messages.RFInfo{
 				Band:    m.StateSet.Info.Band,
+				Radio:   m.StateSet.Info.Radio,
 				Channel: m.StateSet.Info.Channel,
 			},
 			State: state,
@@ -111,6 +184,7 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 			BaseMessage: messages.BaseMessage{Address: address},
 			RFInfo: messages.RFInfo{
 				Band:    m.RssiReq.Info.Band,
+				Radio:   m.RssiReq.Info.Radio,
 				Channel: m.RssiReq.Info.Channel,
 			},
 		}
@@ -119,7 +193,8 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 		c.OutputChan <- messages.StateRequest{
 			BaseMessage: messages.BaseMessage{Address: address},
 			RFInfo: messages.RFInfo{
-				Band: m.StateReq.Info.Band,
+				Band:  m.StateReq.Info.Band,
+				Radio: m.StateReq.Info.Radio,
 			},
 		}
 
@@ -136,6 +211,12 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 			Name:        m.FieldReq.Name,
 		}
 
+	case *protocol.Base_SensorReq:
+		c.OutputChan <- messages.SensorRequest{
+			BaseMessage: messages.BaseMessage{Address: address},
+			Name:        m.SensorReq.Name,
+		}
+
 	default:
 		return fmt.Errorf("[WARNING] Connector.handleIncoming: unhandled message type (%t)", m)
 	}
@@ -145,7 +226,7 @@ func (c *ZMQConnector) handleIncoming(data [][]byte) error {
 
 // handleOutgoing handles outgoing messages (ie. from ONS to nodes)
 // This maps from ONS messages to protobufs for external use
-func (c *ZMQConnector) handleOutgoing(message interface{}) error {
+func (c *base) handleOutgoing(message interface{}) error {
 
 	base := protocol.Base{}
 	address := ""
@@ -155,7 +236,16 @@ func (c *ZMQConnector) handleOutgoing(message interface{}) error {
 		address = m.Address
 		base.Message = &protocol.Base_Packet{
 			Packet: &protocol.Packet{
-				Info: &protocol.RFInfo{Band: m.Band},
+				Info: &protocol.RFInfo{
+					Band:      m.Band,
+					Radio:     m.Radio,
+					Channel:   m.Channel,
+					Rssi:      float32(m.RSSI),
+					Snr:       float32(m.SNR),
+					Lqi:       uint32(m.LQI),
+					StartTime: uint64(m.StartTime / time.Microsecond),
+					EndTime:   uint64(m.EndTime / time.Microsecond),
+				},
 				Data: m.Data,
 			},
 		}
@@ -178,7 +268,7 @@ func (c *ZMQConnector) handleOutgoing(message interface{}) error {
 
 		base.Message = &protocol.Base_StateResp{
 			StateResp: &protocol.StateResp{
-				Info:  &protocol.RFInfo{Band: m.Band},
+				Info:  &protocol.RFInfo{Band: m.Band, Radio: m.Radio},
 				State: state,
 			},
 		}
@@ -187,7 +277,7 @@ func (c *ZMQConnector) handleOutgoing(message interface{}) error {
 		address = m.Address
 		base.Message = &protocol.Base_RssiResp{
 			RssiResp: &protocol.RSSIResp{
-				Info: &protocol.RFInfo{Band: m.Band, Channel: m.Channel},
+				Info: &protocol.RFInfo{Band: m.Band, Radio: m.Radio, Channel: m.Channel},
 				Rssi: m.RSSI,
 			},
 		}
@@ -195,7 +285,53 @@ func (c *ZMQConnector) handleOutgoing(message interface{}) error {
 		address = m.Address
 		base.Message = &protocol.Base_SendComplete{
 			SendComplete: &protocol.SendComplete{
-				Info: &protocol.RFInfo{Band: m.Band},
+				Info: &protocol.RFInfo{Band: m.Band, Radio: m.Radio},
+			},
+		}
+
+	case messages.RegisterAck:
+		address = m.Address
+		ack := protocol.RegisterAck{
+			Accepted:         m.Accepted,
+			Reason:           m.Reason,
+			Version:          ProtocolVersion,
+			Features:         Features,
+			HeartbeatTimeout: uint32(c.timeout / time.Millisecond),
+		}
+		for _, b := range m.Bands {
+			ack.Bands = append(ack.Bands, &protocol.BandInfo{
+				Name:           b.Name,
+				Frequency:      b.Frequency,
+				Baud:           b.Baud,
+				Channels:       b.Channels,
+				ChannelSpacing: b.ChannelSpacing,
+			})
+		}
+		base.Message = &protocol.Base_RegisterAck{RegisterAck: &ack}
+
+		// Remove rejected nodes once the rejection has been sent
+		if !m.Accepted {
+			defer func() {
+				delete(c.clients, address)
+				delete(c.lastSeen, address)
+			}()
+		}
+
+	case messages.FieldSet:
+		address = m.Address
+		base.Message = &protocol.Base_FieldSet{
+			FieldSet: &protocol.FieldSet{
+				Name: m.Name,
+				Data: m.Data,
+			},
+		}
+
+	case messages.Command:
+		address = m.Address
+		base.Message = &protocol.Base_Command{
+			Command: &protocol.Command{
+				Name: m.Name,
+				Data: m.Data,
 			},
 		}
 
@@ -208,6 +344,16 @@ func (c *ZMQConnector) handleOutgoing(message interface{}) error {
 			},
 		}
 
+	case messages.SensorResponse:
+		address = m.Address
+		base.Message = &protocol.Base_SensorResp{
+			SensorResp: &protocol.SensorResp{
+				Name:  m.Name,
+				Value: m.Value,
+				Error: m.Error,
+			},
+		}
+
 	default:
 		return fmt.Errorf("[WARNING] Connector.handleOutgoing: unsupported message type (%T)", message)
 	}
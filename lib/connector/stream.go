@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// MaxFrameLength is the maximum length of a length-prefixed protocol message
+const MaxFrameLength = 1 << 20
+
+// WriteFrame writes a length-prefixed message (32-bit big endian length followed by the message data)
+func WriteFrame(w io.Writer, data []byte) error {
+	if len(data) > MaxFrameLength {
+		return fmt.Errorf("frame length %d exceeds maximum (%d)", len(data), MaxFrameLength)
+	}
+
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads a length-prefixed message
+func ReadFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length > MaxFrameLength {
+		return nil, fmt.Errorf("frame length %d exceeds maximum (%d)", length, MaxFrameLength)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// StreamConnector is a connector instance using length-prefixed protobuf messages over stream sockets
+type StreamConnector struct {
+	base
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns map[string]net.Conn
+	count int
+}
+
+// NewStreamConnector creates a stream connector listening on the provided network ("tcp" or "unix") and address
+func NewStreamConnector(network, address string) (*StreamConnector, error) {
+	if network == "unix" {
+		// Remove stale sockets from previous instances
+		os.Remove(address)
+	}
+
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := StreamConnector{
+		listener: l,
+		conns:    make(map[string]net.Conn),
+	}
+	c.base = newBase(c.sendFrame)
+
+	go c.accept()
+	go c.run()
+
+	return &c, nil
+}
+
+// Addr fetches the address the connector is listening on
+func (c *StreamConnector) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+func (c *StreamConnector) sendFrame(id string, data []byte) error {
+	c.mu.Lock()
+	conn, ok := c.conns[id]
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no connection found for client %s", id)
+	}
+
+	return WriteFrame(conn, data)
+}
+
+// accept accepts incoming connections until the listener is closed
+func (c *StreamConnector) accept() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.count++
+		id := fmt.Sprintf("%s-%d", conn.RemoteAddr(), c.count)
+		c.conns[id] = conn
+		c.mu.Unlock()
+
+		go c.receive(id, conn)
+	}
+}
+
+// receive forwards messages from a connection to the connector
+func (c *StreamConnector) receive(id string, conn net.Conn) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.conns, id)
+		c.mu.Unlock()
+		conn.Close()
+		c.disconnect(id)
+	}()
+
+	for {
+		data, err := ReadFrame(conn)
+		if err != nil {
+			if err != io.EOF && !c.closed() {
+				log.Printf("Connection error (client %s): %s", id, err)
+			}
+			return
+		}
+		if !c.forward(id, data) {
+			return
+		}
+	}
+}
+
+// Exit closes the listener and any open connections
+func (c *StreamConnector) Exit() {
+	c.close()
+	c.listener.Close()
+
+	c.mu.Lock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.mu.Unlock()
+}
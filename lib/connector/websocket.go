@@ -0,0 +1,166 @@
+package connector
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+
+	"github.com/ryankurte/yawns/lib/protocol"
+)
+
+// wsConn is a websocket client connection
+// Responses are sent in the format (binary protobuf or JSON) of the last message received from the client
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+	json bool
+}
+
+// WebSocketConnector is a connector instance using websockets, for browser and WASM nodes
+// Messages are protocol.Base protobufs, sent as binary messages or as JSON in text messages
+type WebSocketConnector struct {
+	base
+	listener net.Listener
+	server   *http.Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[string]*wsConn
+	count int
+}
+
+// NewWebSocketConnector creates a websocket connector listening on the provided address and path
+func NewWebSocketConnector(address, path string) (*WebSocketConnector, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	c := WebSocketConnector{
+		listener: l,
+		conns:    make(map[string]*wsConn),
+		upgrader: websocket.Upgrader{
+			// Nodes are commonly served from a different origin to the simulator
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+	c.base = newBase(c.sendFrame)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, c.handleConnection)
+	c.server = &http.Server{Handler: mux}
+
+	go c.server.Serve(l)
+	go c.run()
+
+	return &c, nil
+}
+
+// Addr fetches the address the connector is listening on
+func (c *WebSocketConnector) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+func (c *WebSocketConnector) sendFrame(id string, data []byte) error {
+	c.mu.Lock()
+	conn, ok := c.conns[id]
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no connection found for client %s", id)
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if !conn.json {
+		return conn.conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+
+	message := protocol.Base{}
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	text, err := (&jsonpb.Marshaler{}).MarshalToString(&message)
+	if err != nil {
+		return err
+	}
+	return conn.conn.WriteMessage(websocket.TextMessage, []byte(text))
+}
+
+// handleConnection upgrades and receives messages from websocket connections
+func (c *WebSocketConnector) handleConnection(w http.ResponseWriter, r *http.Request) {
+	ws, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	conn := &wsConn{conn: ws}
+
+	c.mu.Lock()
+	c.count++
+	id := fmt.Sprintf("%s-%d", ws.RemoteAddr(), c.count)
+	c.conns[id] = conn
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.conns, id)
+		c.mu.Unlock()
+		ws.Close()
+		c.disconnect(id)
+	}()
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && !c.closed() {
+				log.Printf("Connection error (client %s): %s", id, err)
+			}
+			return
+		}
+
+		// Convert JSON messages to protobufs for common handling
+		if messageType == websocket.TextMessage {
+			message := protocol.Base{}
+			if err := jsonpb.Unmarshal(bytes.NewReader(data), &message); err != nil {
+				log.Printf("Error parsing JSON message (client %s): %s", id, err)
+				continue
+			}
+			if data, err = proto.Marshal(&message); err != nil {
+				continue
+			}
+		}
+
+		conn.mu.Lock()
+		conn.json = messageType == websocket.TextMessage
+		conn.mu.Unlock()
+
+		if !c.forward(id, data) {
+			return
+		}
+	}
+}
+
+// Exit closes the websocket server and any open connections
+func (c *WebSocketConnector) Exit() {
+	c.close()
+	c.server.Close()
+
+	c.mu.Lock()
+	for _, conn := range c.conns {
+		conn.conn.Close()
+	}
+	c.mu.Unlock()
+}
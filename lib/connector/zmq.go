@@ -0,0 +1,60 @@
+//go:build zmq
+// +build zmq
+
+package connector
+
+import (
+	"github.com/zeromq/goczmq"
+)
+
+const (
+	// DefaultBindAddress is the default simulator bind address, using ZMQ as required by libyawns
+	DefaultBindAddress = "tcp://*:10109"
+	// DefaultClientAddress is the default simulator address for clients
+	DefaultClientAddress = "tcp://localhost:10109"
+)
+
+// ZMQConnector is a connector instance using ZMQ messaging
+type ZMQConnector struct {
+	base
+	ch *goczmq.Channeler
+}
+
+// NewZMQConnector creates a new ZMQ based connector instance and binds a connector instance and handler to the provided address
+func NewZMQConnector(bindAddress string) *ZMQConnector {
+	c := ZMQConnector{}
+
+	c.ch = goczmq.NewRouterChanneler(bindAddress)
+	c.base = newBase(func(id string, data []byte) error {
+		c.ch.SendChan <- [][]byte{[]byte(id), data}
+		return nil
+	})
+
+	go c.receive()
+	go c.run()
+
+	return &c
+}
+
+func newZMQConnector(bindAddress string) (Connector, error) {
+	return NewZMQConnector(bindAddress), nil
+}
+
+// receive forwards ZMQ messages to the connector
+func (c *ZMQConnector) receive() {
+	for p := range c.ch.RecvChan {
+		// Router messages consist of the client ID and message data
+		if len(p) != 2 {
+			continue
+		}
+		if !c.forward(string(p[0]), p[1]) {
+			return
+		}
+	}
+}
+
+// Exit a ZMQConnector instance
+func (c *ZMQConnector) Exit() {
+	c.close()
+	c.ch.Destroy()
+}
@@ -0,0 +1,20 @@
+//go:build !zmq
+// +build !zmq
+
+package connector
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultBindAddress is the default simulator bind address, using the native stream backend
+	DefaultBindAddress = "stream+tcp://:10109"
+	// DefaultClientAddress is the default simulator address for clients
+	DefaultClientAddress = "stream+tcp://localhost:10109"
+)
+
+// newZMQConnector is unavailable in builds without ZMQ support
+func newZMQConnector(bindAddress string) (Connector, error) {
+	return nil, fmt.Errorf("ZMQ connector unavailable (build with -tags zmq), use a stream+tcp://, unix:// or loopback:// address")
+}
@@ -0,0 +1,213 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// MaxBufferedLines is the maximum number of output lines retained for matching by Expect
+const MaxBufferedLines = 1024
+
+// debugBufferLength is the number of output chunks buffered for the interactive console
+const debugBufferLength = 64
+
+// Console bridges a node console pseudo-terminal to the simulator
+// Node output is logged and buffered for matching, and mirrored to an interactive pseudo-terminal for debugging
+type Console struct {
+	Address   string
+	NodePath  string // Path to the pseudo-terminal used by the node
+	DebugPath string // Path to the interactive pseudo-terminal
+
+	node, nodeTTY   *os.File
+	debug, debugTTY *os.File
+	debugOut        chan []byte
+
+	log func(address, direction, data string)
+
+	mu      sync.Mutex
+	partial []byte
+	lines   []string
+	updated chan struct{}
+}
+
+// NewConsole creates a console for a node with links in the provided directory
+func NewConsole(dir, address string, log func(address, direction, data string)) (*Console, error) {
+	c := Console{
+		Address:   address,
+		NodePath:  fmt.Sprintf("%s/%s.node", dir, address),
+		DebugPath: fmt.Sprintf("%s/%s", dir, address),
+		debugOut:  make(chan []byte, debugBufferLength),
+		log:       log,
+		lines:     make([]string, 0),
+		updated:   make(chan struct{}),
+	}
+
+	var err error
+	if c.node, c.nodeTTY, err = openRaw(c.NodePath); err != nil {
+		return nil, err
+	}
+	if c.debug, c.debugTTY, err = openRaw(c.DebugPath); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	go c.readNode()
+	go c.readDebug()
+	go c.writeDebug()
+
+	return &c, nil
+}
+
+// openRaw opens a pseudo-terminal in raw mode and links the terminal to the provided path
+// The terminal is held open so the console persists while nodes or users attach and detach
+func openRaw(path string) (*os.File, *os.File, error) {
+	p, tty, err := pty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err = term.MakeRaw(int(tty.Fd())); err != nil {
+		p.Close()
+		tty.Close()
+		return nil, nil, err
+	}
+
+	os.Remove(path)
+	if err = os.Symlink(tty.Name(), path); err != nil {
+		p.Close()
+		tty.Close()
+		return nil, nil, err
+	}
+
+	return p, tty, nil
+}
+
+// Send writes a line to the node console
+func (c *Console) Send(line string) error {
+	// Clear buffered output so Expect matches responses to this line
+	c.mu.Lock()
+	c.lines = c.lines[:0]
+	c.mu.Unlock()
+
+	c.log(c.Address, "tx", line)
+
+	_, err := c.node.Write([]byte(line + "\n"))
+	return err
+}
+
+// Expect awaits a line of node console output matching the provided pattern
+// Output received since the last Send is matched, matched lines are consumed
+func (c *Console) Expect(pattern *regexp.Regexp, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		for i, line := range c.lines {
+			if pattern.MatchString(line) {
+				c.lines = c.lines[i+1:]
+				c.mu.Unlock()
+				c.log(c.Address, "expect", fmt.Sprintf("matched '%s'", pattern))
+				return nil
+			}
+		}
+		updated := c.updated
+		c.mu.Unlock()
+
+		select {
+		case <-updated:
+		case <-timer.C:
+			c.log(c.Address, "expect", fmt.Sprintf("timeout '%s'", pattern))
+			return fmt.Errorf("console %s: timeout awaiting '%s' after %s", c.Address, pattern, timeout)
+		}
+	}
+}
+
+// Close closes the console and removes the console links
+func (c *Console) Close() {
+	for _, f := range []*os.File{c.node, c.nodeTTY, c.debug, c.debugTTY} {
+		if f != nil {
+			f.Close()
+		}
+	}
+	os.Remove(c.NodePath)
+	os.Remove(c.DebugPath)
+}
+
+// readNode receives output from the node, mirroring it to the interactive console
+func (c *Console) readNode() {
+	buff := make([]byte, 1024)
+	for {
+		n, err := c.node.Read(buff)
+		if err != nil {
+			close(c.debugOut)
+			return
+		}
+
+		data := append([]byte{}, buff[:n]...)
+		select {
+		case c.debugOut <- data:
+		default:
+			// Drop output if the interactive console is not being read
+		}
+
+		c.handleOutput(data)
+	}
+}
+
+// handleOutput splits node output into lines for logging and matching
+func (c *Console) handleOutput(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial = append(c.partial, data...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(c.partial[:i]), "\r")
+		c.partial = c.partial[i+1:]
+
+		c.log(c.Address, "rx", line)
+
+		c.lines = append(c.lines, line)
+		if len(c.lines) > MaxBufferedLines {
+			c.lines = c.lines[len(c.lines)-MaxBufferedLines:]
+		}
+	}
+
+	close(c.updated)
+	c.updated = make(chan struct{})
+}
+
+// readDebug passes interactive console input to the node
+func (c *Console) readDebug() {
+	buff := make([]byte, 1024)
+	for {
+		n, err := c.debug.Read(buff)
+		if err != nil {
+			return
+		}
+		if _, err := c.node.Write(buff[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// writeDebug writes node output to the interactive console
+func (c *Console) writeDebug() {
+	for data := range c.debugOut {
+		if _, err := c.debug.Write(data); err != nil {
+			return
+		}
+	}
+}
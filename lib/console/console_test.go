@@ -0,0 +1,94 @@
+package console
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yawns-console")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "console.csv")
+	c, err := NewConsoles(&config.Consoles{Dir: dir, LogFile: logFile}, []string{"0x0001"})
+	if err != nil {
+		t.Skipf("Unable to allocate pseudo-terminals: %s", err)
+	}
+	defer c.Close()
+
+	path, ok := c.Path("0x0001")
+	assert.True(t, ok)
+	assert.EqualValues(t, filepath.Join(dir, "0x0001.node"), path)
+
+	// Attach to the consoles as a node and as a user
+	node, err := os.OpenFile(path, os.O_RDWR, 0)
+	assert.Nil(t, err)
+	defer node.Close()
+	nodeReader := bufio.NewReader(node)
+
+	user, err := os.OpenFile(filepath.Join(dir, "0x0001"), os.O_RDWR, 0)
+	assert.Nil(t, err)
+	defer user.Close()
+	userReader := bufio.NewReader(user)
+
+	t.Run("Sends lines to nodes", func(t *testing.T) {
+		assert.Nil(t, c.Send("0x0001", "help"))
+		line, err := nodeReader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.EqualValues(t, "help\n", line)
+	})
+
+	t.Run("Matches node output", func(t *testing.T) {
+		node.WriteString("commands: help, reset\r\n")
+		assert.Nil(t, c.Expect("0x0001", regexp.MustCompile("^commands:"), time.Second))
+	})
+
+	t.Run("Consumes matched output", func(t *testing.T) {
+		assert.NotNil(t, c.Expect("0x0001", regexp.MustCompile("^commands:"), 100*time.Millisecond))
+	})
+
+	t.Run("Mirrors node output to interactive consoles", func(t *testing.T) {
+		line, err := userReader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.EqualValues(t, "commands: help, reset\r\n", line)
+	})
+
+	t.Run("Passes interactive input to nodes", func(t *testing.T) {
+		user.WriteString("reset\n")
+		line, err := nodeReader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.EqualValues(t, "reset\n", line)
+	})
+
+	t.Run("Rejects unknown nodes", func(t *testing.T) {
+		assert.NotNil(t, c.Send("0x0002", "help"))
+	})
+
+	t.Run("Logs console output", func(t *testing.T) {
+		c.Close()
+
+		f, err := os.Open(logFile)
+		assert.Nil(t, err)
+		defer f.Close()
+
+		records, err := csv.NewReader(f).ReadAll()
+		assert.Nil(t, err)
+		assert.True(t, len(records) >= 4)
+		assert.EqualValues(t, []string{"0x0001", "tx", "help"}, records[1][1:])
+		assert.EqualValues(t, []string{"0x0001", "rx", "commands: help, reset"}, records[2][1:])
+
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "Removes console links")
+	})
+}
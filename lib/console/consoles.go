@@ -0,0 +1,137 @@
+/**
+ * OpenNetworkSim Console Package
+ * Bridges node serial consoles over pseudo-terminals, allowing node console output to be logged with simulation
+ * timestamps, scripted interaction from simulation updates, and interactive debugging of individual nodes
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package console
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+)
+
+// Consoles manages the consoles for a set of nodes
+type Consoles struct {
+	consoles map[string]*Console
+
+	mu      sync.Mutex
+	start   time.Time
+	logFile *os.File
+	log     *csv.Writer
+}
+
+// NewConsoles creates consoles for the provided node addresses
+func NewConsoles(c *config.Consoles, addresses []string) (*Consoles, error) {
+	if c.Dir == "" {
+		return nil, fmt.Errorf("no console directory configured")
+	}
+
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := Consoles{
+		consoles: make(map[string]*Console),
+		start:    time.Now(),
+	}
+
+	if c.LogFile != "" {
+		cs.logFile, err = os.Create(c.LogFile)
+		if err != nil {
+			return nil, err
+		}
+		cs.log = csv.NewWriter(cs.logFile)
+		cs.log.Write([]string{"time", "address", "direction", "data"})
+		cs.log.Flush()
+	}
+
+	for _, address := range addresses {
+		console, err := NewConsole(c.Dir, address, cs.logLine)
+		if err != nil {
+			cs.Close()
+			return nil, fmt.Errorf("error creating console for node %s (%s)", address, err)
+		}
+		cs.consoles[address] = console
+	}
+
+	return &cs, nil
+}
+
+// Path fetches the console device path for a node
+func (c *Consoles) Path(address string) (string, bool) {
+	console, ok := c.consoles[address]
+	if !ok {
+		return "", false
+	}
+	return console.NodePath, true
+}
+
+// Start sets the simulation start time used for console log timestamps
+// Output prior to the simulation start is logged with negative offsets
+func (c *Consoles) Start(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start = t
+}
+
+// Send writes a line to a node console
+func (c *Consoles) Send(address, line string) error {
+	console, ok := c.consoles[address]
+	if !ok {
+		return fmt.Errorf("no console found for node %s", address)
+	}
+	return console.Send(line)
+}
+
+// Expect awaits a line of output from a node console matching the provided pattern
+func (c *Consoles) Expect(address string, pattern *regexp.Regexp, timeout time.Duration) error {
+	console, ok := c.consoles[address]
+	if !ok {
+		return fmt.Errorf("no console found for node %s", address)
+	}
+	return console.Expect(pattern, timeout)
+}
+
+// Close closes all consoles and the console log
+func (c *Consoles) Close() {
+	for _, console := range c.consoles {
+		console.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logFile != nil {
+		c.log.Flush()
+		c.logFile.Close()
+		c.logFile = nil
+	}
+}
+
+// logLine writes a console line to the log with the simulation time
+func (c *Consoles) logLine(address, direction, data string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logFile == nil {
+		return
+	}
+
+	c.log.Write([]string{time.Now().Sub(c.start).String(), address, direction, data})
+	c.log.Flush()
+	if err := c.log.Error(); err != nil {
+		log.Printf("Console log error: %s", err)
+	}
+}
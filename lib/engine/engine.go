@@ -3,28 +3,57 @@ package engine
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/medium"
+	"github.com/ryankurte/yawns/lib/messages"
 	"github.com/ryankurte/yawns/lib/plugins"
+	"github.com/ryankurte/yawns/lib/types"
 )
 
+// defaultConsoleTimeout is the timeout for console updates awaiting output if no timeout is specified
+const defaultConsoleTimeout = 5 * time.Second
+
+// consoleExpect is a console output expectation resolved by an expect goroutine
+type consoleExpect struct {
+	id     uint64
+	result messages.ConsoleResult
+}
+
 // Engine is the base simulation engine
 type Engine struct {
 	nodes map[string]*Node
+	bands []messages.BandInfo
 
-	Updates []*Update
+	Updates   []*Update
+	triggers  []*Update
+	groups    map[string][]*Update
+	schedule  schedule
+	scheduled uint64
+	rand      *rand.Rand
 
 	medium        Medium
+	sensors       Sensors
+	consoles      Consoles
+	processes     Processes
 	pluginManager *plugins.PluginManager
 
 	connectorReadCh  chan interface{}
 	connectorWriteCh chan interface{}
 
 	runnerLogCh chan string
+	finishCh    chan error
+
+	consoleCh     chan consoleExpect
+	expects       map[uint64]messages.ConsoleResult
+	expectCounter uint64
 
 	startTime   time.Time
 	currentTime time.Time
@@ -35,7 +64,11 @@ type Engine struct {
 // NewEngine creates a new engine instance
 func NewEngine(c *config.Config) *Engine {
 	// Create engine object
-	e := Engine{}
+	e := Engine{
+		finishCh:  make(chan error, 1),
+		consoleCh: make(chan consoleExpect, 1024),
+		expects:   make(map[uint64]messages.ConsoleResult),
+	}
 
 	e.loadConfig(c)
 
@@ -48,6 +81,21 @@ func (e *Engine) BindMedium(m Medium) {
 	e.medium = m
 }
 
+// BindSensors binds virtual sensors for node sensor requests
+func (e *Engine) BindSensors(s Sensors) {
+	e.sensors = s
+}
+
+// BindConsoles binds node consoles for console updates
+func (e *Engine) BindConsoles(c Consoles) {
+	e.consoles = c
+}
+
+// BindProcesses binds node process control for fault injection updates
+func (e *Engine) BindProcesses(p Processes) {
+	e.processes = p
+}
+
 func (e *Engine) BindConnectorChannels(read, write chan interface{}) {
 	e.connectorReadCh = read
 	e.connectorWriteCh = write
@@ -58,9 +106,116 @@ func (e *Engine) BindRunnerChannel(logCh chan string) {
 }
 
 func (e *Engine) BindPlugin(p interface{}) error {
+	// Bind output for plugins that send messages to nodes
+	if o, ok := p.(plugins.OutputBinder); ok {
+		o.BindOutput(e.sendToNode)
+	}
+	// Bind control for plugins that drive the simulation
+	if c, ok := p.(plugins.ControlBinder); ok {
+		c.BindControl(e)
+	}
 	return e.pluginManager.BindPlugin(p)
 }
 
+// SendField pushes a field value to a node
+func (e *Engine) SendField(address, name, data string) error {
+	if _, ok := e.nodes[address]; !ok {
+		return fmt.Errorf("Node %s not found", address)
+	}
+	e.sendToNode(messages.FieldSet{
+		BaseMessage: messages.BaseMessage{Address: address},
+		Name:        name,
+		Data:        data,
+	})
+	return nil
+}
+
+// SendCommand sends a command to a node
+func (e *Engine) SendCommand(address, name, data string) error {
+	if _, ok := e.nodes[address]; !ok {
+		return fmt.Errorf("Node %s not found", address)
+	}
+	e.sendToNode(messages.Command{
+		BaseMessage: messages.BaseMessage{Address: address},
+		Name:        name,
+		Data:        data,
+	})
+	return nil
+}
+
+// Schedule schedules an update for execution at the provided simulation time
+// Updates scheduled in the past are executed on the next tick
+func (e *Engine) Schedule(at time.Duration, nodes []string, action config.UpdateAction, data map[string]string) error {
+	for _, address := range nodes {
+		if _, ok := e.nodes[address]; !ok {
+			return fmt.Errorf("Node %s not found", address)
+		}
+	}
+	if action == config.UpdateGroup {
+		if _, ok := e.groups[data["group"]]; !ok {
+			return fmt.Errorf("Update group %s not found", data["group"])
+		}
+	}
+
+	u := config.Update{Action: action, TimeStamp: at, Nodes: nodes, Data: data}
+	e.scheduleUpdate(NewUpdate(&u), at, nodes)
+
+	return nil
+}
+
+// Stats fetches a snapshot of the medium statistics
+func (e *Engine) Stats() (medium.Stats, error) {
+	m, ok := e.medium.(MediumStats)
+	if !ok {
+		return medium.Stats{}, fmt.Errorf("Medium does not provide statistics")
+	}
+	return m.Stats(), nil
+}
+
+// Location fetches the current location of a node
+func (e *Engine) Location(address string) (types.Location, error) {
+	node, ok := e.nodes[address]
+	if !ok {
+		return types.Location{}, fmt.Errorf("Node %s not found", address)
+	}
+	return node.Location, nil
+}
+
+// Finish ends the simulation, with a nil result if the simulation passed
+// Only the first result is used, subsequent calls are ignored
+func (e *Engine) Finish(result error) {
+	select {
+	case e.finishCh <- result:
+	default:
+	}
+}
+
+// forwardToMedium forwards connector messages to the medium, passing packets through plugin packet filters
+// Dropped packets are completed immediately so the sending node does not wait for the medium
+func (e *Engine) forwardToMedium(d time.Duration, message interface{}) {
+	if p, ok := message.(messages.Packet); ok {
+		filtered, forward := e.pluginManager.FilterPacket(d, p)
+		if !forward {
+			e.sendToNode(messages.NewSendComplete(p.Address, p.Band, p.Radio, p.Channel))
+			return
+		}
+		message = filtered
+	}
+	e.medium.Send() <- message
+}
+
+// injectPackets transmits packets pending from plugin packet filters in the medium
+func (e *Engine) injectPackets(d time.Duration) {
+	for _, p := range e.pluginManager.PendingPackets(d) {
+		e.medium.Send() <- messages.InjectedPacket{Packet: p}
+	}
+}
+
+// sendToNode sends a message to a node via the connector
+func (e *Engine) sendToNode(message interface{}) {
+	e.connectorWriteCh <- message
+}
+
 // LoadConfig Loads a simulation config
 func (e *Engine) loadConfig(c *config.Config) {
 
@@ -68,9 +223,23 @@ func (e *Engine) loadConfig(c *config.Config) {
 	e.tickRate = c.TickRate
 	e.endTime = c.EndTime
 
+	// Create band information for node registration (sorted by name for consistent ordering)
+	e.bands = make([]messages.BandInfo, 0, len(c.Medium.Bands))
+	for name, b := range c.Medium.Bands {
+		e.bands = append(e.bands, messages.BandInfo{
+			Name:           name,
+			Frequency:      float64(b.Frequency),
+			Baud:           float64(b.Baud),
+			Channels:       uint32(b.Channels.Count),
+			ChannelSpacing: float64(b.Channels.Spacing),
+		})
+	}
+	sort.Slice(e.bands, func(i, j int) bool { return e.bands[i].Name < e.bands[j].Name })
+
 	// Create map of nodes
 	e.nodes = make(map[string]*Node)
-	for _, n := range c.Nodes {
+	for i := range c.Nodes {
+		n := c.Nodes[i]
 		node := Node{
 			Node:      &n,
 			connected: false,
@@ -80,11 +249,28 @@ func (e *Engine) loadConfig(c *config.Config) {
 		e.nodes[n.Address] = &node
 	}
 
-	// Create Update array
+	// Create Update array and schedule untriggered updates
+	e.rand = rand.New(rand.NewSource(c.Seed))
 	e.Updates = make([]*Update, len(c.Updates))
-	for i, u := range c.Updates {
-		Update := NewUpdate(&u)
-		e.Updates[i] = Update
+	e.triggers = make([]*Update, 0)
+	e.schedule = make(schedule, 0)
+	for i := range c.Updates {
+		update := NewUpdate(&c.Updates[i])
+		e.Updates[i] = update
+
+		if update.Trigger != nil {
+			e.triggers = append(e.triggers, update)
+		} else {
+			e.scheduleUpdate(update, update.TimeStamp, update.Nodes)
+		}
+	}
+
+	// Create update groups
+	e.groups = make(map[string][]*Update)
+	for name, updates := range c.Groups {
+		for i := range updates {
+			e.groups[name] = append(e.groups[name], NewUpdate(&updates[i]))
+		}
 	}
 
 	e.endTime = c.EndTime
@@ -95,10 +281,25 @@ func (e *Engine) Info() {
 	log.Printf("Engine Info")
 	log.Printf("  - End Time: %d ms", e.endTime)
 	log.Printf("  - Nodes: %d", len(e.nodes))
-	log.Printf("  - Updates: %d", len(e.Updates))
+	log.Printf("  - Updates: %d (%d triggered)", len(e.Updates), len(e.triggers))
+	log.Printf("  - Update groups: %d", len(e.groups))
 }
 
 func (e *Engine) handleUpdate(d time.Duration, addresses []string, action config.UpdateAction, data map[string]string) error {
+	// Interferer updates are passed to the medium
+	switch action {
+	case config.UpdateStartInterferer, config.UpdateStopInterferer:
+		return e.handleInterfererUpdate(action, data)
+	case config.UpdateBlockLink, config.UpdateDegradeLink, config.UpdateRestoreLink, config.UpdatePartition:
+		return e.handleLinkUpdate(d, addresses, action, data)
+	}
+
+	// Updates without nodes are passed to plugins once for simulation wide actions (ie. band statistic checks)
+	if len(addresses) == 0 {
+		e.pluginManager.OnUpdate(d, action, "", data)
+		return nil
+	}
+
 	for _, address := range addresses {
 		err := e.handleNodeUpdate(d, address, action, data)
 		if err != nil {
@@ -120,6 +321,36 @@ func (e *Engine) handleNodeUpdate(d time.Duration, address string, action config
 	switch action {
 	case config.UpdateSetLocation:
 		err = HandleSetLocationUpdate(node, data)
+		if err == nil && e.medium != nil {
+			// Engine nodes are copies of the node configuration, so moves are passed to the medium
+			e.medium.Send() <- messages.LocationSet{
+				BaseMessage: messages.BaseMessage{Address: address},
+				Location:    node.Location,
+			}
+		}
+
+	case config.UpdateSetField:
+		key, value := data["key"], data["value"]
+		if key == "" {
+			err = fmt.Errorf("No key in set-field update for address '%s'", address)
+			break
+		}
+		err = e.SendField(address, key, value)
+
+	case config.UpdateCommand:
+		command := data["command"]
+		if command == "" {
+			err = fmt.Errorf("No command in command update for address '%s'", address)
+			break
+		}
+		err = e.SendCommand(address, command, data["data"])
+
+	case config.UpdateConsoleSend, config.UpdateConsoleExpect:
+		err = e.handleConsoleUpdate(d, address, action, data)
+
+	case config.UpdateKillNode, config.UpdateRestartNode, config.UpdatePauseNode, config.UpdateResumeNode,
+		config.UpdateRadioOff, config.UpdateRadioOn:
+		err = e.handleNodeFaultUpdate(d, address, action, data)
 
 	default:
 		e.pluginManager.OnUpdate(d, action, address, data)
@@ -131,6 +362,95 @@ func (e *Engine) handleNodeUpdate(d time.Duration, address string, action config
 	return err
 }
 
+// handleConsoleUpdate sends lines to and awaits output from node consoles
+// Output is awaited asynchronously to avoid blocking the simulation, with results passed to plugins as
+// messages.ConsoleResult messages from the simulation loop
+func (e *Engine) handleConsoleUpdate(d time.Duration, address string, action config.UpdateAction, data map[string]string) error {
+	if e.consoles == nil {
+		return fmt.Errorf("No consoles configured for console update")
+	}
+
+	timeout := defaultConsoleTimeout
+	if t, ok := data["timeout"]; ok {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return fmt.Errorf("Invalid console timeout '%s' (%s)", t, err)
+		}
+	}
+
+	expect := data["expect"]
+	if action == config.UpdateConsoleExpect {
+		expect = data["pattern"]
+		if expect == "" {
+			return fmt.Errorf("No pattern in console-expect update for address '%s'", address)
+		}
+	}
+
+	var pattern *regexp.Regexp
+	if expect != "" {
+		var err error
+		if pattern, err = regexp.Compile(expect); err != nil {
+			return fmt.Errorf("Invalid console pattern '%s' (%s)", expect, err)
+		}
+	}
+
+	if action == config.UpdateConsoleSend {
+		if err := e.consoles.Send(address, data["line"]); err != nil {
+			return err
+		}
+	}
+
+	if pattern != nil {
+		e.expectCounter++
+		c := consoleExpect{
+			id:     e.expectCounter,
+			result: messages.ConsoleResult{BaseMessage: messages.NewBaseMessage(address), Pattern: expect, Start: d},
+		}
+		e.expects[c.id] = c.result
+
+		go func() {
+			if err := e.consoles.Expect(address, pattern, timeout); err != nil {
+				log.Printf("[ERROR] Console expect failed: %s", err)
+				c.result.Error = err.Error()
+			}
+			e.consoleCh <- c
+		}()
+	}
+
+	return nil
+}
+
+// resolveConsoleExpect passes the result of a console expectation to plugins
+func (e *Engine) resolveConsoleExpect(d time.Duration, c consoleExpect) {
+	delete(e.expects, c.id)
+	e.pluginManager.OnMessage(d, c.result)
+}
+
+// closeConsoleExpects resolves completed console expectations and fails any still awaiting output
+func (e *Engine) closeConsoleExpects(d time.Duration) {
+	for {
+		select {
+		case c := <-e.consoleCh:
+			e.resolveConsoleExpect(d, c)
+			continue
+		default:
+		}
+		break
+	}
+
+	ids := make([]uint64, 0, len(e.expects))
+	for id := range e.expects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		r := e.expects[id]
+		r.Error = "Simulation ended before console output matched"
+		e.resolveConsoleExpect(d, consoleExpect{id: id, result: r})
+	}
+}
+
 func (e *Engine) getNode(address string) (*Node, error) {
 	if node, ok := e.nodes[address]; ok {
 		return node, nil
@@ -204,32 +524,19 @@ setup:
 	return nil
 }
 
-// Handle Updates at a given tick
-func (e *Engine) handleUpdates(d time.Duration) {
-	for i, u := range e.Updates {
-		// If the time has passed and the Update has not been executed
-		if d >= u.TimeStamp && !u.executed {
-			// Execute the Update
-			err := e.handleUpdate(d, u.Nodes, u.Action, u.Data)
-			if err != nil {
-				log.Printf("[ERROR] Update error: %s", err)
-			}
-
-			// Update the Update list
-			u.executed = true
-			e.Updates[i] = u
-		}
-	}
-}
-
 // Run the engine
+// An error is returned if the simulation is finished with a failure result
 func (e *Engine) Run() error {
+	var result error
 
 	interruptCh := make(chan os.Signal)
 	signal.Notify(interruptCh, syscall.SIGINT, syscall.SIGTERM)
 
 	// Run simulation
 	e.startTime = time.Now()
+	if e.consoles != nil {
+		e.consoles.Start(e.startTime)
+	}
 	log.Printf("[INFO] Simulation: starting")
 	endTimer := time.After(e.endTime)
 
@@ -244,14 +551,24 @@ running:
 			log.Printf("[INFO] Simulation: completed")
 			break running
 
+		// Exit when finished by a plugin
+		case result = <-e.finishCh:
+			if result != nil {
+				log.Printf("[INFO] Simulation: failed after %s (%s)", time.Now().Sub(e.startTime), result)
+			} else {
+				log.Printf("[INFO] Simulation: passed after %s", time.Now().Sub(e.startTime))
+			}
+			break running
+
 		// External connector inputs
 		case message, ok := <-e.connectorReadCh:
 			if !ok {
 				log.Printf("[ERROR] Connector channel error")
 				break running
 			}
-			e.medium.Send() <- message
-			e.HandleConnectorMessage(time.Now().Sub(e.startTime), message)
+			d := time.Now().Sub(e.startTime)
+			e.forwardToMedium(d, message)
+			e.HandleConnectorMessage(d, message)
 
 		// Medium outputs
 		case message, ok := <-e.medium.Receive():
@@ -267,14 +584,20 @@ running:
 			log.Printf("[INFO] Simulation: interrupted after %s", time.Now().Sub(e.startTime))
 			break running
 
+		// Console expect results
+		case c := <-e.consoleCh:
+			e.resolveConsoleExpect(time.Now().Sub(e.startTime), c)
+
 		// Simulation Update ticks
 		case t := <-runTimer.C:
 			d := t.Sub(e.startTime)
 			e.handleUpdates(d)
+			e.injectPackets(d)
+			e.pluginManager.OnTick(d)
 		}
 	}
 
-	return nil
+	return result
 }
 
 // Ready Checks whether the engine is ready to launch
@@ -292,5 +615,6 @@ func (e *Engine) Ready() bool {
 }
 
 func (e *Engine) Close() {
+	e.closeConsoleExpects(time.Now().Sub(e.startTime))
 	e.pluginManager.OnClose()
 }
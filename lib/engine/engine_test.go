@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"fmt"
 	"math"
+	"regexp"
 	"strconv"
 	"testing"
 )
@@ -9,7 +11,10 @@ import (
 import (
 	"github.com/ryankurte/yawns/lib/config"
 	"github.com/ryankurte/yawns/lib/connector"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/sensors"
 	"github.com/ryankurte/yawns/lib/types"
+	"github.com/stretchr/testify/assert"
 	"time"
 )
 
@@ -25,10 +30,14 @@ func FloatEq(a, b float64) bool {
 func TestEngine(t *testing.T) {
 
 	var e *Engine
-	connector := connector.NewZMQConnector(connector.DefaultIPCAddress)
+	connector, err := connector.NewLoopbackConnector("engine-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connector.Exit()
 
 	t.Run("Create from config", func(t *testing.T) {
-		cfg := config.Config{}
+		cfg := config.Config{TickRate: time.Millisecond}
 
 		node := types.Node{Address: "TestAddress", Location: types.Location{Lat: 0.0, Lng: 0.0}}
 		cfg.Nodes = append(cfg.Nodes, node)
@@ -71,3 +80,514 @@ func TestEngine(t *testing.T) {
 	})
 
 }
+
+func TestRegistration(t *testing.T) {
+	cfg := config.Config{TickRate: time.Millisecond}
+	cfg.Medium.Bands = map[string]config.Band{
+		"Sub1GHz": {Frequency: 433e6, Baud: 10e3, Channels: config.Channels{Count: 8, Spacing: 1e6}},
+	}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"})
+
+	e := NewEngine(&cfg)
+	read, write := make(chan interface{}, 8), make(chan interface{}, 8)
+	e.BindConnectorChannels(read, write)
+
+	t.Run("Acknowledges configured nodes", func(t *testing.T) {
+		e.OnConnected(0, "0x0001")
+		ack, ok := (<-write).(messages.RegisterAck)
+		assert.True(t, ok)
+		assert.True(t, ack.Accepted)
+		assert.EqualValues(t, []messages.BandInfo{
+			{Name: "Sub1GHz", Frequency: 433e6, Baud: 10e3, Channels: 8, ChannelSpacing: 1e6},
+		}, ack.Bands)
+		assert.True(t, e.nodes["0x0001"].connected)
+	})
+
+	t.Run("Rejects unknown nodes", func(t *testing.T) {
+		e.OnConnected(0, "0x0002")
+		ack, ok := (<-write).(messages.RegisterAck)
+		assert.True(t, ok)
+		assert.False(t, ack.Accepted)
+		assert.EqualValues(t, "0x0002", ack.Address)
+	})
+}
+
+func TestFieldInjection(t *testing.T) {
+	cfg := config.Config{TickRate: time.Millisecond}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"})
+
+	e := NewEngine(&cfg)
+	read, write := make(chan interface{}, 8), make(chan interface{}, 8)
+	e.BindConnectorChannels(read, write)
+
+	t.Run("Pushes fields to nodes", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateSetField, map[string]string{"key": "temperature", "value": "21.5"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.FieldSet{BaseMessage: messages.NewBaseMessage("0x0001"), Name: "temperature", Data: "21.5"}, <-write)
+	})
+
+	t.Run("Sends commands to nodes", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateCommand, map[string]string{"command": "button", "data": "pressed"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.Command{BaseMessage: messages.NewBaseMessage("0x0001"), Name: "button", Data: "pressed"}, <-write)
+	})
+
+	t.Run("Rejects invalid updates", func(t *testing.T) {
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateCommand, map[string]string{}))
+		assert.NotNil(t, e.SendField("0x0002", "temperature", "21.5"))
+	})
+}
+
+func TestSensorRequests(t *testing.T) {
+	cfg := config.Config{TickRate: time.Millisecond}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"})
+
+	e := NewEngine(&cfg)
+	read, write := make(chan interface{}, 8), make(chan interface{}, 8)
+	e.BindConnectorChannels(read, write)
+
+	request := messages.SensorRequest{BaseMessage: messages.NewBaseMessage("0x0001"), Name: "temperature"}
+
+	t.Run("Responds with errors when no sensors are configured", func(t *testing.T) {
+		e.HandleConnectorMessage(time.Second, request)
+		resp := (<-write).(messages.SensorResponse)
+		assert.EqualValues(t, "no sensors configured", resp.Error)
+	})
+
+	s, err := sensors.NewSensors(&config.Sensors{Fields: map[string]config.Sensor{
+		"temperature": {Type: config.SensorConstant, Value: 21.5},
+	}})
+	assert.Nil(t, err)
+	e.BindSensors(s)
+
+	t.Run("Responds with sensor readings", func(t *testing.T) {
+		e.HandleConnectorMessage(time.Second, request)
+		assert.EqualValues(t, messages.SensorResponse{BaseMessage: messages.NewBaseMessage("0x0001"), Name: "temperature", Value: 21.5}, <-write)
+		assert.Len(t, s.Readings(), 1)
+	})
+
+	t.Run("Responds with errors for unknown sensors", func(t *testing.T) {
+		e.HandleConnectorMessage(time.Second, messages.SensorRequest{BaseMessage: messages.NewBaseMessage("0x0001"), Name: "humidity"})
+		resp := (<-write).(messages.SensorResponse)
+		assert.EqualValues(t, "no sensor found with name 'humidity'", resp.Error)
+	})
+}
+
+type fakeUpdatePlugin struct {
+	addresses []string
+}
+
+func (p *fakeUpdatePlugin) OnUpdate(d time.Duration, action config.UpdateAction, address string, data map[string]string) error {
+	p.addresses = append(p.addresses, address)
+	return nil
+}
+
+type fakeMessagePlugin struct {
+	messages []interface{}
+}
+
+func (p *fakeMessagePlugin) OnMessage(d time.Duration, message interface{}) error {
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+type fakeConsoles struct {
+	sent    chan string
+	expects chan string
+}
+
+func (c *fakeConsoles) Start(t time.Time) {}
+
+func (c *fakeConsoles) Send(address, line string) error {
+	c.sent <- address + ":" + line
+	return nil
+}
+
+func (c *fakeConsoles) Expect(address string, pattern *regexp.Regexp, timeout time.Duration) error {
+	c.expects <- address + ":" + pattern.String() + ":" + timeout.String()
+	return nil
+}
+
+func TestConsoleUpdates(t *testing.T) {
+	cfg := config.Config{TickRate: time.Millisecond}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"})
+	e := NewEngine(&cfg)
+
+	t.Run("Rejects console updates without consoles", func(t *testing.T) {
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleSend, map[string]string{"line": "help"}))
+	})
+
+	c := fakeConsoles{sent: make(chan string, 1), expects: make(chan string, 1)}
+	e.BindConsoles(&c)
+
+	t.Run("Sends lines and awaits responses", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleSend, map[string]string{"line": "help", "expect": "^commands", "timeout": "2s"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, "0x0001:help", <-c.sent)
+		assert.EqualValues(t, "0x0001:^commands:2s", <-c.expects)
+	})
+
+	t.Run("Awaits console output", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleExpect, map[string]string{"pattern": "ready"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, "0x0001:ready:"+defaultConsoleTimeout.String(), <-c.expects)
+	})
+
+	t.Run("Passes console results to plugins", func(t *testing.T) {
+		p := fakeMessagePlugin{}
+		assert.Nil(t, e.BindPlugin(&p))
+
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleExpect, map[string]string{"pattern": "ready"})
+		assert.Nil(t, err)
+		<-c.expects
+
+		// Resolve results from this and previous updates
+		for len(e.expects) > 0 {
+			e.resolveConsoleExpect(2*time.Second, <-e.consoleCh)
+		}
+		assert.Len(t, p.messages, 3)
+		assert.Contains(t, p.messages, messages.ConsoleResult{
+			BaseMessage: messages.NewBaseMessage("0x0001"), Pattern: "^commands", Start: time.Second,
+		})
+	})
+
+	t.Run("Fails console results pending at exit", func(t *testing.T) {
+		e := NewEngine(&cfg)
+		e.BindConsoles(&fakeConsoles{expects: make(chan string)})
+		p := fakeMessagePlugin{}
+		assert.Nil(t, e.BindPlugin(&p))
+
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleExpect, map[string]string{"pattern": "ready"})
+		assert.Nil(t, err)
+		e.Close()
+
+		assert.Len(t, p.messages, 1)
+		r, ok := p.messages[0].(messages.ConsoleResult)
+		assert.True(t, ok)
+		assert.EqualValues(t, "Simulation ended before console output matched", r.Error)
+	})
+
+	t.Run("Rejects invalid console updates", func(t *testing.T) {
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleExpect, map[string]string{}))
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleExpect, map[string]string{"pattern": "("}))
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateConsoleSend, map[string]string{"line": "help", "timeout": "soon"}))
+	})
+}
+
+func TestScenarios(t *testing.T) {
+	field := func(key string) map[string]string { return map[string]string{"key": key, "value": "1"} }
+
+	newEngine := func(cfg config.Config) (*Engine, chan interface{}) {
+		cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"}, types.Node{Address: "0x0002"})
+		e := NewEngine(&cfg)
+		write := make(chan interface{}, 64)
+		e.BindConnectorChannels(make(chan interface{}), write)
+		return e, write
+	}
+
+	// received fetches the fields sent to nodes
+	received := func(write chan interface{}) []string {
+		fields := make([]string, 0)
+		for len(write) > 0 {
+			m := (<-write).(messages.FieldSet)
+			fields = append(fields, m.Address+":"+m.Name)
+		}
+		return fields
+	}
+
+	t.Run("Executes updates in time order", func(t *testing.T) {
+		e, write := newEngine(config.Config{Updates: []config.Update{
+			{Action: config.UpdateSetField, TimeStamp: 2 * time.Second, Nodes: []string{"0x0001"}, Data: field("b")},
+			{Action: config.UpdateSetField, TimeStamp: time.Second, Nodes: []string{"0x0001"}, Data: field("a")},
+			{Action: config.UpdateSetField, TimeStamp: 5 * time.Second, Nodes: []string{"0x0001"}, Data: field("c")},
+		}})
+
+		e.handleUpdates(500 * time.Millisecond)
+		assert.Empty(t, received(write))
+		e.handleUpdates(3 * time.Second)
+		assert.EqualValues(t, []string{"0x0001:a", "0x0001:b"}, received(write))
+		e.handleUpdates(3 * time.Second)
+		assert.Empty(t, received(write), "Updates are executed once")
+	})
+
+	t.Run("Repeats periodic updates", func(t *testing.T) {
+		e, write := newEngine(config.Config{Updates: []config.Update{
+			{Action: config.UpdateSetField, TimeStamp: time.Second, Period: time.Second, Count: 3, Nodes: []string{"0x0001"}, Data: field("a")},
+			{Action: config.UpdateSetField, Period: 2 * time.Second, Until: 5 * time.Second, Nodes: []string{"0x0002"}, Data: field("b")},
+		}})
+
+		for d := time.Duration(0); d <= 10*time.Second; d += 100 * time.Millisecond {
+			e.handleUpdates(d)
+		}
+		fields := received(write)
+
+		count := map[string]int{}
+		for _, f := range fields {
+			count[f]++
+		}
+		assert.EqualValues(t, 3, count["0x0001:a"], "Limits executions to count")
+		assert.EqualValues(t, 3, count["0x0002:b"], "Stops executions after until")
+	})
+
+	t.Run("Applies seeded jitter", func(t *testing.T) {
+		updates := []config.Update{
+			{Action: config.UpdateSetField, TimeStamp: time.Second, Jitter: time.Second, Nodes: []string{"0x0001"}, Data: field("a")},
+		}
+		a, _ := newEngine(config.Config{Seed: 1, Updates: updates})
+		b, _ := newEngine(config.Config{Seed: 1, Updates: updates})
+
+		assert.EqualValues(t, a.schedule[0].at, b.schedule[0].at)
+		assert.True(t, a.schedule[0].at >= time.Second && a.schedule[0].at < 2*time.Second)
+	})
+
+	t.Run("Executes updates on triggers", func(t *testing.T) {
+		e, write := newEngine(config.Config{Updates: []config.Update{
+			{Action: config.UpdateSetField, TimeStamp: 10 * time.Second, Data: field("restart"),
+				Trigger: &config.Trigger{Type: config.TriggerDisconnected}},
+			{Action: config.UpdateSetField, Nodes: []string{"0x0002"}, Data: field("alarm"),
+				Trigger: &config.Trigger{Type: config.TriggerField, Nodes: []string{"0x0001"}, Key: "temperature", Match: "^[3-9][0-9]", Once: true}},
+		}})
+
+		e.HandleConnectorMessage(time.Second, messages.Deregister{BaseMessage: messages.NewBaseMessage("0x0001"), Reason: messages.DeregisterTimeout})
+		e.handleUpdates(5 * time.Second)
+		assert.Empty(t, received(write))
+		e.handleUpdates(11 * time.Second)
+		assert.EqualValues(t, []string{"0x0001:restart"}, received(write), "Applies to the triggering node after the delay")
+
+		temperature := func(address, value string) messages.FieldSet {
+			return messages.FieldSet{BaseMessage: messages.NewBaseMessage(address), Name: "temperature", Data: value}
+		}
+		e.HandleConnectorMessage(12*time.Second, temperature("0x0001", "21"))
+		e.HandleConnectorMessage(12*time.Second, temperature("0x0002", "35"))
+		e.handleUpdates(12 * time.Second)
+		assert.Empty(t, received(write), "Filters by node and value")
+
+		e.HandleConnectorMessage(13*time.Second, temperature("0x0001", "35"))
+		e.HandleConnectorMessage(13*time.Second, temperature("0x0001", "36"))
+		e.handleUpdates(13 * time.Second)
+		assert.EqualValues(t, []string{"0x0002:alarm"}, received(write), "Activates once")
+	})
+
+	t.Run("Runs update groups", func(t *testing.T) {
+		e, write := newEngine(config.Config{
+			Groups: map[string][]config.Update{
+				"join": {
+					{Action: config.UpdateSetField, Data: field("a")},
+					{Action: config.UpdateSetField, TimeStamp: time.Second, Nodes: []string{"0x0001"}, Data: field("b")},
+				},
+			},
+			Updates: []config.Update{
+				{Action: config.UpdateGroup, TimeStamp: 2 * time.Second, Period: 10 * time.Second, Nodes: []string{"0x0002"}, Data: map[string]string{"group": "join"}},
+			},
+		})
+
+		e.handleUpdates(2 * time.Second)
+		e.handleUpdates(2 * time.Second)
+		assert.EqualValues(t, []string{"0x0002:a"}, received(write), "Members inherit group nodes")
+		e.handleUpdates(3 * time.Second)
+		assert.EqualValues(t, []string{"0x0001:b"}, received(write), "Members are relative to the group execution")
+		e.handleUpdates(12 * time.Second)
+		e.handleUpdates(12 * time.Second)
+		assert.EqualValues(t, []string{"0x0002:a"}, received(write), "Groups can be repeated")
+	})
+
+	t.Run("Schedules plugin updates", func(t *testing.T) {
+		e, write := newEngine(config.Config{})
+
+		assert.NotNil(t, e.Schedule(time.Second, []string{"0x0003"}, config.UpdateSetField, field("a")), "Rejects unknown nodes")
+		assert.NotNil(t, e.Schedule(time.Second, nil, config.UpdateGroup, map[string]string{"group": "none"}), "Rejects unknown groups")
+
+		assert.Nil(t, e.Schedule(2*time.Second, []string{"0x0002"}, config.UpdateSetField, field("b")))
+		assert.Nil(t, e.Schedule(time.Second, []string{"0x0001"}, config.UpdateSetField, field("a")))
+		e.handleUpdates(3 * time.Second)
+		assert.EqualValues(t, []string{"0x0001:a", "0x0002:b"}, received(write))
+	})
+
+	t.Run("Passes updates without nodes to plugins once", func(t *testing.T) {
+		e, _ := newEngine(config.Config{Updates: []config.Update{
+			{Action: config.UpdateCheckStats, TimeStamp: time.Second, Data: map[string]string{"band": "b1"}},
+			{Action: config.UpdateCheckStats, TimeStamp: time.Second, Nodes: []string{"0x0001", "0x0002"}},
+		}})
+		p := fakeUpdatePlugin{}
+		e.BindPlugin(&p)
+
+		e.handleUpdates(time.Second)
+		assert.EqualValues(t, []string{"", "0x0001", "0x0002"}, p.addresses)
+	})
+
+	t.Run("Finishes with the first result", func(t *testing.T) {
+		e, _ := newEngine(config.Config{})
+
+		e.Finish(nil)
+		e.Finish(fmt.Errorf("failed"))
+		assert.Nil(t, <-e.finishCh)
+	})
+}
+
+type fakeProcesses struct {
+	calls []string
+}
+
+func (p *fakeProcesses) Kill(address string) error {
+	p.calls = append(p.calls, "kill:"+address)
+	return nil
+}
+
+func (p *fakeProcesses) Restart(address string) error {
+	p.calls = append(p.calls, "restart:"+address)
+	return nil
+}
+
+func (p *fakeProcesses) Pause(address string) error {
+	p.calls = append(p.calls, "pause:"+address)
+	return nil
+}
+
+func (p *fakeProcesses) Resume(address string) error {
+	p.calls = append(p.calls, "resume:"+address)
+	return nil
+}
+
+type fakeMedium struct {
+	send chan interface{}
+}
+
+func (m *fakeMedium) Send() chan interface{}    { return m.send }
+func (m *fakeMedium) Receive() chan interface{} { return nil }
+
+func TestFaults(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"}, types.Node{Address: "0x0002"}, types.Node{Address: "0x0003"})
+	e := NewEngine(&cfg)
+	m := fakeMedium{send: make(chan interface{}, 16)}
+	e.BindMedium(&m)
+
+	t.Run("Rejects process faults without process control", func(t *testing.T) {
+		assert.NotNil(t, e.handleNodeUpdate(time.Second, "0x0001", config.UpdateKillNode, map[string]string{}))
+	})
+
+	p := fakeProcesses{}
+	e.BindProcesses(&p)
+
+	t.Run("Kills and restarts nodes after a duration", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0001", config.UpdateKillNode, map[string]string{"duration": "2s"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, []string{"kill:0x0001"}, p.calls)
+
+		e.handleUpdates(2 * time.Second)
+		assert.EqualValues(t, []string{"kill:0x0001"}, p.calls)
+		e.handleUpdates(3 * time.Second)
+		assert.EqualValues(t, []string{"kill:0x0001", "restart:0x0001"}, p.calls)
+	})
+
+	t.Run("Fails and restores radios", func(t *testing.T) {
+		radio := uint32(1)
+		err := e.handleNodeUpdate(time.Second, "0x0002", config.UpdateRadioOff, map[string]string{"band": "b1", "radio": "1", "duration": "1s"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.RadioFault{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Band: "b1", Radio: &radio, Failed: true}, <-m.send)
+
+		e.handleUpdates(2 * time.Second)
+		assert.EqualValues(t, messages.RadioFault{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Band: "b1", Radio: &radio, Failed: false}, <-m.send)
+
+		err = e.handleNodeUpdate(time.Second, "0x0002", config.UpdateRadioOff, map[string]string{"band": "b1"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.RadioFault{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Band: "b1", Failed: true}, <-m.send)
+	})
+
+	t.Run("Partitions groups of nodes", func(t *testing.T) {
+		err := e.handleUpdate(time.Second, nil, config.UpdatePartition, map[string]string{"groups": "0x0001|0x0002,0x0003"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.LinkFault{From: []string{"0x0001"}, To: []string{"0x0002", "0x0003"}, Attenuation: math.Inf(1)}, <-m.send)
+	})
+
+	t.Run("Degrades links between nodes", func(t *testing.T) {
+		err := e.handleUpdate(time.Second, []string{"0x0001", "0x0002"}, config.UpdateDegradeLink, map[string]string{"attenuation": "20dB"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, messages.LinkFault{From: []string{"0x0001"}, To: []string{"0x0002"}, Attenuation: 20}, <-m.send)
+	})
+
+	t.Run("Clears all link faults", func(t *testing.T) {
+		assert.Nil(t, e.handleUpdate(time.Second, nil, config.UpdateRestoreLink, map[string]string{}))
+		assert.EqualValues(t, messages.LinkFault{}, <-m.send)
+	})
+
+	t.Run("Rejects invalid link faults", func(t *testing.T) {
+		assert.NotNil(t, e.handleUpdate(time.Second, nil, config.UpdatePartition, map[string]string{}))
+		assert.NotNil(t, e.handleUpdate(time.Second, []string{"0x0001"}, config.UpdateBlockLink, map[string]string{}))
+		assert.NotNil(t, e.handleUpdate(time.Second, nil, config.UpdateBlockLink, map[string]string{"groups": "0x0001|0x0009"}))
+		assert.NotNil(t, e.handleUpdate(time.Second, []string{"0x0001", "0x0002"}, config.UpdateDegradeLink, map[string]string{"attenuation": "lots"}))
+		assert.NotNil(t, e.handleUpdate(time.Second, []string{"0x0001", "0x0002"}, config.UpdateBlockLink, map[string]string{"duration": "soon"}))
+		assert.Empty(t, m.send)
+	})
+}
+
+type fakePacketFilter struct {
+	pending []messages.Packet
+}
+
+func (f *fakePacketFilter) FilterPacket(d time.Duration, p messages.Packet) (messages.Packet, bool) {
+	if p.Address == "0x0002" {
+		return p, false
+	}
+	p.Data = append([]byte{0xff}, p.Data...)
+	return p, true
+}
+
+func (f *fakePacketFilter) PendingPackets(d time.Duration) []messages.Packet {
+	pending := f.pending
+	f.pending = nil
+	return pending
+}
+
+func TestPacketFilters(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Nodes = append(cfg.Nodes, types.Node{Address: "0x0001"}, types.Node{Address: "0x0002"})
+	e := NewEngine(&cfg)
+	m := fakeMedium{send: make(chan interface{}, 16)}
+	e.BindMedium(&m)
+	write := make(chan interface{}, 16)
+	e.BindConnectorChannels(make(chan interface{}), write)
+
+	f := fakePacketFilter{}
+	assert.Nil(t, e.BindPlugin(&f))
+
+	t.Run("Forwards filtered packets to the medium", func(t *testing.T) {
+		e.forwardToMedium(time.Second, messages.NewPacket("0x0001", []byte{0x01}, messages.RFInfo{Band: "b1"}))
+		assert.EqualValues(t, messages.NewPacket("0x0001", []byte{0xff, 0x01}, messages.RFInfo{Band: "b1"}), <-m.send)
+		assert.Empty(t, write)
+	})
+
+	t.Run("Completes dropped packets", func(t *testing.T) {
+		e.forwardToMedium(time.Second, messages.NewPacket("0x0002", []byte{0x01}, messages.RFInfo{Band: "b1", Radio: 1}))
+		assert.Empty(t, m.send)
+		assert.EqualValues(t, messages.NewSendComplete("0x0002", "b1", 1, 0), <-write)
+	})
+
+	t.Run("Injects pending packets", func(t *testing.T) {
+		p := messages.NewPacket("0x0001", []byte{0x02}, messages.RFInfo{Band: "b1"})
+		f.pending = []messages.Packet{p}
+		e.injectPackets(time.Second)
+		assert.EqualValues(t, messages.InjectedPacket{Packet: p}, <-m.send)
+	})
+
+	t.Run("Locates nodes", func(t *testing.T) {
+		_, err := e.Location("0x0001")
+		assert.Nil(t, err)
+		_, err = e.Location("0x0003")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Moves nodes in the medium", func(t *testing.T) {
+		err := e.handleNodeUpdate(time.Second, "0x0002", config.UpdateSetLocation, map[string]string{"lat": "-36.9", "lon": "174.8"})
+		assert.Nil(t, err)
+
+		location := types.Location{Lat: -36.9, Lng: 174.8}
+		assert.EqualValues(t, messages.LocationSet{BaseMessage: messages.BaseMessage{Address: "0x0002"}, Location: location}, <-m.send)
+		l, err := e.Location("0x0002")
+		assert.Nil(t, err)
+		assert.EqualValues(t, location, l)
+		l, err = e.Location("0x0001")
+		assert.Nil(t, err)
+		assert.EqualValues(t, types.Location{}, l, "Only moves the updated node")
+	})
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// faultReversals maps fault updates to the updates restoring them after a duration
+var faultReversals = map[config.UpdateAction]config.UpdateAction{
+	config.UpdateKillNode:    config.UpdateRestartNode,
+	config.UpdatePauseNode:   config.UpdateResumeNode,
+	config.UpdateRadioOff:    config.UpdateRadioOn,
+	config.UpdateBlockLink:   config.UpdateRestoreLink,
+	config.UpdateDegradeLink: config.UpdateRestoreLink,
+	config.UpdatePartition:   config.UpdateRestoreLink,
+}
+
+// handleNodeFaultUpdate handles process and radio fault updates for a node
+func (e *Engine) handleNodeFaultUpdate(d time.Duration, address string, action config.UpdateAction, data map[string]string) error {
+	duration, err := faultDuration(action, data)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case config.UpdateKillNode, config.UpdateRestartNode, config.UpdatePauseNode, config.UpdateResumeNode:
+		if e.processes == nil {
+			return fmt.Errorf("No process control configured for %s update", action)
+		}
+	}
+
+	switch action {
+	case config.UpdateKillNode:
+		err = e.processes.Kill(address)
+	case config.UpdateRestartNode:
+		err = e.processes.Restart(address)
+	case config.UpdatePauseNode:
+		err = e.processes.Pause(address)
+	case config.UpdateResumeNode:
+		err = e.processes.Resume(address)
+
+	case config.UpdateRadioOff, config.UpdateRadioOn:
+		fault := messages.RadioFault{
+			BaseMessage: messages.BaseMessage{Address: address},
+			Band:        data["band"],
+			Failed:      action == config.UpdateRadioOff,
+		}
+		if r, ok := data["radio"]; ok {
+			radio, err := strconv.ParseUint(r, 0, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid radio '%s' in %s update (%s)", r, action, err)
+			}
+			id := uint32(radio)
+			fault.Radio = &id
+		}
+		e.medium.Send() <- fault
+	}
+	if err != nil {
+		return err
+	}
+
+	e.scheduleReversal(d+duration, []string{address}, action, data)
+
+	return nil
+}
+
+// handleLinkUpdate blocks, degrades or restores links between each of the provided nodes, or between groups of nodes
+func (e *Engine) handleLinkUpdate(d time.Duration, addresses []string, action config.UpdateAction, data map[string]string) error {
+	duration, err := faultDuration(action, data)
+	if err != nil {
+		return err
+	}
+
+	var groups [][]string
+	if g, ok := data["groups"]; ok {
+		groups = parseGroups(g)
+	} else if action == config.UpdatePartition {
+		return fmt.Errorf("No groups in %s update", action)
+	} else {
+		for _, a := range addresses {
+			groups = append(groups, []string{a})
+		}
+	}
+
+	for _, g := range groups {
+		for _, a := range g {
+			if _, ok := e.nodes[a]; !ok {
+				return fmt.Errorf("%s update node %s not found", action, a)
+			}
+		}
+	}
+
+	attenuation := math.Inf(1)
+	switch action {
+	case config.UpdateDegradeLink:
+		var a types.Attenuation
+		if err := a.UnmarshalText([]byte(data["attenuation"])); err != nil {
+			return fmt.Errorf("Invalid attenuation '%s' in %s update (%s)", data["attenuation"], action, err)
+		}
+		attenuation = float64(a)
+	case config.UpdateRestoreLink:
+		attenuation = 0
+	}
+
+	// Restoring without nodes clears all link faults
+	if action == config.UpdateRestoreLink && len(groups) == 0 {
+		e.medium.Send() <- messages.LinkFault{}
+		return nil
+	}
+
+	if len(groups) < 2 {
+		return fmt.Errorf("%s update requires at least two nodes or groups", action)
+	}
+
+	for i := range groups {
+		for j := i + 1; j < len(groups); j++ {
+			e.medium.Send() <- messages.LinkFault{From: groups[i], To: groups[j], Attenuation: attenuation}
+		}
+	}
+
+	e.scheduleReversal(d+duration, addresses, action, data)
+
+	return nil
+}
+
+// faultDuration parses the optional duration after which a fault is reversed from update data
+func faultDuration(action config.UpdateAction, data map[string]string) (time.Duration, error) {
+	s, ok := data["duration"]
+	if !ok {
+		return 0, nil
+	}
+	if _, ok := faultReversals[action]; !ok {
+		return 0, fmt.Errorf("%s updates can not have a duration", action)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration '%s' in %s update (%s)", s, action, err)
+	}
+	return duration, nil
+}
+
+// scheduleReversal schedules the update reversing a fault at the provided time if a duration is specified
+func (e *Engine) scheduleReversal(at time.Duration, nodes []string, action config.UpdateAction, data map[string]string) {
+	if _, ok := data["duration"]; !ok {
+		return
+	}
+
+	restore := make(map[string]string)
+	for k, v := range data {
+		if k != "duration" {
+			restore[k] = v
+		}
+	}
+
+	u := config.Update{
+		Action:    faultReversals[action],
+		TimeStamp: at,
+		Nodes:     nodes,
+		Data:      restore,
+		Comment:   fmt.Sprintf("Reverse %s", action),
+	}
+	e.scheduleUpdate(NewUpdate(&u), at, nodes)
+}
+
+// parseGroups parses groups of node addresses (ie. "0x0001,0x0002|0x0003")
+func parseGroups(s string) [][]string {
+	groups := make([][]string, 0)
+	for _, g := range strings.Split(s, "|") {
+		group := make([]string, 0)
+		for _, a := range strings.Split(g, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				group = append(group, a)
+			}
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
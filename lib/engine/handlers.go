@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -14,10 +15,20 @@ func (e *Engine) HandleConnectorMessage(d time.Duration, message interface{}) {
 	switch m := message.(type) {
 	case messages.Register:
 		e.OnConnected(d, m.GetAddress())
+		e.handleTriggers(d, config.TriggerConnected, m.GetAddress(), "", "")
+	case messages.Deregister:
+		e.OnDisconnected(d, m.GetAddress(), m.Reason)
+		e.handleTriggers(d, config.TriggerDisconnected, m.GetAddress(), "", m.Reason)
 	case messages.Packet:
 		e.OnReceived(d, m.Band, m.GetAddress(), m.Data)
 	case messages.Event:
 		e.OnEvent(d, m.Address, m.Data)
+		e.handleTriggers(d, config.TriggerEvent, m.GetAddress(), "", m.Data)
+	case messages.FieldSet:
+		e.OnMessage(d, message)
+		e.handleTriggers(d, config.TriggerField, m.GetAddress(), m.Name, m.Data)
+	case messages.SensorRequest:
+		e.OnSensorRequest(d, m.GetAddress(), m.Name)
 	default:
 		e.OnMessage(d, message)
 	}
@@ -36,16 +47,50 @@ func (e *Engine) OnConnected(d time.Duration, address string) {
 	node, ok := e.nodes[address]
 	if !ok {
 		log.Printf("Node registration not found")
+		e.connectorWriteCh <- messages.RegisterAck{
+			BaseMessage: messages.BaseMessage{Address: address},
+			Reason:      fmt.Sprintf("no node configured with address %s", address),
+		}
 		return
 	}
 
+	// Acknowledge registration with simulation band information
+	e.connectorWriteCh <- messages.RegisterAck{
+		BaseMessage: messages.BaseMessage{Address: address},
+		Accepted:    true,
+		Bands:       e.bands,
+	}
+
 	// Set connected state
 	node.connected = true
+	node.connects++
+
+	if node.connects > 1 {
+		log.Printf("[INFO] Node %s reconnected at %s", address, d)
+	}
 
 	// Call connected plugins
 	e.pluginManager.OnConnected(d, address)
 }
 
+// OnDisconnected called when a node deregisters or is disconnected by the connector
+func (e *Engine) OnDisconnected(d time.Duration, address, reason string) {
+	node, ok := e.nodes[address]
+	if !ok {
+		log.Printf("Node registration not found")
+		return
+	}
+
+	// Clear connected state
+	node.connected = false
+	node.disconnects++
+
+	log.Printf("[INFO] Node %s disconnected at %s (%s)", address, d, reason)
+
+	// Call disconnected plugins
+	e.pluginManager.OnDisconnected(d, address)
+}
+
 // OnReceived called when a packet is received from the connector
 func (e *Engine) OnReceived(d time.Duration, band, address string, data []byte) {
 	// Update stats
@@ -77,6 +122,30 @@ func (e *Engine) OnEvent(d time.Duration, address string, data string) {
 	e.pluginManager.OnEvent(d, address, data)
 }
 
+// OnSensorRequest called when a node requests a virtual sensor reading
+func (e *Engine) OnSensorRequest(d time.Duration, address, name string) {
+	resp := messages.SensorResponse{
+		BaseMessage: messages.BaseMessage{Address: address},
+		Name:        name,
+	}
+
+	node, ok := e.nodes[address]
+	switch {
+	case !ok:
+		resp.Error = fmt.Sprintf("no node configured with address %s", address)
+	case e.sensors == nil:
+		resp.Error = "no sensors configured"
+	default:
+		value, err := e.sensors.Read(d, name, address, node.Location)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Value = value
+	}
+
+	e.sendToNode(resp)
+}
+
 // OnUpdate called for simulation updates
 func (e *Engine) OnUpdate(d time.Duration, eventType config.UpdateAction, address string, data map[string]string) {
 	e.pluginManager.OnUpdate(d, eventType, address, data)
@@ -1,9 +1,40 @@
 package engine
 
-import ()
+import (
+	"regexp"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/medium"
+	"github.com/ryankurte/yawns/lib/types"
+)
 
 // Medium interface defines a medium implementation for simulation purposes
 type Medium interface {
 	Send() chan interface{}
 	Receive() chan interface{}
 }
+
+// MediumStats interface is implemented by media that provide statistics
+type MediumStats interface {
+	Stats() medium.Stats
+}
+
+// Sensors interface defines virtual sensors readable by nodes
+type Sensors interface {
+	Read(d time.Duration, name, address string, location types.Location) (float64, error)
+}
+
+// Consoles interface defines node consoles for scripted interaction
+type Consoles interface {
+	Start(t time.Time)
+	Send(address, line string) error
+	Expect(address string, pattern *regexp.Regexp, timeout time.Duration) error
+}
+
+// Processes interface defines node process control for fault injection
+type Processes interface {
+	Kill(address string) error
+	Restart(address string) error
+	Pause(address string) error
+	Resume(address string) error
+}
@@ -10,6 +10,8 @@ type Node struct {
 	connected   bool   // Indicates whether a node has connected to the engine
 	received    uint32 // Received packet count
 	sent        uint32 // Sent packet count
+	connects    uint32 // Connection count, connections after the first are reconnections
+	disconnects uint32 // Disconnection count
 }
 
 // NewNode creates an engine node using a provided configuration
@@ -1,12 +1,18 @@
 package engine
 
 import (
+	"container/heap"
 	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
 )
 
 import (
 	"github.com/ryankurte/yawns/lib/config"
 	"github.com/ryankurte/yawns/lib/helpers"
+	"github.com/ryankurte/yawns/lib/messages"
 )
 
 // Update engine type
@@ -15,15 +21,147 @@ type Update struct {
 	// Base node configuration
 	*config.Update
 
-	executed bool
+	// Trigger match pattern and activation state
+	match *regexp.Regexp
+	fired bool
 }
 
 // NewUpdate creates an engine Update using a provided configuration
 func NewUpdate(u *config.Update) *Update {
-	return &Update{
-		Update:   u,
-		executed: false,
+	update := Update{
+		Update: u,
 	}
+	if u.Trigger != nil {
+		// Patterns are checked on configuration loading
+		update.match, _ = regexp.Compile(u.Trigger.Match)
+	}
+	return &update
+}
+
+// scheduled is a pending update execution
+type scheduled struct {
+	at     time.Duration // Execution time (including jitter)
+	start  time.Duration // Activation time, periodic executions are relative to this
+	seq    uint64        // Scheduling order, to execute simultaneous updates in order
+	count  int           // Number of executions in this activation
+	update *Update
+	nodes  []string
+}
+
+// schedule is a queue of pending update executions ordered by execution time
+type schedule []*scheduled
+
+func (s schedule) Len() int { return len(s) }
+func (s schedule) Less(i, j int) bool {
+	if s[i].at == s[j].at {
+		return s[i].seq < s[j].seq
+	}
+	return s[i].at < s[j].at
+}
+func (s schedule) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *schedule) Push(x interface{}) { *s = append(*s, x.(*scheduled)) }
+func (s *schedule) Pop() interface{} {
+	old := *s
+	n := len(old)
+	item := old[n-1]
+	*s = old[:n-1]
+	return item
+}
+
+// scheduleUpdate schedules an update activation at the provided time
+func (e *Engine) scheduleUpdate(u *Update, start time.Duration, nodes []string) {
+	e.push(&scheduled{start: start, update: u, nodes: nodes}, start)
+}
+
+// push adds an update execution to the schedule at the provided time plus jitter
+func (e *Engine) push(s *scheduled, at time.Duration) {
+	if s.update.Jitter > 0 {
+		at += time.Duration(e.rand.Int63n(int64(s.update.Jitter)))
+	}
+	if s.update.Until > 0 && at > s.update.Until {
+		return
+	}
+	s.at = at
+	s.seq = e.scheduled
+	e.scheduled++
+	heap.Push(&e.schedule, s)
+}
+
+// handleUpdates executes scheduled updates due at the provided time
+func (e *Engine) handleUpdates(d time.Duration) {
+	for len(e.schedule) > 0 && e.schedule[0].at <= d {
+		s := heap.Pop(&e.schedule).(*scheduled)
+
+		err := e.executeUpdate(d, s.update, s.nodes)
+		if err != nil {
+			log.Printf("[ERROR] Update error: %s", err)
+		}
+
+		// Reschedule periodic updates relative to the activation to avoid accumulating jitter
+		s.count++
+		if s.update.Period > 0 && (s.update.Count == 0 || s.count < s.update.Count) {
+			e.push(s, s.start+time.Duration(s.count)*s.update.Period)
+		}
+	}
+}
+
+// executeUpdate executes an update, starting group updates or applying the update to nodes
+func (e *Engine) executeUpdate(d time.Duration, u *Update, nodes []string) error {
+	if u.Action != config.UpdateGroup {
+		return e.handleUpdate(d, nodes, u.Action, u.Data)
+	}
+
+	name := u.Data["group"]
+	group, ok := e.groups[name]
+	if !ok {
+		return fmt.Errorf("Update group %s not found", name)
+	}
+
+	for _, member := range group {
+		memberNodes := member.Nodes
+		if len(memberNodes) == 0 {
+			memberNodes = nodes
+		}
+		e.scheduleUpdate(member, d+member.TimeStamp, memberNodes)
+	}
+
+	return nil
+}
+
+// handleTriggers activates triggered updates matching a node event
+func (e *Engine) handleTriggers(d time.Duration, t config.TriggerType, address, key, value string) {
+	for _, u := range e.triggers {
+		trigger := u.Trigger
+		if trigger.Type != t || (trigger.Once && u.fired) {
+			continue
+		}
+		if len(trigger.Nodes) > 0 && !contains(trigger.Nodes, address) {
+			continue
+		}
+		if trigger.Key != "" && trigger.Key != key {
+			continue
+		}
+		if !u.match.MatchString(value) {
+			continue
+		}
+
+		u.fired = true
+
+		nodes := u.Nodes
+		if len(nodes) == 0 {
+			nodes = []string{address}
+		}
+		e.scheduleUpdate(u, d+u.TimeStamp, nodes)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // UpdateHandler interface implemented by modules that can consume Updates
@@ -46,3 +184,20 @@ func HandleSetLocationUpdate(node *Node, data map[string]string) error {
 	}
 	return nil
 }
+
+// handleInterfererUpdate starts or stops the interferers listed in an update
+func (e *Engine) handleInterfererUpdate(action config.UpdateAction, data map[string]string) error {
+	names, ok := data["interferers"]
+	if !ok || names == "" {
+		return fmt.Errorf("handleUpdate error parsing %s, no interferers specified", action)
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		e.medium.Send() <- messages.InterfererSet{
+			Name:   strings.TrimSpace(name),
+			Active: action == config.UpdateStartInterferer,
+		}
+	}
+
+	return nil
+}
@@ -10,8 +10,10 @@ package helpers
 
 import (
 	"fmt"
+	"image/color"
 	"io/ioutil"
 	"strconv"
+	"strings"
 
 	"github.com/go-yaml/yaml"
 )
@@ -44,6 +46,19 @@ func ParseFieldToInt(name string, data map[string]string) (int, error) {
 	return fieldInt, nil
 }
 
+// ParseColour parses a #rrggbb hex colour
+func ParseColour(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour '%s' (expected #rrggbb)", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour '%s' (%s)", s, err)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
 func ReadYAMLFile(name string, data interface{}) error {
 	d, err := ioutil.ReadFile(name)
 	if err != nil {
@@ -71,3 +86,78 @@ func WriteYAMLFile(name string, data interface{}) error {
 
 	return nil
 }
+
+// ReadYAMLTree reads a YAML file as a generic document, preserving integers written in other bases
+// as strings so unquoted addresses (ie. 0x0001) are not rewritten as decimal when re-encoded
+func ReadYAMLTree(name string) (map[interface{}]interface{}, error) {
+	d, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("ReadYAMLTree error loading file (%s)", err)
+	}
+
+	n := yamlNode{}
+	err = yaml.Unmarshal(d, &n)
+	if err != nil {
+		return nil, fmt.Errorf("ReadYAMLTree error parsing file %s (%s)", name, err)
+	}
+
+	if n.value == nil {
+		return make(map[interface{}]interface{}), nil
+	}
+	t, ok := n.value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ReadYAMLTree error parsing file %s (document is not a map)", name)
+	}
+
+	return t, nil
+}
+
+// yamlNode decodes a generic YAML value for ReadYAMLTree
+type yamlNode struct {
+	value interface{}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (n *yamlNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		nodes := make(map[yamlNode]yamlNode)
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		m := make(map[interface{}]interface{}, len(nodes))
+		for k, c := range nodes {
+			m[k.value] = c.value
+		}
+		n.value = m
+
+	case []interface{}:
+		nodes := make([]yamlNode, 0)
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		l := make([]interface{}, len(nodes))
+		for i, c := range nodes {
+			l[i] = c.value
+		}
+		n.value = l
+
+	case int, int64, uint64:
+		raw := ""
+		if err := unmarshal(&raw); err == nil && raw != fmt.Sprint(v) {
+			n.value = raw
+		} else {
+			n.value = v
+		}
+
+	default:
+		n.value = v
+	}
+
+	return nil
+}
@@ -6,8 +6,13 @@ import (
 	"github.com/ryankurte/yawns/lib/types"
 )
 
-func (m *Medium) SetTransceiverState(now time.Time, nodeIndex int, band string, state types.TransceiverState) {
-	transceiver := m.transceivers[nodeIndex][band]
+// SetTransceiverState sets the state of a node radio, radios that do not exist are ignored
+func (m *Medium) SetTransceiverState(now time.Time, nodeIndex int, band string, radio uint32, state types.TransceiverState) {
+	key := radioKey{band, radio}
+	transceiver, ok := m.transceivers[nodeIndex][key]
+	if !ok {
+		return
+	}
 	transceiver.SetState(now, state)
-	m.transceivers[nodeIndex][band] = transceiver
+	m.putTransceiver(nodeIndex, key, transceiver)
 }
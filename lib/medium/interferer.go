@@ -0,0 +1,227 @@
+/**
+ * OpenNetworkSim Medium Package
+ * Implements wireless medium simulation
+ * Interferer implementation, this defines external noise sources and jammers in the medium
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package medium
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// TracePoint is a power level in an interferer trace
+type TracePoint struct {
+	Offset time.Duration
+	Power  types.Attenuation
+	On     bool
+}
+
+// Interferer is an external noise source or jammer
+type Interferer struct {
+	Name string
+	config.Interferer
+
+	// Node used for fading calculations
+	node types.Node
+
+	active    bool
+	startTime time.Time
+
+	// Random pattern state
+	rand       *rand.Rand
+	randomOn   bool
+	nextToggle time.Time
+
+	trace []TracePoint
+}
+
+// NewInterferer creates a new interferer instance
+func NewInterferer(name string, c config.Interferer) (*Interferer, error) {
+	i := Interferer{
+		Name:       name,
+		Interferer: c,
+		node:       types.Node{Address: name, Location: c.Location},
+	}
+
+	switch c.Pattern {
+	case "":
+		i.Pattern = config.InterfererContinuous
+	case config.InterfererContinuous:
+	case config.InterfererPeriodic:
+		if c.Period <= 0 || c.Duration <= 0 {
+			return nil, fmt.Errorf("interferer %s: periodic pattern requires period and duration", name)
+		}
+	case config.InterfererRandom:
+		if c.MeanOn <= 0 || c.MeanOff <= 0 {
+			return nil, fmt.Errorf("interferer %s: random pattern requires meanon and meanoff", name)
+		}
+		i.rand = rand.New(rand.NewSource(c.Seed))
+	case config.InterfererTrace:
+		trace, err := LoadTrace(c.Trace)
+		if err != nil {
+			return nil, fmt.Errorf("interferer %s: %s", name, err)
+		}
+		i.trace = trace
+	default:
+		return nil, fmt.Errorf("interferer %s: unrecognised pattern '%s'", name, c.Pattern)
+	}
+
+	return &i, nil
+}
+
+// LoadTrace loads an interferer trace file
+// Traces are CSV files with a time offset (ie. 10ms) and a power in dB (or "off") on each line
+func LoadTrace(file string) ([]TracePoint, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	trace := make([]TracePoint, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		offset, err := time.ParseDuration(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace offset '%s' (%s)", record[0], err)
+		}
+		if len(trace) > 0 && offset < trace[len(trace)-1].Offset {
+			return nil, fmt.Errorf("trace offsets must be increasing (%s)", record[0])
+		}
+
+		p := TracePoint{Offset: offset}
+		if power := strings.TrimSpace(record[1]); power != "off" {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(power, "dB"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trace power '%s' (%s)", record[1], err)
+			}
+			p.Power, p.On = types.Attenuation(v), true
+		}
+
+		trace = append(trace, p)
+	}
+
+	if len(trace) == 0 {
+		return nil, fmt.Errorf("trace file %s is empty", file)
+	}
+
+	return trace, nil
+}
+
+// Start starts the interferer activity pattern
+func (i *Interferer) Start(now time.Time) {
+	if i.active {
+		return
+	}
+	i.active = true
+	i.startTime = now
+	i.randomOn = true
+	if i.rand != nil {
+		i.nextToggle = now.Add(i.exponential(i.MeanOn))
+	}
+}
+
+// Stop stops the interferer
+func (i *Interferer) Stop() {
+	i.active = false
+}
+
+// Active checks whether the interferer has been started
+func (i *Interferer) Active() bool {
+	return i.active
+}
+
+// OnChannel checks whether the interferer occupies the provided band and channel
+func (i *Interferer) OnChannel(band string, channel int32) bool {
+	if band != i.Band {
+		return false
+	}
+	if len(i.Channels) == 0 {
+		return true
+	}
+	for _, c := range i.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interferer) exponential(mean time.Duration) time.Duration {
+	return time.Duration(i.rand.ExpFloat64() * float64(mean))
+}
+
+// GetPower fetches the power of the interferer (in dB relative to node transmit power) at the provided time
+// and whether the interferer is emitting
+func (i *Interferer) GetPower(now time.Time) (types.Attenuation, bool) {
+	if !i.active {
+		return 0, false
+	}
+
+	elapsed := now.Sub(i.startTime)
+
+	switch i.Pattern {
+	case config.InterfererPeriodic:
+		if elapsed < i.Offset {
+			return 0, false
+		}
+		if (elapsed-i.Offset)%i.Period >= i.Duration {
+			return 0, false
+		}
+
+	case config.InterfererRandom:
+		for !now.Before(i.nextToggle) {
+			i.randomOn = !i.randomOn
+			if i.randomOn {
+				i.nextToggle = i.nextToggle.Add(i.exponential(i.MeanOn))
+			} else {
+				i.nextToggle = i.nextToggle.Add(i.exponential(i.MeanOff))
+			}
+		}
+		if !i.randomOn {
+			return 0, false
+		}
+
+	case config.InterfererTrace:
+		last := i.trace[len(i.trace)-1]
+		if i.Loop && last.Offset > 0 {
+			elapsed = elapsed % last.Offset
+		}
+
+		point := TracePoint{}
+		for _, p := range i.trace {
+			if p.Offset > elapsed {
+				break
+			}
+			point = p
+		}
+		return point.Power, point.On
+	}
+
+	return i.Power, true
+}
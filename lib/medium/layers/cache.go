@@ -8,12 +8,13 @@ import (
 
 // Cache is a simple map based cache to minimise computations required for each layer
 type Cache struct {
-	cache map[string]float64
+	cache      map[string]float64
+	components map[string]types.AttenuationMap
 }
 
 // NewCache creates a cache for attenuation v
 func NewCache() Cache {
-	return Cache{make(map[string]float64)}
+	return Cache{make(map[string]float64), make(map[string]types.AttenuationMap)}
 }
 
 func (c *Cache) key(band types.Frequency, a, b types.Location) string {
@@ -32,3 +33,17 @@ func (c *Cache) Get(band types.Frequency, a, b types.Location) (float64, bool) {
 	v, ok := c.cache[key]
 	return v, ok
 }
+
+// SetMap adds attenuation components (and the overall attenuation) for a given band and node pair
+func (c *Cache) SetMap(band types.Frequency, a, b types.Location, attenuation types.AttenuationMap) {
+	key := c.key(band, a, b)
+	c.components[key] = attenuation
+	c.cache[key] = float64(attenuation.Reduce())
+}
+
+// GetMap fetches attenuation components (if available) for a given band and node pair
+func (c *Cache) GetMap(band types.Frequency, a, b types.Location) (types.AttenuationMap, bool) {
+	key := c.key(band, a, b)
+	v, ok := c.components[key]
+	return v, ok
+}
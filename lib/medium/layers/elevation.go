@@ -0,0 +1,357 @@
+/**
+ * OpenNetworkSim Medium Layers package
+ * Elevation sources for terrain modelling
+ * Terrain profiles can be extracted from mapbox terrain-rgb tiles or from georeferenced elevation models
+ * (SRTM .hgt files, single band GeoTIFF DEMs and ESRI ASCII grids)
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package layers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ryankurte/go-mapbox/lib/maps"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+const (
+	// maxProfileSamples limits the number of samples in an extracted terrain profile
+	maxProfileSamples = 4096
+	// srtmVoid is the SRTM no data marker
+	srtmVoid = -32768
+)
+
+// TerrainSource interface is implemented by sources that can provide terrain profiles between points
+type TerrainSource interface {
+	// Profile fetches evenly spaced terrain altitudes (in meters) between two locations
+	Profile(p1, p2 types.Location) []float64
+}
+
+// tileTerrain is a terrain source using a mapbox terrain-rgb tile
+type tileTerrain struct {
+	tile maps.Tile
+}
+
+// Profile fetches a terrain profile from the terrain-rgb tile
+func (t *tileTerrain) Profile(p1, p2 types.Location) []float64 {
+	return t.tile.InterpolateAltitudes(onsToMapLoc(&p1), onsToMapLoc(&p2))
+}
+
+// Grid is a north-up georeferenced elevation grid in WGS84 coordinates
+type Grid struct {
+	Rows, Cols int
+	// North is the latitude of the centre of the first (northernmost) row
+	North float64
+	// West is the longitude of the centre of the first (westernmost) column
+	West float64
+	// LatStep and LngStep are the cell sizes in degrees
+	LatStep, LngStep float64
+	// NoData is the marker for missing values (ignored if HasNoData is not set)
+	NoData    float64
+	HasNoData bool
+	// Data is the row-major elevation data from north to south
+	Data []float64
+}
+
+// value fetches the value of a cell, returning false for missing or out of bounds cells
+func (g *Grid) value(row, col int) (float64, bool) {
+	if row < 0 || row >= g.Rows || col < 0 || col >= g.Cols {
+		return 0, false
+	}
+	v := g.Data[row*g.Cols+col]
+	if (g.HasNoData && v == g.NoData) || math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+// Altitude fetches the (bilinear interpolated) altitude at a location
+func (g *Grid) Altitude(l types.Location) (float64, bool) {
+	y := (g.North - l.Lat) / g.LatStep
+	x := (l.Lng - g.West) / g.LngStep
+
+	if y < -0.5 || x < -0.5 || y > float64(g.Rows)-0.5 || x > float64(g.Cols)-0.5 {
+		return 0, false
+	}
+
+	r0, c0 := int(math.Floor(y)), int(math.Floor(x))
+	fy, fx := y-float64(r0), x-float64(c0)
+
+	sum, weights := 0.0, 0.0
+	for _, s := range []struct {
+		r, c int
+		w    float64
+	}{
+		{r0, c0, (1 - fy) * (1 - fx)},
+		{r0, c0 + 1, (1 - fy) * fx},
+		{r0 + 1, c0, fy * (1 - fx)},
+		{r0 + 1, c0 + 1, fy * fx},
+	} {
+		if v, ok := g.value(s.r, s.c); ok && s.w > 0 {
+			sum += v * s.w
+			weights += s.w
+		}
+	}
+
+	// Fall back to the nearest cell on grid edges or where all weighted neighbours are void
+	if weights == 0 {
+		return g.value(int(math.Floor(y+0.5)), int(math.Floor(x+0.5)))
+	}
+
+	return sum / weights, true
+}
+
+// Resolution fetches the approximate resolution of the grid in meters
+func (g *Grid) Resolution() float64 {
+	lat := (g.North - float64(g.Rows)*g.LatStep/2) * math.Pi / 180
+	return math.Min(g.LatStep, g.LngStep*math.Cos(lat)) * math.Pi / 180 * types.EarthRadius
+}
+
+// Elevation is a terrain source built from one or more elevation grids
+type Elevation struct {
+	Grids []*Grid
+}
+
+// LoadElevation loads elevation grids from the provided files
+// File types are detected by extension, .hgt for SRTM, .tif / .tiff for GeoTIFF, .asc for ESRI ASCII grids
+func LoadElevation(files ...string) (*Elevation, error) {
+	e := Elevation{Grids: make([]*Grid, 0)}
+
+	for _, f := range files {
+		var g *Grid
+		var err error
+
+		switch strings.ToLower(filepath.Ext(f)) {
+		case ".hgt":
+			g, err = LoadSRTM(f)
+		case ".tif", ".tiff":
+			g, err = LoadGeoTIFF(f)
+		case ".asc":
+			g, err = LoadASCIIGrid(f)
+		default:
+			err = fmt.Errorf("unrecognised elevation file type '%s'", filepath.Ext(f))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("LoadElevation error loading %s (%s)", f, err)
+		}
+
+		e.Grids = append(e.Grids, g)
+	}
+
+	return &e, nil
+}
+
+// Altitude fetches the altitude at a location from the first grid containing it
+func (e *Elevation) Altitude(l types.Location) (float64, bool) {
+	for _, g := range e.Grids {
+		if v, ok := g.Altitude(l); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Profile samples terrain altitudes between two locations at the resolution of the underlying grids
+// Void samples are filled from neighbouring samples, an empty profile is returned if no data is available
+func (e *Elevation) Profile(p1, p2 types.Location) []float64 {
+	if len(e.Grids) == 0 {
+		return []float64{}
+	}
+
+	resolution := e.Grids[0].Resolution()
+	for _, g := range e.Grids {
+		resolution = math.Min(resolution, g.Resolution())
+	}
+
+	d := project(p1, p2)
+	distance := math.Sqrt(d.X*d.X + d.Y*d.Y)
+	count := int(math.Ceil(distance/resolution)) + 1
+	if count < 2 {
+		count = 2
+	}
+	if count > maxProfileSamples {
+		count = maxProfileSamples
+	}
+
+	profile := make([]float64, count)
+	valid := make([]bool, count)
+	found := false
+	for i := range profile {
+		f := float64(i) / float64(count-1)
+		l := types.Location{Lat: p1.Lat + (p2.Lat-p1.Lat)*f, Lng: p1.Lng + (p2.Lng-p1.Lng)*f}
+		profile[i], valid[i] = e.Altitude(l)
+		found = found || valid[i]
+	}
+	if !found {
+		return []float64{}
+	}
+
+	// Fill voids forwards then backwards
+	for i := 1; i < count; i++ {
+		if !valid[i] && valid[i-1] {
+			profile[i], valid[i] = profile[i-1], true
+		}
+	}
+	for i := count - 2; i >= 0; i-- {
+		if !valid[i] && valid[i+1] {
+			profile[i], valid[i] = profile[i+1], true
+		}
+	}
+
+	return profile
+}
+
+var srtmNameRegex = regexp.MustCompile(`^([NS])(\d{2})([EW])(\d{3})`)
+
+// LoadSRTM loads an SRTM .hgt file
+// The location of the tile is parsed from the file name (ie. N36E174.hgt) and the resolution from the file size
+func LoadSRTM(file string) (*Grid, error) {
+	matches := srtmNameRegex.FindStringSubmatch(strings.ToUpper(filepath.Base(file)))
+	if matches == nil {
+		return nil, fmt.Errorf("SRTM file name must be of the form N00E000.hgt")
+	}
+
+	lat, _ := strconv.Atoi(matches[2])
+	lng, _ := strconv.Atoi(matches[4])
+	if matches[1] == "S" {
+		lat = -lat
+	}
+	if matches[3] == "W" {
+		lng = -lng
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(math.Sqrt(float64(len(data) / 2)))
+	if size < 2 || size*size*2 != len(data) {
+		return nil, fmt.Errorf("invalid SRTM file length (%d)", len(data))
+	}
+
+	g := Grid{
+		Rows:      size,
+		Cols:      size,
+		North:     float64(lat + 1),
+		West:      float64(lng),
+		LatStep:   1.0 / float64(size-1),
+		LngStep:   1.0 / float64(size-1),
+		NoData:    srtmVoid,
+		HasNoData: true,
+		Data:      make([]float64, size*size),
+	}
+
+	for i := range g.Data {
+		g.Data[i] = float64(int16(binary.BigEndian.Uint16(data[i*2:])))
+	}
+
+	return &g, nil
+}
+
+// LoadASCIIGrid loads an ESRI ASCII grid (.asc) file with geographic (WGS84) coordinates
+func LoadASCIIGrid(file string) (*Grid, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+
+	header := make(map[string]float64)
+	var first string
+	for scanner.Scan() {
+		key := strings.ToLower(scanner.Text())
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			// Start of data
+			first = key
+			break
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("unexpected end of header")
+		}
+		v, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header value for %s (%s)", key, err)
+		}
+		header[key] = v
+	}
+
+	for _, k := range []string{"ncols", "nrows"} {
+		if _, ok := header[k]; !ok {
+			return nil, fmt.Errorf("missing header field %s", k)
+		}
+	}
+
+	g := Grid{
+		Rows: int(header["nrows"]),
+		Cols: int(header["ncols"]),
+	}
+
+	// Cells may be square (cellsize) or have separate x and y sizes (dx and dy)
+	if size, ok := header["cellsize"]; ok {
+		g.LatStep, g.LngStep = size, size
+	} else if dx, ok := header["dx"]; ok {
+		dy, ok := header["dy"]
+		if !ok {
+			return nil, fmt.Errorf("missing header field dy")
+		}
+		g.LatStep, g.LngStep = dy, dx
+	} else {
+		return nil, fmt.Errorf("missing header field cellsize or dx and dy")
+	}
+	g.NoData, g.HasNoData = header["nodata_value"]
+
+	// Header locations may refer to the lower left corner or centre of the lower left cell
+	if x, ok := header["xllcenter"]; ok {
+		g.West = x
+	} else if x, ok := header["xllcorner"]; ok {
+		g.West = x + g.LngStep/2
+	} else {
+		return nil, fmt.Errorf("missing header field xllcorner or xllcenter")
+	}
+	if y, ok := header["yllcenter"]; ok {
+		g.North = y + float64(g.Rows-1)*g.LatStep
+	} else if y, ok := header["yllcorner"]; ok {
+		g.North = y + float64(g.Rows)*g.LatStep - g.LatStep/2
+	} else {
+		return nil, fmt.Errorf("missing header field yllcorner or yllcenter")
+	}
+
+	g.Data = make([]float64, 0, g.Rows*g.Cols)
+	if first != "" {
+		v, _ := strconv.ParseFloat(first, 64)
+		g.Data = append(g.Data, v)
+	}
+	for scanner.Scan() {
+		v, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grid value '%s'", scanner.Text())
+		}
+		g.Data = append(g.Data, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(g.Data) != g.Rows*g.Cols {
+		return nil, fmt.Errorf("expected %d grid values (found %d)", g.Rows*g.Cols, len(g.Data))
+	}
+
+	return &g, nil
+}
@@ -0,0 +1,201 @@
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// buildTestGeoTIFF builds a minimal little endian, single strip, int16 GeoTIFF
+func buildTestGeoTIFF(width, height int, values []int16, scale, tiepoint []float64) []byte {
+	type entry struct {
+		tag, kind uint16
+		count     uint32
+		data      []byte
+	}
+
+	le := binary.LittleEndian
+	u16 := func(v uint16) []byte { b := make([]byte, 2); le.PutUint16(b, v); return b }
+	u32 := func(v uint32) []byte { b := make([]byte, 4); le.PutUint32(b, v); return b }
+	f64s := func(vs []float64) []byte {
+		buf := bytes.Buffer{}
+		binary.Write(&buf, le, vs)
+		return buf.Bytes()
+	}
+
+	pixels := bytes.Buffer{}
+	binary.Write(&pixels, le, values)
+
+	entries := []entry{
+		{tiffTagImageWidth, tiffShort, 1, u16(uint16(width))},
+		{tiffTagImageLength, tiffShort, 1, u16(uint16(height))},
+		{tiffTagBitsPerSample, tiffShort, 1, u16(16)},
+		{tiffTagCompression, tiffShort, 1, u16(tiffCompressionNone)},
+		{tiffTagStripOffsets, tiffLong, 1, nil},
+		{tiffTagSamplesPerPixel, tiffShort, 1, u16(1)},
+		{tiffTagRowsPerStrip, tiffShort, 1, u16(uint16(height))},
+		{tiffTagStripByteCounts, tiffLong, 1, u32(uint32(pixels.Len()))},
+		{tiffTagSampleFormat, tiffShort, 1, u16(tiffFormatInt)},
+		{geoTagPixelScale, tiffDouble, uint32(len(scale)), f64s(scale)},
+		{geoTagTiepoint, tiffDouble, uint32(len(tiepoint)), f64s(tiepoint)},
+		{gdalTagNoData, tiffASCII, 7, []byte("-9999\x00\x00")},
+	}
+
+	// Layout: header, IFD, external tag data, pixel data
+	ifdSize := 2 + len(entries)*12 + 4
+	extOffset := 8 + ifdSize
+	ext := bytes.Buffer{}
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			ext.Write(e.data)
+		}
+	}
+	pixelOffset := extOffset + ext.Len()
+	entries[4].data = u32(uint32(pixelOffset))
+
+	out := bytes.Buffer{}
+	out.WriteString("II")
+	out.Write(u16(42))
+	out.Write(u32(8))
+	out.Write(u16(uint16(len(entries))))
+	next := extOffset
+	for _, e := range entries {
+		out.Write(u16(e.tag))
+		out.Write(u16(e.kind))
+		out.Write(u32(e.count))
+		if len(e.data) > 4 {
+			out.Write(u32(uint32(next)))
+			next += len(e.data)
+		} else {
+			out.Write(append(e.data, make([]byte, 4-len(e.data))...))
+		}
+	}
+	out.Write(u32(0))
+	out.Write(ext.Bytes())
+	out.Write(pixels.Bytes())
+
+	return out.Bytes()
+}
+
+func TestElevation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yawns-elevation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 3x3 SRTM grid over one degree, rows from north to south
+	values := []int16{100, 200, 300, 400, 500, 600, 700, 800, srtmVoid}
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.BigEndian, values)
+	srtmFile := filepath.Join(dir, "S37E174.hgt")
+	if err := ioutil.WriteFile(srtmFile, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gridFile := filepath.Join(dir, "grid.asc")
+	grid := "ncols 3\nnrows 2\nxllcorner 174.0\nyllcorner -37.0\ncellsize 0.5\nNODATA_value -9999\n" +
+		"10 20 30\n40 50 -9999\n"
+	if err := ioutil.WriteFile(gridFile, []byte(grid), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Loads SRTM files", func(t *testing.T) {
+		g, err := LoadSRTM(srtmFile)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, g.Rows)
+		assert.InDelta(t, -36.0, g.North, 1e-9)
+		assert.InDelta(t, 174.0, g.West, 1e-9)
+
+		alt, ok := g.Altitude(types.Location{Lat: -36.0, Lng: 174.0})
+		assert.True(t, ok)
+		assert.InDelta(t, 100, alt, 1e-6)
+
+		alt, ok = g.Altitude(types.Location{Lat: -36.25, Lng: 174.25})
+		assert.True(t, ok)
+		assert.InDelta(t, 300, alt, 1e-6)
+
+		// Void cells are excluded from interpolation
+		alt, ok = g.Altitude(types.Location{Lat: -36.75, Lng: 174.75})
+		assert.True(t, ok)
+		assert.InDelta(t, (500.0+600+800)/3, alt, 1e-6)
+
+		_, ok = g.Altitude(types.Location{Lat: -35.0, Lng: 174.5})
+		assert.False(t, ok)
+	})
+
+	t.Run("Loads ESRI ASCII grids", func(t *testing.T) {
+		g, err := LoadASCIIGrid(gridFile)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, g.Rows)
+		assert.EqualValues(t, 3, g.Cols)
+		assert.InDelta(t, -36.25, g.North, 1e-9)
+		assert.InDelta(t, 174.25, g.West, 1e-9)
+
+		alt, ok := g.Altitude(types.Location{Lat: -36.75, Lng: 174.75})
+		assert.True(t, ok)
+		assert.InDelta(t, 50, alt, 1e-6)
+
+		// Cell centre locations with separate cell sizes
+		data := "ncols 3\nnrows 2\nxllcenter 174.25\nyllcenter -36.75\ndx 0.5\ndy 0.25\n10 20 30\n40 50 60\n"
+		file := filepath.Join(dir, "cells.asc")
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0644))
+
+		g, err = LoadASCIIGrid(file)
+		assert.Nil(t, err)
+		assert.InDelta(t, -36.5, g.North, 1e-9)
+		assert.InDelta(t, 174.25, g.West, 1e-9)
+		assert.InDelta(t, 0.25, g.LatStep, 1e-9)
+		assert.InDelta(t, 0.5, g.LngStep, 1e-9)
+
+		assert.Nil(t, ioutil.WriteFile(file, []byte("ncols 1\nnrows 1\nxllcenter 0\nyllcenter 0\ndx 0.5\n1\n"), 0644))
+		_, err = LoadASCIIGrid(file)
+		assert.NotNil(t, err, "Requires dy with dx")
+	})
+
+	t.Run("Loads GeoTIFF files", func(t *testing.T) {
+		values := []int16{1, 2, 3, 4, 5, -9999}
+		data := buildTestGeoTIFF(3, 2, values, []float64{0.5, 0.5, 0}, []float64{0, 0, 0, 174.0, -36.0, 0})
+		file := filepath.Join(dir, "dem.tif")
+		assert.Nil(t, ioutil.WriteFile(file, data, 0644))
+
+		g, err := LoadGeoTIFF(file)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, g.Rows)
+		assert.EqualValues(t, 3, g.Cols)
+		assert.InDelta(t, -36.25, g.North, 1e-9)
+		assert.InDelta(t, 174.25, g.West, 1e-9)
+		assert.True(t, g.HasNoData)
+		assert.InDelta(t, -9999, g.NoData, 1e-9)
+
+		alt, ok := g.Altitude(types.Location{Lat: -36.25, Lng: 174.75})
+		assert.True(t, ok)
+		assert.InDelta(t, 2, alt, 1e-6)
+	})
+
+	t.Run("Extracts terrain profiles across grids", func(t *testing.T) {
+		e, err := LoadElevation(gridFile, srtmFile)
+		assert.Nil(t, err)
+		assert.Len(t, e.Grids, 2)
+
+		profile := e.Profile(types.Location{Lat: -36.25, Lng: 174.25}, types.Location{Lat: -36.25, Lng: 174.75})
+		assert.True(t, len(profile) > 2)
+		assert.InDelta(t, 10, profile[0], 1e-6)
+		assert.InDelta(t, 20, profile[len(profile)-1], 1e-6)
+
+		profile = e.Profile(types.Location{Lat: 10, Lng: 10}, types.Location{Lat: 10.1, Lng: 10.1})
+		assert.Len(t, profile, 0)
+	})
+
+	t.Run("Rejects unknown elevation formats", func(t *testing.T) {
+		_, err := LoadElevation(filepath.Join(dir, "dem.xyz"))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,282 @@
+/**
+ * OpenNetworkSim Medium Layers package
+ * Floorplan definitions for indoor propagation modelling
+ * Floorplans can be loaded from a simple YAML wall list or from GeoJSON feature collections
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package layers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+const (
+	// DefaultFloorFactor is the empirical floor loss factor (b) for the COST 231 multi-wall model
+	DefaultFloorFactor = 0.46
+)
+
+// Floor defines a building floor by its base altitude
+type Floor struct {
+	Name     string  // Floor name (for logging / rendering)
+	Altitude float64 // Base altitude of the floor in meters
+}
+
+// Wall is a wall (or set of wall segments) of a given material
+type Wall struct {
+	Material string           // Name of the wall material (must exist in Floorplan.Materials)
+	Floors   []int            // Indices of the floors the wall exists on (all floors if empty)
+	Points   []types.Location // Wall vertices
+	Closed   bool             // Closed indicates the last point should be joined to the first
+}
+
+// Floorplan describes building geometry for indoor attenuation calculations
+type Floorplan struct {
+	// Loss per floor penetrated
+	FloorLoss types.Attenuation
+	// Empirical floor factor (b) used in the multi-wall model
+	FloorFactor float64
+	// Building floors, ordered by altitude
+	Floors []Floor
+	// Per-material wall penetration losses
+	Materials map[string]types.Attenuation
+	// Walls in the building
+	Walls []Wall
+}
+
+// WallSegment is a single straight wall segment
+type WallSegment struct {
+	A, B     types.Location
+	Material string
+	Loss     types.Attenuation
+	Floors   []int
+}
+
+// LoadFloorplan loads a floorplan from a YAML or GeoJSON file
+// GeoJSON files are detected by a .json or .geojson extension
+func LoadFloorplan(file string) (*Floorplan, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("LoadFloorplan error loading file (%s)", err)
+	}
+
+	var fp *Floorplan
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json", ".geojson":
+		fp, err = parseGeoJSONFloorplan(data)
+	default:
+		fp = &Floorplan{}
+		err = yaml.Unmarshal(data, fp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadFloorplan error parsing file %s (%s)", file, err)
+	}
+
+	if fp.FloorFactor == 0 {
+		fp.FloorFactor = DefaultFloorFactor
+	}
+	if len(fp.Floors) == 0 {
+		fp.Floors = []Floor{{Name: "ground", Altitude: 0}}
+	}
+
+	for _, w := range fp.Walls {
+		if _, ok := fp.Materials[w.Material]; !ok {
+			return nil, fmt.Errorf("LoadFloorplan error: unknown wall material '%s'", w.Material)
+		}
+	}
+
+	return fp, nil
+}
+
+// FloorIndex fetches the index of the floor containing the provided altitude
+func (fp *Floorplan) FloorIndex(alt float64) int {
+	index := 0
+	for i, f := range fp.Floors {
+		if alt >= f.Altitude {
+			index = i
+		}
+	}
+	return index
+}
+
+// Segments splits the floorplan walls into individual wall segments
+func (fp *Floorplan) Segments() []WallSegment {
+	segments := make([]WallSegment, 0)
+	for _, w := range fp.Walls {
+		count := len(w.Points) - 1
+		if w.Closed && len(w.Points) > 2 {
+			count++
+		}
+		for i := 0; i < count; i++ {
+			segments = append(segments, WallSegment{
+				A:        w.Points[i],
+				B:        w.Points[(i+1)%len(w.Points)],
+				Material: w.Material,
+				Loss:     fp.Materials[w.Material],
+				Floors:   w.Floors,
+			})
+		}
+	}
+	return segments
+}
+
+// OnFloor checks whether a wall segment exists on the provided floor
+func (s *WallSegment) OnFloor(floor int) bool {
+	if len(s.Floors) == 0 {
+		return true
+	}
+	for _, f := range s.Floors {
+		if f == floor {
+			return true
+		}
+	}
+	return false
+}
+
+// point is a location projected onto a local plane in meters
+type point struct {
+	X, Y float64
+}
+
+// project converts a location to local planar coordinates about the provided origin
+// using an equirectangular approximation (valid at building scales)
+func project(origin, l types.Location) point {
+	lat0 := origin.Lat * math.Pi / 180
+	return point{
+		X: (l.Lng - origin.Lng) * math.Pi / 180 * math.Cos(lat0) * types.EarthRadius,
+		Y: (l.Lat - origin.Lat) * math.Pi / 180 * types.EarthRadius,
+	}
+}
+
+// intersect calculates whether the segments p1-p2 and p3-p4 intersect
+// returning the fraction along p1-p2 at which the intersection occurs
+func intersect(p1, p2, p3, p4 point) (float64, bool) {
+	d := (p2.X-p1.X)*(p4.Y-p3.Y) - (p2.Y-p1.Y)*(p4.X-p3.X)
+	if d == 0 {
+		// Parallel or collinear segments are not considered crossings
+		return 0, false
+	}
+
+	t := ((p3.X-p1.X)*(p4.Y-p3.Y) - (p3.Y-p1.Y)*(p4.X-p3.X)) / d
+	u := ((p3.X-p1.X)*(p2.Y-p1.Y) - (p3.Y-p1.Y)*(p2.X-p1.X)) / d
+
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+
+	return t, true
+}
+
+// geoJSON types for floorplan parsing
+// Floor and material definitions are supported as foreign members of the feature collection,
+// with losses specified as plain numbers in dB
+type geoJSONFeatureCollection struct {
+	Type        string             `json:"type"`
+	FloorLoss   float64            `json:"floorloss"`
+	FloorFactor float64            `json:"floorfactor"`
+	Floors      []Floor            `json:"floors"`
+	Materials   map[string]float64 `json:"materials"`
+	Features    []geoJSONFeature   `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties struct {
+		Material string `json:"material"`
+		Floors   []int  `json:"floors"`
+	} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+func geoJSONLine(coords [][]float64) ([]types.Location, error) {
+	points := make([]types.Location, len(coords))
+	for i, c := range coords {
+		if len(c) < 2 {
+			return nil, fmt.Errorf("invalid GeoJSON position %v", c)
+		}
+		points[i] = types.Location{Lng: c[0], Lat: c[1]}
+	}
+	return points, nil
+}
+
+func parseGeoJSONFloorplan(data []byte) (*Floorplan, error) {
+	fc := geoJSONFeatureCollection{}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected GeoJSON FeatureCollection (received '%s')", fc.Type)
+	}
+
+	fp := Floorplan{
+		FloorLoss:   types.Attenuation(fc.FloorLoss),
+		FloorFactor: fc.FloorFactor,
+		Floors:      fc.Floors,
+		Materials:   make(map[string]types.Attenuation),
+		Walls:       make([]Wall, 0),
+	}
+	for k, v := range fc.Materials {
+		fp.Materials[k] = types.Attenuation(v)
+	}
+
+	for _, f := range fc.Features {
+		lines := make([][][]float64, 0)
+
+		switch f.Geometry.Type {
+		case "LineString":
+			var c [][]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &c); err != nil {
+				return nil, err
+			}
+			lines = append(lines, c)
+		case "MultiLineString", "Polygon":
+			var c [][][]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &c); err != nil {
+				return nil, err
+			}
+			lines = append(lines, c...)
+		case "MultiPolygon":
+			var c [][][][]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &c); err != nil {
+				return nil, err
+			}
+			for _, p := range c {
+				lines = append(lines, p...)
+			}
+		default:
+			// Other geometries (ie. points) do not describe walls
+			continue
+		}
+
+		// Polygon rings repeat the first position so do not need closing
+		for _, l := range lines {
+			points, err := geoJSONLine(l)
+			if err != nil {
+				return nil, err
+			}
+			fp.Walls = append(fp.Walls, Wall{
+				Material: f.Properties.Material,
+				Floors:   f.Properties.Floors,
+				Points:   points,
+			})
+		}
+	}
+
+	return &fp, nil
+}
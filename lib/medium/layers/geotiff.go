@@ -0,0 +1,325 @@
+package layers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TIFF / GeoTIFF tag identifiers
+const (
+	tiffTagImageWidth      = 256
+	tiffTagImageLength     = 257
+	tiffTagBitsPerSample   = 258
+	tiffTagCompression     = 259
+	tiffTagStripOffsets    = 273
+	tiffTagSamplesPerPixel = 277
+	tiffTagRowsPerStrip    = 278
+	tiffTagStripByteCounts = 279
+	tiffTagPlanarConfig    = 284
+	tiffTagPredictor       = 317
+	tiffTagTileWidth       = 322
+	tiffTagTileLength      = 323
+	tiffTagTileOffsets     = 324
+	tiffTagTileByteCounts  = 325
+	tiffTagSampleFormat    = 339
+	geoTagPixelScale       = 33550
+	geoTagTiepoint         = 33922
+	geoTagTransformation   = 34264
+	gdalTagNoData          = 42113
+)
+
+// TIFF field types
+const (
+	tiffByte     = 1
+	tiffASCII    = 2
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+	tiffSByte    = 6
+	tiffSShort   = 8
+	tiffSLong    = 9
+	tiffFloat    = 11
+	tiffDouble   = 12
+)
+
+// TIFF compression and sample formats
+const (
+	tiffCompressionNone    = 1
+	tiffCompressionDeflate = 8
+	tiffCompressionAdobe   = 32946
+	tiffFormatUint         = 1
+	tiffFormatInt          = 2
+	tiffFormatFloat        = 3
+)
+
+var tiffTypeSizes = map[uint16]int{
+	tiffByte: 1, tiffASCII: 1, tiffShort: 2, tiffLong: 4, tiffRational: 8,
+	tiffSByte: 1, tiffSShort: 2, tiffSLong: 4, tiffFloat: 4, tiffDouble: 8,
+}
+
+// tiffReader is a minimal TIFF reader supporting the subset of the format used by single band DEMs
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+	tags  map[uint16][]float64
+	ascii map[uint16]string
+}
+
+// LoadGeoTIFF loads a single band GeoTIFF elevation model with geographic (WGS84) coordinates
+// Uncompressed and deflate compressed strip or tile images of integer or floating point samples are supported
+func LoadGeoTIFF(file string) (*Grid, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseGeoTIFF(data)
+}
+
+func parseGeoTIFF(data []byte) (*Grid, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid TIFF header")
+	}
+
+	r := tiffReader{data: data, tags: make(map[uint16][]float64), ascii: make(map[uint16]string)}
+	switch string(data[0:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF byte order")
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("unsupported TIFF version (BigTIFF is not supported)")
+	}
+
+	if err := r.readIFD(int(r.order.Uint32(data[4:]))); err != nil {
+		return nil, err
+	}
+
+	width, height := r.int(tiffTagImageWidth, 0), r.int(tiffTagImageLength, 0)
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("missing image dimensions")
+	}
+	if r.int(tiffTagSamplesPerPixel, 1) != 1 {
+		return nil, fmt.Errorf("only single band images are supported")
+	}
+
+	values, err := r.readSamples(width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	g := Grid{Rows: height, Cols: width, Data: values}
+
+	// Georeferencing from either pixel scale and tiepoint or a transformation matrix
+	// This assumes the default PixelIsArea raster type, so the tiepoint refers to the corner of the first pixel
+	if scale, ok := r.tags[geoTagPixelScale]; ok && len(scale) >= 2 {
+		tie, ok := r.tags[geoTagTiepoint]
+		if !ok || len(tie) < 6 {
+			return nil, fmt.Errorf("missing GeoTIFF tiepoint")
+		}
+		g.LngStep, g.LatStep = scale[0], scale[1]
+		g.West = tie[3] + (0.5-tie[0])*g.LngStep
+		g.North = tie[4] - (0.5-tie[1])*g.LatStep
+	} else if m, ok := r.tags[geoTagTransformation]; ok && len(m) >= 8 {
+		if m[1] != 0 || m[4] != 0 {
+			return nil, fmt.Errorf("rotated GeoTIFF transformations are not supported")
+		}
+		g.LngStep, g.LatStep = m[0], -m[5]
+		g.West = m[3] + 0.5*g.LngStep
+		g.North = m[7] - 0.5*g.LatStep
+	} else {
+		return nil, fmt.Errorf("missing GeoTIFF georeferencing tags")
+	}
+
+	if math.Abs(g.West) > 360 || math.Abs(g.North) > 90 {
+		return nil, fmt.Errorf("GeoTIFF must use geographic (WGS84) coordinates")
+	}
+
+	if nd, ok := r.ascii[gdalTagNoData]; ok {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(strings.Trim(nd, "\x00")), 64); err == nil {
+			g.NoData, g.HasNoData = v, true
+		}
+	}
+
+	return &g, nil
+}
+
+// int fetches the first value of an integer tag or the provided default
+func (r *tiffReader) int(tag uint16, def int) int {
+	if v, ok := r.tags[tag]; ok && len(v) > 0 {
+		return int(v[0])
+	}
+	return def
+}
+
+// readIFD reads tags from the first image file directory
+func (r *tiffReader) readIFD(offset int) error {
+	if offset+2 > len(r.data) {
+		return fmt.Errorf("invalid IFD offset")
+	}
+	count := int(r.order.Uint16(r.data[offset:]))
+
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(r.data) {
+			return fmt.Errorf("truncated IFD")
+		}
+
+		tag := r.order.Uint16(r.data[entry:])
+		kind := r.order.Uint16(r.data[entry+2:])
+		n := int(r.order.Uint32(r.data[entry+4:]))
+
+		size, ok := tiffTypeSizes[kind]
+		if !ok {
+			continue
+		}
+
+		// Values are stored inline where they fit in four bytes
+		start := entry + 8
+		if size*n > 4 {
+			start = int(r.order.Uint32(r.data[entry+8:]))
+		}
+		if start+size*n > len(r.data) {
+			return fmt.Errorf("tag %d data out of bounds", tag)
+		}
+		raw := r.data[start : start+size*n]
+
+		if kind == tiffASCII {
+			r.ascii[tag] = string(raw)
+			continue
+		}
+
+		values := make([]float64, n)
+		for j := range values {
+			values[j] = r.value(kind, raw[j*size:])
+		}
+		r.tags[tag] = values
+	}
+
+	return nil
+}
+
+// value decodes a single value of the provided type
+func (r *tiffReader) value(kind uint16, b []byte) float64 {
+	switch kind {
+	case tiffByte:
+		return float64(b[0])
+	case tiffSByte:
+		return float64(int8(b[0]))
+	case tiffShort:
+		return float64(r.order.Uint16(b))
+	case tiffSShort:
+		return float64(int16(r.order.Uint16(b)))
+	case tiffLong:
+		return float64(r.order.Uint32(b))
+	case tiffSLong:
+		return float64(int32(r.order.Uint32(b)))
+	case tiffRational:
+		return float64(r.order.Uint32(b)) / float64(r.order.Uint32(b[4:]))
+	case tiffFloat:
+		return float64(math.Float32frombits(r.order.Uint32(b)))
+	case tiffDouble:
+		return math.Float64frombits(r.order.Uint64(b))
+	}
+	return 0
+}
+
+// sample decodes a single image sample
+func (r *tiffReader) sample(format, bits int, b []byte) (float64, error) {
+	switch {
+	case format == tiffFormatFloat && bits == 32:
+		return r.value(tiffFloat, b), nil
+	case format == tiffFormatFloat && bits == 64:
+		return r.value(tiffDouble, b), nil
+	case format == tiffFormatInt && bits == 8:
+		return r.value(tiffSByte, b), nil
+	case format == tiffFormatInt && bits == 16:
+		return r.value(tiffSShort, b), nil
+	case format == tiffFormatInt && bits == 32:
+		return r.value(tiffSLong, b), nil
+	case format == tiffFormatUint && bits == 8:
+		return r.value(tiffByte, b), nil
+	case format == tiffFormatUint && bits == 16:
+		return r.value(tiffShort, b), nil
+	case format == tiffFormatUint && bits == 32:
+		return r.value(tiffLong, b), nil
+	}
+	return 0, fmt.Errorf("unsupported sample format %d with %d bits", format, bits)
+}
+
+// block fetches and decompresses an image strip or tile
+func (r *tiffReader) block(offset, length int) ([]byte, error) {
+	if offset+length > len(r.data) {
+		return nil, fmt.Errorf("image data out of bounds")
+	}
+	raw := r.data[offset : offset+length]
+
+	switch r.int(tiffTagCompression, tiffCompressionNone) {
+	case tiffCompressionNone:
+		return raw, nil
+	case tiffCompressionDeflate, tiffCompressionAdobe:
+		z, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		return ioutil.ReadAll(z)
+	}
+	return nil, fmt.Errorf("unsupported TIFF compression (%d)", r.int(tiffTagCompression, 0))
+}
+
+// readSamples reads all image samples from strips or tiles into a row-major array
+func (r *tiffReader) readSamples(width, height int) ([]float64, error) {
+	bits := r.int(tiffTagBitsPerSample, 8)
+	format := r.int(tiffTagSampleFormat, tiffFormatUint)
+	bytesPerSample := bits / 8
+	if r.int(tiffTagPredictor, 1) != 1 {
+		return nil, fmt.Errorf("TIFF predictors are not supported")
+	}
+
+	values := make([]float64, width*height)
+
+	// Strips are treated as tiles spanning the image width
+	blockWidth, blockHeight := width, r.int(tiffTagRowsPerStrip, height)
+	offsets, counts := r.tags[tiffTagStripOffsets], r.tags[tiffTagStripByteCounts]
+	if _, ok := r.tags[tiffTagTileOffsets]; ok {
+		blockWidth, blockHeight = r.int(tiffTagTileWidth, 0), r.int(tiffTagTileLength, 0)
+		offsets, counts = r.tags[tiffTagTileOffsets], r.tags[tiffTagTileByteCounts]
+	}
+	if blockWidth == 0 || blockHeight == 0 || len(offsets) == 0 || len(offsets) != len(counts) {
+		return nil, fmt.Errorf("invalid TIFF image layout")
+	}
+
+	across := (width + blockWidth - 1) / blockWidth
+	for i := range offsets {
+		block, err := r.block(int(offsets[i]), int(counts[i]))
+		if err != nil {
+			return nil, err
+		}
+
+		x0, y0 := (i%across)*blockWidth, (i/across)*blockHeight
+		for y := 0; y < blockHeight && y0+y < height; y++ {
+			for x := 0; x < blockWidth && x0+x < width; x++ {
+				index := (y*blockWidth + x) * bytesPerSample
+				if index+bytesPerSample > len(block) {
+					return nil, fmt.Errorf("truncated TIFF image block")
+				}
+				v, err := r.sample(format, bits, block[index:])
+				if err != nil {
+					return nil, err
+				}
+				values[(y0+y)*width+x0+x] = v
+			}
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,91 @@
+package layers
+
+import (
+	"math"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// IndoorLayer implements the COST 231 multi-wall model using a building floorplan.
+// Free space loss is provided by the free-space layer, this layer adds the wall and floor penetration losses.
+type IndoorLayer struct {
+	floorplan *Floorplan
+	segments  []WallSegment
+	cache     Cache
+}
+
+// NewIndoorLayer creates a new indoor layer from the provided map configuration
+func NewIndoorLayer(c *config.Maps) (*IndoorLayer, error) {
+	fp, err := LoadFloorplan(c.Floorplan)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewIndoorLayerFromFloorplan(fp), nil
+}
+
+// NewIndoorLayerFromFloorplan creates a new indoor layer using an existing floorplan
+func NewIndoorLayerFromFloorplan(fp *Floorplan) *IndoorLayer {
+	return &IndoorLayer{
+		floorplan: fp,
+		segments:  fp.Segments(),
+		cache:     NewCache(),
+	}
+}
+
+// Floorplan fetches the floorplan used by the layer
+func (l *IndoorLayer) Floorplan() *Floorplan {
+	return l.floorplan
+}
+
+// CalculateFading calculates the wall and floor losses for a link
+func (l *IndoorLayer) CalculateFading(band config.Band, p1, p2 types.Location) (float64, error) {
+	attenuation, ok := l.cache.Get(band.Frequency, p1, p2)
+	if ok {
+		return attenuation, nil
+	}
+
+	attenuation = float64(l.WallLoss(p1, p2) + l.FloorLoss(p1, p2))
+
+	l.cache.Set(band.Frequency, p1, p2, attenuation)
+
+	return attenuation, nil
+}
+
+// WallLoss calculates the sum of the losses for walls crossed by the link between two points
+// Walls are only counted where they exist on the floor at the link altitude at the crossing point
+func (l *IndoorLayer) WallLoss(p1, p2 types.Location) types.Attenuation {
+	loss := types.Attenuation(0)
+
+	a, b := project(p1, p1), project(p1, p2)
+
+	for _, s := range l.segments {
+		t, ok := intersect(a, b, project(p1, s.A), project(p1, s.B))
+		if !ok {
+			continue
+		}
+
+		alt := p1.Alt + t*(p2.Alt-p1.Alt)
+		if s.OnFloor(l.floorplan.FloorIndex(alt)) {
+			loss += s.Loss
+		}
+	}
+
+	return loss
+}
+
+// FloorLoss calculates the loss due to floors penetrated by the link between two points
+// Using the multi-wall floor term: L = Lf * kf ^ ((kf + 2) / (kf + 1) - b)
+func (l *IndoorLayer) FloorLoss(p1, p2 types.Location) types.Attenuation {
+	f1, f2 := l.floorplan.FloorIndex(p1.Alt), l.floorplan.FloorIndex(p2.Alt)
+
+	kf := math.Abs(float64(f1 - f2))
+	if kf == 0 {
+		return 0
+	}
+
+	exp := (kf+2)/(kf+1) - l.floorplan.FloorFactor
+
+	return types.Attenuation(math.Pow(kf, exp)) * l.floorplan.FloorLoss
+}
@@ -0,0 +1,128 @@
+package layers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+const testFloorplanYAML = `
+floorloss: 15dB
+floors:
+  - name: ground
+    altitude: 0
+  - name: first
+    altitude: 3
+  - name: second
+    altitude: 6
+materials:
+  concrete: 10dB
+  drywall: 3dB
+walls:
+  - material: concrete
+    points:
+      - {lat: -36.8000, lng: 174.7001}
+      - {lat: -36.8010, lng: 174.7001}
+  - material: drywall
+    floors: [0]
+    points:
+      - {lat: -36.8000, lng: 174.7002}
+      - {lat: -36.8010, lng: 174.7002}
+`
+
+const testFloorplanGeoJSON = `{
+  "type": "FeatureCollection",
+  "floorloss": 15,
+  "materials": {"concrete": 10},
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"material": "concrete"},
+      "geometry": {
+        "type": "Polygon",
+        "coordinates": [[[174.7001, -36.8000], [174.7002, -36.8000], [174.7002, -36.8010], [174.7001, -36.8010], [174.7001, -36.8000]]]
+      }
+    }
+  ]
+}`
+
+func writeTestFile(t *testing.T, name, data string) string {
+	dir, err := ioutil.TempDir("", "yawns-indoor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestIndoorLayer(t *testing.T) {
+	band := config.Band{Frequency: 2.4e9}
+
+	p1 := types.Location{Lat: -36.8005, Lng: 174.7000, Alt: 1}
+	p2 := types.Location{Lat: -36.8005, Lng: 174.7003, Alt: 1}
+
+	t.Run("Loads YAML floorplans", func(t *testing.T) {
+		file := writeTestFile(t, "floorplan.yml", testFloorplanYAML)
+		defer os.RemoveAll(filepath.Dir(file))
+
+		l, err := NewIndoorLayer(&config.Maps{Floorplan: file})
+		assert.Nil(t, err)
+		assert.Len(t, l.segments, 2)
+
+		t.Run("Counts walls crossed on the same floor", func(t *testing.T) {
+			fading, err := l.CalculateFading(band, p1, p2)
+			assert.Nil(t, err)
+			assert.InDelta(t, 13.0, fading, 0.01)
+		})
+
+		t.Run("Only counts walls on the floor at the crossing", func(t *testing.T) {
+			a, b := p1, p2
+			a.Alt, b.Alt = 4, 4
+			assert.InDelta(t, 10.0, float64(l.WallLoss(a, b)), 0.01)
+		})
+
+		t.Run("Ignores walls not crossed", func(t *testing.T) {
+			b := types.Location{Lat: -36.8005, Lng: 174.70005, Alt: 1}
+			assert.InDelta(t, 0.0, float64(l.WallLoss(p1, b)), 0.01)
+		})
+
+		t.Run("Calculates floor losses using node altitude", func(t *testing.T) {
+			a := types.Location{Lat: -36.8005, Lng: 174.7000, Alt: 1}
+			b := types.Location{Lat: -36.8005, Lng: 174.7000, Alt: 4}
+			assert.InDelta(t, 15.0, float64(l.FloorLoss(a, b)), 0.01)
+
+			b.Alt = 7
+			assert.InDelta(t, 27.48, float64(l.FloorLoss(a, b)), 0.1)
+		})
+	})
+
+	t.Run("Loads GeoJSON floorplans", func(t *testing.T) {
+		file := writeTestFile(t, "floorplan.geojson", testFloorplanGeoJSON)
+		defer os.RemoveAll(filepath.Dir(file))
+
+		l, err := NewIndoorLayer(&config.Maps{Floorplan: file})
+		assert.Nil(t, err)
+		assert.Len(t, l.segments, 4)
+
+		fading, err := l.CalculateFading(band, p1, p2)
+		assert.Nil(t, err)
+		assert.InDelta(t, 20.0, fading, 0.01)
+	})
+
+	t.Run("Rejects unknown materials", func(t *testing.T) {
+		file := writeTestFile(t, "floorplan.yml", "walls:\n  - material: steel\n")
+		defer os.RemoveAll(filepath.Dir(file))
+
+		_, err := NewIndoorLayer(&config.Maps{Floorplan: file})
+		assert.NotNil(t, err)
+	})
+}
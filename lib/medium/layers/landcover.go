@@ -0,0 +1,235 @@
+package layers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/ryankurte/go-mapbox/lib/maps"
+	"github.com/ryankurte/go-rf"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/helpers"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// Land cover attenuation models
+const (
+	// LandCoverWeissberger applies Weissberger foliage loss over the distance through the class
+	LandCoverWeissberger = "weissberger"
+	// LandCoverLinear applies a specific attenuation (dB/m) over the distance through the class, limited to Loss where set
+	LandCoverLinear = "linear"
+	// LandCoverFixed applies a fixed loss where the path crosses the class
+	LandCoverFixed = "fixed"
+	// LandCoverNone applies no loss (ie. for water or open ground), distances are still reported
+	LandCoverNone = "none"
+)
+
+// landCoverClass is a land cover class with a parsed raster colour
+type landCoverClass struct {
+	name   string
+	colour color.RGBA
+	config.LandCoverClass
+}
+
+// LandCoverLayer implements per-class attenuation using a classified land cover map tile.
+// Each pixel colour maps to a class, and each class applies its own attenuation model to the
+// distance the link travels through it.
+type LandCoverLayer struct {
+	landCover maps.Tile
+	classes   []landCoverClass
+	cache     Cache
+}
+
+// NewLandCoverLayer creates a new land cover layer from the provided map configuration
+func NewLandCoverLayer(c *config.Maps) (*LandCoverLayer, error) {
+	return newLandCoverLayer(c, c.LandCover, c.LandCoverClasses)
+}
+
+// FoliageLayer applies Weissberger fading using a foliage map tile with foliage areas blacked out.
+// This is a single class land cover layer, reporting fading as a single value under the layer name.
+type FoliageLayer struct {
+	landCover *LandCoverLayer
+}
+
+// NewFoliageLayer creates a foliage layer from the provided map configuration
+func NewFoliageLayer(c *config.Maps) (*FoliageLayer, error) {
+	classes := map[string]config.LandCoverClass{
+		"foliage": config.LandCoverClass{Colour: "#000000", Model: LandCoverWeissberger},
+	}
+	l, err := newLandCoverLayer(c, c.Foliage, classes)
+	if err != nil {
+		return nil, err
+	}
+	return &FoliageLayer{landCover: l}, nil
+}
+
+// CalculateFading calculates the foliage fading for a link
+func (f *FoliageLayer) CalculateFading(band config.Band, p1, p2 types.Location) (float64, error) {
+	return f.landCover.CalculateFading(band, p1, p2)
+}
+
+func newLandCoverLayer(c *config.Maps, file string, classes map[string]config.LandCoverClass) (*LandCoverLayer, error) {
+	t := LandCoverLayer{}
+
+	var err error
+	t.classes, err = parseLandCoverClasses(classes)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := maps.LoadImage(file)
+	if err != nil {
+		log.Printf("Error loading %s", file)
+		return nil, err
+	}
+	t.landCover = maps.NewTile(c.X, c.Y, c.Level, c.GetTileSize(), img)
+
+	t.cache = NewCache()
+
+	return &t, nil
+}
+
+// parseLandCoverClasses validates land cover class configurations and parses class colours
+func parseLandCoverClasses(classes map[string]config.LandCoverClass) ([]landCoverClass, error) {
+	parsed := make([]landCoverClass, 0, len(classes))
+
+	for name, c := range classes {
+		colour, err := helpers.ParseColour(c.Colour)
+		if err != nil {
+			return nil, fmt.Errorf("land cover class %s: %s", name, err)
+		}
+
+		switch c.Model {
+		case LandCoverWeissberger, LandCoverLinear, LandCoverFixed, LandCoverNone:
+		case "":
+			c.Model = LandCoverNone
+		default:
+			return nil, fmt.Errorf("land cover class %s: unrecognised model '%s'", name, c.Model)
+		}
+
+		for _, p := range parsed {
+			if p.colour == colour {
+				return nil, fmt.Errorf("land cover classes %s and %s share colour %s", p.name, name, c.Colour)
+			}
+		}
+
+		parsed = append(parsed, landCoverClass{name: name, colour: colour, LandCoverClass: c})
+	}
+
+	// Sort for consistent reporting
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].name < parsed[j].name })
+
+	return parsed, nil
+}
+
+// classify finds the index of the class matching a pixel, returning -1 for unclassified or transparent pixels
+func (t *LandCoverLayer) classify(pixel color.Color) int {
+	r, g, b, a := pixel.RGBA()
+	if a == 0 {
+		return -1
+	}
+	for i, c := range t.classes {
+		if uint8(r>>8) == c.colour.R && uint8(g>>8) == c.colour.G && uint8(b>>8) == c.colour.B {
+			return i
+		}
+	}
+	return -1
+}
+
+// distances splits a path distance between classes using the pixels sampled along the path
+func (t *LandCoverLayer) distances(distance float64, pixels []color.Color) map[string]float64 {
+	distances := make(map[string]float64)
+	if len(pixels) == 0 {
+		return distances
+	}
+
+	counts := make([]int, len(t.classes))
+	for _, p := range pixels {
+		if i := t.classify(p); i >= 0 {
+			counts[i]++
+		}
+	}
+
+	for i, c := range t.classes {
+		if counts[i] > 0 {
+			distances[c.name] = distance / float64(len(pixels)) * float64(counts[i])
+		}
+	}
+
+	return distances
+}
+
+// ClassDistances fetches the distance (in meters) the path between two points travels through each land cover class
+func (t *LandCoverLayer) ClassDistances(p1, p2 types.Location) map[string]float64 {
+	distance := rf.CalculateDistanceLOS(p1.Lat, p1.Lng, p1.Alt, p2.Lat, p2.Lng, p2.Alt)
+
+	pixels := make([]color.Color, 0)
+	t.landCover.InterpolateLocations(onsToMapLoc(&p1), onsToMapLoc(&p2), func(pixel color.Color) color.Color {
+		pixels = append(pixels, pixel)
+		return pixel
+	})
+
+	return t.distances(float64(distance), pixels)
+}
+
+// classFading calculates the per-class attenuation for the provided class distances
+func (t *LandCoverLayer) classFading(band config.Band, distances map[string]float64) (types.AttenuationMap, error) {
+	fading := make(types.AttenuationMap)
+	var err error
+
+	for _, c := range t.classes {
+		d, ok := distances[c.name]
+		if !ok {
+			continue
+		}
+
+		switch c.Model {
+		case LandCoverWeissberger:
+			f, e := rf.CalculateFoliageLoss(rf.Frequency(band.Frequency), rf.Distance(d))
+			if e != nil && err == nil {
+				err = e
+			}
+			fading[c.name] = types.Attenuation(f)
+		case LandCoverLinear:
+			loss := float64(c.Rate) * d
+			if c.Loss > 0 {
+				loss = math.Min(loss, float64(c.Loss))
+			}
+			fading[c.name] = types.Attenuation(loss)
+		case LandCoverFixed:
+			fading[c.name] = c.Loss
+		default:
+			fading[c.name] = 0
+		}
+	}
+
+	return fading, err
+}
+
+// CalculateFadingMap calculates the per-class land cover fading for a link
+func (t *LandCoverLayer) CalculateFadingMap(band config.Band, p1, p2 types.Location) (types.AttenuationMap, error) {
+	if fading, ok := t.cache.GetMap(band.Frequency, p1, p2); ok {
+		return fading, nil
+	}
+
+	fading, err := t.classFading(band, t.ClassDistances(p1, p2))
+
+	t.cache.SetMap(band.Frequency, p1, p2, fading)
+
+	return fading, err
+}
+
+// CalculateFading calculates the overall land cover fading for a link
+func (t *LandCoverLayer) CalculateFading(band config.Band, p1, p2 types.Location) (float64, error) {
+	attenuation, ok := t.cache.Get(band.Frequency, p1, p2)
+	if ok {
+		return attenuation, nil
+	}
+
+	fading, err := t.CalculateFadingMap(band, p1, p2)
+
+	return float64(fading.Reduce()), err
+}
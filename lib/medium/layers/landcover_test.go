@@ -0,0 +1,82 @@
+package layers
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+func TestLandCoverLayer(t *testing.T) {
+	band := config.Band{Frequency: 2.4e9}
+
+	classes, err := parseLandCoverClasses(map[string]config.LandCoverClass{
+		"forest":     {Colour: "#006400", Model: LandCoverWeissberger},
+		"vegetation": {Colour: "#90ee90", Model: LandCoverLinear, Rate: 0.1, Loss: 5},
+		"water":      {Colour: "#0000ff", Model: LandCoverNone},
+		"urban":      {Colour: "#808080", Model: LandCoverFixed, Loss: 6},
+	})
+	assert.Nil(t, err)
+
+	l := LandCoverLayer{classes: classes, cache: NewCache()}
+
+	forest := color.RGBA{0x00, 0x64, 0x00, 0xff}
+	vegetation := color.RGBA{0x90, 0xee, 0x90, 0xff}
+	water := color.RGBA{0x00, 0x00, 0xff, 0xff}
+	other := color.RGBA{0x12, 0x34, 0x56, 0xff}
+
+	t.Run("Splits path distance between classes", func(t *testing.T) {
+		pixels := []color.Color{forest, forest, vegetation, water, other, color.RGBA{0x00, 0x64, 0x00, 0x00}}
+		distances := l.distances(600, pixels)
+
+		assert.Len(t, distances, 3)
+		assert.InDelta(t, 200, distances["forest"], 1e-6)
+		assert.InDelta(t, 100, distances["vegetation"], 1e-6)
+		assert.InDelta(t, 100, distances["water"], 1e-6)
+	})
+
+	t.Run("Applies per-class attenuation models", func(t *testing.T) {
+		fading, _ := l.classFading(band, map[string]float64{"vegetation": 20, "water": 100, "urban": 1})
+		assert.Len(t, fading, 3)
+		assert.InDelta(t, 2.0, float64(fading["vegetation"]), 1e-6)
+		assert.InDelta(t, 0.0, float64(fading["water"]), 1e-6)
+		assert.InDelta(t, 6.0, float64(fading["urban"]), 1e-6)
+
+		fading, _ = l.classFading(band, map[string]float64{"vegetation": 200})
+		assert.InDelta(t, 5.0, float64(fading["vegetation"]), 1e-6)
+	})
+
+	t.Run("Reports foliage under the foliage layer key", func(t *testing.T) {
+		classes, err := parseLandCoverClasses(map[string]config.LandCoverClass{
+			"foliage": {Colour: "#000000", Model: LandCoverWeissberger},
+		})
+		assert.Nil(t, err)
+		foliage := FoliageLayer{landCover: &LandCoverLayer{classes: classes, cache: NewCache()}}
+
+		p1, p2 := types.Location{Lat: 1, Lng: 2}, types.Location{Lat: 1.001, Lng: 2}
+		foliage.landCover.cache.SetMap(band.Frequency, p1, p2, types.AttenuationMap{"foliage": 7})
+
+		lm := NewLayerManager()
+		assert.Nil(t, lm.BindLayer("foliage", &foliage))
+		fading, err := lm.CalculateFading(band, p1, p2)
+		assert.Nil(t, err)
+		assert.EqualValues(t, types.AttenuationMap{"foliage": 7}, fading)
+	})
+
+	t.Run("Rejects invalid classes", func(t *testing.T) {
+		_, err := parseLandCoverClasses(map[string]config.LandCoverClass{"a": {Colour: "green"}})
+		assert.NotNil(t, err)
+
+		_, err = parseLandCoverClasses(map[string]config.LandCoverClass{"a": {Colour: "#000000", Model: "magic"}})
+		assert.NotNil(t, err)
+
+		_, err = parseLandCoverClasses(map[string]config.LandCoverClass{
+			"a": {Colour: "#000000"},
+			"b": {Colour: "#000000"},
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -22,6 +22,12 @@ type FadingInterface interface {
 	//CalculateFadingBounds(band config.Band, p1, p2 types.Location) (float64, float64)
 }
 
+// FadingMapInterface interface for layers reporting fading as a set of components (ie. per land cover class)
+// Components are reported in the layer manager AttenuationMap as "layer/component"
+type FadingMapInterface interface {
+	CalculateFadingMap(band config.Band, p1, p2 types.Location) (types.AttenuationMap, error)
+}
+
 // InfoInterface allows layers to return arbitrary info structures
 type InfoInterface interface {
 	GetInfo() interface{}
@@ -34,16 +40,18 @@ type RenderInterface interface {
 
 // LayerManager manages a set of medium layers
 type LayerManager struct {
-	FadingInterfaces map[string]FadingInterface
-	InfoInterfaces   map[string]InfoInterface
-	RenderInterface  RenderInterface
+	FadingInterfaces    map[string]FadingInterface
+	FadingMapInterfaces map[string]FadingMapInterface
+	InfoInterfaces      map[string]InfoInterface
+	RenderInterface     RenderInterface
 }
 
 // NewLayerManager creates a new medium layer manager
 func NewLayerManager() *LayerManager {
 	return &LayerManager{
-		FadingInterfaces: make(map[string]FadingInterface),
-		InfoInterfaces:   make(map[string]InfoInterface),
+		FadingInterfaces:    make(map[string]FadingInterface),
+		FadingMapInterfaces: make(map[string]FadingMapInterface),
+		InfoInterfaces:      make(map[string]InfoInterface),
 	}
 }
 
@@ -51,7 +59,10 @@ func NewLayerManager() *LayerManager {
 // This checks the layer against available interfaces and binds where matches are found
 func (lm *LayerManager) BindLayer(name string, layer interface{}) error {
 	match := false
-	if fading, ok := layer.(FadingInterface); ok {
+	if fadingMap, ok := layer.(FadingMapInterface); ok {
+		lm.FadingMapInterfaces[name] = fadingMap
+		match = true
+	} else if fading, ok := layer.(FadingInterface); ok {
 		lm.FadingInterfaces[name] = fading
 		match = true
 	}
@@ -75,6 +86,10 @@ func (lm *LayerManager) GetLayer(name string) (interface{}, error) {
 	if ok {
 		return f, nil
 	}
+	fm, ok := lm.FadingMapInterfaces[name]
+	if ok {
+		return fm, nil
+	}
 	i, ok := lm.InfoInterfaces[name]
 	if ok {
 		return i, nil
@@ -94,6 +109,13 @@ func (lm *LayerManager) CalculateFading(band config.Band, p1, p2 types.Location)
 		layers[name] = types.Attenuation(layerFading)
 	}
 
+	for name, layer := range lm.FadingMapInterfaces {
+		layerFadings, _ := layer.CalculateFadingMap(band, p1, p2)
+		for component, fading := range layerFadings {
+			layers[name+"/"+component] = fading
+		}
+	}
+
 	return layers, nil
 }
 
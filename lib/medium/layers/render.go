@@ -13,11 +13,14 @@ import (
 	"github.com/ryankurte/yawns/lib/types"
 )
 
+var wallColour = color.RGBA{255, 255, 255, 255}
+
 // RenderLayer provides mechanisms for map rendering and visualisation
 // Using the provided map tile
 type RenderLayer struct {
 	In        chan RenderCommand
 	satellite maps.Tile
+	floorplan *Floorplan
 }
 
 type RenderCommand struct {
@@ -38,11 +41,16 @@ func NewRenderLayer(c *config.Maps) (*RenderLayer, error) {
 		log.Printf("Error loading %s", c.Satellite)
 		return nil, err
 	}
-	r.satellite = maps.NewTile(c.X, c.Y, c.Level, 512, satelliteImg)
+	r.satellite = maps.NewTile(c.X, c.Y, c.Level, c.GetTileSize(), satelliteImg)
 
 	return &r, nil
 }
 
+// SetFloorplan attaches a floorplan to the render layer so walls are included in renders
+func (m *RenderLayer) SetFloorplan(fp *Floorplan) {
+	m.floorplan = fp
+}
+
 // Run launches a render layer thread to process RenderEvents
 func (m *RenderLayer) Run() {
 	for {
@@ -63,6 +71,12 @@ func (m *RenderLayer) Run() {
 func (m *RenderLayer) Render(fileName string, nodes types.Nodes, links types.Links) error {
 	tile := m.satellite
 
+	if m.floorplan != nil {
+		for _, s := range m.floorplan.Segments() {
+			tile.DrawLine(onsToMapLoc(&s.A), onsToMapLoc(&s.B), wallColour)
+		}
+	}
+
 	for _, l := range links {
 		n1, n2 := nodes[l.A], nodes[l.B]
 		tile.DrawLine(onsToMapLoc(&n1.Location), onsToMapLoc(&n2.Location), color.RGBA{255, 0, 0, 255})
@@ -88,7 +102,8 @@ type Render struct {
 }
 
 func (m *RenderLayer) NewRender() *Render {
-	return &Render{tile: m.satellite.Clone()}
+	r := &Render{tile: m.satellite.Clone()}
+	return r.Walls(m.floorplan, wallColour)
 }
 
 func (r *Render) Nodes(nodes types.Nodes, c color.RGBA, size uint64) *Render {
@@ -100,6 +115,16 @@ func (r *Render) Nodes(nodes types.Nodes, c color.RGBA, size uint64) *Render {
 	return r
 }
 
+func (r *Render) Walls(fp *Floorplan, c color.Color) *Render {
+	if fp == nil {
+		return r
+	}
+	for _, s := range fp.Segments() {
+		r.tile.DrawLine(onsToMapLoc(&s.A), onsToMapLoc(&s.B), c)
+	}
+	return r
+}
+
 func (r *Render) Links(nodes types.Nodes, links types.Links, color color.Color) *Render {
 	for _, l := range links {
 		n1, n2 := nodes[l.A], nodes[l.B]
@@ -11,15 +11,22 @@ import (
 	"github.com/ryankurte/yawns/lib/types"
 )
 
+// TerrainLayer implements terrain diffraction fading using the bullington figure 12 method
+// Terrain is loaded from elevation model files where provided, otherwise from a mapbox terrain-rgb tile
 type TerrainLayer struct {
-	terrain       maps.Tile
+	terrain       TerrainSource
 	defaultOffset float64
 	cache         Cache
 }
 
+// NewTerrainLayer creates a new terrain layer from the provided map configuration
 func NewTerrainLayer(c *config.Maps) (*TerrainLayer, error) {
-	t := TerrainLayer{
-		defaultOffset: 1.0,
+	if len(c.Elevation) != 0 {
+		elevation, err := LoadElevation(c.Elevation...)
+		if err != nil {
+			return nil, err
+		}
+		return NewTerrainLayerFromSource(elevation), nil
 	}
 
 	terrainImg, _, err := maps.LoadImage(c.Terrain)
@@ -27,11 +34,17 @@ func NewTerrainLayer(c *config.Maps) (*TerrainLayer, error) {
 		log.Printf("Error loading %s", c.Terrain)
 		return nil, err
 	}
-	t.terrain = maps.NewTile(c.X, c.Y, c.Level, 512, terrainImg)
 
-	t.cache = NewCache()
+	return NewTerrainLayerFromSource(&tileTerrain{maps.NewTile(c.X, c.Y, c.Level, c.GetTileSize(), terrainImg)}), nil
+}
 
-	return &t, nil
+// NewTerrainLayerFromSource creates a new terrain layer using the provided terrain source
+func NewTerrainLayerFromSource(source TerrainSource) *TerrainLayer {
+	return &TerrainLayer{
+		terrain:       source,
+		defaultOffset: 1.0,
+		cache:         NewCache(),
+	}
 }
 
 // CalculateFading calculates the free space fading for a link
@@ -41,10 +54,8 @@ func (m *TerrainLayer) CalculateFading(band config.Band, p1, p2 types.Location)
 		return attenuation, nil
 	}
 
-	p1m, p2m := onsToMapLoc(&p1), onsToMapLoc(&p2)
-
 	// Fetch terrain between points
-	terrain := m.terrain.InterpolateAltitudes(p1m, p2m)
+	terrain := m.terrain.Profile(p1, p2)
 	if len(terrain) == 0 {
 		return 0.0, fmt.Errorf("no terrain between %+v and %+v", p1, p2)
 	}
@@ -89,10 +100,8 @@ func (m *TerrainLayer) CalculateFading(band config.Band, p1, p2 types.Location)
 }
 
 func (m *TerrainLayer) GraphTerrain(file string, p1, p2 types.Location) error {
-	p1m, p2m := onsToMapLoc(&p1), onsToMapLoc(&p2)
-
 	// Fetch terrain between points
-	terrain := m.terrain.InterpolateAltitudes(p1m, p2m)
+	terrain := m.terrain.Profile(p1, p2)
 	if len(terrain) == 0 {
 		return fmt.Errorf("no terrain between %+v and %+v", p1, p2)
 	}
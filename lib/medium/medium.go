@@ -11,6 +11,9 @@ package medium
 import (
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/ryankurte/yawns/lib/config"
@@ -27,20 +30,34 @@ type Link struct {
 	Fading   float64
 }
 
+// linkKey identifies a directional link between nodes
+type linkKey struct {
+	From, To string
+}
+
+// stopTimeout is the maximum time to wait for the medium to exit
+const stopTimeout = time.Second
+
 // Medium is the wireless medium simulation instance
 type Medium struct {
 	config        *config.Medium
 	nodes         *types.Nodes
 	transmissions []*Transmission
-	transceivers  []map[string]Transceiver
+	transceivers  []map[radioKey]Transceiver
+	receivers     map[string][]receiver
+	interferers   []*Interferer
+	linkFaults    map[linkKey]types.Attenuation
 	rate          time.Duration
+	startTime     time.Time
 
 	layerManager *layers.LayerManager
 
-	stats Stats
+	stats      Stats
+	statsMutex sync.Mutex
 
 	inCh  chan interface{}
 	outCh chan interface{}
+	done  chan struct{}
 }
 
 // NewMedium creates a new medium instance
@@ -49,24 +66,71 @@ func NewMedium(c *config.Medium, rate time.Duration, nodes *types.Nodes) (*Mediu
 	m := Medium{
 		config:        c,
 		rate:          rate,
+		startTime:     time.Now(),
 		inCh:          make(chan interface{}, 128),
 		outCh:         make(chan interface{}, 128),
+		done:          make(chan struct{}),
 		transmissions: make([]*Transmission, 0),
-		transceivers:  make([]map[string]Transceiver, len(*nodes)),
+		transceivers:  make([]map[radioKey]Transceiver, len(*nodes)),
+		receivers:     make(map[string][]receiver),
+		linkFaults:    make(map[linkKey]types.Attenuation),
 		layerManager:  layers.NewLayerManager(),
 		nodes:         nodes,
 		stats:         NewStats(),
 	}
 
-	// Initialise TransceiverState for each node and band
+	bands := make([]string, 0, len(c.Bands))
+	for name := range c.Bands {
+		bands = append(bands, name)
+	}
+	sort.Strings(bands)
+
+	// Initialise TransceiverState for each node radio
+	// Nodes without configured radios have a single radio on each band
 	for i, n := range *nodes {
 		m.stats.Nodes[n.Address] = NewNodeStats()
-		m.transceivers[i] = make(map[string]Transceiver)
-		for j := range c.Bands {
-			m.transceivers[i][j] = *NewTransceiver(time.Now())
+		m.transceivers[i] = make(map[radioKey]Transceiver)
+
+		radios := n.Radios
+		if len(radios) == 0 {
+			radios = make([]types.Radio, len(bands))
+			for j, b := range bands {
+				radios[j] = types.Radio{Band: b}
+			}
+		}
+
+		for _, r := range radios {
+			key := radioKey{r.Band, r.ID}
+			if _, ok := c.Bands[r.Band]; !ok {
+				return nil, fmt.Errorf("node %s radio %d: no matching band configured (%s)", n.Address, r.ID, r.Band)
+			}
+			if _, ok := m.transceivers[i][key]; ok {
+				return nil, fmt.Errorf("node %s: duplicate radio %d on band %s", n.Address, r.ID, r.Band)
+			}
+			m.transceivers[i][key] = *NewTransceiver(time.Now())
+			m.receivers[r.Band] = append(m.receivers[r.Band], receiver{node: i, radio: r})
 		}
 	}
 
+	// Create interferers (sorted by name for consistent ordering)
+	names := make([]string, 0, len(c.Interferers))
+	for name := range c.Interferers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ic := c.Interferers[name]
+		if _, ok := c.Bands[ic.Band]; !ok {
+			return nil, fmt.Errorf("interferer %s: no matching band configured (%s)", name, ic.Band)
+		}
+		i, err := NewInterferer(name, ic)
+		if err != nil {
+			return nil, err
+		}
+		m.interferers = append(m.interferers, i)
+	}
+
 	m.BindDefaultLayers(c)
 
 	return &m, nil
@@ -86,7 +150,7 @@ func (m *Medium) BindDefaultLayers(c *config.Medium) error {
 		m.layerManager.BindLayer("render", mapLayer)
 	}
 
-	if c.Maps.Terrain != "" {
+	if c.Maps.Terrain != "" || len(c.Maps.Elevation) != 0 {
 		mapLayer, err := layers.NewTerrainLayer(&c.Maps)
 		if err != nil {
 			return err
@@ -102,6 +166,27 @@ func (m *Medium) BindDefaultLayers(c *config.Medium) error {
 		m.layerManager.BindLayer("foliage", mapLayer)
 	}
 
+	if c.Maps.LandCover != "" {
+		mapLayer, err := layers.NewLandCoverLayer(&c.Maps)
+		if err != nil {
+			return err
+		}
+		m.layerManager.BindLayer("landcover", mapLayer)
+	}
+
+	if c.Maps.Floorplan != "" {
+		indoorLayer, err := layers.NewIndoorLayer(&c.Maps)
+		if err != nil {
+			return err
+		}
+		m.layerManager.BindLayer("indoor", indoorLayer)
+
+		// Attach walls to the render layer where available
+		if r, ok := m.layerManager.RenderInterface.(*layers.RenderLayer); ok {
+			r.SetFloorplan(indoorLayer.Floorplan())
+		}
+	}
+
 	return nil
 }
 
@@ -121,6 +206,35 @@ func (m *Medium) Receive() chan interface{} {
 	return m.outCh
 }
 
+// Stats returns a snapshot of the medium statistics, this is safe to call while the medium is running
+func (m *Medium) Stats() Stats {
+	m.statsMutex.Lock()
+	defer m.statsMutex.Unlock()
+
+	m.updateTransceiverStats(time.Now())
+
+	return m.stats.Copy()
+}
+
+// putTransceiver stores an updated transceiver
+// Transceivers are only modified by the medium, with stores guarded by the statsMutex so that
+// statistics snapshots may read transceiver stats while the medium is running
+func (m *Medium) putTransceiver(index int, key radioKey, t Transceiver) {
+	m.statsMutex.Lock()
+	m.transceivers[index][key] = t
+	m.statsMutex.Unlock()
+}
+
+// updateTransceiverStats copies transceiver stats (including the time in current states) to the medium stats
+// This must be called with the statsMutex held
+func (m *Medium) updateTransceiverStats(now time.Time) {
+	for i, n := range *m.nodes {
+		for k, t := range m.transceivers[i] {
+			m.stats.Nodes[n.Address].Transceivers[k.String()] = t.StatsAt(now)
+		}
+	}
+}
+
 func (m *Medium) preloadFadings() {
 	for _, v := range m.config.Bands {
 		for i1, n1 := range *m.nodes {
@@ -147,15 +261,31 @@ func (m *Medium) GetPointToPointFading(band config.Band, n1, n2 types.Node) type
 func (m *Medium) Start() {
 	m.preloadFadings()
 
+	now := time.Now()
+	m.startTime = now
+	for _, i := range m.interferers {
+		if !i.Disabled {
+			i.Start(now)
+		}
+	}
+
 	go m.Run()
 }
 
+// Stop stops the running medium, waiting (up to stopTimeout) for statistics to be written
 func (m *Medium) Stop() {
 	close(m.inCh)
+	select {
+	case <-m.done:
+	case <-time.After(stopTimeout):
+		log.Printf("[WARNING] Medium stop timed out")
+	}
 }
 
 // Run runs the medium simulation
 func (m *Medium) Run() {
+	defer close(m.done)
+
 	log.Printf("[INFO] Medium running")
 
 	lastTime := time.Now()
@@ -181,16 +311,16 @@ running:
 			// Calculate delta between runs
 			delta := now.Sub(lastTime)
 			lastTime = now
+			m.statsMutex.Lock()
 			m.stats.AddTick(delta)
+			m.statsMutex.Unlock()
 			m.update(now)
 		}
 	}
 
-	for i, n := range *m.nodes {
-		for k, t := range m.transceivers[i] {
-			m.stats.Nodes[n.Address].Transceivers[k] = t.Stats
-		}
-	}
+	m.statsMutex.Lock()
+	m.updateTransceiverStats(time.Now())
+	m.statsMutex.Unlock()
 
 	log.Printf("[INFO] Medium exited")
 	log.Printf("Medium Info")
@@ -207,10 +337,12 @@ func (m *Medium) handleMessage(message interface{}) error {
 	switch msg := message.(type) {
 	case messages.Packet:
 		return m.sendPacket(time.Now(), msg)
+	case messages.InjectedPacket:
+		return m.injectPacket(time.Now(), msg.Packet)
 	case messages.RSSIRequest:
-		rssi, err := m.getRSSI(msg.Address, msg.Band, msg.Channel)
+		rssi, err := m.getRSSI(time.Now(), msg.Address, msg.Band, msg.Radio, msg.Channel)
 		if err != nil {
-			log.Printf("[ERROR] Medium RSSI get error: %s", err)
+			return fmt.Errorf("Medium RSSI get error: %s", err)
 		}
 		m.outCh <- messages.RSSIResponse{
 			BaseMessage: msg.BaseMessage,
@@ -218,8 +350,15 @@ func (m *Medium) handleMessage(message interface{}) error {
 			RSSI:        float32(rssi),
 		}
 	case messages.StateRequest:
-		nodeIndex, _ := m.getNodeIndex(msg.Address)
-		state := m.transceivers[nodeIndex][msg.Band].State
+		nodeIndex, err := m.getNodeIndex(msg.Address)
+		if err != nil {
+			return err
+		}
+		transceiver, ok := m.transceivers[nodeIndex][radioKey{msg.Band, msg.Radio}]
+		if !ok {
+			return fmt.Errorf("node %s has no radio %d on band %s", msg.Address, msg.Radio, msg.Band)
+		}
+		state := transceiver.State
 		m.outCh <- messages.StateResponse{
 			BaseMessage: msg.BaseMessage,
 			RFInfo:      msg.RFInfo,
@@ -227,13 +366,43 @@ func (m *Medium) handleMessage(message interface{}) error {
 		}
 
 	case messages.StateSet:
-		m.setTransceiverState(msg.Address, msg.Band, msg.State)
+		return m.setTransceiverState(msg.Address, msg.Band, msg.Radio, msg.State)
 
 	case messages.Register:
+		// Power on transceivers for reconnecting nodes
+		if m.stats.Nodes[msg.Address].Disconnects == 0 {
+			return nil
+		}
+		m.statsMutex.Lock()
+		m.stats.IncrementReconnects(msg.Address)
+		m.statsMutex.Unlock()
+		return m.setNodeTransceiversState(msg.Address, types.TransceiverStateIdle)
+
+	case messages.Deregister:
+		// Power off transceivers while nodes are disconnected
+		m.statsMutex.Lock()
+		m.stats.IncrementDisconnects(msg.Address)
+		m.statsMutex.Unlock()
+		return m.setNodeTransceiversState(msg.Address, types.TransceiverStateOff)
+
+	case messages.FieldSet, messages.FieldGet, messages.SensorRequest:
 		// Mock to avoid warning on unhandled message
 
-	case messages.FieldSet:
-		// Mock to avoid warning on unhandled message
+	case messages.InterfererSet:
+		return m.setInterfererState(time.Now(), msg.Name, msg.Active)
+
+	case messages.LocationSet:
+		node, err := m.getNodeByAddr(msg.Address)
+		if err != nil {
+			return err
+		}
+		node.Location = msg.Location
+
+	case messages.RadioFault:
+		return m.setRadioFault(msg.Address, msg.Band, msg.Radio, msg.Failed)
+
+	case messages.LinkFault:
+		m.setLinkFault(msg.From, msg.To, types.Attenuation(msg.Attenuation))
 
 	default:
 		log.Printf("[WARNING] medium unhandled message type: %T", message)
@@ -246,21 +415,123 @@ func (m *Medium) Render(filename string, nodes types.Nodes, links types.Links) e
 	return m.layerManager.Render(filename, nodes, links)
 }
 
-func (m *Medium) setTransceiverState(address, band string, state types.TransceiverState) error {
+func (m *Medium) setTransceiverState(address, band string, radio uint32, state types.TransceiverState) error {
 	index, err := m.getNodeIndex(address)
 	if err != nil {
 		return err
 	}
-	transceiver, ok := m.transceivers[index][band]
+	key := radioKey{band, radio}
+	transceiver, ok := m.transceivers[index][key]
 	if !ok {
-		return fmt.Errorf("Transceiver not found for band: %s", band)
+		return fmt.Errorf("Transceiver not found for node %s band: %s radio: %d", address, band, radio)
 	}
 	transceiver.SetState(time.Now(), state)
-	m.transceivers[index][band] = transceiver
+	m.putTransceiver(index, key, transceiver)
 	return nil
 }
 
+// setNodeTransceiversState sets the state of all transceivers for a node
+func (m *Medium) setNodeTransceiversState(address string, state types.TransceiverState) error {
+	index, err := m.getNodeIndex(address)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for key, transceiver := range m.transceivers[index] {
+		transceiver.SetState(now, state)
+		m.putTransceiver(index, key, transceiver)
+	}
+	return nil
+}
+
+// setRadioFault fails or restores a node radio, all node radios on the band if no radio is specified,
+// or all node radios if no band is specified
+func (m *Medium) setRadioFault(address, band string, radio *uint32, failed bool) error {
+	index, err := m.getNodeIndex(address)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	now := time.Now()
+	for key, transceiver := range m.transceivers[index] {
+		if band != "" && (key.Band != band || (radio != nil && key.ID != *radio)) {
+			continue
+		}
+		found = true
+
+		transceiver.Failed = failed
+		if failed {
+			transceiver.SetState(now, types.TransceiverStateOff)
+		} else {
+			transceiver.SetState(now, types.TransceiverStateIdle)
+		}
+		m.putTransceiver(index, key, transceiver)
+	}
+	if !found && radio != nil {
+		return fmt.Errorf("node %s has no radio %d on band %s", address, *radio, band)
+	} else if !found {
+		return fmt.Errorf("node %s has no radios on band %s", address, band)
+	}
+
+	log.Printf("[INFO] Medium: node %s radio fault (band: '%s' failed: %t)", address, band, failed)
+
+	return nil
+}
+
+// setLinkFault sets the additional attenuation of links in both directions between nodes
+// Zero attenuation clears faults, and all faults are cleared if no nodes are provided
+func (m *Medium) setLinkFault(from, to []string, attenuation types.Attenuation) {
+	if len(from) == 0 && len(to) == 0 {
+		m.linkFaults = make(map[linkKey]types.Attenuation)
+		return
+	}
+
+	for _, a := range from {
+		for _, b := range to {
+			if a == b {
+				continue
+			}
+			for _, k := range []linkKey{{a, b}, {b, a}} {
+				if attenuation == 0 {
+					delete(m.linkFaults, k)
+				} else {
+					m.linkFaults[k] = attenuation
+				}
+			}
+		}
+	}
+}
+
+// getReceiverIndex fetches the index of a node radio in the receivers for a band
+func (m *Medium) getReceiverIndex(nodeIndex int, band string, radio uint32) (int, error) {
+	for i, r := range m.receivers[band] {
+		if r.node == nodeIndex && r.radio.ID == radio {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no radio %d found on band %s for node %s", radio, band, (*m.nodes)[nodeIndex].Address)
+}
+
+// getLinkFading calculates the fading between a transmission and a receiver including node and radio gains
+func (m *Medium) getLinkFading(band config.Band, t *Transmission, r receiver) types.Attenuation {
+	n := (*m.nodes)[r.node]
+	fading := m.GetPointToPointFading(band, *t.Origin, n).Reduce()
+	fading += m.linkFaults[linkKey{t.Origin.Address, n.Address}]
+	return fading - t.Gain - types.Attenuation(n.Gain+r.radio.Gain)
+}
+
 func (m *Medium) sendPacket(now time.Time, p messages.Packet) error {
+	return m.transmit(now, p, false)
+}
+
+// injectPacket transmits a packet from a node location without changing the node radio state
+func (m *Medium) injectPacket(now time.Time, p messages.Packet) error {
+	return m.transmit(now, p, true)
+}
+
+// transmit starts a transmission, injected transmissions do not affect the origin radio or statistics
+func (m *Medium) transmit(now time.Time, p messages.Packet, injected bool) error {
 
 	fromAddress, bandName := p.Address, p.Band
 
@@ -277,26 +548,45 @@ func (m *Medium) sendPacket(now time.Time, p messages.Packet) error {
 		return fmt.Errorf("Medium error: no matching band configured (%s)", bandName)
 	}
 
-	m.stats.IncrementSent(fromAddress, bandName)
+	// Locate source radio
+	sourceIndex, err := m.getReceiverIndex(nodeIndex, bandName, p.Radio)
+	if err != nil {
+		return err
+	}
+	sourceRadio := m.receivers[bandName][sourceIndex].radio
+
+	// Packets sent from failed radios are not received (but still complete)
+	failed := !injected && m.transceivers[nodeIndex][radioKey{bandName, p.Radio}].Failed
 
-	//log.Printf("[DEBUG] Medium - Starting transmission from %s", fromAddress)
+	if !injected {
+		m.statsMutex.Lock()
+		m.stats.IncrementSent(fromAddress, bandName)
+		m.statsMutex.Unlock()
 
-	// Set transmitting state
-	m.setTransceiverState(fromAddress, bandName, types.TransceiverStateTransmitting)
+		//log.Printf("[DEBUG] Medium - Starting transmission from %s", fromAddress)
+
+		// Set transmitting state
+		m.setTransceiverState(fromAddress, bandName, p.Radio, types.TransceiverStateTransmitting)
+	}
 
 	// Create transmission instance
 	t := NewTransmission(now, source, &band, p)
-	t.SendOK = make([]bool, len(*m.nodes))
-	t.RSSIs = make([][]types.Attenuation, len(*m.nodes))
-
-	// Calculate initial transmission states for simulated nodes
-	for i, n := range *m.nodes {
-		if n.Address == fromAddress {
+	t.Injected = injected
+	t.Gain = types.Attenuation(source.Gain + sourceRadio.Gain + sourceRadio.Power)
+	receivers := m.receivers[bandName]
+	t.SendOK = make([]bool, len(receivers))
+	t.RSSIs = make([][]types.Attenuation, len(receivers))
+
+	// Calculate initial transmission states for simulated node radios on the band
+	for i, r := range receivers {
+		n := (*m.nodes)[r.node]
+		if r.node == nodeIndex || failed {
 			t.SendOK[i] = false
+			t.RSSIs[i] = []types.Attenuation{}
 			continue
 		}
 
-		fading := m.GetPointToPointFading(band, *source, n).Reduce()
+		fading := m.getLinkFading(band, t, r)
 		t.RSSIs[i] = make([]types.Attenuation, 1)
 		t.RSSIs[i][0] = fading
 
@@ -309,10 +599,10 @@ func (m *Medium) sendPacket(now time.Time, p messages.Packet) error {
 		t.SendOK[i] = true
 
 		// Update radio states
-		transceiver := m.transceivers[i][t.Band]
+		transceiver := m.transceivers[r.node][radioKey{bandName, r.radio.ID}]
 		if transceiver.State == types.TransceiverStateReceive {
 			// Devices in receive state will enter receiving state
-			m.setTransceiverState(n.Address, bandName, types.TransceiverStateReceiving)
+			m.setTransceiverState(n.Address, bandName, r.radio.ID, types.TransceiverStateReceiving)
 		}
 	}
 
@@ -330,6 +620,9 @@ func (m *Medium) update(now time.Time) {
 	// Calculate collisions for each pair of transmissions at each node
 	m.updateCollisions(now)
 
+	// Calculate collisions between transmissions and interferers at each node
+	m.updateInterference(now)
+
 	// Finalise completed transmissions
 	m.finaliseTransmissions(now)
 }
@@ -340,18 +633,19 @@ func (m *Medium) updateTransmissions(now time.Time) {
 	for i, t := range m.transmissions {
 		// Update receive states
 		band := m.config.Bands[t.Band]
-		for j, n := range *m.nodes {
+		for j, r := range m.receivers[t.Band] {
+			n := (*m.nodes)[r.node]
 			if n.Address == t.Origin.Address {
 				continue
 			}
-			fading := m.GetPointToPointFading(band, *t.Origin, n).Reduce()
+			fading := m.getLinkFading(band, t, r)
 			m.transmissions[i].RSSIs[j] = append(t.RSSIs[j], fading)
 
 			// Reject if fading exceeds link budget
 			if t.SendOK[j] && fading > band.LinkBudget {
-				log.Printf("Updating failed state for node %d (%s)", j, n.Address)
+				log.Printf("Updating failed state for node %d (%s)", r.node, n.Address)
 				m.transmissions[i].SendOK[j] = false
-				m.setTransceiverState(n.Address, t.Band, types.TransceiverStateReceive)
+				m.setTransceiverState(n.Address, t.Band, r.radio.ID, types.TransceiverStateReceive)
 			}
 
 			// TODO: Reject if radio exits receiving state
@@ -368,13 +662,18 @@ func (m *Medium) updateTransmissions(now time.Time) {
 
 // updateCollisions calculates collisions based on the interference budget and last rssi value
 func (m *Medium) updateCollisions(now time.Time) {
-	for i, n := range *m.nodes {
-		// Compare all transmissions
-		for j1, t1 := range m.transmissions {
-			for j2, t2 := range m.transmissions {
-				// Filter transmissions we don't need to compare
-				if j1 == j2 || t1.Band != t2.Band || t1.Channel != t2.Channel ||
-					n.Address == t1.Origin.Address || n.Address == t2.Origin.Address ||
+	// Compare all transmissions
+	for j1, t1 := range m.transmissions {
+		for j2, t2 := range m.transmissions {
+			// Filter transmissions we don't need to compare
+			if j1 == j2 || t1.Band != t2.Band || t1.Channel != t2.Channel {
+				continue
+			}
+
+			// At each radio on the band
+			for i, r := range m.receivers[t1.Band] {
+				n := (*m.nodes)[r.node]
+				if n.Address == t1.Origin.Address || n.Address == t2.Origin.Address ||
 					(!t1.SendOK[i] && !t2.SendOK[i]) {
 					continue
 				}
@@ -392,34 +691,40 @@ func (m *Medium) updateCollisions(now time.Time) {
 					(rssiDifference < 0 && rssiDifference > -band.InterferenceBudget) {
 					m.transmissions[j1].SendOK[i] = false
 					m.transmissions[j2].SendOK[i] = false
-					m.setTransceiverState(n.Address, t1.Band, types.TransceiverStateReceive)
+					m.setTransceiverState(n.Address, t1.Band, r.radio.ID, types.TransceiverStateReceive)
+					m.statsMutex.Lock()
+					m.stats.IncrementCollided(n.Address, t1.Band)
+					m.statsMutex.Unlock()
 				}
 			}
 		}
 	}
 }
 
-func (m *Medium) getRSSI(address, bandName string, channel int32) (types.Attenuation, error) {
-	band := m.config.Bands[bandName]
+// getRSSI calculates the channel power (in dBm) at a radio, including the noise floor, active interferers
+// and any in flight transmissions on the channel
+func (m *Medium) getRSSI(now time.Time, address, bandName string, radio uint32, channel int32) (types.Attenuation, error) {
 	nodeIndex, err := m.getNodeIndex(address)
 	if err != nil {
 		return 0.0, err
 	}
+	receiverIndex, err := m.getReceiverIndex(nodeIndex, bandName, radio)
+	if err != nil {
+		return 0.0, err
+	}
 
-	rssi := band.NoiseFloor
+	// Received powers from in flight transmissions are summed with the noise floor in the linear domain
+	band := m.config.Bands[bandName]
+	sum := math.Pow(10, float64(m.getNoiseFloor(now, m.receivers[bandName][receiverIndex], bandName, channel))/10)
 	for _, t := range m.transmissions {
-		if t.Band != bandName || t.Channel != channel {
+		if t.Band != bandName || t.Channel != channel || len(t.RSSIs[receiverIndex]) == 0 {
 			continue
 		}
-		fading := t.RSSIs[nodeIndex][len(t.RSSIs[nodeIndex])-1]
-
-		if fading > rssi {
-			rssi = fading
-		}
-
+		received := band.NoiseFloor + band.LinkBudget - t.RSSIs[receiverIndex][len(t.RSSIs[receiverIndex])-1]
+		sum += math.Pow(10, float64(received)/10)
 	}
 
-	return rssi, nil
+	return types.Attenuation(10 * math.Log10(sum)), nil
 }
 
 // finaliseTransmissions finalises any completed transmissions
@@ -433,19 +738,34 @@ func (m *Medium) finaliseTransmissions(now time.Time) {
 			//log.Printf("[DEBUG] Medium - Completing transmission from %s", t.Origin.Address)
 
 			// Update origin transmitting state
-			m.outCh <- messages.NewSendComplete(t.Origin.Address, t.Band, t.Channel)
-			if band.NoAutoTXRXTransition {
-				m.setTransceiverState(t.Origin.Address, t.Band, types.TransceiverStateIdle)
-			} else {
-				m.setTransceiverState(t.Origin.Address, t.Band, types.TransceiverStateReceive)
+			if !t.Injected {
+				m.outCh <- messages.NewSendComplete(t.Origin.Address, t.Band, t.Radio, t.Channel)
+
+				// Origins that disconnected during the transmission remain powered off
+				originIndex, _ := m.getNodeIndex(t.Origin.Address)
+				if m.transceivers[originIndex][radioKey{t.Band, t.Radio}].State != types.TransceiverStateOff {
+					if band.NoAutoTXRXTransition {
+						m.setTransceiverState(t.Origin.Address, t.Band, t.Radio, types.TransceiverStateIdle)
+					} else {
+						m.setTransceiverState(t.Origin.Address, t.Band, t.Radio, types.TransceiverStateReceive)
+					}
+				}
 			}
 
 			// Distribute to receivers
-			for i, n := range *m.nodes {
-				if t.SendOK[i] && m.transceivers[i][t.Band].State == types.TransceiverStateReceiving {
-					m.outCh <- messages.NewPacket(n.Address, t.Data, t.GetRFInfo(i))
-					m.setTransceiverState(n.Address, t.Band, types.TransceiverStateReceive)
-					m.stats.IncrementReceived(t.Origin.Address, n.Address, t.Band)
+			for i, r := range m.receivers[t.Band] {
+				n := (*m.nodes)[r.node]
+				key := radioKey{t.Band, r.radio.ID}
+				if t.SendOK[i] && m.transceivers[r.node][key].State == types.TransceiverStateReceiving {
+					noise := m.getPacketNoise(now, t, i)
+					m.outCh <- messages.NewPacket(n.Address, t.Data, t.GetRFInfo(&band, i, r.radio.ID, noise, m.startTime))
+					m.setTransceiverState(n.Address, t.Band, r.radio.ID, types.TransceiverStateReceive)
+					if !t.Injected {
+						m.statsMutex.Lock()
+						m.stats.IncrementReceived(t.Origin.Address, n.Address, t.Band)
+						m.stats.AddLatency(now.Sub(t.StartTime))
+						m.statsMutex.Unlock()
+					}
 				}
 			}
 
@@ -463,6 +783,116 @@ func (m *Medium) finaliseTransmissions(now time.Time) {
 	}
 }
 
+// setInterfererState starts or stops an interferer
+func (m *Medium) setInterfererState(now time.Time, name string, active bool) error {
+	for _, i := range m.interferers {
+		if i.Name == name {
+			if active {
+				i.Start(now)
+			} else {
+				i.Stop()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no interferer found matching the provided name (%s)", name)
+}
+
+// getInterference fetches the attenuation of the signal from an interferer to a node, and whether the interferer
+// is currently emitting above the link budget on the provided band and channel
+func (m *Medium) getInterference(now time.Time, i *Interferer, r receiver, bandName string, channel int32) (types.Attenuation, bool) {
+	if !i.OnChannel(bandName, channel) {
+		return 0, false
+	}
+	power, on := i.GetPower(now)
+	if !on {
+		return 0, false
+	}
+
+	band := m.config.Bands[bandName]
+	n := (*m.nodes)[r.node]
+	fading := m.GetPointToPointFading(band, i.node, n).Reduce() - power - types.Attenuation(n.Gain+r.radio.Gain)
+	if fading > band.LinkBudget {
+		return 0, false
+	}
+
+	return fading, true
+}
+
+// getNoiseFloor calculates the noise floor at a node, raised by the power received from any active interferers
+// Interferer powers are referenced to the noise floor at the link budget, then summed with the band noise floor
+func (m *Medium) getNoiseFloor(now time.Time, r receiver, bandName string, channel int32) types.Attenuation {
+	band := m.config.Bands[bandName]
+
+	sum := math.Pow(10, float64(band.NoiseFloor)/10)
+	for _, i := range m.interferers {
+		fading, ok := m.getInterference(now, i, r, bandName, channel)
+		if !ok {
+			continue
+		}
+		received := band.NoiseFloor + band.LinkBudget - fading
+		sum += math.Pow(10, float64(received)/10)
+	}
+
+	return types.Attenuation(10 * math.Log10(sum))
+}
+
+// getPacketNoise calculates the noise and interference power (in dBm) at a receiver during a transmission,
+// including the noise floor, active interferers and any overlapping transmissions on the same channel
+func (m *Medium) getPacketNoise(now time.Time, t *Transmission, receiverIndex int) types.Attenuation {
+	band := m.config.Bands[t.Band]
+	r := m.receivers[t.Band][receiverIndex]
+
+	sum := math.Pow(10, float64(m.getNoiseFloor(now, r, t.Band, t.Channel))/10)
+	for _, o := range m.transmissions {
+		if o == t || o.Band != t.Band || o.Channel != t.Channel || len(o.RSSIs[receiverIndex]) == 0 {
+			continue
+		}
+		if !o.StartTime.Before(t.EndTime) || !t.StartTime.Before(o.EndTime) {
+			continue
+		}
+		received := band.NoiseFloor + band.LinkBudget - o.RSSIs[receiverIndex][len(o.RSSIs[receiverIndex])-1]
+		sum += math.Pow(10, float64(received)/10)
+	}
+
+	return types.Attenuation(10 * math.Log10(sum))
+}
+
+// updateInterference fails transmissions at nodes where an interferer is not below the transmission
+// by at least the interference budget
+func (m *Medium) updateInterference(now time.Time) {
+	if len(m.interferers) == 0 {
+		return
+	}
+
+	for j, t := range m.transmissions {
+		band := m.config.Bands[t.Band]
+
+		for i, r := range m.receivers[t.Band] {
+			if !t.SendOK[i] {
+				continue
+			}
+
+			n := (*m.nodes)[r.node]
+			rssi := t.RSSIs[i][len(t.RSSIs[i])-1]
+
+			for _, in := range m.interferers {
+				fading, ok := m.getInterference(now, in, r, t.Band, t.Channel)
+				if !ok || fading-rssi >= band.InterferenceBudget {
+					continue
+				}
+
+				m.transmissions[j].SendOK[i] = false
+				m.setTransceiverState(n.Address, t.Band, r.radio.ID, types.TransceiverStateReceive)
+				m.statsMutex.Lock()
+				m.stats.IncrementInterfered(n.Address, t.Band)
+				m.statsMutex.Unlock()
+				break
+			}
+		}
+	}
+}
+
 func (m *Medium) getNodeIndex(addr string) (int, error) {
 	for i, n := range *m.nodes {
 		if n.Address == addr {
@@ -1,11 +1,14 @@
 package medium
 
 import (
+	"io/ioutil"
+	"math"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/ryankurte/yawns/lib/config"
-	//"github.com/ryankurte/yawns/lib/medium/layers"
+	"github.com/ryankurte/yawns/lib/medium/layers"
 	"github.com/ryankurte/yawns/lib/messages"
 	"github.com/ryankurte/yawns/lib/types"
 
@@ -37,7 +40,7 @@ func TestMedium(t *testing.T) {
 	now := time.Now()
 	for i := range nodes {
 		for b := range c.Medium.Bands {
-			m.SetTransceiverState(now, i, b, types.TransceiverStateReceive)
+			m.SetTransceiverState(now, i, b, 0, types.TransceiverStateReceive)
 		}
 	}
 
@@ -94,7 +97,7 @@ func TestMedium(t *testing.T) {
 		now := time.Now()
 		m.sendPacket(now, msg)
 
-		assert.EqualValues(t, types.TransceiverStateTransmitting, m.transceivers[nodeIndex][bandName].State, "Sets transceiver state for node")
+		assert.EqualValues(t, types.TransceiverStateTransmitting, m.transceivers[nodeIndex][radioKey{bandName, 0}].State, "Sets transceiver state for node")
 		assert.EqualValues(t, 1, len(m.transmissions), "Stores transmission instance")
 
 		transmission := m.transmissions[0]
@@ -115,7 +118,7 @@ func TestMedium(t *testing.T) {
 
 		assert.EqualValues(t, 0, len(m.transmissions), "Removes transmission instance")
 
-		assert.EqualValues(t, types.TransceiverStateReceive, m.transceivers[nodeIndex][bandName].State, "Resets transceiver state for node")
+		assert.EqualValues(t, types.TransceiverStateReceive, m.transceivers[nodeIndex][radioKey{bandName, 0}].State, "Resets transceiver state for node")
 	})
 
 	t.Run("Handles node movement during packet transmission", func(t *testing.T) {
@@ -219,8 +222,468 @@ func TestMedium(t *testing.T) {
 
 }
 
+// fixedFading is a test layer applying the same fading to all links
+type fixedFading float64
+
+func (f fixedFading) CalculateFading(band config.Band, p1, p2 types.Location) (float64, error) {
+	return float64(f), nil
+}
+
+// distanceFading is a test layer applying fading (in dB per kilometre) proportional to link distance
+type distanceFading float64
+
+func (f distanceFading) CalculateFading(band config.Band, p1, p2 types.Location) (float64, error) {
+	return p1.Distance(p2) / 1000 * float64(f), nil
+}
+
+func TestInterferers(t *testing.T) {
+	bandName := "Sub1GHz"
+	c := config.Medium{
+		Bands: map[string]config.Band{
+			bandName: config.Band{
+				Frequency:          433e6,
+				Baud:               10e3,
+				LinkBudget:         90,
+				InterferenceBudget: 20,
+				NoiseFloor:         -80,
+			},
+		},
+		Interferers: map[string]config.Interferer{
+			"jammer":    config.Interferer{Band: bandName, Power: 0, Disabled: true},
+			"microwave": config.Interferer{Band: bandName, Channels: []int32{2}, Power: -30},
+		},
+	}
+
+	nodes := types.Nodes{
+		types.Node{Address: "0x0001", Location: types.Location{Lat: -36.80, Lng: 174.70}},
+		types.Node{Address: "0x0002", Location: types.Location{Lat: -36.81, Lng: 174.70}},
+	}
+
+	m, err := NewMedium(&c, time.Millisecond, &nodes)
+	assert.Nil(t, err)
+	m.layerManager = layers.NewLayerManager()
+	m.BindLayer("fixed", fixedFading(50))
+
+	now := time.Now()
+	for i := range nodes {
+		m.SetTransceiverState(now, i, bandName, 0, types.TransceiverStateReceive)
+	}
+	for _, i := range m.interferers {
+		if !i.Disabled {
+			i.Start(now)
+		}
+	}
+
+	t.Run("Raises the noise floor on active channels", func(t *testing.T) {
+		rssi, err := m.getRSSI(now, nodes[1].Address, bandName, 0, 1)
+		assert.Nil(t, err)
+		assert.InDelta(t, -80, float64(rssi), 0.01)
+
+		// Microwave received at 10dB above the noise floor
+		rssi, err = m.getRSSI(now, nodes[1].Address, bandName, 0, 2)
+		assert.Nil(t, err)
+		assert.InDelta(t, -69.59, float64(rssi), 0.01)
+	})
+
+	t.Run("Includes in flight transmissions in channel RSSI", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(bandName, 1),
+			Data:        []byte("test data"),
+		}
+		m.sendPacket(now, msg)
+
+		// Transmission received at 40dB above the noise floor
+		rssi, err := m.getRSSI(now, nodes[1].Address, bandName, 0, 1)
+		assert.Nil(t, err)
+		assert.InDelta(t, -40, float64(rssi), 0.01)
+
+		rssi, err = m.getRSSI(now, nodes[1].Address, bandName, 0, 2)
+		assert.Nil(t, err)
+		assert.InDelta(t, -69.59, float64(rssi), 0.01)
+
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[1].Address, msg.Data, msg.RFInfo, m.outCh)
+	})
+
+	t.Run("Weak interferers do not cause collisions", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(bandName, 2),
+			Data:        []byte("test data"),
+		}
+		m.sendPacket(now, msg)
+		end := m.transmissions[0].EndTime.Add(time.Microsecond)
+		m.update(end)
+
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[1].Address, msg.Data, msg.RFInfo, m.outCh)
+	})
+
+	t.Run("Started interferers cause collisions", func(t *testing.T) {
+		err := m.handleMessage(messages.InterfererSet{Name: "jammer", Active: true})
+		assert.Nil(t, err)
+
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(bandName, 1),
+			Data:        []byte("test data"),
+		}
+		m.sendPacket(now, msg)
+		m.update(now)
+		assert.EqualValues(t, []bool{false, false}, m.transmissions[0].SendOK)
+
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		assert.EqualValues(t, 1, m.stats.Nodes[nodes[1].Address].Interfered)
+
+		err = m.handleMessage(messages.InterfererSet{Name: "jammer", Active: false})
+		assert.Nil(t, err)
+		rssi, _ := m.getRSSI(now, nodes[1].Address, bandName, 0, 1)
+		assert.InDelta(t, -80, float64(rssi), 0.01)
+
+		err = m.handleMessage(messages.InterfererSet{Name: "oven", Active: true})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Implements activity patterns", func(t *testing.T) {
+		i, err := NewInterferer("periodic", config.Interferer{Pattern: config.InterfererPeriodic,
+			Period: 100 * time.Millisecond, Duration: 10 * time.Millisecond, Offset: 5 * time.Millisecond})
+		assert.Nil(t, err)
+		i.Start(now)
+
+		_, on := i.GetPower(now)
+		assert.False(t, on)
+		_, on = i.GetPower(now.Add(110 * time.Millisecond))
+		assert.True(t, on)
+		_, on = i.GetPower(now.Add(120 * time.Millisecond))
+		assert.False(t, on)
+
+		i, err = NewInterferer("random", config.Interferer{Pattern: config.InterfererRandom,
+			MeanOn: time.Millisecond, MeanOff: time.Millisecond})
+		assert.Nil(t, err)
+		i.Start(now)
+		count := 0
+		for j := 0; j < 1000; j++ {
+			if _, on := i.GetPower(now.Add(time.Duration(j) * time.Millisecond)); on {
+				count++
+			}
+		}
+		assert.InDelta(t, 500, count, 100)
+
+		_, err = NewInterferer("invalid", config.Interferer{Pattern: config.InterfererPeriodic})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Replays power traces", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "yawns-trace")
+		assert.Nil(t, err)
+		defer os.Remove(f.Name())
+		f.WriteString("# offset, power\n0ms, -10dB\n10ms, 5\n20ms, off\n")
+		f.Close()
+
+		i, err := NewInterferer("trace", config.Interferer{Pattern: config.InterfererTrace, Trace: f.Name(), Loop: true})
+		assert.Nil(t, err)
+		i.Start(now)
+
+		p, on := i.GetPower(now.Add(5 * time.Millisecond))
+		assert.True(t, on)
+		assert.EqualValues(t, -10, p)
+		p, on = i.GetPower(now.Add(15 * time.Millisecond))
+		assert.True(t, on)
+		assert.EqualValues(t, 5, p)
+		_, on = i.GetPower(now.Add(25 * time.Millisecond))
+		assert.True(t, on, "Loops trace")
+	})
+}
+
+func TestRadios(t *testing.T) {
+	subGHz, wifi := "Sub1GHz", "2.4GHz"
+	band := config.Band{Frequency: 433e6, Baud: 10e3, LinkBudget: 90, InterferenceBudget: 20, NoiseFloor: -80}
+	c := config.Medium{
+		Bands: map[string]config.Band{subGHz: band, wifi: band},
+	}
+
+	nodes := types.Nodes{
+		types.Node{Address: "0x0001", Location: types.Location{Lat: -36.80, Lng: 174.70},
+			Radios: []types.Radio{{Band: subGHz}, {Band: wifi, ID: 1}}},
+		types.Node{Address: "0x0002", Location: types.Location{Lat: -36.81, Lng: 174.70},
+			Radios: []types.Radio{{Band: wifi, ID: 1}}},
+		types.Node{Address: "0x0003", Location: types.Location{Lat: -36.82, Lng: 174.70}},
+	}
+
+	m, err := NewMedium(&c, time.Millisecond, &nodes)
+	assert.Nil(t, err)
+	m.layerManager = layers.NewLayerManager()
+	m.BindLayer("fixed", fixedFading(50))
+
+	now := time.Now()
+	for i, n := range nodes {
+		for _, b := range []string{subGHz, wifi} {
+			m.SetTransceiverState(now, i, b, 0, types.TransceiverStateReceive)
+		}
+		for _, r := range n.Radios {
+			m.SetTransceiverState(now, i, r.Band, r.ID, types.TransceiverStateReceive)
+		}
+	}
+
+	t.Run("Creates radios for each node", func(t *testing.T) {
+		assert.Len(t, m.transceivers[0], 2)
+		assert.Len(t, m.transceivers[1], 1)
+		assert.Len(t, m.transceivers[2], 2, "Defaults to one radio per band")
+	})
+
+	t.Run("Includes transceiver stats in snapshots", func(t *testing.T) {
+		stats := m.Stats()
+		assert.Len(t, stats.Nodes[nodes[0].Address].Transceivers, 2)
+		assert.True(t, stats.Nodes[nodes[0].Address].Transceivers[wifi+":1"].ReceiveTime > 0)
+		assert.True(t, stats.Nodes[nodes[2].Address].Transceivers[subGHz].ReceiveTime > 0)
+	})
+
+	t.Run("Delivers packets only to radios on the band", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[2].Address, msg.Data, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0)
+	})
+
+	t.Run("Reports the receiving radio", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRadioRFInfo(wifi, 1, 1),
+			Data:        []byte("test data"),
+		}
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[1].Address, msg.Data, messages.NewRadioRFInfo(wifi, 1, 1), m.outCh)
+		CheckPacketForward(t, nodes[2].Address, msg.Data, messages.NewRadioRFInfo(wifi, 0, 1), m.outCh)
+	})
+
+	t.Run("Reports received packet metadata", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[2].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+		assert.Nil(t, m.sendPacket(now, msg))
+		transmission := m.transmissions[0]
+		m.update(transmission.EndTime.Add(time.Microsecond))
+
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		resp, ok := ChannelGet(t, m.outCh, time.Millisecond).(messages.Packet)
+		assert.True(t, ok)
+		assert.InDelta(t, -40, resp.RSSI, 0.01)
+		assert.InDelta(t, 40, resp.SNR, 0.01)
+		assert.EqualValues(t, 255, resp.LQI)
+		assert.EqualValues(t, transmission.StartTime.Sub(m.startTime), resp.StartTime)
+		assert.EqualValues(t, transmission.PacketTime, resp.EndTime-resp.StartTime)
+	})
+
+	t.Run("Maps SINR to LQI", func(t *testing.T) {
+		assert.EqualValues(t, 0, SINRToLQI(-10))
+		assert.EqualValues(t, 127, SINRToLQI(15))
+		assert.EqualValues(t, 255, SINRToLQI(45))
+	})
+
+	t.Run("Rejects operations on missing radios", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[1].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+		assert.NotNil(t, m.sendPacket(now, msg))
+
+		err := m.handleMessage(messages.StateRequest{BaseMessage: messages.BaseMessage{Address: nodes[1].Address},
+			RFInfo: messages.NewRFInfo(subGHz, 1)})
+		assert.NotNil(t, err)
+
+		err = m.handleMessage(messages.StateRequest{BaseMessage: messages.BaseMessage{Address: "0x0004"},
+			RFInfo: messages.NewRFInfo(subGHz, 1)})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Powers off disconnected nodes", func(t *testing.T) {
+		address := messages.BaseMessage{Address: nodes[2].Address}
+		assert.Nil(t, m.handleMessage(messages.Deregister{BaseMessage: address}))
+		for _, transceiver := range m.transceivers[2] {
+			assert.EqualValues(t, types.TransceiverStateOff, transceiver.State)
+		}
+		assert.EqualValues(t, 1, m.stats.Nodes[nodes[2].Address].Disconnects)
+
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0)
+
+		assert.Nil(t, m.handleMessage(messages.Register{BaseMessage: address}))
+		for _, transceiver := range m.transceivers[2] {
+			assert.EqualValues(t, types.TransceiverStateIdle, transceiver.State)
+		}
+		assert.EqualValues(t, 1, m.stats.Nodes[nodes[2].Address].Reconnects)
+	})
+
+	t.Run("Blocks faulted links", func(t *testing.T) {
+		m.SetTransceiverState(now, 2, subGHz, 0, types.TransceiverStateReceive)
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+
+		fault := messages.LinkFault{From: []string{nodes[2].Address}, To: []string{nodes[0].Address}, Attenuation: math.Inf(1)}
+		assert.Nil(t, m.handleMessage(fault))
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0, "Blocks links in both directions")
+
+		assert.Nil(t, m.handleMessage(messages.LinkFault{}))
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[2].Address, msg.Data, msg.RFInfo, m.outCh)
+	})
+
+	t.Run("Fails radios until restored", func(t *testing.T) {
+		address := messages.BaseMessage{Address: nodes[2].Address}
+		assert.Nil(t, m.handleMessage(messages.RadioFault{BaseMessage: address, Band: subGHz, Failed: true}))
+		m.SetTransceiverState(now, 2, subGHz, 0, types.TransceiverStateReceive)
+		assert.EqualValues(t, types.TransceiverStateOff, m.transceivers[2][radioKey{Band: subGHz}].State)
+		assert.NotEqual(t, types.TransceiverStateOff, m.transceivers[2][radioKey{Band: wifi}].State, "Only fails the selected radio")
+
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("test data"),
+		}
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0)
+
+		assert.Nil(t, m.handleMessage(messages.RadioFault{BaseMessage: address, Band: subGHz, Failed: false}))
+		assert.EqualValues(t, types.TransceiverStateIdle, m.transceivers[2][radioKey{Band: subGHz}].State)
+		radio := uint32(3)
+		assert.NotNil(t, m.handleMessage(messages.RadioFault{BaseMessage: address, Band: subGHz, Radio: &radio}))
+	})
+
+	t.Run("Fails all radios on a band without a radio", func(t *testing.T) {
+		nodes := types.Nodes{
+			types.Node{Address: "0x0001", Radios: []types.Radio{{Band: subGHz}, {Band: subGHz, ID: 1}, {Band: wifi}}},
+		}
+		m, err := NewMedium(&c, time.Millisecond, &nodes)
+		assert.Nil(t, err)
+
+		address := messages.BaseMessage{Address: nodes[0].Address}
+		assert.Nil(t, m.handleMessage(messages.RadioFault{BaseMessage: address, Band: subGHz, Failed: true}))
+		assert.True(t, m.transceivers[0][radioKey{Band: subGHz}].Failed)
+		assert.True(t, m.transceivers[0][radioKey{Band: subGHz, ID: 1}].Failed)
+		assert.False(t, m.transceivers[0][radioKey{Band: wifi}].Failed)
+
+		radio := uint32(1)
+		assert.Nil(t, m.handleMessage(messages.RadioFault{BaseMessage: address, Band: subGHz, Radio: &radio, Failed: false}))
+		assert.True(t, m.transceivers[0][radioKey{Band: subGHz}].Failed)
+		assert.False(t, m.transceivers[0][radioKey{Band: subGHz, ID: 1}].Failed)
+	})
+
+	t.Run("Transmits injected packets without affecting the origin", func(t *testing.T) {
+		msg := messages.Packet{
+			BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+			RFInfo:      messages.NewRFInfo(subGHz, 1),
+			Data:        []byte("injected"),
+		}
+		m.SetTransceiverState(now, 2, subGHz, 0, types.TransceiverStateReceive)
+		sent := m.stats.Nodes[nodes[0].Address].Sent
+
+		assert.Nil(t, m.handleMessage(messages.InjectedPacket{Packet: msg}))
+		assert.NotEqual(t, types.TransceiverStateTransmitting, m.transceivers[0][radioKey{Band: subGHz}].State)
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+
+		CheckPacketForward(t, nodes[2].Address, msg.Data, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0, "Does not complete sending")
+		assert.EqualValues(t, sent, m.stats.Nodes[nodes[0].Address].Sent)
+	})
+
+	t.Run("Rejects radios on unknown bands", func(t *testing.T) {
+		invalid := types.Nodes{types.Node{Address: "0x0001", Radios: []types.Radio{{Band: "5GHz"}}}}
+		_, err := NewMedium(&c, time.Millisecond, &invalid)
+		assert.NotNil(t, err)
+
+		invalid = types.Nodes{types.Node{Address: "0x0001", Radios: []types.Radio{{Band: wifi}, {Band: wifi}}}}
+		_, err = NewMedium(&c, time.Millisecond, &invalid)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestLocations(t *testing.T) {
+	bandName := "Sub1GHz"
+	c := config.Medium{
+		Bands: map[string]config.Band{
+			bandName: config.Band{Frequency: 433e6, Baud: 10e3, LinkBudget: 90, InterferenceBudget: 20, NoiseFloor: -80},
+		},
+	}
+
+	nodes := types.Nodes{
+		types.Node{Address: "0x0001", Location: types.Location{Lat: -36.80, Lng: 174.70}},
+		types.Node{Address: "0x0002", Location: types.Location{Lat: -36.81, Lng: 174.70}},
+	}
+
+	m, err := NewMedium(&c, time.Millisecond, &nodes)
+	assert.Nil(t, err)
+	m.layerManager = layers.NewLayerManager()
+	m.BindLayer("distance", distanceFading(50))
+
+	now := time.Now()
+	for i := range nodes {
+		m.SetTransceiverState(now, i, bandName, 0, types.TransceiverStateReceive)
+	}
+
+	msg := messages.Packet{
+		BaseMessage: messages.BaseMessage{Address: nodes[0].Address},
+		RFInfo:      messages.NewRFInfo(bandName, 1),
+		Data:        []byte("test data"),
+	}
+
+	t.Run("Delivers packets to nodes in range", func(t *testing.T) {
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		CheckPacketForward(t, nodes[1].Address, msg.Data, msg.RFInfo, m.outCh)
+	})
+
+	t.Run("Moves nodes out of range", func(t *testing.T) {
+		location := types.Location{Lat: -36.83, Lng: 174.70}
+		err := m.handleMessage(messages.LocationSet{BaseMessage: messages.BaseMessage{Address: nodes[1].Address}, Location: location})
+		assert.Nil(t, err)
+		assert.EqualValues(t, location, nodes[1].Location)
+
+		assert.Nil(t, m.sendPacket(now, msg))
+		m.update(m.transmissions[0].EndTime.Add(time.Microsecond))
+		CheckSendComplete(t, msg.Address, msg.RFInfo, m.outCh)
+		assert.Len(t, m.outCh, 0)
+
+		err = m.handleMessage(messages.LocationSet{BaseMessage: messages.BaseMessage{Address: "0x0003"}, Location: location})
+		assert.NotNil(t, err, "Rejects unknown nodes")
+	})
+}
+
 func CheckSendComplete(t assert.TestingT, address string, rfInfo messages.RFInfo, ch chan interface{}, msgAndArgs ...interface{}) {
-	sendComplete := messages.NewSendComplete(address, rfInfo.Band, rfInfo.Channel)
+	sendComplete := messages.NewSendComplete(address, rfInfo.Band, rfInfo.Radio, rfInfo.Channel)
 	resp := ChannelGet(t, ch, time.Millisecond, msgAndArgs...)
 	assert.IsType(t, messages.SendComplete{}, resp, msgAndArgs...)
 	assert.EqualValues(t, sendComplete, resp, msgAndArgs...)
@@ -231,7 +694,8 @@ func CheckPacketForward(t assert.TestingT, address string, data []byte, rfInfo m
 	resp := ChannelGet(t, ch, time.Millisecond, msgAndArgs...)
 	assert.IsType(t, messages.Packet{}, resp, msgAndArgs...)
 	if respPacket, ok := resp.(messages.Packet); ok {
-		forwardedPacket.RSSI = respPacket.RSSI
+		forwardedPacket.RSSI, forwardedPacket.SNR, forwardedPacket.LQI = respPacket.RSSI, respPacket.SNR, respPacket.LQI
+		forwardedPacket.StartTime, forwardedPacket.EndTime = respPacket.StartTime, respPacket.EndTime
 	}
 	assert.EqualValues(t, forwardedPacket, resp, msgAndArgs...)
 }
@@ -65,10 +65,12 @@ func (c *continuousDuration) Update(x time.Duration) {
 }
 
 type Stats struct {
-	Tick  continuousDuration
-	Bands map[string]BandStats
-	Nodes map[string]NodeStats
-	Links map[string][]LinkStats
+	Tick continuousDuration
+	// Latency from the start of transmission to packet delivery
+	Latency continuousDuration
+	Bands   map[string]BandStats
+	Nodes   map[string]NodeStats
+	Links   map[string][]LinkStats
 }
 
 func NewStats() Stats {
@@ -80,10 +82,37 @@ func NewStats() Stats {
 	}
 }
 
+// Copy creates a deep copy of the statistics
+func (s *Stats) Copy() Stats {
+	c := NewStats()
+	c.Tick = s.Tick
+	c.Latency = s.Latency
+	for k, v := range s.Bands {
+		c.Bands[k] = v
+	}
+	for k, v := range s.Nodes {
+		transceivers := make(map[string]TransceiverStats, len(v.Transceivers))
+		for t, ts := range v.Transceivers {
+			transceivers[t] = ts
+		}
+		v.Transceivers = transceivers
+		c.Nodes[k] = v
+	}
+	for k, v := range s.Links {
+		c.Links[k] = append([]LinkStats{}, v...)
+	}
+	return c
+}
+
 func (s *Stats) AddTick(t time.Duration) {
 	s.Tick.Update(t)
 }
 
+// AddLatency records the latency of a delivered packet
+func (s *Stats) AddLatency(t time.Duration) {
+	s.Latency.Update(t)
+}
+
 func (s *Stats) IncrementSent(address string, band string) {
 	nodeStats, ok := s.Nodes[address]
 	if !ok {
@@ -122,8 +151,64 @@ func (s *Stats) IncrementReceived(from, to string, band string) {
 	}
 }
 
+// IncrementInterfered records a packet lost at a node due to an external interferer
+func (s *Stats) IncrementInterfered(address string, band string) {
+	nodeStats, ok := s.Nodes[address]
+	if !ok {
+		nodeStats = NewNodeStats()
+	}
+	nodeStats.Interfered++
+	s.Nodes[address] = nodeStats
+
+	bandStats, ok := s.Bands[band]
+	if !ok {
+		bandStats = NewBandStats()
+	}
+	bandStats.InterferedCount++
+	s.Bands[band] = bandStats
+}
+
+// IncrementCollided records a collision between transmissions at a node
+func (s *Stats) IncrementCollided(address string, band string) {
+	nodeStats, ok := s.Nodes[address]
+	if !ok {
+		nodeStats = NewNodeStats()
+	}
+	nodeStats.Collisions++
+	s.Nodes[address] = nodeStats
+
+	bandStats, ok := s.Bands[band]
+	if !ok {
+		bandStats = NewBandStats()
+	}
+	bandStats.CollisionCount++
+	s.Bands[band] = bandStats
+}
+
+// IncrementDisconnects records a node disconnecting from the simulator
+func (s *Stats) IncrementDisconnects(address string) {
+	nodeStats, ok := s.Nodes[address]
+	if !ok {
+		nodeStats = NewNodeStats()
+	}
+	nodeStats.Disconnects++
+	s.Nodes[address] = nodeStats
+}
+
+// IncrementReconnects records a node reconnecting to the simulator after a disconnect
+func (s *Stats) IncrementReconnects(address string) {
+	nodeStats, ok := s.Nodes[address]
+	if !ok {
+		nodeStats = NewNodeStats()
+	}
+	nodeStats.Reconnects++
+	s.Nodes[address] = nodeStats
+}
+
 type BandStats struct {
-	PacketCount uint64
+	PacketCount     uint64
+	InterferedCount uint64
+	CollisionCount  uint64
 }
 
 func NewBandStats() BandStats {
@@ -152,6 +237,10 @@ type TransceiverStats struct {
 type NodeStats struct {
 	Sent         uint64
 	Received     uint64
+	Interfered   uint64
+	Collisions   uint64
+	Disconnects  uint64
+	Reconnects   uint64
 	Transceivers map[string]TransceiverStats
 }
 
@@ -1,15 +1,39 @@
 package medium
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ryankurte/yawns/lib/types"
 )
 
+// radioKey identifies a radio on a node
+type radioKey struct {
+	Band string
+	ID   uint32
+}
+
+// String formats a radio key for stats output, radio 0 is identified by the band name alone
+func (k radioKey) String() string {
+	if k.ID == 0 {
+		return k.Band
+	}
+	return fmt.Sprintf("%s:%d", k.Band, k.ID)
+}
+
+// receiver is a radio on a node that can receive transmissions on a band
+type receiver struct {
+	node  int
+	radio types.Radio
+}
+
 type Transceiver struct {
 	// Current transceiver state
 	State types.TransceiverState
 
+	// Failed transceivers are held off until restored
+	Failed bool
+
 	lastTime time.Time
 
 	Stats TransceiverStats
@@ -23,24 +47,35 @@ func NewTransceiver(startTime time.Time) *Transceiver {
 }
 
 func (t *Transceiver) SetState(now time.Time, state types.TransceiverState) {
-	lastState := t.State
+	t.Stats = t.StatsAt(now)
+
+	if t.Failed {
+		state = types.TransceiverStateOff
+	}
+
+	t.State = state
+	t.lastTime = now
+}
+
+// StatsAt fetches the transceiver stats including the time spent in the current state up to the provided time
+func (t *Transceiver) StatsAt(now time.Time) TransceiverStats {
+	stats := t.Stats
 	stateTime := now.Sub(t.lastTime)
 
-	switch lastState {
+	switch t.State {
 	case types.TransceiverStateOff:
-		t.Stats.OffTime += stateTime
+		stats.OffTime += stateTime
 	case types.TransceiverStateIdle:
-		t.Stats.IdleTime += stateTime
+		stats.IdleTime += stateTime
 	case types.TransceiverStateSleep:
-		t.Stats.SleepTime += stateTime
+		stats.SleepTime += stateTime
 	case types.TransceiverStateReceive:
-		t.Stats.ReceiveTime += stateTime
+		stats.ReceiveTime += stateTime
 	case types.TransceiverStateReceiving:
-		t.Stats.ReceivingTime += stateTime
+		stats.ReceivingTime += stateTime
 	case types.TransceiverStateTransmitting:
-		t.Stats.TransmittingTime += stateTime
+		stats.TransmittingTime += stateTime
 	}
 
-	t.State = state
-	t.lastTime = now
+	return stats
 }
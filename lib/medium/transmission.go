@@ -20,13 +20,18 @@ import (
 type Transmission struct {
 	Origin     *types.Node
 	Band       string
+	Radio      uint32
 	Channel    int32
+	Gain       types.Attenuation
 	Data       []byte
 	StartTime  time.Time
 	PacketTime time.Duration
 	EndTime    time.Time
-	SendOK     []bool
-	RSSIs      [][]types.Attenuation
+	// SendOK and RSSIs are indexed by receiver on the transmission band
+	SendOK []bool
+	RSSIs  [][]types.Attenuation
+	// Injected transmissions are sent on behalf of the origin (ie. by an adversary)
+	Injected bool
 }
 
 // NewTransmission creates a new transmission instance
@@ -38,6 +43,7 @@ func NewTransmission(now time.Time, origin *types.Node, band *config.Band, msg m
 	t := Transmission{
 		Origin:     origin,
 		Band:       msg.Band,
+		Radio:      msg.Radio,
 		Channel:    msg.Channel,
 		Data:       msg.Data,
 		StartTime:  now,
@@ -47,19 +53,44 @@ func NewTransmission(now time.Time, origin *types.Node, band *config.Band, msg m
 	return &t
 }
 
-func (t *Transmission) GetAverageRSSI(nodeIndex int) float64 {
+func (t *Transmission) GetAverageRSSI(receiverIndex int) float64 {
 	sum := types.Attenuation(0)
-	for _, v := range t.RSSIs[nodeIndex] {
+	for _, v := range t.RSSIs[receiverIndex] {
 		sum += v
 	}
-	return float64(sum) / float64(len(t.RSSIs[nodeIndex]))
+	return float64(sum) / float64(len(t.RSSIs[receiverIndex]))
 }
 
-func (t *Transmission) GetRFInfo(nodeIndex int) messages.RFInfo {
-	return messages.RFInfo{
-		Band:    t.Band,
-		Channel: t.Channel,
-		RSSI:    t.GetAverageRSSI(nodeIndex),
+// LQI mapping bounds, SINRs at or below LQIMinSINR map to an LQI of 0 and at or above LQIMaxSINR to 255
+const (
+	LQIMinSINR = 0.0
+	LQIMaxSINR = 30.0
+)
+
+// SINRToLQI maps a signal to interference and noise ratio (in dB) to a link quality indicator
+func SINRToLQI(sinr float64) uint8 {
+	if sinr <= LQIMinSINR {
+		return 0
+	}
+	if sinr >= LQIMaxSINR {
+		return 255
 	}
+	return uint8((sinr - LQIMinSINR) / (LQIMaxSINR - LQIMinSINR) * 255)
 }
 
+// GetRFInfo fetches the RF information for the transmission as received by the provided receiver and radio
+// Noise is the noise and interference power (in dBm) at the receiver, and epoch the start of the simulation
+func (t *Transmission) GetRFInfo(band *config.Band, receiverIndex int, radio uint32, noise types.Attenuation, epoch time.Time) messages.RFInfo {
+	rssi := float64(band.NoiseFloor+band.LinkBudget) - t.GetAverageRSSI(receiverIndex)
+
+	return messages.RFInfo{
+		Band:      t.Band,
+		Radio:     radio,
+		Channel:   t.Channel,
+		RSSI:      rssi,
+		SNR:       rssi - float64(band.NoiseFloor),
+		LQI:       SINRToLQI(rssi - float64(noise)),
+		StartTime: t.StartTime.Sub(epoch),
+		EndTime:   t.EndTime.Sub(epoch),
+	}
+}
@@ -9,6 +9,8 @@
 package messages
 
 import (
+	"time"
+
 	"github.com/ryankurte/yawns/lib/types"
 )
 
@@ -52,22 +54,62 @@ func (message *BaseMessage) GetAddress() string { return message.Address }
 // RFInfo structure encodes RF packet information
 type RFInfo struct {
 	Band    string
+	Radio   uint32
 	Channel int32
-	RSSI    float64
+
+	// Received packet metadata
+	RSSI      float64       // Received signal strength in dBm
+	SNR       float64       // Signal to noise ratio in dB
+	LQI       uint8         // Link quality indicator derived from SINR
+	StartTime time.Duration // Transmission start time relative to the start of the simulation
+	EndTime   time.Duration // Transmission end time relative to the start of the simulation
 }
 
 func NewRFInfo(band string, channel int32) RFInfo {
-	return RFInfo{band, channel, 0.0}
+	return RFInfo{Band: band, Channel: channel}
+}
+
+// NewRadioRFInfo creates RF information for a specific radio on a band
+func NewRadioRFInfo(band string, radio uint32, channel int32) RFInfo {
+	return RFInfo{Band: band, Radio: radio, Channel: channel}
 }
 
 // Register message sent when a device registers with the simulator
 type Register struct {
 	BaseMessage
+	Version  uint32   // Protocol version implemented by the device
+	Features []string // Protocol features required by the device
+}
+
+// BandInfo describes a simulated band for devices
+type BandInfo struct {
+	Name           string
+	Frequency      float64 // Centre frequency in Hz
+	Baud           float64 // Baud rate in bps
+	Channels       uint32  // Number of channels
+	ChannelSpacing float64 // Channel spacing in Hz
+}
+
+// RegisterAck message sent to a device in response to registration
+type RegisterAck struct {
+	BaseMessage
+	Accepted bool
+	Reason   string
+	Bands    []BandInfo
 }
 
+// Deregister reasons
+const (
+	DeregisterRequested = "deregistered"
+	DeregisterTimeout   = "heartbeat timeout"
+	DeregisterClosed    = "connection closed"
+	DeregisterReplaced  = "re-registered"
+)
+
 // Deregister message sent when a device is deregistered from the simulator
 type Deregister struct {
 	BaseMessage
+	Reason string
 }
 
 // Packet encodes an RF packet to be sent or received
@@ -122,15 +164,58 @@ type SendComplete struct {
 	RFInfo
 }
 
-func NewSendComplete(address, bandName string, channel int32) SendComplete {
+func NewSendComplete(address, bandName string, radio uint32, channel int32) SendComplete {
 	return SendComplete{
 		BaseMessage: BaseMessage{
 			Address: address,
 		},
-		RFInfo: NewRFInfo(bandName, channel),
+		RFInfo: NewRadioRFInfo(bandName, radio, channel),
 	}
 }
 
+// InterfererSet starts or stops an external interferer in the medium
+type InterfererSet struct {
+	Name   string
+	Active bool
+}
+
+// LocationSet moves a node in the medium
+type LocationSet struct {
+	BaseMessage
+	Location types.Location
+}
+
+// RadioFault fails (powers off) or restores node radios in the medium
+// All radios on the node are affected if no band is specified, and all radios on the band if no radio is specified
+type RadioFault struct {
+	BaseMessage
+	Band   string
+	Radio  *uint32
+	Failed bool
+}
+
+// LinkFault sets the additional attenuation (in dB) of links between nodes in the medium
+// Links are modified in both directions between each From and To node, infinite attenuation blocks links and
+// zero attenuation clears the fault. All link faults are cleared if no nodes are specified
+type LinkFault struct {
+	From, To    []string
+	Attenuation float64
+}
+
+// ConsoleResult is the result of awaiting output matching a pattern from a node console
+type ConsoleResult struct {
+	BaseMessage
+	Pattern string
+	Start   time.Duration // Simulation time at which output was first awaited
+	Error   string        // Empty if matching output was received
+}
+
+// InjectedPacket is a packet transmitted in the medium on behalf of a node (ie. by an adversary)
+// Injected packets propagate from the node location but do not change the node radio state or complete sending
+type InjectedPacket struct {
+	Packet
+}
+
 type FieldSet struct {
 	BaseMessage
 	Name string
@@ -148,6 +233,27 @@ type FieldResp struct {
 	Data string
 }
 
+// Command is sent to a device to inject a command (ie. simulated button presses or configuration changes)
+type Command struct {
+	BaseMessage
+	Name string
+	Data string
+}
+
+// SensorRequest requests a virtual sensor reading for a device
+type SensorRequest struct {
+	BaseMessage
+	Name string
+}
+
+// SensorResponse is a virtual sensor reading sent to a device
+type SensorResponse struct {
+	BaseMessage
+	Name  string
+	Value float64
+	Error string
+}
+
 type Event struct {
 	BaseMessage
 	Address string
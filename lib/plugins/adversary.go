@@ -0,0 +1,392 @@
+/**
+ * Adversary plugin
+ * Manipulates packets between nodes and the medium (dropping, corrupting, delaying, duplicating, replaying and
+ * injecting packets) for testing security and robustness of network protocols
+ *
+ * Copyright 2017 Ryan Kurte
+ */
+
+package plugins
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// AdversaryAction is an action applied by the adversary to matching packets
+type AdversaryAction string
+
+const (
+	// AdversaryDrop drops matching packets
+	AdversaryDrop AdversaryAction = "drop"
+	// AdversaryCorrupt flips random "bits" (default 1) in matching packets
+	AdversaryCorrupt AdversaryAction = "corrupt"
+	// AdversaryDelay holds matching packets for the "delay" before transmitting them
+	AdversaryDelay AdversaryAction = "delay"
+	// AdversaryDuplicate transmits "copies" (default 1) of matching packets in addition to the original
+	AdversaryDuplicate AdversaryAction = "duplicate"
+	// AdversaryReplay records matching packets and retransmits them "copies" times after the "delay"
+	AdversaryReplay AdversaryAction = "replay"
+	// AdversaryInject transmits crafted packets ("data") from an "address" at a time, with an optional "period"
+	AdversaryInject AdversaryAction = "inject"
+)
+
+// AdversaryRule defines packets matched by the adversary and the action applied to them
+// Empty match fields match all packets
+type AdversaryRule struct {
+	Action AdversaryAction
+
+	// Packet matching
+	Nodes       []string      // Source node addresses
+	Band        string        // Packet band
+	Pattern     string        // Hex byte pattern found anywhere in the packet, with ?? matching any byte
+	Probability float64       // Probability of applying the action to a matching packet (defaults to 1)
+	Start       time.Duration // Start of the active window
+	End         time.Duration // End of the active window (unlimited if zero)
+	Count       int           // Maximum number of packets to match (unlimited if zero)
+
+	// Action options
+	Bits   int           // Bits flipped by corrupt rules
+	Delay  time.Duration // Delay for delay and replay rules
+	Copies int           // Copies transmitted by duplicate and replay rules
+
+	// Injection options
+	At      time.Duration // Injection time
+	Period  time.Duration // Injection period (once if zero)
+	Address string        // Spoofed source address
+	Radio   uint32        // Source radio
+	Channel int32         // Transmission channel
+	Data    string        // Hex packet data
+
+	pattern []int
+	data    []byte
+	matched int
+	next    time.Duration
+}
+
+// AdversaryConfig configures the adversary plugin
+type AdversaryConfig struct {
+	// Location of the adversary, if set only packets sent by nodes within the range are affected
+	Location *types.Location
+	// Range of the adversary in meters
+	Range types.Distance
+	// Seed for random actions
+	Seed int64
+	// Rules are evaluated in order, with the first matching rule applied to each packet
+	Rules []AdversaryRule
+}
+
+// PacketRecorder interface is implemented by plugins that record annotated packets (ie. the PCAPPlugin)
+// Packets annotated while filtering replace the record of the packet sent by the node, so modified packets
+// are only recorded once
+type PacketRecorder interface {
+	Annotate(d time.Duration, band, address string, message []byte, annotation string) error
+}
+
+// pendingPacket is a packet awaiting transmission by the adversary
+type pendingPacket struct {
+	at         time.Duration
+	packet     messages.Packet
+	annotation string
+}
+
+// AdversaryPlugin is an attacker in the medium that manipulates packets sent by nodes
+type AdversaryPlugin struct {
+	config   AdversaryConfig
+	rand     *rand.Rand
+	control  Controller
+	recorder PacketRecorder
+	pending  []pendingPacket
+}
+
+// NewAdversaryPlugin creates an adversary plugin from plugin options
+func NewAdversaryPlugin(options map[string]interface{}) (*AdversaryPlugin, error) {
+	data, err := yaml.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	c := AdversaryConfig{}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("Error parsing adversary configuration (%s)", err)
+	}
+
+	return NewAdversary(c)
+}
+
+// NewAdversary creates an adversary plugin with the provided configuration
+func NewAdversary(c AdversaryConfig) (*AdversaryPlugin, error) {
+	if c.Location != nil && c.Range <= 0 {
+		return nil, fmt.Errorf("Adversary location requires a range")
+	}
+
+	for i := range c.Rules {
+		if err := c.Rules[i].init(); err != nil {
+			return nil, fmt.Errorf("Adversary rule %d error: %s", i, err)
+		}
+	}
+
+	a := AdversaryPlugin{
+		config:  c,
+		rand:    rand.New(rand.NewSource(c.Seed)),
+		pending: make([]pendingPacket, 0),
+	}
+
+	return &a, nil
+}
+
+// init validates a rule and parses patterns and data
+func (r *AdversaryRule) init() error {
+	switch r.Action {
+	case AdversaryDrop, AdversaryDelay, AdversaryDuplicate, AdversaryReplay, AdversaryCorrupt:
+	case AdversaryInject:
+		if r.Address == "" || r.Band == "" {
+			return fmt.Errorf("inject rules require an address and band")
+		}
+	default:
+		return fmt.Errorf("unrecognised action '%s'", r.Action)
+	}
+
+	if r.Probability == 0 {
+		r.Probability = 1
+	}
+	if r.Bits == 0 {
+		r.Bits = 1
+	}
+	if r.Copies == 0 {
+		r.Copies = 1
+	}
+	if (r.Action == AdversaryDelay || r.Action == AdversaryReplay) && r.Delay <= 0 {
+		return fmt.Errorf("%s rules require a delay", r.Action)
+	}
+
+	var err error
+	if r.pattern, err = parsePattern(r.Pattern); err != nil {
+		return err
+	}
+	if r.data, err = parseHex(r.Data); err != nil {
+		return err
+	}
+	if r.Action == AdversaryInject && len(r.data) == 0 {
+		return fmt.Errorf("inject rules require data")
+	}
+
+	r.next = r.At
+
+	return nil
+}
+
+// BindControl binds the simulation controller, used to locate nodes
+func (a *AdversaryPlugin) BindControl(c Controller) {
+	a.control = c
+}
+
+// BindRecorder binds a packet recorder to log adversary actions
+func (a *AdversaryPlugin) BindRecorder(r PacketRecorder) {
+	a.recorder = r
+}
+
+// FilterPacket applies the first matching rule to a packet sent by a node
+func (a *AdversaryPlugin) FilterPacket(d time.Duration, p messages.Packet) (messages.Packet, bool) {
+	if !a.inRange(p.Address) {
+		return p, true
+	}
+
+	for i := range a.config.Rules {
+		r := &a.config.Rules[i]
+		if !r.match(d, p) || a.rand.Float64() >= r.Probability {
+			continue
+		}
+		r.matched++
+
+		switch r.Action {
+		case AdversaryDrop:
+			a.record(d, p, "dropped")
+			return p, false
+
+		case AdversaryCorrupt:
+			corrupted := p
+			corrupted.Data = make([]byte, len(p.Data))
+			copy(corrupted.Data, p.Data)
+			for j := 0; j < r.Bits && len(corrupted.Data) > 0; j++ {
+				bit := a.rand.Intn(len(corrupted.Data) * 8)
+				corrupted.Data[bit/8] ^= 1 << uint(bit%8)
+			}
+			a.record(d, corrupted, fmt.Sprintf("corrupted %d bits", r.Bits))
+			return corrupted, true
+
+		case AdversaryDelay:
+			a.record(d, p, fmt.Sprintf("delayed %s", r.Delay))
+			a.schedule(d+r.Delay, p, "delayed release")
+			return p, false
+
+		case AdversaryDuplicate:
+			for j := 0; j < r.Copies; j++ {
+				a.schedule(d, p, fmt.Sprintf("duplicate %d", j+1))
+			}
+			return p, true
+
+		case AdversaryReplay:
+			a.record(d, p, "recorded")
+			for j := 0; j < r.Copies; j++ {
+				a.schedule(d+r.Delay*time.Duration(j+1), p, fmt.Sprintf("replay %d", j+1))
+			}
+			return p, true
+		}
+	}
+
+	return p, true
+}
+
+// PendingPackets returns delayed, duplicated, replayed and injected packets due for transmission
+func (a *AdversaryPlugin) PendingPackets(d time.Duration) []messages.Packet {
+	// Schedule injections
+	for i := range a.config.Rules {
+		r := &a.config.Rules[i]
+		if r.Action != AdversaryInject || r.next < 0 || r.next > d {
+			continue
+		}
+		if r.End > 0 && r.next > r.End || r.Count > 0 && r.matched >= r.Count {
+			r.next = -1
+			continue
+		}
+
+		data := make([]byte, len(r.data))
+		copy(data, r.data)
+		p := messages.NewPacket(r.Address, data, messages.RFInfo{Band: r.Band, Radio: r.Radio, Channel: r.Channel})
+		a.schedule(r.next, p, "injected")
+
+		r.matched++
+		if r.Period > 0 {
+			r.next += r.Period
+		} else {
+			r.next = -1
+		}
+	}
+
+	packets := make([]messages.Packet, 0)
+	remaining := make([]pendingPacket, 0, len(a.pending))
+	for _, p := range a.pending {
+		if p.at > d {
+			remaining = append(remaining, p)
+			continue
+		}
+		a.record(d, p.packet, p.annotation)
+		packets = append(packets, p.packet)
+	}
+	a.pending = remaining
+
+	return packets
+}
+
+// schedule adds a packet for transmission by the adversary
+func (a *AdversaryPlugin) schedule(at time.Duration, p messages.Packet, annotation string) {
+	a.pending = append(a.pending, pendingPacket{at: at, packet: p, annotation: annotation})
+}
+
+// record logs an adversary action and annotates the packet in the bound recorder
+func (a *AdversaryPlugin) record(d time.Duration, p messages.Packet, annotation string) {
+	log.Printf("[INFO] Adversary: %s packet from %s on %s at %s", annotation, p.Address, p.Band, d)
+	if a.recorder != nil {
+		if err := a.recorder.Annotate(d, p.Band, p.Address, p.Data, "adversary: "+annotation); err != nil {
+			log.Printf("[WARNING] Adversary: error recording packet (%s)", err)
+		}
+	}
+}
+
+// inRange checks whether a node is within range of the adversary
+func (a *AdversaryPlugin) inRange(address string) bool {
+	if a.config.Location == nil {
+		return true
+	}
+	if a.control == nil {
+		return false
+	}
+	l, err := a.control.Location(address)
+	if err != nil {
+		return false
+	}
+	return a.config.Location.Distance(l) <= float64(a.config.Range)
+}
+
+// match checks whether a packet matches a rule
+func (r *AdversaryRule) match(d time.Duration, p messages.Packet) bool {
+	if r.Action == AdversaryInject {
+		return false
+	}
+	if d < r.Start || (r.End > 0 && d > r.End) || (r.Count > 0 && r.matched >= r.Count) {
+		return false
+	}
+	if r.Band != "" && r.Band != p.Band {
+		return false
+	}
+	if len(r.Nodes) > 0 {
+		found := false
+		for _, n := range r.Nodes {
+			if n == p.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return matchPattern(r.pattern, p.Data)
+}
+
+// parsePattern parses a hex byte pattern, with ?? (stored as -1) matching any byte
+func parsePattern(s string) ([]int, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("invalid pattern '%s' (odd length)", s)
+	}
+
+	pattern := make([]int, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		if s[i:i+2] == "??" {
+			pattern = append(pattern, -1)
+			continue
+		}
+		b, err := hex.DecodeString(s[i : i+2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' (%s)", s, err)
+		}
+		pattern = append(pattern, int(b[0]))
+	}
+	return pattern, nil
+}
+
+// parseHex parses hex data, ignoring whitespace
+func parseHex(s string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid data '%s' (%s)", s, err)
+	}
+	return data, nil
+}
+
+// matchPattern checks whether a pattern is found anywhere in the data, empty patterns match all data
+func matchPattern(pattern []int, data []byte) bool {
+	for i := 0; i+len(pattern) <= len(data); i++ {
+		found := true
+		for j, b := range pattern {
+			if b >= 0 && int(data[i+j]) != b {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+type testRecorder struct {
+	annotations []string
+}
+
+func (r *testRecorder) Annotate(d time.Duration, band, address string, message []byte, annotation string) error {
+	r.annotations = append(r.annotations, address+":"+annotation)
+	return nil
+}
+
+func TestAdversary(t *testing.T) {
+	packet := func(address string, data ...byte) messages.Packet {
+		return messages.NewPacket(address, data, messages.RFInfo{Band: "Sub1GHz"})
+	}
+
+	t.Run("Parses plugin options", func(t *testing.T) {
+		a, err := NewAdversaryPlugin(map[string]interface{}{
+			"location": map[interface{}]interface{}{"lat": -36.8, "lng": 174.7},
+			"range":    "100m",
+			"rules": []interface{}{
+				map[interface{}]interface{}{"action": "delay", "nodes": []interface{}{"0x0001"}, "delay": "100ms"},
+			},
+		})
+		assert.Nil(t, err)
+		assert.InDelta(t, -36.8, a.config.Location.Lat, 0.001)
+		assert.EqualValues(t, 100, a.config.Range)
+		assert.EqualValues(t, 100*time.Millisecond, a.config.Rules[0].Delay)
+		assert.EqualValues(t, []string{"0x0001"}, a.config.Rules[0].Nodes)
+	})
+
+	t.Run("Rejects invalid rules", func(t *testing.T) {
+		invalid := []AdversaryRule{
+			{Action: "explode"},
+			{Action: AdversaryDelay},
+			{Action: AdversaryDrop, Pattern: "0"},
+			{Action: AdversaryDrop, Pattern: "zz"},
+			{Action: AdversaryInject, Address: "0x0001", Band: "Sub1GHz"},
+		}
+		for _, r := range invalid {
+			_, err := NewAdversary(AdversaryConfig{Rules: []AdversaryRule{r}})
+			assert.NotNil(t, err, "%+v", r)
+		}
+		_, err := NewAdversary(AdversaryConfig{Location: &types.Location{}})
+		assert.NotNil(t, err, "Requires a range with a location")
+	})
+
+	t.Run("Matches packets by node, band and pattern", func(t *testing.T) {
+		a, err := NewAdversary(AdversaryConfig{Rules: []AdversaryRule{
+			{Action: AdversaryDrop, Nodes: []string{"0x0001"}, Band: "Sub1GHz", Pattern: "41 ?? cd"},
+		}})
+		assert.Nil(t, err)
+
+		_, ok := a.FilterPacket(0, packet("0x0001", 0x00, 0x41, 0x88, 0xcd))
+		assert.False(t, ok)
+		_, ok = a.FilterPacket(0, packet("0x0001", 0x41, 0x88, 0xce))
+		assert.True(t, ok, "Filters by pattern")
+		_, ok = a.FilterPacket(0, packet("0x0002", 0x41, 0x88, 0xcd))
+		assert.True(t, ok, "Filters by node")
+		p := packet("0x0001", 0x41, 0x88, 0xcd)
+		p.Band = "2.4GHz"
+		_, ok = a.FilterPacket(0, p)
+		assert.True(t, ok, "Filters by band")
+	})
+
+	t.Run("Limits matches by window and count", func(t *testing.T) {
+		a, err := NewAdversary(AdversaryConfig{Rules: []AdversaryRule{
+			{Action: AdversaryDrop, Start: time.Second, End: 5 * time.Second, Count: 2},
+		}})
+		assert.Nil(t, err)
+
+		dropped := 0
+		for _, d := range []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 6 * time.Second} {
+			if _, ok := a.FilterPacket(d, packet("0x0001", 0x01)); !ok {
+				dropped++
+			}
+		}
+		assert.EqualValues(t, 2, dropped)
+	})
+
+	t.Run("Corrupts packets with seeded bit flips", func(t *testing.T) {
+		rules := []AdversaryRule{{Action: AdversaryCorrupt, Bits: 3}}
+		a, _ := NewAdversary(AdversaryConfig{Seed: 1, Rules: rules})
+		b, _ := NewAdversary(AdversaryConfig{Seed: 1, Rules: []AdversaryRule{{Action: AdversaryCorrupt, Bits: 3}}})
+
+		original := packet("0x0001", 0x00, 0x00, 0x00, 0x00)
+		p, ok := a.FilterPacket(0, original)
+		assert.True(t, ok)
+		q, _ := b.FilterPacket(0, original)
+		assert.EqualValues(t, p.Data, q.Data)
+		assert.EqualValues(t, []byte{0, 0, 0, 0}, original.Data, "Does not modify the original packet")
+
+		flipped := 0
+		for _, v := range p.Data {
+			for ; v != 0; v &= v - 1 {
+				flipped++
+			}
+		}
+		assert.True(t, flipped > 0 && flipped <= 3)
+	})
+
+	t.Run("Delays, duplicates and replays packets", func(t *testing.T) {
+		a, err := NewAdversary(AdversaryConfig{Rules: []AdversaryRule{
+			{Action: AdversaryDelay, Nodes: []string{"0x0001"}, Delay: time.Second},
+			{Action: AdversaryDuplicate, Nodes: []string{"0x0002"}, Copies: 2},
+			{Action: AdversaryReplay, Nodes: []string{"0x0003"}, Delay: 2 * time.Second},
+		}})
+		assert.Nil(t, err)
+		r := testRecorder{}
+		a.BindRecorder(&r)
+
+		_, ok := a.FilterPacket(0, packet("0x0001", 0x01))
+		assert.False(t, ok, "Holds delayed packets")
+		_, ok = a.FilterPacket(0, packet("0x0002", 0x02))
+		assert.True(t, ok)
+		_, ok = a.FilterPacket(0, packet("0x0003", 0x03))
+		assert.True(t, ok)
+
+		assert.Len(t, a.PendingPackets(0), 2, "Sends duplicates immediately")
+		assert.Len(t, a.PendingPackets(500*time.Millisecond), 0)
+
+		pending := a.PendingPackets(time.Second)
+		assert.Len(t, pending, 1)
+		assert.EqualValues(t, "0x0001", pending[0].Address)
+
+		pending = a.PendingPackets(2 * time.Second)
+		assert.Len(t, pending, 1)
+		assert.EqualValues(t, []byte{0x03}, pending[0].Data)
+
+		assert.EqualValues(t, []string{
+			"0x0001:adversary: delayed 1s",
+			"0x0003:adversary: recorded",
+			"0x0002:adversary: duplicate 1",
+			"0x0002:adversary: duplicate 2",
+			"0x0001:adversary: delayed release",
+			"0x0003:adversary: replay 1",
+		}, r.annotations)
+	})
+
+	t.Run("Injects crafted packets", func(t *testing.T) {
+		a, err := NewAdversary(AdversaryConfig{Rules: []AdversaryRule{
+			{Action: AdversaryInject, Address: "0x0001", Band: "Sub1GHz", Data: "41 88 01", At: time.Second, Period: time.Second, Count: 2},
+		}})
+		assert.Nil(t, err)
+
+		assert.Len(t, a.PendingPackets(500*time.Millisecond), 0)
+		pending := a.PendingPackets(time.Second)
+		assert.Len(t, pending, 1)
+		assert.EqualValues(t, packet("0x0001", 0x41, 0x88, 0x01), pending[0])
+		assert.Len(t, a.PendingPackets(2*time.Second), 1)
+		assert.Len(t, a.PendingPackets(3*time.Second), 0, "Limits injections to count")
+	})
+
+	t.Run("Only affects nodes in range", func(t *testing.T) {
+		origin := types.Location{Lat: -36.8, Lng: 174.7}
+		c := testController{locations: map[string]types.Location{
+			"0x0001": origin,
+			"0x0002": {Lat: origin.Lat + 0.01, Lng: origin.Lng},
+		}}
+		a, err := NewAdversary(AdversaryConfig{Location: &origin, Range: 500, Rules: []AdversaryRule{{Action: AdversaryDrop}}})
+		assert.Nil(t, err)
+		a.BindControl(&c)
+
+		_, ok := a.FilterPacket(0, packet("0x0001", 0x01))
+		assert.False(t, ok)
+		_, ok = a.FilterPacket(0, packet("0x0002", 0x01))
+		assert.True(t, ok, "Ignores nodes out of range (~1.1km)")
+	})
+}
@@ -0,0 +1,260 @@
+package plugins
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/ryankurte/yawns/lib/medium"
+)
+
+// Operator is a state check comparison operator
+type Operator string
+
+const (
+	// OperatorEqual checks the value is equal to "value" (the default)
+	OperatorEqual Operator = "eq"
+	// OperatorNotEqual checks the value is not equal to "value"
+	OperatorNotEqual Operator = "ne"
+	// OperatorLess checks the value is numerically less than "value"
+	OperatorLess Operator = "lt"
+	// OperatorLessEqual checks the value is numerically less than or equal to "value"
+	OperatorLessEqual Operator = "le"
+	// OperatorGreater checks the value is numerically greater than "value"
+	OperatorGreater Operator = "gt"
+	// OperatorGreaterEqual checks the value is numerically greater than or equal to "value"
+	OperatorGreaterEqual Operator = "ge"
+	// OperatorRange checks the value is numerically between "min" and "max" (inclusive)
+	OperatorRange Operator = "range"
+	// OperatorApprox checks the value is numerically within "tolerance" of "value"
+	OperatorApprox Operator = "approx"
+	// OperatorMatch checks the value matches the regular expression "value"
+	OperatorMatch Operator = "match"
+	// OperatorExists checks the field has been set
+	OperatorExists Operator = "exists"
+	// OperatorMissing checks the field has not been set
+	OperatorMissing Operator = "missing"
+	// OperatorExpression checks a starlark expression ("expr") is true
+	OperatorExpression Operator = "expr"
+)
+
+// comparison compares values using an operator
+type comparison struct {
+	op                         Operator
+	expected                   string
+	value, min, max, tolerance float64
+	pattern                    *regexp.Regexp
+}
+
+// newComparison parses a comparison from update data
+func newComparison(data map[string]string) (*comparison, error) {
+	c := comparison{op: Operator(data["op"]), expected: data["value"]}
+	if c.op == "" {
+		c.op = OperatorEqual
+	}
+
+	var err error
+	switch c.op {
+	case OperatorEqual, OperatorNotEqual:
+		if _, ok := data["value"]; !ok {
+			return nil, fmt.Errorf("No value for %s comparison", c.op)
+		}
+
+	case OperatorLess, OperatorLessEqual, OperatorGreater, OperatorGreaterEqual:
+		if c.value, err = parseNumber("value", data); err != nil {
+			return nil, err
+		}
+
+	case OperatorRange:
+		if c.min, err = parseNumber("min", data); err != nil {
+			return nil, err
+		}
+		if c.max, err = parseNumber("max", data); err != nil {
+			return nil, err
+		}
+		c.expected = fmt.Sprintf("[%s, %s]", data["min"], data["max"])
+
+	case OperatorApprox:
+		if c.value, err = parseNumber("value", data); err != nil {
+			return nil, err
+		}
+		if c.tolerance, err = parseNumber("tolerance", data); err != nil {
+			return nil, err
+		}
+		c.expected = fmt.Sprintf("%s +/- %s", data["value"], data["tolerance"])
+
+	case OperatorMatch:
+		if c.pattern, err = regexp.Compile(data["value"]); err != nil {
+			return nil, fmt.Errorf("Invalid match pattern '%s' (%s)", data["value"], err)
+		}
+
+	case OperatorExists, OperatorMissing:
+		c.expected = ""
+
+	default:
+		return nil, fmt.Errorf("Unrecognised comparison operator '%s'", c.op)
+	}
+
+	return &c, nil
+}
+
+// Compare compares an actual value (and whether the value was found) with the expected value
+func (c *comparison) Compare(actual string, found bool) (bool, error) {
+	switch c.op {
+	case OperatorExists:
+		return found, nil
+	case OperatorMissing:
+		return !found, nil
+	case OperatorEqual:
+		return actual == c.expected, nil
+	case OperatorNotEqual:
+		return actual != c.expected, nil
+	case OperatorMatch:
+		return c.pattern.MatchString(actual), nil
+	}
+
+	v, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false, fmt.Errorf("Value '%s' is not a number", actual)
+	}
+
+	switch c.op {
+	case OperatorLess:
+		return v < c.value, nil
+	case OperatorLessEqual:
+		return v <= c.value, nil
+	case OperatorGreater:
+		return v > c.value, nil
+	case OperatorGreaterEqual:
+		return v >= c.value, nil
+	case OperatorRange:
+		return v >= c.min && v <= c.max, nil
+	case OperatorApprox:
+		return math.Abs(v-c.value) <= c.tolerance, nil
+	}
+
+	return false, fmt.Errorf("Unrecognised comparison operator '%s'", c.op)
+}
+
+// parseNumber parses a numeric field from update data
+func parseNumber(name string, data map[string]string) (float64, error) {
+	s, ok := data[name]
+	if !ok {
+		return 0, fmt.Errorf("No %s in update data", name)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s '%s' (%s)", name, s, err)
+	}
+	return v, nil
+}
+
+// parseWindow parses the optional "within" (eventually) or "during" (always) check window from update data
+func parseWindow(data map[string]string) (within, during time.Duration, err error) {
+	if s, ok := data["within"]; ok {
+		if within, err = time.ParseDuration(s); err != nil {
+			return 0, 0, fmt.Errorf("Invalid within window '%s' (%s)", s, err)
+		}
+	}
+	if s, ok := data["during"]; ok {
+		if during, err = time.ParseDuration(s); err != nil {
+			return 0, 0, fmt.Errorf("Invalid during window '%s' (%s)", s, err)
+		}
+	}
+	if within > 0 && during > 0 {
+		return 0, 0, fmt.Errorf("Checks can not be both within and during a window")
+	}
+	return within, during, nil
+}
+
+// expression is a starlark boolean expression evaluated against node fields
+type expression struct {
+	src     string
+	options syntax.FileOptions
+}
+
+// newExpression checks and creates an expression
+func newExpression(src string) (*expression, error) {
+	e := expression{src: src}
+	if _, err := e.options.ParseExpr("expr", src, 0); err != nil {
+		return nil, fmt.Errorf("Invalid expression '%s' (%s)", src, err)
+	}
+	return &e, nil
+}
+
+// Eval evaluates the expression with the provided variables, returning the result and whether it is true
+func (e *expression) Eval(env starlark.StringDict) (string, bool, error) {
+	thread := starlark.Thread{Name: "expr"}
+	v, err := starlark.EvalOptions(&e.options, &thread, "expr", e.src, env)
+	if err != nil {
+		return "", false, fmt.Errorf("Expression '%s' error: %s", e.src, err)
+	}
+	return v.String(), bool(v.Truth()), nil
+}
+
+// statMetric fetches a medium statistic for a node (or link if "to" is set) or for a band if no address is provided
+func statMetric(stats *medium.Stats, address string, data map[string]string) (float64, error) {
+	metric := data["metric"]
+
+	if address == "" {
+		name := data["band"]
+		band, ok := stats.Bands[name]
+		if !ok {
+			return 0, fmt.Errorf("No statistics for band '%s'", name)
+		}
+		switch metric {
+		case "packets":
+			return float64(band.PacketCount), nil
+		case "interfered":
+			return float64(band.InterferedCount), nil
+		case "collisions":
+			return float64(band.CollisionCount), nil
+		}
+		return 0, fmt.Errorf("Unrecognised band metric '%s'", metric)
+	}
+
+	node, ok := stats.Nodes[address]
+	if !ok {
+		return 0, fmt.Errorf("No statistics for address '%s'", address)
+	}
+
+	switch metric {
+	case "sent":
+		return float64(node.Sent), nil
+	case "received":
+		return float64(node.Received), nil
+	case "interfered":
+		return float64(node.Interfered), nil
+	case "collisions":
+		return float64(node.Collisions), nil
+	case "disconnects":
+		return float64(node.Disconnects), nil
+	case "reconnects":
+		return float64(node.Reconnects), nil
+	case "pdr":
+		// Packet delivery ratio from the node to the "to" node over all bands
+		to, ok := data["to"]
+		if !ok {
+			return 0, fmt.Errorf("No destination (to) for pdr metric")
+		}
+		if node.Sent == 0 {
+			return 0, fmt.Errorf("No packets sent by address '%s'", address)
+		}
+		delivered := uint64(0)
+		for _, links := range stats.Links {
+			for _, l := range links {
+				if l.From == address && l.To == to {
+					delivered += l.Sent
+				}
+			}
+		}
+		return float64(delivered) / float64(node.Sent), nil
+	}
+
+	return 0, fmt.Errorf("Unrecognised node metric '%s'", metric)
+}
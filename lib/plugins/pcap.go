@@ -37,6 +37,14 @@ type PCAPPlugin struct {
 	interfaceIDs map[string]int
 	bands        map[string]config.Band
 	startTime    time.Time
+	annotated    *pcapRecord
+}
+
+// pcapRecord identifies a packet sent by a node
+type pcapRecord struct {
+	d       time.Duration
+	band    string
+	address string
 }
 
 // NewPCAPPlugin creates a new PCAP file writer plugin
@@ -98,7 +106,27 @@ func NewPCAPPlugin(bands map[string]config.Band, startTime time.Time, options ma
 }
 
 // Received logs a received packet
+// Packets annotated as they are sent (ie. modified by an adversary) are only logged with the annotation
 func (p *PCAPPlugin) Received(d time.Duration, band string, address string, message []byte) error {
+	annotated := p.annotated
+	p.annotated = nil
+	if annotated != nil && *annotated == (pcapRecord{d, band, address}) {
+		return nil
+	}
+
+	return p.write(d, band, address, message, "")
+}
+
+// Annotate logs a packet with an annotation in the packet comment (ie. an adversary action)
+// Annotations of packets sent by nodes replace the record of the packet passed to Received at the same time
+func (p *PCAPPlugin) Annotate(d time.Duration, band string, address string, message []byte, annotation string) error {
+	p.annotated = &pcapRecord{d, band, address}
+
+	return p.write(d, band, address, message, annotation)
+}
+
+// write logs a packet with an optional annotation
+func (p *PCAPPlugin) write(d time.Duration, band string, address string, message []byte, annotation string) error {
 	interfaceID, ok := p.interfaceIDs[band]
 	if !ok {
 		return fmt.Errorf("Unrecognised band name (%s)", band)
@@ -109,9 +137,14 @@ func (p *PCAPPlugin) Received(d time.Duration, band string, address string, mess
 		return fmt.Errorf("Unable to locate band (%s)", band)
 	}
 
+	comment := fmt.Sprintf("Simulator address: %s", address)
+	if annotation != "" {
+		comment += fmt.Sprintf(" (%s)", annotation)
+	}
+
 	packetOpts := types.EnhancedPacketOptions{
 		//OriginalLength: uint32(len(message) + int(bandInfo.PacketOverhead)),
-		Comment: fmt.Sprintf("Simulator address: %s", address),
+		Comment: comment,
 	}
 
 	return p.fileWriter.WriteEnhancedPacketBlock(uint32(interfaceID), p.startTime.Add(d), message, packetOpts)
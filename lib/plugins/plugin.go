@@ -14,6 +14,9 @@ import (
 	"time"
 
 	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/medium"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
 )
 
 // ConnectHandler interface should be implemented by plugins that need to detect
@@ -54,6 +57,43 @@ type UpdateHandler interface {
 	OnUpdate(d time.Duration, eventType config.UpdateAction, address string, data map[string]string) error
 }
 
+// TickHandler interface should be implemented by plugins to be called on each simulation tick
+type TickHandler interface {
+	OnTick(d time.Duration) error
+}
+
+// PacketFilter interface should be implemented by plugins that manipulate packets sent by nodes before they
+// reach the medium. FilterPacket returns the (possibly modified) packet and whether it should be forwarded, and
+// PendingPackets returns packets due to be transmitted by the plugin (ie. delayed, replayed or injected packets)
+type PacketFilter interface {
+	FilterPacket(d time.Duration, p messages.Packet) (messages.Packet, bool)
+	PendingPackets(d time.Duration) []messages.Packet
+}
+
+// Controller interface is provided to plugins that drive the simulation
+type Controller interface {
+	// Schedule schedules an update for execution at the provided simulation time
+	Schedule(at time.Duration, nodes []string, action config.UpdateAction, data map[string]string) error
+	// Stats fetches a snapshot of the medium statistics
+	Stats() (medium.Stats, error)
+	// Location fetches the current location of a node
+	Location(address string) (types.Location, error)
+	// Finish ends the simulation, with a nil error if the simulation passed
+	Finish(result error)
+}
+
+// ControlBinder interface should be implemented by plugins that drive the simulation
+// The bound controller may only be used from within plugin handlers
+type ControlBinder interface {
+	BindControl(c Controller)
+}
+
+// OutputBinder interface should be implemented by plugins that send messages to nodes
+// The bound send function forwards messages (ie. messages.FieldResp) to the connector
+type OutputBinder interface {
+	BindOutput(send func(message interface{}))
+}
+
 // CloseHandler interface should be implemented by plugins to handle plugin closing at simulation exit
 type CloseHandler interface {
 	Close()
@@ -68,6 +108,8 @@ type PluginManager struct {
 	eventHandlers      []EventHandler
 	messageHandlers    []MessageHandler
 	updateHandlers     []UpdateHandler
+	tickHandlers       []TickHandler
+	packetFilters      []PacketFilter
 	closeHandlers      []CloseHandler
 }
 
@@ -115,6 +157,16 @@ func (pm *PluginManager) BindPlugin(plugin interface{}) error {
 		bound++
 	}
 
+	if tick, ok := plugin.(TickHandler); ok {
+		pm.tickHandlers = append(pm.tickHandlers, tick)
+		bound++
+	}
+
+	if filter, ok := plugin.(PacketFilter); ok {
+		pm.packetFilters = append(pm.packetFilters, filter)
+		bound++
+	}
+
 	if close, ok := plugin.(CloseHandler); ok {
 		pm.closeHandlers = append(pm.closeHandlers, close)
 	}
@@ -132,6 +184,13 @@ func (pm *PluginManager) OnConnected(d time.Duration, address string) {
 	}
 }
 
+// OnDisconnected calls bound plugin DisconnectHandlers
+func (pm *PluginManager) OnDisconnected(d time.Duration, address string) {
+	for _, h := range pm.disconnectHandlers {
+		h.Disconnected(d, address)
+	}
+}
+
 // OnReceived calls bound plugin ReceiveHandlers
 func (pm *PluginManager) OnReceived(d time.Duration, band, address string, data []byte) {
 	for _, h := range pm.receiveHandlers {
@@ -167,6 +226,34 @@ func (pm *PluginManager) OnUpdate(d time.Duration, eventType config.UpdateAction
 	}
 }
 
+// OnTick calls bound plugin TickHandlers
+func (pm *PluginManager) OnTick(d time.Duration) {
+	for _, h := range pm.tickHandlers {
+		h.OnTick(d)
+	}
+}
+
+// FilterPacket passes a packet through bound plugin PacketFilters, returning the packet to forward to the medium
+// and whether it should be forwarded
+func (pm *PluginManager) FilterPacket(d time.Duration, p messages.Packet) (messages.Packet, bool) {
+	for _, h := range pm.packetFilters {
+		var ok bool
+		if p, ok = h.FilterPacket(d, p); !ok {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// PendingPackets collects packets due to be transmitted by bound plugin PacketFilters
+func (pm *PluginManager) PendingPackets(d time.Duration) []messages.Packet {
+	packets := make([]messages.Packet, 0)
+	for _, h := range pm.packetFilters {
+		packets = append(packets, h.PendingPackets(d)...)
+	}
+	return packets
+}
+
 // OnClose calls bound plugin CloseHandlers
 func (pm *PluginManager) OnClose() {
 	for _, h := range pm.closeHandlers {
@@ -0,0 +1,371 @@
+/**
+ * Scripting plugin
+ * Runs Starlark (https://github.com/google/starlark-go) scripts to implement scenario and assertion logic
+ * that cannot be expressed with configuration updates
+ *
+ * Copyright 2017 Ryan Kurte
+ */
+
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/medium"
+	"github.com/ryankurte/yawns/lib/messages"
+)
+
+// Script callback function names
+const (
+	ScriptOnPacket = "on_packet"
+	ScriptOnEvent  = "on_event"
+	ScriptOnField  = "on_field"
+	ScriptOnTick   = "on_tick"
+)
+
+// ScriptPlugin runs a Starlark script with callbacks for simulation events
+// Scripts can schedule updates, read medium statistics and pass or fail the simulation
+type ScriptPlugin struct {
+	fileName  string
+	thread    *starlark.Thread
+	callbacks map[string]*starlark.Function
+	control   Controller
+	now       time.Duration
+	finished  bool
+	result    error
+	mutex     sync.Mutex
+}
+
+// NewScriptPlugin loads a script plugin using the script file specified in the plugin options
+func NewScriptPlugin(options map[string]interface{}) (*ScriptPlugin, error) {
+	fileName, err := GetOptionString(FileName, "", options)
+	if err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		return nil, fmt.Errorf("Script plugin requires a script file")
+	}
+
+	return LoadScriptPlugin(fileName, nil)
+}
+
+// LoadScriptPlugin loads a script plugin from the provided file name or source (if not nil)
+func LoadScriptPlugin(fileName string, src interface{}) (*ScriptPlugin, error) {
+	s := ScriptPlugin{
+		fileName:  fileName,
+		callbacks: make(map[string]*starlark.Function),
+	}
+
+	s.thread = &starlark.Thread{
+		Name:  fileName,
+		Print: func(_ *starlark.Thread, msg string) { log.Printf("Script: %s", msg) },
+	}
+
+	// Globals are not frozen after loading so scripts can keep state between callbacks
+	builtins := s.builtins()
+	options := syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true, Recursion: true}
+	_, program, err := starlark.SourceProgramOptions(&options, fileName, src, builtins.Has)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading script %s (%s)", fileName, err)
+	}
+	globals, err := program.Init(s.thread, builtins)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading script %s (%s)", fileName, err)
+	}
+
+	for _, name := range []string{ScriptOnPacket, ScriptOnEvent, ScriptOnField, ScriptOnTick} {
+		v, ok := globals[name]
+		if !ok {
+			continue
+		}
+		f, ok := v.(*starlark.Function)
+		if !ok {
+			return nil, fmt.Errorf("Script %s: %s must be a function", fileName, name)
+		}
+		s.callbacks[name] = f
+	}
+
+	log.Printf("Loaded script %s", fileName)
+
+	return &s, nil
+}
+
+// BindControl binds the controller used by scripts to drive the simulation
+func (s *ScriptPlugin) BindControl(c Controller) {
+	s.control = c
+}
+
+// Received calls the script packet callback for packets sent by nodes
+func (s *ScriptPlugin) Received(d time.Duration, band, address string, message []byte) error {
+	return s.call(d, ScriptOnPacket, starlark.String(band), starlark.String(address), starlark.Bytes(message))
+}
+
+// OnEvent calls the script event callback for node events
+func (s *ScriptPlugin) OnEvent(d time.Duration, address string, data string) error {
+	return s.call(d, ScriptOnEvent, starlark.String(address), starlark.String(data))
+}
+
+// OnMessage calls the script field callback for fields set by nodes
+func (s *ScriptPlugin) OnMessage(d time.Duration, message interface{}) error {
+	if m, ok := message.(messages.FieldSet); ok {
+		return s.call(d, ScriptOnField, starlark.String(m.GetAddress()), starlark.String(m.Name), starlark.String(m.Data))
+	}
+	return nil
+}
+
+// OnTick calls the script tick callback
+func (s *ScriptPlugin) OnTick(d time.Duration) error {
+	return s.call(d, ScriptOnTick)
+}
+
+// Result fetches the script result, this is nil unless the script has failed
+func (s *ScriptPlugin) Result() (finished bool, result error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.finished, s.result
+}
+
+// call calls a script callback (if defined) with the simulation time in seconds followed by the provided arguments
+// Script errors (including calls to fail) finish the simulation with a failure
+func (s *ScriptPlugin) call(d time.Duration, name string, args ...starlark.Value) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, ok := s.callbacks[name]
+	if !ok || s.finished {
+		return nil
+	}
+
+	s.now = d
+	args = append([]starlark.Value{starlark.Float(d.Seconds())}, args...)
+
+	_, err := starlark.Call(s.thread, f, starlark.Tuple(args), nil)
+	if err != nil && !s.finished {
+		if e, ok := err.(*starlark.EvalError); ok {
+			log.Printf("Script %s backtrace: %s", s.fileName, e.Backtrace())
+		}
+		s.finish(fmt.Errorf("Script %s %s error: %s", s.fileName, name, err))
+	}
+
+	return err
+}
+
+// finish records the script result and ends the simulation
+func (s *ScriptPlugin) finish(result error) {
+	s.finished, s.result = true, result
+	if result != nil {
+		log.Printf("Script %s: FAILED at %s (%s)", s.fileName, s.now, result)
+	} else {
+		log.Printf("Script %s: PASSED at %s", s.fileName, s.now)
+	}
+	if s.control != nil {
+		s.control.Finish(result)
+	}
+}
+
+// builtins creates the functions available to scripts
+func (s *ScriptPlugin) builtins() starlark.StringDict {
+	return starlark.StringDict{
+		"schedule":         starlark.NewBuiltin("schedule", s.schedule),
+		"set_location":     starlark.NewBuiltin("set_location", s.setLocation),
+		"start_interferer": starlark.NewBuiltin("start_interferer", s.interferer(config.UpdateStartInterferer)),
+		"stop_interferer":  starlark.NewBuiltin("stop_interferer", s.interferer(config.UpdateStopInterferer)),
+		"stats":            starlark.NewBuiltin("stats", s.stats),
+		"succeed":          starlark.NewBuiltin("succeed", s.succeed),
+	}
+}
+
+// number is a script argument accepting int or float values
+type number float64
+
+// Unpack implements starlark.Unpacker for number arguments
+func (n *number) Unpack(v starlark.Value) error {
+	f, ok := starlark.AsFloat(v)
+	if !ok {
+		return fmt.Errorf("got %s, want number", v.Type())
+	}
+	*n = number(f)
+	return nil
+}
+
+// scheduleUpdate schedules an update the provided delay (in seconds) after the current simulation time
+func (s *ScriptPlugin) scheduleUpdate(delay number, nodes []string, action config.UpdateAction, data map[string]string) error {
+	if s.control == nil {
+		return fmt.Errorf("updates can only be scheduled from callbacks")
+	}
+	if delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	return s.control.Schedule(s.now+time.Duration(float64(delay)*float64(time.Second)), nodes, action, data)
+}
+
+// schedule(action, nodes=[], data={}, delay=0) schedules an update
+func (s *ScriptPlugin) schedule(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var action string
+	var nodeList *starlark.List
+	var dataDict *starlark.Dict
+	var delay number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "action", &action, "nodes?", &nodeList, "data?", &dataDict, "delay?", &delay); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0)
+	if nodeList != nil {
+		for i := 0; i < nodeList.Len(); i++ {
+			address, ok := starlark.AsString(nodeList.Index(i))
+			if !ok {
+				return nil, fmt.Errorf("%s: nodes must be strings, not %s", b.Name(), nodeList.Index(i).Type())
+			}
+			nodes = append(nodes, address)
+		}
+	}
+
+	data := make(map[string]string)
+	if dataDict != nil {
+		for _, item := range dataDict.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("%s: data keys must be strings, not %s", b.Name(), item[0].Type())
+			}
+			if value, ok := starlark.AsString(item[1]); ok {
+				data[key] = value
+			} else {
+				data[key] = item[1].String()
+			}
+		}
+	}
+
+	if err := s.scheduleUpdate(delay, nodes, config.UpdateAction(action), data); err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+	return starlark.None, nil
+}
+
+// set_location(address, lat, lon, delay=0) schedules a node location update
+func (s *ScriptPlugin) setLocation(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var address string
+	var lat, lon, delay number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "address", &address, "lat", &lat, "lon", &lon, "delay?", &delay); err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"lat": fmt.Sprintf("%f", lat),
+		"lon": fmt.Sprintf("%f", lon),
+	}
+	if err := s.scheduleUpdate(delay, []string{address}, config.UpdateSetLocation, data); err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+	return starlark.None, nil
+}
+
+// interferer creates the start_interferer(name, delay=0) and stop_interferer(name, delay=0) builtins
+func (s *ScriptPlugin) interferer(action config.UpdateAction) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var name string
+		var delay number
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "delay?", &delay); err != nil {
+			return nil, err
+		}
+
+		if err := s.scheduleUpdate(delay, nil, action, map[string]string{"interferers": name}); err != nil {
+			return nil, fmt.Errorf("%s: %s", b.Name(), err)
+		}
+		return starlark.None, nil
+	}
+}
+
+// stats() fetches medium statistics as a dict of nodes, bands and links
+func (s *ScriptPlugin) stats(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	if s.control == nil {
+		return nil, fmt.Errorf("%s: statistics are only available from callbacks", b.Name())
+	}
+
+	stats, err := s.control.Stats()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+
+	return statsToStarlark(&stats), nil
+}
+
+// succeed(reason="") ends the simulation with a pass result
+func (s *ScriptPlugin) succeed(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var reason string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason?", &reason); err != nil {
+		return nil, err
+	}
+	if s.control == nil {
+		return nil, fmt.Errorf("%s: the simulation can only be finished from callbacks", b.Name())
+	}
+
+	if reason != "" {
+		log.Printf("Script %s: %s", s.fileName, reason)
+	}
+	s.finish(nil)
+
+	return starlark.None, nil
+}
+
+// statsToStarlark converts medium statistics to a starlark dict
+func statsToStarlark(stats *medium.Stats) *starlark.Dict {
+	nodes := starlark.NewDict(len(stats.Nodes))
+	for address, n := range stats.Nodes {
+		nodes.SetKey(starlark.String(address), newDict(map[string]starlark.Value{
+			"sent":        starlark.MakeUint64(n.Sent),
+			"received":    starlark.MakeUint64(n.Received),
+			"interfered":  starlark.MakeUint64(n.Interfered),
+			"collisions":  starlark.MakeUint64(n.Collisions),
+			"disconnects": starlark.MakeUint64(n.Disconnects),
+			"reconnects":  starlark.MakeUint64(n.Reconnects),
+		}))
+	}
+
+	bands := starlark.NewDict(len(stats.Bands))
+	for name, b := range stats.Bands {
+		bands.SetKey(starlark.String(name), newDict(map[string]starlark.Value{
+			"packets":    starlark.MakeUint64(b.PacketCount),
+			"interfered": starlark.MakeUint64(b.InterferedCount),
+			"collisions": starlark.MakeUint64(b.CollisionCount),
+		}))
+	}
+
+	links := starlark.NewDict(len(stats.Links))
+	for name, band := range stats.Links {
+		list := make([]starlark.Value, 0, len(band))
+		for _, l := range band {
+			list = append(list, newDict(map[string]starlark.Value{
+				"from": starlark.String(l.From),
+				"to":   starlark.String(l.To),
+				"sent": starlark.MakeUint64(l.Sent),
+			}))
+		}
+		links.SetKey(starlark.String(name), starlark.NewList(list))
+	}
+
+	return newDict(map[string]starlark.Value{
+		"nodes": nodes,
+		"bands": bands,
+		"links": links,
+	})
+}
+
+// newDict creates a starlark dict from a map of values
+func newDict(values map[string]starlark.Value) *starlark.Dict {
+	d := starlark.NewDict(len(values))
+	for k, v := range values {
+		d.SetKey(starlark.String(k), v)
+	}
+	return d
+}
@@ -0,0 +1,171 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/medium"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+type scheduledUpdate struct {
+	At     time.Duration
+	Nodes  []string
+	Action config.UpdateAction
+	Data   map[string]string
+}
+
+type testController struct {
+	updates   []scheduledUpdate
+	stats     medium.Stats
+	locations map[string]types.Location
+	finished  bool
+	result    error
+}
+
+func (c *testController) Schedule(at time.Duration, nodes []string, action config.UpdateAction, data map[string]string) error {
+	if action == "invalid" {
+		return fmt.Errorf("invalid action")
+	}
+	c.updates = append(c.updates, scheduledUpdate{at, nodes, action, data})
+	return nil
+}
+
+func (c *testController) Stats() (medium.Stats, error) {
+	return c.stats, nil
+}
+
+func (c *testController) Location(address string) (types.Location, error) {
+	l, ok := c.locations[address]
+	if !ok {
+		return types.Location{}, fmt.Errorf("node %s not found", address)
+	}
+	return l, nil
+}
+
+func (c *testController) Finish(result error) {
+	c.finished, c.result = true, result
+}
+
+const testScript = `
+packets = {}
+
+def on_packet(time, band, address, data):
+    packets[address] = packets.get(address, 0) + len(data)
+    if len(data) > 4:
+        fail("packet too long from %s" % address)
+
+def on_event(time, address, data):
+    if data == "moved":
+        set_location(address, -36.5, 174.5, delay=1)
+    elif data == "noisy":
+        start_interferer("noise")
+        stop_interferer("noise", delay=2.5)
+    elif data == "invalid":
+        schedule("invalid")
+
+def on_field(time, address, key, value):
+    schedule("command", nodes=[address], data={"command": key, "data": value, "count": 2})
+
+def on_tick(time):
+    s = stats()
+    if s["nodes"]["a"]["received"] >= 3 and packets.get("a", 0) > 0:
+        succeed("node a received %d packets" % s["nodes"]["a"]["received"])
+`
+
+func TestScript(t *testing.T) {
+
+	t.Run("Rejects invalid scripts", func(t *testing.T) {
+		_, err := LoadScriptPlugin("invalid.star", "def on_tick(:")
+		assert.NotNil(t, err)
+
+		_, err = LoadScriptPlugin("invalid.star", "on_tick = 1")
+		assert.NotNil(t, err)
+
+		_, err = NewScriptPlugin(map[string]interface{}{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Rejects builtins outside of callbacks", func(t *testing.T) {
+		_, err := LoadScriptPlugin("toplevel.star", `schedule("command", nodes=["a"])`)
+		assert.NotNil(t, err)
+	})
+
+	s, err := LoadScriptPlugin("test.star", testScript)
+	assert.Nil(t, err)
+
+	c := testController{stats: medium.NewStats()}
+	c.stats.Nodes["a"] = medium.NodeStats{Received: 1}
+	s.BindControl(&c)
+
+	pm := NewPluginManager()
+	assert.Nil(t, pm.BindPlugin(s))
+
+	t.Run("Schedules updates relative to callbacks", func(t *testing.T) {
+		pm.OnEvent(2*time.Second, "a", "moved")
+		pm.OnEvent(3*time.Second, "b", "noisy")
+
+		assert.EqualValues(t, []scheduledUpdate{
+			{3 * time.Second, []string{"a"}, config.UpdateSetLocation, map[string]string{"lat": "-36.500000", "lon": "174.500000"}},
+			{3 * time.Second, nil, config.UpdateStartInterferer, map[string]string{"interferers": "noise"}},
+			{5500 * time.Millisecond, nil, config.UpdateStopInterferer, map[string]string{"interferers": "noise"}},
+		}, c.updates)
+	})
+
+	t.Run("Handles field callbacks", func(t *testing.T) {
+		c.updates = nil
+		pm.OnMessage(4*time.Second, messages.FieldSet{BaseMessage: messages.NewBaseMessage("b"), Name: "reset", Data: "now"})
+
+		assert.EqualValues(t, []scheduledUpdate{
+			{4 * time.Second, []string{"b"}, config.UpdateCommand, map[string]string{"command": "reset", "data": "now", "count": "2"}},
+		}, c.updates)
+	})
+
+	t.Run("Reads medium statistics", func(t *testing.T) {
+		pm.OnReceived(5*time.Second, "b1", "a", []byte{1, 2})
+		pm.OnTick(5 * time.Second)
+		assert.False(t, c.finished)
+
+		c.stats.Nodes["a"] = medium.NodeStats{Received: 3}
+		pm.OnTick(6 * time.Second)
+		assert.True(t, c.finished)
+		assert.Nil(t, c.result)
+
+		finished, result := s.Result()
+		assert.True(t, finished)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Fails on script errors", func(t *testing.T) {
+		s, err := LoadScriptPlugin("test.star", testScript)
+		assert.Nil(t, err)
+		c := testController{stats: medium.NewStats()}
+		s.BindControl(&c)
+
+		err = s.OnEvent(time.Second, "a", "invalid")
+		assert.NotNil(t, err)
+		assert.True(t, c.finished)
+		assert.NotNil(t, c.result)
+
+		// Callbacks are not called once finished
+		err = s.Received(2*time.Second, "b1", "a", []byte{1, 2, 3, 4, 5})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Fails on script fail calls", func(t *testing.T) {
+		s, err := LoadScriptPlugin("test.star", testScript)
+		assert.Nil(t, err)
+		c := testController{stats: medium.NewStats()}
+		s.BindControl(&c)
+
+		err = s.Received(time.Second, "b1", "a", []byte{1, 2, 3, 4, 5})
+		assert.NotNil(t, err)
+		assert.True(t, c.finished)
+		assert.Contains(t, c.result.Error(), "packet too long from a")
+	})
+}
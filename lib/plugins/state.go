@@ -3,9 +3,14 @@ package plugins
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"go.starlark.net/starlark"
+
 	"github.com/ryankurte/yawns/lib/config"
 	"github.com/ryankurte/yawns/lib/helpers"
 	"github.com/ryankurte/yawns/lib/messages"
@@ -20,8 +25,12 @@ type StateManager struct {
 	fields     FieldMap
 	fieldMutex sync.Mutex
 	events     []StateEvent
+	pending    []*stateCheck
 	eventMutex sync.Mutex
+	now        time.Duration
 	outputFile string
+	send       func(message interface{})
+	control    Controller
 }
 
 // StateEvent is a simulation state event record
@@ -30,10 +39,20 @@ type StateEvent struct {
 	Time     time.Duration
 	Address  string
 	Key      string
+	Operator Operator
 	Expected string
 	Actual   string
 	Result   bool
-	Error    error
+	Error    string
+	Within   time.Duration // Window in which the check must pass
+	During   time.Duration // Window over which the check must continue to pass
+	Resolved time.Duration // Time at which the result was determined
+}
+
+// stateCheck is a state check awaiting a result
+type stateCheck struct {
+	event StateEvent
+	eval  func() (actual string, ok bool, err error)
 }
 
 // Summary is a state event plugin
@@ -72,45 +91,114 @@ func (sm *StateManager) OnMessage(d time.Duration, message interface{}) error {
 	switch m := message.(type) {
 	case messages.FieldSet:
 		sm.setField(m.Address, m.Name, m.Data)
+		sm.updateChecks(d)
+
+	case messages.ConsoleResult:
+		sm.eventMutex.Lock()
+		if d > sm.now {
+			sm.now = d
+		}
+		sm.record(d, &StateEvent{
+			Type:     config.UpdateConsoleExpect,
+			Time:     m.Start,
+			Address:  m.Address,
+			Key:      "console",
+			Operator: OperatorMatch,
+			Expected: m.Pattern,
+			Result:   m.Error == "",
+			Error:    m.Error,
+		})
+		sm.eventMutex.Unlock()
+
+	case messages.FieldGet:
+		// Missing fields are returned empty so nodes are not left awaiting a response
+		data, err := sm.getField(m.Address, m.Name)
+		if err != nil {
+			log.Printf("StateManager field request error: %s", err)
+		}
+		if sm.send != nil {
+			sm.send(messages.FieldResp{
+				BaseMessage: m.BaseMessage,
+				Name:        m.Name,
+				Data:        data,
+			})
+		}
 	}
 
 	return nil
 }
 
+// BindOutput binds the function used to send field responses to nodes
+func (sm *StateManager) BindOutput(send func(message interface{})) {
+	sm.send = send
+}
+
+// BindControl binds the controller used to fetch medium statistics for checks
+func (sm *StateManager) BindControl(c Controller) {
+	sm.control = c
+}
+
 // OnUpdate is called to handle simulation updates
-// This allows fields to be set and checked at simulation time
+// This allows fields and statistics to be checked at simulation time, or within or during a window
 func (sm *StateManager) OnUpdate(d time.Duration, eventType config.UpdateAction, address string, data map[string]string) error {
-	se := StateEvent{Type: eventType, Time: d, Address: address, Result: false}
-	var ok bool
+	check := stateCheck{event: StateEvent{Type: eventType, Time: d, Address: address, Key: data["key"]}}
+	se := &check.event
 
+	var err error
 	switch eventType {
 	case config.UpdateCheckState:
-		if se.Key, ok = data["key"]; !ok {
-			se.Error = fmt.Errorf("No key in state update for address '%s'", address)
-			break
-		}
-		if se.Expected, ok = data["value"]; !ok {
-			se.Error = fmt.Errorf("No value in state update for address '%s'", address)
-			break
-		}
-		se.Actual, se.Error = sm.getField(se.Address, se.Key)
-		if se.Error == nil && se.Actual == se.Expected {
-			se.Result = true
-		}
-
-		log.Printf("STATE check for address: '%s' key: '%s' expected: '%s' actual: '%s' ok: '%v'", address, se.Key, se.Expected, se.Actual, se.Result)
+		check.eval, err = sm.fieldCheck(se, data)
+	case config.UpdateCheckTree:
+		check.eval, err = sm.treeCheck(se, data)
+	case config.UpdateCheckStats:
+		check.eval, err = sm.statsCheck(se, data)
+	default:
+		return nil
+	}
+	if err == nil {
+		se.Within, se.During, err = parseWindow(data)
 	}
 
 	sm.eventMutex.Lock()
-	sm.events = append(sm.events, se)
-	sm.eventMutex.Unlock()
+	defer sm.eventMutex.Unlock()
+
+	if d > sm.now {
+		sm.now = d
+	}
+
+	if err != nil {
+		se.Error = err.Error()
+		sm.record(d, se)
+		return nil
+	}
+
+	if !sm.evaluate(d, &check) {
+		sm.pending = append(sm.pending, &check)
+	}
 
 	return nil
 }
 
+// OnTick is called on each simulation tick to update windowed checks
+func (sm *StateManager) OnTick(d time.Duration) error {
+	sm.updateChecks(d)
+	return nil
+}
+
 // Close the state manager plugin
-// This finalises the output log if enabled.
+// This fails incomplete checks and finalises the output log if enabled.
 func (sm *StateManager) Close() {
+	sm.eventMutex.Lock()
+	for _, c := range sm.pending {
+		c.event.Error = "Simulation ended before check completed"
+		if c.event.Actual != "" {
+			c.event.Error += fmt.Sprintf(" (last value: '%s')", c.event.Actual)
+		}
+		sm.record(sm.now, &c.event)
+	}
+	sm.pending = nil
+	sm.eventMutex.Unlock()
+
 	log.Printf("State events: %+v", sm.events)
 
 	if sm.outputFile != "" {
@@ -123,6 +211,222 @@ func (sm *StateManager) Close() {
 
 }
 
+// Events fetches the recorded check results
+func (sm *StateManager) Events() []StateEvent {
+	sm.eventMutex.Lock()
+	defer sm.eventMutex.Unlock()
+
+	return append([]StateEvent{}, sm.events...)
+}
+
+// fieldCheck creates a check comparing a node field or evaluating an expression
+func (sm *StateManager) fieldCheck(se *StateEvent, data map[string]string) (func() (string, bool, error), error) {
+	address, key := se.Address, se.Key
+
+	if src, ok := data["expr"]; ok {
+		expr, err := newExpression(src)
+		if err != nil {
+			return nil, err
+		}
+		se.Operator, se.Expected = OperatorExpression, src
+
+		return func() (string, bool, error) {
+			env := sm.expressionEnv(address, key)
+			result, ok, err := expr.Eval(env)
+			if key != "" {
+				// Report the checked field rather than the expression result
+				result, _ = starlark.AsString(env["value"])
+			}
+			return result, ok, err
+		}, nil
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("No key in state update for address '%s'", address)
+	}
+
+	c, err := newComparison(data)
+	if err != nil {
+		return nil, err
+	}
+	se.Operator, se.Expected = c.op, c.expected
+
+	return func() (string, bool, error) {
+		actual, err := sm.getField(address, key)
+		found := err == nil
+		if !found && c.op != OperatorMissing {
+			return "", false, err
+		}
+		ok, err := c.Compare(actual, found)
+		return actual, ok, err
+	}, nil
+}
+
+// treeCheck creates a check that nodes reach a root node by following a field (ie. parent)
+// All nodes are checked if no address is provided
+func (sm *StateManager) treeCheck(se *StateEvent, data map[string]string) (func() (string, bool, error), error) {
+	key, root := data["key"], data["root"]
+	if key == "" || root == "" {
+		return nil, fmt.Errorf("Tree checks require a key and root")
+	}
+	se.Expected = root
+
+	return func() (string, bool, error) {
+		if se.Address != "" {
+			path, err := sm.treePath(se.Address, key, root)
+			return strings.Join(path, " -> "), err == nil, err
+		}
+
+		addresses := sm.addresses()
+		for _, a := range addresses {
+			if path, err := sm.treePath(a, key, root); err != nil {
+				return strings.Join(path, " -> "), false, err
+			}
+		}
+		return fmt.Sprintf("%d nodes", len(addresses)), true, nil
+	}, nil
+}
+
+// treePath follows a field from a node to the root node, returning the path taken
+func (sm *StateManager) treePath(address, key, root string) ([]string, error) {
+	path := []string{address}
+	for current := address; current != root; {
+		next, err := sm.getField(current, key)
+		if err != nil {
+			return path, err
+		}
+		for _, p := range path {
+			if p == next {
+				return append(path, next), fmt.Errorf("Cycle in %s fields at address '%s'", key, next)
+			}
+		}
+		path = append(path, next)
+		if !sm.hasNode(next) {
+			return path, fmt.Errorf("Address '%s' in %s field is not a node", next, key)
+		}
+		current = next
+	}
+	return path, nil
+}
+
+// statsCheck creates a check comparing a medium statistic
+func (sm *StateManager) statsCheck(se *StateEvent, data map[string]string) (func() (string, bool, error), error) {
+	if data["metric"] == "" {
+		return nil, fmt.Errorf("No metric in stats update")
+	}
+	if se.Address == "" && data["band"] == "" {
+		return nil, fmt.Errorf("Stats updates require nodes or a band")
+	}
+
+	c, err := newComparison(data)
+	if err != nil {
+		return nil, err
+	}
+	se.Key, se.Operator, se.Expected = data["metric"], c.op, c.expected
+
+	return func() (string, bool, error) {
+		if sm.control == nil {
+			return "", false, fmt.Errorf("No medium statistics available")
+		}
+		stats, err := sm.control.Stats()
+		if err != nil {
+			return "", false, err
+		}
+		value, err := statMetric(&stats, se.Address, data)
+		if err != nil {
+			return "", false, err
+		}
+		actual := strconv.FormatFloat(value, 'f', -1, 64)
+		ok, err := c.Compare(actual, true)
+		return actual, ok, err
+	}, nil
+}
+
+// evaluate evaluates a check, recording and returning true if the result has been determined
+// Checks within a window pass at the first success, checks during a window fail at the first failure
+// This must be called with the eventMutex held
+func (sm *StateManager) evaluate(d time.Duration, c *stateCheck) bool {
+	actual, ok, err := c.eval()
+	se := &c.event
+	se.Actual, se.Result, se.Error = actual, ok, ""
+	if err != nil {
+		se.Error = err.Error()
+	}
+
+	switch {
+	case se.Within > 0 && !ok && d < se.Time+se.Within:
+		return false
+	case se.During > 0 && ok && d < se.Time+se.During:
+		return false
+	}
+
+	sm.record(d, se)
+	return true
+}
+
+// updateChecks evaluates pending checks
+func (sm *StateManager) updateChecks(d time.Duration) {
+	sm.eventMutex.Lock()
+	defer sm.eventMutex.Unlock()
+
+	if d > sm.now {
+		sm.now = d
+	}
+
+	pending := sm.pending[:0]
+	for _, c := range sm.pending {
+		if !sm.evaluate(d, c) {
+			pending = append(pending, c)
+		}
+	}
+	sm.pending = pending
+}
+
+// record records a check result
+// This must be called with the eventMutex held
+func (sm *StateManager) record(d time.Duration, se *StateEvent) {
+	se.Resolved = d
+	sm.events = append(sm.events, *se)
+
+	log.Printf("STATE %s for address: '%s' key: '%s' expected: '%s' actual: '%s' ok: '%v' error: '%s'",
+		se.Type, se.Address, se.Key, se.Expected, se.Actual, se.Result, se.Error)
+}
+
+// expressionEnv creates the variables available to expressions
+// These are the node address and fields, the checked field value (None if not set), the fields of all nodes,
+// and the medium statistics (if available)
+func (sm *StateManager) expressionEnv(address, key string) starlark.StringDict {
+	sm.fieldMutex.Lock()
+	nodes := starlark.NewDict(len(sm.fields))
+	for a, fields := range sm.fields {
+		d := starlark.NewDict(len(fields))
+		for k, v := range fields {
+			d.SetKey(starlark.String(k), starlark.String(v))
+		}
+		nodes.SetKey(starlark.String(a), d)
+	}
+	sm.fieldMutex.Unlock()
+
+	env := starlark.StringDict{
+		"address": starlark.String(address),
+		"nodes":   nodes,
+		"fields":  starlark.NewDict(0),
+		"value":   starlark.None,
+	}
+	if fields, found, _ := nodes.Get(starlark.String(address)); found {
+		env["fields"] = fields
+		if value, found, _ := fields.(*starlark.Dict).Get(starlark.String(key)); found {
+			env["value"] = value
+		}
+	}
+	if sm.control != nil {
+		if stats, err := sm.control.Stats(); err == nil {
+			env["stats"] = statsToStarlark(&stats)
+		}
+	}
+	return env
+}
+
 // setField sets the value of a field in the map
 // A mutex is used here to ensure partial updates cannot occur
 func (sm *StateManager) setField(address, key, value string) {
@@ -131,7 +435,7 @@ func (sm *StateManager) setField(address, key, value string) {
 
 	fields, ok := sm.fields[address]
 	if !ok {
-		sm.fields = make(FieldMap)
+		fields = make(Fields)
 	}
 
 	fields[key] = Field(value)
@@ -158,3 +462,25 @@ func (sm *StateManager) getField(address, key string) (string, error) {
 
 	return string(value), nil
 }
+
+// hasNode checks whether an address is a known node
+func (sm *StateManager) hasNode(address string) bool {
+	sm.fieldMutex.Lock()
+	defer sm.fieldMutex.Unlock()
+
+	_, ok := sm.fields[address]
+	return ok
+}
+
+// addresses returns the known node addresses in order
+func (sm *StateManager) addresses() []string {
+	sm.fieldMutex.Lock()
+	defer sm.fieldMutex.Unlock()
+
+	addresses := make([]string, 0, len(sm.fields))
+	for a := range sm.fields {
+		addresses = append(addresses, a)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/medium"
 	"github.com/ryankurte/yawns/lib/messages"
 )
 
@@ -30,6 +31,21 @@ func TestState(t *testing.T) {
 		assert.EqualValues(t, val, val2)
 	})
 
+	t.Run("Responds to field requests", func(t *testing.T) {
+		sent := make([]interface{}, 0)
+		sm.BindOutput(func(m interface{}) { sent = append(sent, m) })
+
+		err := sm.OnMessage(time.Second, messages.FieldGet{BaseMessage: messages.NewBaseMessage(addr), Name: key})
+		assert.Nil(t, err)
+		err = sm.OnMessage(time.Second, messages.FieldGet{BaseMessage: messages.NewBaseMessage(addr), Name: "notkey"})
+		assert.Nil(t, err)
+
+		assert.EqualValues(t, []interface{}{
+			messages.FieldResp{BaseMessage: messages.NewBaseMessage(addr), Name: key, Data: val},
+			messages.FieldResp{BaseMessage: messages.NewBaseMessage(addr), Name: "notkey", Data: ""},
+		}, sent)
+	})
+
 	t.Run("Successful state comparison", func(t *testing.T) {
 		data := map[string]string{
 			"key":   key,
@@ -77,3 +93,198 @@ func TestState(t *testing.T) {
 		assert.EqualValues(t, data["value"], sm.events[2].Expected)
 	})
 }
+
+func TestAssertions(t *testing.T) {
+	addresses := []string{"a", "b", "c", "d"}
+
+	// check runs a check-state update and returns the recorded result
+	check := func(sm *StateManager, address string, data map[string]string) StateEvent {
+		sm.OnUpdate(time.Second, config.UpdateCheckState, address, data)
+		return sm.events[len(sm.events)-1]
+	}
+
+	t.Run("Compares fields", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+		sm.setField("a", "rssi", "-72.5")
+		sm.setField("a", "state", "joined")
+
+		tests := []struct {
+			data   map[string]string
+			result bool
+		}{
+			{map[string]string{"key": "state", "value": "joined"}, true},
+			{map[string]string{"key": "state", "op": "ne", "value": "joined"}, false},
+			{map[string]string{"key": "rssi", "op": "lt", "value": "-70"}, true},
+			{map[string]string{"key": "rssi", "op": "le", "value": "-72.5"}, true},
+			{map[string]string{"key": "rssi", "op": "gt", "value": "-70"}, false},
+			{map[string]string{"key": "rssi", "op": "ge", "value": "-80"}, true},
+			{map[string]string{"key": "rssi", "op": "range", "min": "-80", "max": "-70"}, true},
+			{map[string]string{"key": "rssi", "op": "range", "min": "-70", "max": "-60"}, false},
+			{map[string]string{"key": "rssi", "op": "approx", "value": "-72", "tolerance": "0.5"}, true},
+			{map[string]string{"key": "rssi", "op": "approx", "value": "-72", "tolerance": "0.1"}, false},
+			{map[string]string{"key": "state", "op": "match", "value": "^join"}, true},
+			{map[string]string{"key": "state", "op": "exists"}, true},
+			{map[string]string{"key": "parent", "op": "exists"}, false},
+			{map[string]string{"key": "parent", "op": "missing"}, true},
+			{map[string]string{"key": "state", "op": "gt", "value": "1"}, false},
+		}
+
+		for _, test := range tests {
+			se := check(&sm, "a", test.data)
+			assert.EqualValues(t, test.result, se.Result, "%+v", test.data)
+		}
+	})
+
+	t.Run("Records invalid checks", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+
+		for _, data := range []map[string]string{
+			{"key": "state", "op": "invalid", "value": "1"},
+			{"key": "state", "op": "lt", "value": "x"},
+			{"key": "state", "op": "match", "value": "("},
+			{"key": "state", "value": "1", "within": "1s", "during": "1s"},
+			{"expr": "value =="},
+		} {
+			se := check(&sm, "a", data)
+			assert.False(t, se.Result)
+			assert.NotEmpty(t, se.Error, "%+v", data)
+		}
+	})
+
+	t.Run("Evaluates expressions", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+		sm.setField("a", "temp", "21.5")
+		sm.setField("b", "temp", "24")
+
+		se := check(&sm, "a", map[string]string{"key": "temp", "expr": "float(value) > 20"})
+		assert.True(t, se.Result)
+		assert.EqualValues(t, "21.5", se.Actual)
+
+		se = check(&sm, "", map[string]string{"expr": "all([float(f['temp']) < 25 for f in nodes.values() if 'temp' in f])"})
+		assert.True(t, se.Result)
+
+		se = check(&sm, "a", map[string]string{"expr": "fields['temp'] == nodes['b']['temp']"})
+		assert.False(t, se.Result)
+	})
+
+	t.Run("Checks eventually within a window", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+
+		sm.OnUpdate(time.Second, config.UpdateCheckState, "a", map[string]string{"key": "state", "value": "joined", "within": "5s"})
+		sm.OnUpdate(time.Second, config.UpdateCheckState, "b", map[string]string{"key": "state", "value": "joined", "within": "5s"})
+		assert.Empty(t, sm.events)
+
+		sm.OnMessage(3*time.Second, messages.FieldSet{BaseMessage: messages.NewBaseMessage("a"), Name: "state", Data: "joined"})
+		assert.Len(t, sm.events, 1)
+		assert.True(t, sm.events[0].Result)
+		assert.EqualValues(t, 3*time.Second, sm.events[0].Resolved)
+
+		sm.OnTick(5 * time.Second)
+		assert.Len(t, sm.events, 1)
+		sm.OnTick(6 * time.Second)
+		assert.Len(t, sm.events, 2)
+		assert.False(t, sm.events[1].Result)
+		assert.EqualValues(t, "b", sm.events[1].Address)
+	})
+
+	t.Run("Checks always during a window", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+		sm.setField("a", "rssi", "-70")
+		sm.setField("b", "rssi", "-70")
+
+		data := map[string]string{"key": "rssi", "op": "gt", "value": "-80", "during": "5s"}
+		sm.OnUpdate(time.Second, config.UpdateCheckState, "a", data)
+		sm.OnUpdate(time.Second, config.UpdateCheckState, "b", data)
+		assert.Empty(t, sm.events)
+
+		sm.OnMessage(2*time.Second, messages.FieldSet{BaseMessage: messages.NewBaseMessage("b"), Name: "rssi", Data: "-85"})
+		assert.Len(t, sm.events, 1)
+		assert.False(t, sm.events[0].Result)
+		assert.EqualValues(t, "-85", sm.events[0].Actual)
+
+		sm.OnTick(6 * time.Second)
+		assert.Len(t, sm.events, 2)
+		assert.True(t, sm.events[1].Result)
+		assert.EqualValues(t, "a", sm.events[1].Address)
+	})
+
+	t.Run("Fails incomplete checks on close", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+
+		sm.OnUpdate(time.Second, config.UpdateCheckState, "a", map[string]string{"key": "state", "op": "exists", "within": "5s"})
+		sm.Close()
+		assert.Len(t, sm.events, 1)
+		assert.False(t, sm.events[0].Result)
+		assert.NotEmpty(t, sm.events[0].Error)
+	})
+
+	t.Run("Records console results", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+
+		sm.OnMessage(3*time.Second, messages.ConsoleResult{BaseMessage: messages.NewBaseMessage("a"), Pattern: "ready", Start: time.Second})
+		sm.OnMessage(4*time.Second, messages.ConsoleResult{BaseMessage: messages.NewBaseMessage("b"), Pattern: "ready", Start: time.Second, Error: "timeout"})
+
+		events := sm.Events()
+		assert.Len(t, events, 2)
+		assert.EqualValues(t, StateEvent{Type: config.UpdateConsoleExpect, Time: time.Second, Address: "a", Key: "console",
+			Operator: OperatorMatch, Expected: "ready", Result: true, Resolved: 3 * time.Second}, events[0])
+		assert.False(t, events[1].Result)
+		assert.EqualValues(t, "timeout", events[1].Error)
+	})
+
+	t.Run("Checks trees", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+		sm.setField("b", "parent", "a")
+		sm.setField("c", "parent", "b")
+		sm.setField("d", "parent", "b")
+		data := map[string]string{"key": "parent", "root": "a"}
+
+		sm.OnUpdate(time.Second, config.UpdateCheckTree, "c", data)
+		assert.True(t, sm.events[0].Result)
+		assert.EqualValues(t, "c -> b -> a", sm.events[0].Actual)
+
+		sm.OnUpdate(time.Second, config.UpdateCheckTree, "", data)
+		assert.True(t, sm.events[1].Result, "Checks all nodes without an address")
+
+		sm.setField("b", "parent", "d")
+		sm.OnUpdate(time.Second, config.UpdateCheckTree, "c", data)
+		assert.False(t, sm.events[2].Result, "Detects cycles")
+
+		sm.setField("b", "parent", "e")
+		sm.OnUpdate(time.Second, config.UpdateCheckTree, "", data)
+		assert.False(t, sm.events[3].Result, "Detects unknown nodes")
+	})
+
+	t.Run("Checks medium statistics", func(t *testing.T) {
+		sm := NewStateManager(addresses, map[string]interface{}{})
+		c := testController{stats: medium.NewStats()}
+
+		sm.OnUpdate(time.Second, config.UpdateCheckStats, "a", map[string]string{"metric": "sent", "op": "gt", "value": "1"})
+		assert.False(t, sm.events[0].Result, "Fails without statistics")
+
+		sm.BindControl(&c)
+		c.stats.Nodes["a"] = medium.NodeStats{Sent: 10, Collisions: 2}
+		c.stats.Bands["b1"] = medium.BandStats{PacketCount: 10, CollisionCount: 2}
+		c.stats.Links["b1"] = []medium.LinkStats{{From: "a", To: "b", Sent: 9}}
+
+		tests := []struct {
+			address string
+			data    map[string]string
+			result  bool
+		}{
+			{"a", map[string]string{"metric": "sent", "value": "10"}, true},
+			{"a", map[string]string{"metric": "pdr", "to": "b", "op": "ge", "value": "0.9"}, true},
+			{"a", map[string]string{"metric": "pdr", "to": "c", "op": "ge", "value": "0.9"}, false},
+			{"a", map[string]string{"metric": "collisions", "op": "lt", "value": "2"}, false},
+			{"", map[string]string{"metric": "collisions", "band": "b1", "op": "le", "value": "2"}, true},
+			{"", map[string]string{"metric": "packets", "band": "b2", "op": "le", "value": "2"}, false},
+			{"a", map[string]string{"metric": "invalid", "value": "0"}, false},
+		}
+
+		for _, test := range tests {
+			sm.OnUpdate(time.Second, config.UpdateCheckStats, test.address, test.data)
+			se := sm.events[len(sm.events)-1]
+			assert.EqualValues(t, test.result, se.Result, "%+v", test.data)
+		}
+	})
+}
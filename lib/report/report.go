@@ -0,0 +1,230 @@
+/**
+ * OpenNetworkSim Report Package
+ * Simulation result reports for continuous integration, in JUnit XML and JSON formats
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Case is a single assertion result
+type Case struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Address  string  `json:"address,omitempty"`
+	Key      string  `json:"key,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+	Expected string  `json:"expected,omitempty"`
+	Actual   string  `json:"actual,omitempty"`
+	Passed   bool    `json:"passed"`
+	Error    string  `json:"error,omitempty"`
+	Time     float64 `json:"time"`     // Simulation time at which the assertion started (s)
+	Resolved float64 `json:"resolved"` // Simulation time at which the result was determined (s)
+}
+
+// Report is a simulation result report
+type Report struct {
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+	Duration  float64   `json:"duration"` // Simulation duration (s)
+	Passed    bool      `json:"passed"`
+	Tests     int       `json:"tests"`
+	Failures  int       `json:"failures"`
+	Error     string    `json:"error,omitempty"` // Simulation error (ie. script failures)
+	Cases     []Case    `json:"cases"`
+}
+
+// NewReport creates an empty report for a simulation
+func NewReport(name string, timestamp time.Time, duration time.Duration) *Report {
+	return &Report{
+		Name:      name,
+		Timestamp: timestamp,
+		Duration:  duration.Seconds(),
+		Passed:    true,
+		Cases:     make([]Case, 0),
+	}
+}
+
+// NewCase creates a case, generating a name from the assertion fields
+func NewCase(caseType, address, key, operator, expected string, start time.Duration) Case {
+	name := caseType
+	for _, s := range []string{address, key, operator, expected} {
+		if s != "" {
+			name += " " + s
+		}
+	}
+	name += fmt.Sprintf(" @%s", start)
+
+	return Case{
+		Name:     name,
+		Type:     caseType,
+		Address:  address,
+		Key:      key,
+		Operator: operator,
+		Expected: expected,
+		Time:     start.Seconds(),
+	}
+}
+
+// Add adds a case to the report
+func (r *Report) Add(c Case) {
+	r.Cases = append(r.Cases, c)
+	r.Tests++
+	if !c.Passed {
+		r.Failures++
+		r.Passed = false
+	}
+}
+
+// Fail records a simulation error, failing the report
+func (r *Report) Fail(err error) {
+	r.Error = err.Error()
+	r.Passed = false
+}
+
+// Err returns an error describing the report failures, or nil if the simulation passed
+func (r *Report) Err() error {
+	switch {
+	case r.Passed:
+		return nil
+	case r.Error != "":
+		return fmt.Errorf("Simulation failed: %s (%d of %d assertions failed)", r.Error, r.Failures, r.Tests)
+	default:
+		return fmt.Errorf("Simulation failed: %d of %d assertions failed", r.Failures, r.Tests)
+	}
+}
+
+// WriteFiles writes JUnit XML (.xml) and JSON (.json) reports using the base name of the provided file
+func (r *Report) WriteFiles(fileName string) error {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+	if err := r.WriteJUnit(base + ".xml"); err != nil {
+		return err
+	}
+	return r.WriteJSON(base + ".json")
+}
+
+// WriteJSON writes the report to a JSON file
+func (r *Report) WriteJSON(fileName string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// WriteJUnit writes the report to a JUnit XML file
+func (r *Report) WriteJUnit(fileName string) error {
+	data, err := r.JUnit()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// junitSuites is the JUnit XML document root
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemErr string      `xml:"system-err,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit encodes the report as JUnit XML
+// Cases are classed by node address, with the case duration being the time taken to determine the result
+func (r *Report) JUnit() ([]byte, error) {
+	errors := 0
+	if r.Error != "" {
+		errors = 1
+	}
+
+	suite := junitSuite{
+		Name:      r.Name,
+		Tests:     r.Tests,
+		Failures:  r.Failures,
+		Errors:    errors,
+		Time:      r.Duration,
+		Timestamp: r.Timestamp.Format("2006-01-02T15:04:05"),
+		Cases:     make([]junitCase, 0, len(r.Cases)),
+		SystemErr: r.Error,
+	}
+
+	for _, c := range r.Cases {
+		className := r.Name
+		if c.Address != "" {
+			className += "." + c.Address
+		}
+
+		details := fmt.Sprintf("time: %gs\nresolved: %gs\naddress: %s\nkey: %s\nexpected: %s\nactual: %s\n",
+			c.Time, c.Resolved, c.Address, c.Key, c.Expected, c.Actual)
+
+		jc := junitCase{
+			Name:      c.Name,
+			ClassName: className,
+			Time:      c.Resolved - c.Time,
+			SystemOut: details,
+		}
+		if !c.Passed {
+			message := c.Error
+			if message == "" {
+				message = fmt.Sprintf("expected '%s' actual '%s'", c.Expected, c.Actual)
+			}
+			jc.Failure = &junitFailure{Message: message, Type: c.Type, Text: details}
+		}
+		suite.Cases = append(suite.Cases, jc)
+	}
+
+	doc := junitSuites{
+		Name:     r.Name,
+		Tests:    r.Tests,
+		Failures: r.Failures,
+		Errors:   errors,
+		Time:     r.Duration,
+		Suites:   []junitSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	r := NewReport("test", time.Now(), 10*time.Second)
+
+	t.Run("Passes without cases", func(t *testing.T) {
+		assert.True(t, r.Passed)
+		assert.Nil(t, r.Err())
+	})
+
+	pass := NewCase("check-state", "0x0001", "state", "eq", "joined", time.Second)
+	pass.Actual, pass.Passed, pass.Resolved = "joined", true, 3
+	fail := NewCase("check-stats", "", "collisions", "lt", "10", 2*time.Second)
+	fail.Actual, fail.Resolved = "12", 2
+
+	t.Run("Names cases", func(t *testing.T) {
+		assert.EqualValues(t, "check-state 0x0001 state eq joined @1s", pass.Name)
+		assert.EqualValues(t, "check-stats collisions lt 10 @2s", fail.Name)
+	})
+
+	t.Run("Fails on failed cases", func(t *testing.T) {
+		r.Add(pass)
+		assert.True(t, r.Passed)
+
+		r.Add(fail)
+		assert.False(t, r.Passed)
+		assert.EqualValues(t, 2, r.Tests)
+		assert.EqualValues(t, 1, r.Failures)
+		assert.NotNil(t, r.Err())
+	})
+
+	t.Run("Encodes JUnit reports", func(t *testing.T) {
+		data, err := r.JUnit()
+		assert.Nil(t, err)
+
+		doc := junitSuites{}
+		assert.Nil(t, xml.Unmarshal(data, &doc))
+		assert.EqualValues(t, 2, doc.Tests)
+		assert.EqualValues(t, 1, doc.Failures)
+		assert.Len(t, doc.Suites, 1)
+		assert.Len(t, doc.Suites[0].Cases, 2)
+
+		c := doc.Suites[0].Cases
+		assert.EqualValues(t, "test.0x0001", c[0].ClassName)
+		assert.EqualValues(t, 2, c[0].Time)
+		assert.Nil(t, c[0].Failure)
+		assert.NotNil(t, c[1].Failure)
+		assert.EqualValues(t, "expected '10' actual '12'", c[1].Failure.Message)
+	})
+
+	t.Run("Records simulation errors", func(t *testing.T) {
+		r := NewReport("test", time.Now(), time.Second)
+		r.Fail(fmt.Errorf("script error"))
+		assert.False(t, r.Passed)
+		assert.Contains(t, r.Err().Error(), "script error")
+	})
+
+	t.Run("Writes report files", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "yawns-report")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		assert.Nil(t, r.WriteFiles(filepath.Join(dir, "report.xml")))
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+		assert.Nil(t, err)
+		decoded := Report{}
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+		assert.EqualValues(t, r.Cases, decoded.Cases)
+		assert.False(t, decoded.Passed)
+
+		_, err = os.Stat(filepath.Join(dir, "report.xml"))
+		assert.Nil(t, err)
+	})
+}
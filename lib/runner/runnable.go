@@ -44,6 +44,11 @@ func NewRunnable(executable string, command string, execs []string, args map[str
 	return &runnable
 }
 
+// SetArg sets a command template argument, this must be called prior to Start
+func (runnable *Runnable) SetArg(key, value string) {
+	runnable.args[key] = value
+}
+
 func generateArgs(command string, args map[string]string) (string, error) {
 	// Parse supplied template
 	tmpl, err := template.New("runner").Parse(command)
@@ -104,6 +109,24 @@ func (runnable *Runnable) Start() error {
 	return nil
 }
 
+// Kill kills the running process and waits for it to exit
+func (runnable *Runnable) Kill() error {
+	if runnable.Cmd == nil || runnable.Process == nil {
+		return fmt.Errorf("Runnable.Kill error: process not started")
+	}
+	if runnable.ProcessState != nil {
+		return nil
+	}
+
+	// Resume paused processes so they can exit
+	runnable.Resume()
+
+	runnable.Process.Kill()
+	runnable.Wait()
+
+	return nil
+}
+
 // Write a line to the running process
 func (runnable *Runnable) Write(line string) {
 	runnable.Cmd.InputChan <- line
@@ -59,6 +59,15 @@ func (runner *Runner) NewRunnable(address, executable, command string, execs []s
 	runner.clients[address] = runnable
 }
 
+// SetArg sets a command template argument for the runnable with the provided address
+func (runner *Runner) SetArg(address, key, value string) {
+	r, ok := runner.clients[address]
+	if !ok {
+		return
+	}
+	r.SetArg(key, value)
+}
+
 // Start launches all child clients
 func (runner *Runner) Start() error {
 
@@ -98,6 +107,64 @@ func (runner *Runner) Write(address, line string) {
 	r.Write(line)
 }
 
+// getRunnable fetches a runnable by address
+func (runner *Runner) getRunnable(address string) (*Runnable, error) {
+	r, ok := runner.clients[address]
+	if !ok {
+		return nil, fmt.Errorf("No client found for address %s", address)
+	}
+	return r, nil
+}
+
+// Kill kills the client with the provided address
+func (runner *Runner) Kill(address string) error {
+	r, err := runner.getRunnable(address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Runner: killing client %s", address)
+	return r.Kill()
+}
+
+// Restart restarts (or starts a killed) client with the provided address
+func (runner *Runner) Restart(address string) error {
+	r, err := runner.getRunnable(address)
+	if err != nil {
+		return err
+	}
+	if r.Cmd != nil && r.Process != nil {
+		r.Kill()
+	}
+
+	log.Printf("Runner: restarting client %s", address)
+	if err := r.Start(); err != nil {
+		return err
+	}
+	go collect(address, r.GetReadCh(), runner.OutputChan)
+
+	return nil
+}
+
+// Pause stops (freezes) the client with the provided address
+func (runner *Runner) Pause(address string) error {
+	r, err := runner.getRunnable(address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Runner: pausing client %s", address)
+	return r.Pause()
+}
+
+// Resume continues the paused client with the provided address
+func (runner *Runner) Resume(address string) error {
+	r, err := runner.getRunnable(address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Runner: resuming client %s", address)
+	return r.Resume()
+}
+
 // Stop exits all child clients
 func (runner *Runner) Stop() error {
 	wg := sync.WaitGroup{}
@@ -105,6 +172,14 @@ func (runner *Runner) Stop() error {
 	for name, runner := range runner.clients {
 		wg.Add(1)
 		go func(name string, runner *Runnable) {
+			// Killed clients have already exited
+			if runner.Cmd == nil || runner.ProcessState != nil {
+				wg.Done()
+				return
+			}
+
+			// Resume paused clients so they can handle the interrupt
+			runner.Resume()
 			runner.Interrupt()
 
 			killTimer := time.AfterFunc(10*time.Second, func() {
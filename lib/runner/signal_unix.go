@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Pause stops (freezes) the running process
+func (runnable *Runnable) Pause() error {
+	if runnable.Cmd == nil || runnable.Process == nil {
+		return fmt.Errorf("Runnable.Pause error: process not started")
+	}
+	return runnable.Process.Signal(syscall.SIGSTOP)
+}
+
+// Resume continues a paused process
+func (runnable *Runnable) Resume() error {
+	if runnable.Cmd == nil || runnable.Process == nil {
+		return fmt.Errorf("Runnable.Resume error: process not started")
+	}
+	return runnable.Process.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package runner
+
+import (
+	"fmt"
+)
+
+// Pause stops (freezes) the running process, this is not supported on windows
+func (runnable *Runnable) Pause() error {
+	return fmt.Errorf("Runnable.Pause error: not supported on this platform")
+}
+
+// Resume continues a paused process, this is not supported on windows
+func (runnable *Runnable) Resume() error {
+	return fmt.Errorf("Runnable.Resume error: not supported on this platform")
+}
@@ -0,0 +1,191 @@
+package sensors
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// SeriesPoint is a value in a sensor time series
+type SeriesPoint struct {
+	Offset time.Duration
+	Value  float64
+}
+
+// Sensor is a virtual sensor
+type Sensor struct {
+	Name string
+	config.Sensor
+
+	rand   *rand.Rand
+	series map[string][]SeriesPoint
+}
+
+// NewSensor creates a virtual sensor instance
+func NewSensor(name string, c config.Sensor) (*Sensor, error) {
+	s := Sensor{
+		Name:   name,
+		Sensor: c,
+		rand:   rand.New(rand.NewSource(c.Noise.Seed)),
+	}
+
+	switch c.Type {
+	case "":
+		s.Type = config.SensorConstant
+	case config.SensorConstant, config.SensorGradient:
+	case config.SensorHotspot:
+		if c.Radius <= 0 {
+			return nil, fmt.Errorf("sensor %s: hotspot requires a radius", name)
+		}
+	case config.SensorTrace:
+		if len(c.Traces) == 0 {
+			return nil, fmt.Errorf("sensor %s: trace sensor requires traces", name)
+		}
+		s.series = make(map[string][]SeriesPoint)
+		for address, file := range c.Traces {
+			series, err := LoadSeries(file)
+			if err != nil {
+				return nil, fmt.Errorf("sensor %s: %s", name, err)
+			}
+			s.series[address] = series
+		}
+	default:
+		return nil, fmt.Errorf("sensor %s: unrecognised type '%s'", name, c.Type)
+	}
+
+	switch c.Noise.Model {
+	case "", config.NoiseGaussian, config.NoiseUniform:
+	default:
+		return nil, fmt.Errorf("sensor %s: unrecognised noise model '%s'", name, c.Noise.Model)
+	}
+
+	return &s, nil
+}
+
+// LoadSeries loads a sensor time series file
+// Series are CSV files with a time offset (ie. 10s) and a value on each line
+func LoadSeries(file string) ([]SeriesPoint, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	series := make([]SeriesPoint, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		offset, err := time.ParseDuration(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid series offset '%s' (%s)", record[0], err)
+		}
+		if len(series) > 0 && offset < series[len(series)-1].Offset {
+			return nil, fmt.Errorf("series offsets must be increasing (%s)", record[0])
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid series value '%s' (%s)", record[1], err)
+		}
+
+		series = append(series, SeriesPoint{Offset: offset, Value: value})
+	}
+
+	if len(series) == 0 {
+		return nil, fmt.Errorf("series file %s is empty", file)
+	}
+
+	return series, nil
+}
+
+// Truth calculates the sensor value for a node at the provided simulation time and location, prior to noise
+func (s *Sensor) Truth(d time.Duration, address string, location types.Location) (float64, error) {
+	base := s.Value + s.Rate*d.Seconds()
+
+	switch s.Type {
+	case config.SensorGradient:
+		north, east := s.Origin.Offset(location)
+		bearing := s.Bearing * math.Pi / 180
+		distance := north*math.Cos(bearing) + east*math.Sin(bearing)
+		return base + s.Gradient*distance, nil
+
+	case config.SensorHotspot:
+		// Move the hotspot from the origin along the bearing
+		bearing := s.Bearing * math.Pi / 180
+		travelled := s.Speed * d.Seconds()
+		north, east := s.Origin.Offset(location)
+		north -= travelled * math.Cos(bearing)
+		east -= travelled * math.Sin(bearing)
+
+		radius := float64(s.Radius)
+		return base + s.Peak*math.Exp(-(north*north+east*east)/(2*radius*radius)), nil
+
+	case config.SensorTrace:
+		series, ok := s.series[address]
+		if !ok {
+			return 0, fmt.Errorf("sensor %s: no trace for node %s", s.Name, address)
+		}
+		return interpolate(series, d), nil
+
+	default:
+		return base, nil
+	}
+}
+
+// applyNoise applies the sensor noise model and resolution to a value
+func (s *Sensor) applyNoise(value float64) float64 {
+	switch s.Noise.Model {
+	case config.NoiseGaussian:
+		value += s.rand.NormFloat64() * s.Noise.Amplitude
+	case config.NoiseUniform:
+		value += (s.rand.Float64()*2 - 1) * s.Noise.Amplitude
+	}
+
+	if s.Noise.Resolution > 0 {
+		value = math.Round(value/s.Noise.Resolution) * s.Noise.Resolution
+	}
+
+	return value
+}
+
+// interpolate linearly interpolates a series at the provided offset
+// Values are held at the first and last points outside of the series
+func interpolate(series []SeriesPoint, d time.Duration) float64 {
+	if d <= series[0].Offset {
+		return series[0].Value
+	}
+
+	for i := 1; i < len(series); i++ {
+		prev, next := series[i-1], series[i]
+		if d > next.Offset {
+			continue
+		}
+		span := next.Offset - prev.Offset
+		if span == 0 {
+			return next.Value
+		}
+		ratio := float64(d-prev.Offset) / float64(span)
+		return prev.Value + (next.Value-prev.Value)*ratio
+	}
+
+	return series[len(series)-1].Value
+}
@@ -0,0 +1,150 @@
+/**
+ * OpenNetworkSim Sensors Package
+ * Implements virtual sensors that provide environmental readings to nodes, varying over space and time
+ * Readings are logged with the underlying ground truth to allow results to be scored
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package sensors
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// Reading is a logged sensor reading
+type Reading struct {
+	Time     time.Duration
+	Address  string
+	Sensor   string
+	Location types.Location
+	Truth    float64 // Sensor value prior to the application of noise
+	Value    float64 // Value reported to the node
+}
+
+// Sensors is a set of virtual sensors
+type Sensors struct {
+	sensors map[string]*Sensor
+	logFile string
+
+	mu       sync.Mutex
+	readings []Reading
+}
+
+// NewSensors creates virtual sensors from the provided configuration
+func NewSensors(c *config.Sensors) (*Sensors, error) {
+	s := Sensors{
+		sensors:  make(map[string]*Sensor),
+		logFile:  c.LogFile,
+		readings: make([]Reading, 0),
+	}
+
+	for name, sc := range c.Fields {
+		sensor, err := NewSensor(name, sc)
+		if err != nil {
+			return nil, err
+		}
+		s.sensors[name] = sensor
+	}
+
+	return &s, nil
+}
+
+// Names fetches the names of the configured sensors
+func (s *Sensors) Names() []string {
+	names := make([]string, 0, len(s.sensors))
+	for name := range s.sensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Read reads a sensor for a node at the provided simulation time and location
+// Readings are logged for later evaluation
+func (s *Sensors) Read(d time.Duration, name, address string, location types.Location) (float64, error) {
+	sensor, ok := s.sensors[name]
+	if !ok {
+		return 0, fmt.Errorf("no sensor found with name '%s'", name)
+	}
+
+	truth, err := sensor.Truth(d, address, location)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value := sensor.applyNoise(truth)
+
+	s.readings = append(s.readings, Reading{
+		Time:     d,
+		Address:  address,
+		Sensor:   name,
+		Location: location,
+		Truth:    truth,
+		Value:    value,
+	})
+
+	return value, nil
+}
+
+// Readings fetches the logged sensor readings
+func (s *Sensors) Readings() []Reading {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Reading{}, s.readings...)
+}
+
+// Close closes the sensors, writing the reading log if enabled
+func (s *Sensors) Close() {
+	if s.logFile == "" {
+		return
+	}
+
+	err := s.WriteLog(s.logFile)
+	if err != nil {
+		log.Printf("Sensors.Close error: %s", err)
+	}
+}
+
+// WriteLog writes the logged sensor readings to a CSV file
+func (s *Sensors) WriteLog(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"time", "address", "sensor", "lat", "lng", "alt", "truth", "value"})
+
+	for _, r := range s.Readings() {
+		w.Write([]string{
+			r.Time.String(),
+			r.Address,
+			r.Sensor,
+			strconv.FormatFloat(r.Location.Lat, 'f', -1, 64),
+			strconv.FormatFloat(r.Location.Lng, 'f', -1, 64),
+			strconv.FormatFloat(r.Location.Alt, 'f', -1, 64),
+			strconv.FormatFloat(r.Truth, 'f', -1, 64),
+			strconv.FormatFloat(r.Value, 'f', -1, 64),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,148 @@
+package sensors
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensors(t *testing.T) {
+	origin := types.Location{Lat: -36.8485, Lng: 174.7633}
+	// Approximately 100m north of the origin
+	north := origin.Move(100, 0)
+
+	t.Run("Rejects invalid sensors", func(t *testing.T) {
+		_, err := NewSensor("invalid", config.Sensor{Type: "invalid"})
+		assert.NotNil(t, err)
+		_, err = NewSensor("hotspot", config.Sensor{Type: config.SensorHotspot})
+		assert.NotNil(t, err)
+		_, err = NewSensor("noise", config.Sensor{Noise: config.SensorNoise{Model: "invalid"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Changes constant values over time", func(t *testing.T) {
+		s, err := NewSensor("constant", config.Sensor{Value: 10, Rate: 0.5})
+		assert.Nil(t, err)
+
+		v, err := s.Truth(10*time.Second, "0x0001", origin)
+		assert.Nil(t, err)
+		assert.InDelta(t, 15, v, 1e-9)
+	})
+
+	t.Run("Interpolates gradients by location", func(t *testing.T) {
+		s, err := NewSensor("gradient", config.Sensor{Type: config.SensorGradient, Value: 10, Gradient: 0.1, Origin: origin})
+		assert.Nil(t, err)
+
+		v, err := s.Truth(0, "0x0001", north)
+		assert.Nil(t, err)
+		assert.InDelta(t, 20, v, 0.01)
+
+		// Locations perpendicular to the gradient bearing are unchanged
+		s.Bearing = 90
+		v, err = s.Truth(0, "0x0001", north)
+		assert.Nil(t, err)
+		assert.InDelta(t, 10, v, 0.01)
+	})
+
+	t.Run("Moves hotspots over time", func(t *testing.T) {
+		s, err := NewSensor("hotspot", config.Sensor{Type: config.SensorHotspot, Peak: 100, Radius: 10, Speed: 10, Origin: origin})
+		assert.Nil(t, err)
+
+		v, err := s.Truth(0, "0x0001", origin)
+		assert.Nil(t, err)
+		assert.InDelta(t, 100, v, 0.01)
+
+		v, err = s.Truth(0, "0x0001", north)
+		assert.Nil(t, err)
+		assert.InDelta(t, 0, v, 0.01)
+
+		v, err = s.Truth(10*time.Second, "0x0001", north)
+		assert.Nil(t, err)
+		assert.InDelta(t, 100, v, 0.01)
+	})
+
+	t.Run("Replays and interpolates traces", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "yawns-sensor")
+		assert.Nil(t, err)
+		defer os.Remove(f.Name())
+		f.WriteString("# offset, value\n0s, 10\n10s, 20\n20s, 15\n")
+		f.Close()
+
+		s, err := NewSensor("trace", config.Sensor{Type: config.SensorTrace, Traces: map[string]string{"0x0001": f.Name()}})
+		assert.Nil(t, err)
+
+		for d, expected := range map[time.Duration]float64{0: 10, 5 * time.Second: 15, 15 * time.Second: 17.5, time.Minute: 15} {
+			v, err := s.Truth(d, "0x0001", origin)
+			assert.Nil(t, err)
+			assert.InDelta(t, expected, v, 1e-9)
+		}
+
+		_, err = s.Truth(0, "0x0002", origin)
+		assert.NotNil(t, err)
+
+		_, err = NewSensor("trace", config.Sensor{Type: config.SensorTrace, Traces: map[string]string{"0x0001": "missing.csv"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Applies seeded noise and resolution", func(t *testing.T) {
+		c := config.Sensor{Value: 10, Noise: config.SensorNoise{Model: config.NoiseUniform, Amplitude: 1, Seed: 4}}
+		a, err := NewSensor("a", c)
+		assert.Nil(t, err)
+		b, err := NewSensor("b", c)
+		assert.Nil(t, err)
+
+		for i := 0; i < 100; i++ {
+			v := a.applyNoise(10)
+			assert.EqualValues(t, v, b.applyNoise(10), "Seeded sensors produce repeatable noise")
+			assert.InDelta(t, 10, v, 1)
+		}
+
+		a.Noise.Resolution = 0.5
+		v := a.applyNoise(10)
+		assert.InDelta(t, 0, math.Mod(v, 0.5), 1e-9)
+	})
+
+	t.Run("Logs readings", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "yawns-sensor-log")
+		assert.Nil(t, err)
+		f.Close()
+		defer os.Remove(f.Name())
+
+		s, err := NewSensors(&config.Sensors{
+			Fields: map[string]config.Sensor{
+				"temperature": {Value: 20, Noise: config.SensorNoise{Model: config.NoiseGaussian, Amplitude: 1}},
+			},
+			LogFile: f.Name(),
+		})
+		assert.Nil(t, err)
+		assert.EqualValues(t, []string{"temperature"}, s.Names())
+
+		v, err := s.Read(time.Second, "temperature", "0x0001", origin)
+		assert.Nil(t, err)
+		_, err = s.Read(time.Second, "humidity", "0x0001", origin)
+		assert.NotNil(t, err)
+
+		readings := s.Readings()
+		assert.Len(t, readings, 1)
+		assert.EqualValues(t, 20, readings[0].Truth)
+		assert.EqualValues(t, v, readings[0].Value)
+
+		s.Close()
+
+		r, err := os.Open(f.Name())
+		assert.Nil(t, err)
+		defer r.Close()
+		records, err := csv.NewReader(r).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, records, 2)
+		assert.EqualValues(t, []string{"1s", "0x0001", "temperature"}, records[1][:3])
+	})
+}
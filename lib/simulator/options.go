@@ -1,9 +1,18 @@
 package sim
 
+import (
+	"time"
+
+	"github.com/ryankurte/yawns/lib/connector"
+)
+
 // Options defines the command line options available to ons instances
 type Options struct {
-	ConfigFile string `short:"c" long:"config" description:"Simulation configuration file" default:"yawns.yml"`
-	BindAddr   string `short:"a" long:"address" description:"Simulator Bind Address"`
+	ConfigFile string            `short:"c" long:"config" description:"Simulation configuration file" default:"yawns.yml"`
+	Variables  map[string]string `long:"var" description:"Set a configuration variable, overriding the configuration value (name:value)"`
+	BindAddr   string            `short:"a" long:"address" description:"Simulator Bind Address (tcp:// or ipc:// for ZMQ, stream+tcp://, unix://, ws:// or loopback://)"`
+
+	HeartbeatTimeout time.Duration `long:"heartbeat-timeout" description:"Disconnect nodes that send no messages or heartbeats within this duration (disabled if zero)"`
 
 	OutputDir  string `short:"o" long:"output" description:"Directory for output files"`
 	PCAPFile   string `short:"f" long:"pcap-file" description:"PCap Output File"`
@@ -19,12 +28,12 @@ type Options struct {
 func DefaultOptions() Options {
 	return Options{
 		ConfigFile: "./example.yml",
-		BindAddr:   "tcp://*:10109",
+		BindAddr:   connector.DefaultBindAddress,
 		PCAPFile:   "",
 		PCAPStream: "",
 		ReportFile: "",
 		LogDir:     "",
 		OutputDir:  "./out/",
-		ClientAddr: "tcp://localhost:10109",
+		ClientAddr: connector.DefaultClientAddress,
 	}
 }
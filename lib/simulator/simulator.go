@@ -6,17 +6,32 @@ import (
 
 	"github.com/ryankurte/yawns/lib/config"
 	"github.com/ryankurte/yawns/lib/connector"
+	"github.com/ryankurte/yawns/lib/console"
 	"github.com/ryankurte/yawns/lib/engine"
 	"github.com/ryankurte/yawns/lib/medium"
 	"github.com/ryankurte/yawns/lib/plugins"
+	"github.com/ryankurte/yawns/lib/report"
 	"github.com/ryankurte/yawns/lib/runner"
+	"github.com/ryankurte/yawns/lib/sensors"
 )
 
 // Simulator instance
 type Simulator struct {
-	engine *engine.Engine
-	runner *runner.Runner
-	medium *medium.Medium
+	engine   *engine.Engine
+	runner   *runner.Runner
+	medium   *medium.Medium
+	sensors  *sensors.Sensors
+	consoles *console.Consoles
+
+	state  *plugins.StateManager
+	script *plugins.ScriptPlugin
+
+	name       string
+	reportFile string
+	startTime  time.Time
+	duration   time.Duration
+	runErr     error
+	result     error
 }
 
 // NewSimulator creates a simulator instance
@@ -27,7 +42,7 @@ func NewSimulator(o *Options) (*Simulator, error) {
 	log.Printf("[DEBUG] Loading configuration file")
 
 	// Load configuration file
-	config, err := config.LoadConfigFile(o.ConfigFile)
+	config, err := config.LoadConfigFileWithVariables(o.ConfigFile, o.Variables)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +55,15 @@ func NewSimulator(o *Options) (*Simulator, error) {
 	log.Printf("[DEBUG] Creating connector layer with bind address: %s and client address: %s", o.BindAddr, o.ClientAddr)
 
 	// Load and bind connector
-	c := connector.NewZMQConnector(o.BindAddr)
-	e.BindConnectorChannels(c.OutputChan, c.InputChan)
+	c, err := connector.NewConnector(o.BindAddr)
+	if err != nil {
+		return nil, err
+	}
+	e.BindConnectorChannels(c.Output(), c.Input())
+
+	if o.HeartbeatTimeout > 0 {
+		c.SetHeartbeatTimeout(o.HeartbeatTimeout)
+	}
 
 	log.Printf("[DEBUG] Creating connector layer")
 
@@ -54,6 +76,7 @@ func NewSimulator(o *Options) (*Simulator, error) {
 	// Create and bind client runner
 	r := runner.NewRunner(config, config.Defaults.Exec, args)
 	e.BindRunnerChannel(r.OutputChan)
+	e.BindProcesses(r)
 
 	log.Printf("[DEBUG] Initialising plugins")
 
@@ -66,14 +89,63 @@ func NewSimulator(o *Options) (*Simulator, error) {
 	stateManager := plugins.NewStateManager(addresses, config.Plugins["state"])
 	e.BindPlugin(&stateManager)
 
+	var pcap *plugins.PCAPPlugin
 	if c, ok := config.Plugins["pcap"]; ok {
-		pcap, err := plugins.NewPCAPPlugin(config.Medium.Bands, time.Now(), c)
+		pcap, err = plugins.NewPCAPPlugin(config.Medium.Bands, time.Now(), c)
 		if err != nil {
 			return nil, err
 		}
 		e.BindPlugin(pcap)
 	}
 
+	if c, ok := config.Plugins["adversary"]; ok {
+		adversary, err := plugins.NewAdversaryPlugin(c)
+		if err != nil {
+			return nil, err
+		}
+		if pcap != nil {
+			adversary.BindRecorder(pcap)
+		}
+		e.BindPlugin(adversary)
+	}
+
+	var script *plugins.ScriptPlugin
+	if c, ok := config.Plugins["script"]; ok {
+		script, err = plugins.NewScriptPlugin(c)
+		if err != nil {
+			return nil, err
+		}
+		e.BindPlugin(script)
+	}
+
+	// Create virtual sensors
+	var s *sensors.Sensors
+	if len(config.Sensors.Fields) > 0 {
+		log.Printf("[DEBUG] Creating virtual sensors")
+
+		s, err = sensors.NewSensors(&config.Sensors)
+		if err != nil {
+			return nil, err
+		}
+		e.BindSensors(s)
+	}
+
+	// Create node consoles and pass console paths to clients
+	var cs *console.Consoles
+	if config.Consoles.Dir != "" {
+		log.Printf("[DEBUG] Creating node consoles in %s", config.Consoles.Dir)
+
+		cs, err = console.NewConsoles(&config.Consoles, addresses)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addresses {
+			path, _ := cs.Path(a)
+			r.SetArg(a, "console", path)
+		}
+		e.BindConsoles(cs)
+	}
+
 	log.Printf("[DEBUG] Launching clients")
 
 	// Launch clients via runner
@@ -84,13 +156,12 @@ func NewSimulator(o *Options) (*Simulator, error) {
 
 	log.Printf("[DEBUG] Creating simulation medium")
 
-	m, err := medium.NewMedium(&config.Medium, config.TickRate, &config.Nodes)
+	m, err := startMedium(config)
 	if err != nil {
 		return nil, err
 	}
 
 	e.BindMedium(m)
-	go m.Run()
 
 	log.Printf("[DEBUG] Configuring simulation engine")
 
@@ -102,7 +173,29 @@ func NewSimulator(o *Options) (*Simulator, error) {
 
 	log.Printf("[INFO] Setup complete")
 
-	return &Simulator{e, r, m}, nil
+	return &Simulator{
+		engine:     e,
+		runner:     r,
+		medium:     m,
+		sensors:    s,
+		consoles:   cs,
+		state:      &stateManager,
+		script:     script,
+		name:       config.Name,
+		reportFile: o.ReportFile,
+	}, nil
+}
+
+// startMedium creates the simulation medium and starts it, enabling interferers and preloading fadings
+func startMedium(c *config.Config) (*medium.Medium, error) {
+	m, err := medium.NewMedium(&c.Medium, c.TickRate, &c.Nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Start()
+
+	return m, nil
 }
 
 // Info displays simulation information
@@ -116,8 +209,11 @@ func (s *Simulator) Run() error {
 	log.Printf("[INFO] Launching Simulation Instance")
 
 	// Run engine
+	s.startTime = time.Now()
 	err := s.engine.Run()
+	s.duration = time.Now().Sub(s.startTime)
 	if err != nil {
+		s.runErr = err
 		return err
 	}
 
@@ -134,4 +230,61 @@ func (s *Simulator) Close() {
 	s.runner.Stop()
 
 	s.engine.Close()
+
+	if s.sensors != nil {
+		s.sensors.Close()
+	}
+
+	if s.consoles != nil {
+		s.consoles.Close()
+	}
+
+	// Generate report once plugins have completed
+	r := s.Report()
+	s.result = r.Err()
+	if s.result != nil {
+		log.Printf("[INFO] %s", s.result)
+	} else {
+		log.Printf("[INFO] Simulation passed (%d assertions)", r.Tests)
+	}
+
+	if s.reportFile != "" {
+		if err := r.WriteFiles(s.reportFile); err != nil {
+			log.Printf("[ERROR] Error writing report: %s", err)
+		}
+	}
+}
+
+// Report generates a report from the simulation assertion results
+func (s *Simulator) Report() *report.Report {
+	r := report.NewReport(s.name, s.startTime, s.duration)
+
+	for _, e := range s.state.Events() {
+		c := report.NewCase(string(e.Type), e.Address, e.Key, string(e.Operator), e.Expected, e.Time)
+		c.Actual, c.Passed, c.Error, c.Resolved = e.Actual, e.Result, e.Error, e.Resolved.Seconds()
+		r.Add(c)
+	}
+
+	if s.script != nil {
+		finished, err := s.script.Result()
+		c := report.NewCase("script", "", "", "", "", 0)
+		c.Passed, c.Resolved = err == nil, s.duration.Seconds()
+		if err != nil {
+			c.Error = err.Error()
+		} else if !finished {
+			c.Actual = "not finished"
+		}
+		r.Add(c)
+	}
+
+	if s.runErr != nil {
+		r.Fail(s.runErr)
+	}
+
+	return r
+}
+
+// Result returns the simulation result once closed, nil if all assertions passed
+func (s *Simulator) Result() error {
+	return s.result
 }
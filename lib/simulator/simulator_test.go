@@ -0,0 +1,76 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryankurte/yawns/lib/config"
+	"github.com/ryankurte/yawns/lib/messages"
+	"github.com/ryankurte/yawns/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getRSSI(t *testing.T, ch chan interface{}, address, band string, channel int32) float32 {
+	select {
+	case resp := <-ch:
+		r, ok := resp.(messages.RSSIResponse)
+		assert.True(t, ok, "expected RSSI response (received %T)", resp)
+		assert.EqualValues(t, address, r.Address)
+		assert.EqualValues(t, channel, r.Channel)
+		return r.RSSI
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for RSSI response on band %s channel %d", band, channel)
+	}
+	return 0
+}
+
+func TestSimulator(t *testing.T) {
+	bandName := "Sub1GHz"
+	c := config.Config{
+		TickRate: time.Millisecond,
+		Medium: config.Medium{
+			Bands: map[string]config.Band{
+				bandName: config.Band{
+					Frequency:          433e6,
+					Baud:               10e3,
+					LinkBudget:         90,
+					InterferenceBudget: 20,
+					NoiseFloor:         -80,
+				},
+			},
+			Interferers: map[string]config.Interferer{
+				"microwave": config.Interferer{
+					Band:     bandName,
+					Channels: []int32{2},
+					Location: types.Location{Lat: -36.801, Lng: 174.70},
+				},
+			},
+		},
+		Nodes: types.Nodes{
+			types.Node{Address: "0x0001", Location: types.Location{Lat: -36.80, Lng: 174.70}},
+		},
+	}
+
+	t.Run("Starting the medium enables interferers", func(t *testing.T) {
+		m, err := startMedium(&c)
+		assert.Nil(t, err)
+		defer m.Stop()
+
+		address := c.Nodes[0].Address
+		m.Send() <- messages.RSSIRequest{
+			BaseMessage: messages.BaseMessage{Address: address},
+			RFInfo:      messages.NewRFInfo(bandName, 1),
+		}
+		quiet := getRSSI(t, m.Receive(), address, bandName, 1)
+
+		m.Send() <- messages.RSSIRequest{
+			BaseMessage: messages.BaseMessage{Address: address},
+			RFInfo:      messages.NewRFInfo(bandName, 2),
+		}
+		noisy := getRSSI(t, m.Receive(), address, bandName, 2)
+
+		assert.InDelta(t, -80, quiet, 0.01)
+		assert.True(t, noisy > quiet+10, "interferer not active (RSSI %.2f, noise floor %.2f)", noisy, quiet)
+	})
+}
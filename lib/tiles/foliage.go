@@ -0,0 +1,45 @@
+package tiles
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DefaultFoliageColours approximate the wood and scrub land cover fills of the mapbox outdoors style
+var DefaultFoliageColours = []string{"#c5dfa9", "#b4d79b", "#d1e6be"}
+
+// DefaultFoliageTolerance is the default RGB distance within which pixels match a foliage colour
+const DefaultFoliageTolerance = 12
+
+// ExtractFoliage builds a foliage map from a styled map image
+// Pixels within the tolerance (RGB distance) of any of the foliage colours are blacked out, all others are white
+func ExtractFoliage(img image.Image, colours []color.RGBA, tolerance float64) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+
+			c := white
+			if a != 0 {
+				for _, f := range colours {
+					dr := float64(r>>8) - float64(f.R)
+					dg := float64(g>>8) - float64(f.G)
+					db := float64(b>>8) - float64(f.B)
+					if math.Sqrt(dr*dr+dg*dg+db*db) <= tolerance {
+						c = black
+						break
+					}
+				}
+			}
+
+			out.SetRGBA(x-bounds.Min.X, y-bounds.Min.Y, c)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,209 @@
+package tiles
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	// Image decoders for tile formats
+	_ "image/jpeg"
+	_ "image/png"
+
+	// SQLite driver for MBTiles
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewSource creates a tile source from a URI
+// MBTiles files are loaded from paths ending in .mbtiles (or with an mbtiles:// prefix), http:// and https:// URIs
+// are treated as URL templates, and other paths (or file:// URIs) as XYZ tile directories.
+// The TMS option flips the tile Y index for sources using the TMS scheme.
+func NewSource(uri string, tms bool) (Source, error) {
+	switch {
+	case strings.HasPrefix(uri, "mbtiles://"):
+		return NewMBTilesSource(strings.TrimPrefix(uri, "mbtiles://"))
+	case strings.HasSuffix(strings.ToLower(uri), ".mbtiles"):
+		return NewMBTilesSource(uri)
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return NewURLSource(uri, tms)
+	default:
+		return NewDirSource(strings.TrimPrefix(uri, "file://"), tms)
+	}
+}
+
+// decodeTile decodes a tile image
+func decodeTile(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// URLSource fetches tiles from a URL template
+// Templates are of the form https://tiles.example.com/{z}/{x}/{y}.png, with {-y} substituted by the TMS Y index
+type URLSource struct {
+	template string
+	tms      bool
+	client   *http.Client
+}
+
+// NewURLSource creates a new URL template tile source
+func NewURLSource(template string, tms bool) (*URLSource, error) {
+	for _, k := range []string{"{x}", "{z}"} {
+		if !strings.Contains(template, k) {
+			return nil, fmt.Errorf("URL template '%s' missing %s", template, k)
+		}
+	}
+	if !strings.Contains(template, "{y}") && !strings.Contains(template, "{-y}") {
+		return nil, fmt.Errorf("URL template '%s' missing {y} or {-y}", template)
+	}
+
+	return &URLSource{
+		template: template,
+		tms:      tms,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// URL builds the URL for a tile
+func (s *URLSource) URL(x, y, level uint64) string {
+	ty := y
+	if s.tms {
+		ty = FlipY(y, level)
+	}
+
+	r := strings.NewReplacer(
+		"{x}", strconv.FormatUint(x, 10),
+		"{y}", strconv.FormatUint(ty, 10),
+		"{-y}", strconv.FormatUint(FlipY(y, level), 10),
+		"{z}", strconv.FormatUint(level, 10),
+	)
+	return r.Replace(s.template)
+}
+
+// GetTile fetches a tile from the URL template
+func (s *URLSource) GetTile(x, y, level uint64) (image.Image, error) {
+	url := s.URL(x, y, level)
+
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTile(data)
+}
+
+// Close closes the URL source
+func (s *URLSource) Close() error {
+	return nil
+}
+
+// DirSource loads tiles from a directory in the z/x/y.{png,jpg} layout
+type DirSource struct {
+	path string
+	tms  bool
+}
+
+var dirSourceExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// NewDirSource creates a new tile directory source
+func NewDirSource(path string, tms bool) (*DirSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("tile source %s is not a directory", path)
+	}
+	return &DirSource{path: path, tms: tms}, nil
+}
+
+// GetTile loads a tile from the tile directory
+func (s *DirSource) GetTile(x, y, level uint64) (image.Image, error) {
+	if s.tms {
+		y = FlipY(y, level)
+	}
+
+	base := filepath.Join(s.path, strconv.FormatUint(level, 10), strconv.FormatUint(x, 10), strconv.FormatUint(y, 10))
+	for _, ext := range dirSourceExtensions {
+		data, err := ioutil.ReadFile(base + ext)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return decodeTile(data)
+	}
+
+	return nil, fmt.Errorf("no tile found at %s", base)
+}
+
+// Close closes the tile directory source
+func (s *DirSource) Close() error {
+	return nil
+}
+
+// MBTilesSource loads raster tiles from an MBTiles (SQLite) file
+// MBTiles files use the TMS scheme, Y indices are flipped on lookup
+type MBTilesSource struct {
+	db *sql.DB
+}
+
+// NewMBTilesSource opens an MBTiles file
+func NewMBTilesSource(file string) (*MBTilesSource, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", file))
+	if err != nil {
+		return nil, err
+	}
+
+	var format string
+	err = db.QueryRow("SELECT value FROM metadata WHERE name = 'format'").Scan(&format)
+	if err != nil && err != sql.ErrNoRows {
+		db.Close()
+		return nil, fmt.Errorf("error reading MBTiles metadata (%s)", err)
+	}
+	if format == "pbf" {
+		db.Close()
+		return nil, fmt.Errorf("vector MBTiles are not supported")
+	}
+
+	return &MBTilesSource{db: db}, nil
+}
+
+// GetTile loads a tile from the MBTiles file
+func (s *MBTilesSource) GetTile(x, y, level uint64) (image.Image, error) {
+	var data []byte
+	err := s.db.QueryRow("SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?",
+		level, x, FlipY(y, level)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no tile found at %d/%d/%d", level, x, y)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return decodeTile(data)
+}
+
+// Close closes the MBTiles file
+func (s *MBTilesSource) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,116 @@
+/**
+ * OpenNetworkSim Tiles package
+ * Fetches and stitches slippy map tiles from local and remote tile sources
+ *
+ * https://github.com/ryankurte/ons
+ * Copyright 2017 Ryan Kurte
+ */
+
+package tiles
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// Source interface is implemented by map tile sources
+type Source interface {
+	// GetTile fetches the tile image at the provided (XYZ scheme) tile location
+	GetTile(x, y, level uint64) (image.Image, error)
+	// Close closes the tile source
+	Close() error
+}
+
+// Tile is a stitched map image with the location of the top left tile
+type Tile struct {
+	image.Image
+	X, Y, Level uint64
+	// Size is the base tile size in pixels
+	Size uint64
+	// Count of stitched tiles in each axis
+	XCount, YCount uint64
+}
+
+// LocationToTileID fetches the XYZ tile containing a location at the provided level
+func LocationToTileID(l types.Location, level uint64) (uint64, uint64) {
+	n := math.Exp2(float64(level))
+	lat := l.Lat * math.Pi / 180
+
+	x := math.Floor((l.Lng + 180) / 360 * n)
+	y := math.Floor((1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n)
+
+	// Clamp to valid tiles
+	x = math.Max(0, math.Min(n-1, x))
+	y = math.Max(0, math.Min(n-1, y))
+
+	return uint64(x), uint64(y)
+}
+
+// FlipY converts a tile Y index between XYZ and TMS schemes
+func FlipY(y, level uint64) uint64 {
+	return (uint64(1) << level) - 1 - y
+}
+
+// ExpandBounds expands a bounding box by a margin in meters on each side
+func ExpandBounds(min, max types.Location, margin float64) (types.Location, types.Location) {
+	dLat := margin / types.EarthRadius * 180 / math.Pi
+	dLng := dLat / math.Cos((min.Lat+max.Lat)/2*math.Pi/180)
+
+	min.Lat, min.Lng = math.Max(min.Lat-dLat, -85), min.Lng-dLng
+	max.Lat, max.Lng = math.Min(max.Lat+dLat, 85), max.Lng+dLng
+
+	return min, max
+}
+
+// GetEnclosingTiles fetches the tiles enclosing a bounding box from a source and stitches them into a single image
+func GetEnclosingTiles(s Source, a, b types.Location, level uint64) (*Tile, error) {
+	xStart, yStart := LocationToTileID(a, level)
+	xEnd, yEnd := LocationToTileID(b, level)
+	if xStart > xEnd {
+		xStart, xEnd = xEnd, xStart
+	}
+	if yStart > yEnd {
+		yStart, yEnd = yEnd, yStart
+	}
+
+	xCount, yCount := xEnd-xStart+1, yEnd-yStart+1
+
+	var stitched *image.RGBA
+	size := 0
+
+	for y := uint64(0); y < yCount; y++ {
+		for x := uint64(0); x < xCount; x++ {
+			img, err := s.GetTile(xStart+x, yStart+y, level)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching tile %d/%d/%d (%s)", level, xStart+x, yStart+y, err)
+			}
+
+			bounds := img.Bounds()
+			if stitched == nil {
+				size = bounds.Dx()
+				stitched = image.NewRGBA(image.Rect(0, 0, size*int(xCount), size*int(yCount)))
+			}
+			if bounds.Dx() != size || bounds.Dy() != size {
+				return nil, fmt.Errorf("tile %d/%d/%d size %dx%d does not match base size %d",
+					level, xStart+x, yStart+y, bounds.Dx(), bounds.Dy(), size)
+			}
+
+			offset := image.Pt(int(x)*size, int(y)*size)
+			draw.Draw(stitched, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Src)
+		}
+	}
+
+	return &Tile{
+		Image:  stitched,
+		X:      xStart,
+		Y:      yStart,
+		Level:  level,
+		Size:   uint64(size),
+		XCount: xCount,
+		YCount: yCount,
+	}, nil
+}
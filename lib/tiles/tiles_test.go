@@ -0,0 +1,163 @@
+package tiles
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryankurte/yawns/lib/helpers"
+	"github.com/ryankurte/yawns/lib/types"
+)
+
+// testTile builds a solid tile image encoding its location in the pixel colour
+func testTile(x, y, size int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			img.Set(i, j, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	buf := bytes.Buffer{}
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func assertStitched(t *testing.T, tile *Tile, x, y uint64) {
+	assert.EqualValues(t, x, tile.X)
+	assert.EqualValues(t, y, tile.Y)
+	assert.EqualValues(t, 2, tile.XCount)
+	assert.EqualValues(t, 2, tile.YCount)
+	assert.EqualValues(t, 4, tile.Size)
+
+	for _, p := range []struct{ px, py, tx, ty int }{{0, 0, 0, 0}, {5, 0, 1, 0}, {0, 5, 0, 1}, {7, 7, 1, 1}} {
+		r, g, _, _ := tile.At(p.px, p.py).RGBA()
+		assert.EqualValues(t, int(x)+p.tx, r>>8)
+		assert.EqualValues(t, int(y)+p.ty, g>>8)
+	}
+}
+
+func TestTiles(t *testing.T) {
+	// Bounds spanning tiles 1-2 in X and Y at level 2
+	a := types.Location{Lat: 10, Lng: -10}
+	b := types.Location{Lat: -10, Lng: 10}
+
+	dir, err := ioutil.TempDir("", "yawns-tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("Converts locations to tile IDs", func(t *testing.T) {
+		x, y := LocationToTileID(types.Location{Lat: 10, Lng: -10}, 2)
+		assert.EqualValues(t, 1, x)
+		assert.EqualValues(t, 1, y)
+
+		x, y = LocationToTileID(types.Location{Lat: -36.8485, Lng: 174.7633}, 16)
+		assert.EqualValues(t, 64582, x)
+		assert.EqualValues(t, 39992, y)
+
+		assert.EqualValues(t, 2, FlipY(1, 2))
+	})
+
+	t.Run("Expands bounds by a margin", func(t *testing.T) {
+		min, max := ExpandBounds(types.Location{Lat: 0, Lng: 0}, types.Location{Lat: 1, Lng: 1}, 1000)
+		assert.InDelta(t, -0.009, min.Lat, 0.0001)
+		assert.InDelta(t, 1.009, max.Lat, 0.0001)
+		assert.True(t, min.Lng < min.Lat)
+	})
+
+	t.Run("Fetches tiles from URL templates", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var x, y, z int
+			if _, err := fmt.Sscanf(r.URL.Path, "/tiles/%d/%d/%d.png", &z, &x, &y); err != nil || z != 2 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(testTile(x, y, 4))
+		}))
+		defer server.Close()
+
+		s, err := NewSource(server.URL+"/tiles/{z}/{x}/{y}.png", false)
+		assert.Nil(t, err)
+
+		tile, err := GetEnclosingTiles(s, a, b, 2)
+		assert.Nil(t, err)
+		assertStitched(t, tile, 1, 1)
+
+		_, err = GetEnclosingTiles(s, a, b, 3)
+		assert.NotNil(t, err)
+
+		u, _ := NewURLSource("http://localhost/{z}/{x}/{-y}.png", false)
+		assert.Equal(t, "http://localhost/2/1/2.png", u.URL(1, 1, 2))
+
+		_, err = NewURLSource("http://localhost/{z}/{x}.png", false)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Loads tiles from XYZ directories", func(t *testing.T) {
+		tileDir := filepath.Join(dir, "xyz")
+		for x := 1; x <= 2; x++ {
+			for y := 1; y <= 2; y++ {
+				path := filepath.Join(tileDir, "2", fmt.Sprintf("%d", x))
+				assert.Nil(t, os.MkdirAll(path, 0755))
+				assert.Nil(t, ioutil.WriteFile(filepath.Join(path, fmt.Sprintf("%d.png", y)), testTile(x, y, 4), 0644))
+			}
+		}
+
+		s, err := NewSource(tileDir, false)
+		assert.Nil(t, err)
+
+		tile, err := GetEnclosingTiles(s, a, b, 2)
+		assert.Nil(t, err)
+		assertStitched(t, tile, 1, 1)
+	})
+
+	t.Run("Loads tiles from MBTiles files", func(t *testing.T) {
+		file := filepath.Join(dir, "test.mbtiles")
+		db, err := sql.Open("sqlite3", file)
+		assert.Nil(t, err)
+		_, err = db.Exec("CREATE TABLE metadata (name text, value text); " +
+			"CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob); " +
+			"INSERT INTO metadata VALUES ('format', 'png');")
+		assert.Nil(t, err)
+		for x := 1; x <= 2; x++ {
+			for y := 1; y <= 2; y++ {
+				_, err = db.Exec("INSERT INTO tiles VALUES (?, ?, ?, ?)", 2, x, FlipY(uint64(y), 2), testTile(x, y, 4))
+				assert.Nil(t, err)
+			}
+		}
+		db.Close()
+
+		s, err := NewSource(file, false)
+		assert.Nil(t, err)
+		defer s.Close()
+
+		tile, err := GetEnclosingTiles(s, a, b, 2)
+		assert.Nil(t, err)
+		assertStitched(t, tile, 1, 1)
+	})
+
+	t.Run("Extracts foliage from styled maps", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+		img.Set(0, 0, color.RGBA{0xc7, 0xe0, 0xa8, 0xff})
+		img.Set(1, 0, color.RGBA{0x80, 0x80, 0x80, 0xff})
+
+		wood, err := helpers.ParseColour("#c5dfa9")
+		assert.Nil(t, err)
+
+		out := ExtractFoliage(img, []color.RGBA{wood}, DefaultFoliageTolerance)
+		assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(0, 0))
+		assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(1, 0))
+	})
+}
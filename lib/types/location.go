@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"math"
 )
 
 // Location is a world location in floating point degrees with altitude in meters
@@ -14,3 +15,32 @@ type Location struct {
 func (l Location) String() string {
 	return fmt.Sprintf("%f,%f,%f", l.Lat, l.Lng, l.Alt)
 }
+
+// EarthRadius is the mean earth radius in meters, used for local distance approximations
+const EarthRadius = 6371e3
+
+// Offset calculates the approximate north and east offset in meters from the location to another nearby location
+func (l Location) Offset(to Location) (north, east float64) {
+	lat := (l.Lat + to.Lat) / 2 * math.Pi / 180
+	north = (to.Lat - l.Lat) * math.Pi / 180 * EarthRadius
+	east = (to.Lng - l.Lng) * math.Pi / 180 * EarthRadius * math.Cos(lat)
+	return north, east
+}
+
+// Distance calculates the approximate distance in meters between the location and another nearby location,
+// including any difference in altitude
+func (l Location) Distance(to Location) float64 {
+	north, east := l.Offset(to)
+	up := to.Alt - l.Alt
+	return math.Sqrt(north*north + east*east + up*up)
+}
+
+// Move offsets the location by north and east distances in meters
+func (l Location) Move(north, east float64) Location {
+	lat := l.Lat * math.Pi / 180
+	return Location{
+		Lat: l.Lat + north/EarthRadius*180/math.Pi,
+		Lng: l.Lng + east/(EarthRadius*math.Cos(lat))*180/math.Pi,
+		Alt: l.Alt,
+	}
+}
@@ -1,17 +1,92 @@
 package types
 
+// Radio is a radio transceiver on a node
+type Radio struct {
+	Band  string  // Band must match a named band in the medium config
+	ID    uint32  // ID identifies the radio, and must be unique for each band on a node
+	Gain  float64 // Gain is the receive and transmit antenna gain in dB
+	Power float64 // Power is the transmit power in dB relative to the band reference power
+}
+
 // Node is a simulated node
 type Node struct {
 	// Public (loadable) fields
 	Address    string            // Address is the node network address
+	Profile    string            // Profile is the name of a node profile the node inherits unset fields from
 	Location   Location          // Location is the physical location of the node
 	Gain       float64           // Gain is the receive and transmit gain modifier in dB (used for different antennas)
 	Executable string            // Executable is the command to be called by the runner
 	Command    string            // Command is the command to be passed to the executable by the runner (if provided)
 	Arguments  map[string]string // Arguments is a map of the arguments to be provided to the node instance by the runner
 	Exec       []string          // Commands to be executed within the node instance
+	Radios     []Radio           // Radios available on the node (defaults to one radio for each band where unset)
 
 	Sent, Received uint32 // Sent and Received packet count
+
+	// Whether the location and gain are set in the node configuration, as zero values may be set explicitly
+	hasLocation, hasGain bool
+}
+
+// UnmarshalYAML decodes a node, recording whether the location and gain are set
+func (n *Node) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type node Node
+	if err := unmarshal((*node)(n)); err != nil {
+		return err
+	}
+
+	fields := make(map[string]interface{})
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	_, n.hasLocation = fields["location"]
+	_, n.hasGain = fields["gain"]
+
+	return nil
+}
+
+// locationSet checks whether the node location is set in the configuration or is non-zero
+func (n *Node) locationSet() bool {
+	return n.hasLocation || n.Location != (Location{})
+}
+
+// gainSet checks whether the node gain is set in the configuration or is non-zero
+func (n *Node) gainSet() bool {
+	return n.hasGain || n.Gain != 0
+}
+
+// Inherit sets unset node fields from a parent node (ie. a profile or the node defaults)
+// Arguments are merged, with node arguments overriding parent arguments. The location and gain are inherited
+// only where set in the parent and not in the node, so explicitly zero values are kept, while other fields
+// are unset where empty
+func (n *Node) Inherit(parent *Node) {
+	if !n.locationSet() && parent.locationSet() {
+		n.Location, n.hasLocation = parent.Location, true
+	}
+	if !n.gainSet() && parent.gainSet() {
+		n.Gain, n.hasGain = parent.Gain, true
+	}
+	if n.Executable == "" {
+		n.Executable = parent.Executable
+	}
+	if n.Command == "" {
+		n.Command = parent.Command
+	}
+
+	args := make(map[string]string, len(parent.Arguments)+len(n.Arguments))
+	for k, a := range parent.Arguments {
+		args[k] = a
+	}
+	for k, a := range n.Arguments {
+		args[k] = a
+	}
+	n.Arguments = args
+
+	if len(n.Exec) == 0 {
+		n.Exec = append([]string{}, parent.Exec...)
+	}
+	if len(n.Radios) == 0 {
+		n.Radios = append([]Radio{}, parent.Radios...)
+	}
 }
 
 type Nodes []Node
@@ -1,8 +1,10 @@
 package types
 
 import (
-	"github.com/stretchr/testify/assert"
 	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNodeType(t *testing.T) {
@@ -42,4 +44,66 @@ func TestNodeType(t *testing.T) {
 		})
 
 	})
+
+	t.Run("Inherits unset fields", func(t *testing.T) {
+		parent := Node{
+			Location:   Location{Lat: 1, Lng: 2},
+			Gain:       3,
+			Executable: "./node",
+			Command:    "{{.address}}",
+			Arguments:  map[string]string{"a": "1", "b": "2"},
+			Exec:       []string{"ls"},
+			Radios:     []Radio{{Band: "Sub1GHz"}},
+		}
+
+		n := Node{Address: "0x0001", Command: "other", Arguments: map[string]string{"b": "3"}}
+		n.Inherit(&parent)
+
+		assert.Equal(t, "0x0001", n.Address)
+		assert.Equal(t, parent.Location, n.Location)
+		assert.Equal(t, 3.0, n.Gain)
+		assert.Equal(t, "./node", n.Executable)
+		assert.Equal(t, "other", n.Command)
+		assert.Equal(t, map[string]string{"a": "1", "b": "3"}, n.Arguments)
+		assert.Equal(t, []string{"ls"}, n.Exec)
+		assert.Equal(t, parent.Radios, n.Radios)
+
+		n.Arguments["a"] = "4"
+		n.Radios[0].Band = "2.4GHz"
+		assert.Equal(t, "1", parent.Arguments["a"], "Does not share parent maps")
+		assert.Equal(t, "Sub1GHz", parent.Radios[0].Band, "Does not share parent slices")
+
+		var empty Node
+		empty.Inherit(&Node{})
+		assert.NotNil(t, empty.Arguments)
+	})
+
+	t.Run("Keeps explicitly zero locations and gains", func(t *testing.T) {
+		parent := Node{Location: Location{Lat: 1, Lng: 2}, Gain: 3}
+
+		var n Node
+		assert.Nil(t, yaml.Unmarshal([]byte("{address: 0x0001, gain: 0, location: {lat: 0, lng: 0}}"), &n))
+		n.Inherit(&parent)
+		assert.Equal(t, "0x0001", n.Address)
+		assert.Equal(t, Location{}, n.Location)
+		assert.Equal(t, 0.0, n.Gain)
+	})
+}
+
+func TestLocation(t *testing.T) {
+	origin := Location{Lat: -36.8485, Lng: 174.7633, Alt: 10}
+
+	t.Run("Moves by offsets in meters", func(t *testing.T) {
+		l := origin.Move(300, -400)
+		north, east := origin.Offset(l)
+		assert.InDelta(t, 300, north, 0.01)
+		assert.InDelta(t, -400, east, 0.01)
+		assert.InDelta(t, 500, origin.Distance(l), 0.01)
+	})
+
+	t.Run("Includes altitude in distances", func(t *testing.T) {
+		l := origin.Move(30, 0)
+		l.Alt += 40
+		assert.InDelta(t, 50, origin.Distance(l), 0.01)
+	})
 }
@@ -1,5 +1,9 @@
 package types
 
+import (
+	"strings"
+)
+
 // Frequency type for parsing/rendering
 type Frequency float64
 
@@ -59,6 +63,17 @@ func (am AttenuationMap) Reduce() Attenuation {
 	return sum
 }
 
+// Sum calculates the sum of the attenuation values for a key and any "key/component" entries
+func (am AttenuationMap) Sum(key string) Attenuation {
+	sum := Attenuation(0)
+	for k, v := range am {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			sum += v
+		}
+	}
+	return sum
+}
+
 // Baud type for parsing/rendering
 type Baud float64
 